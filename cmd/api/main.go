@@ -2,80 +2,661 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
+	"challenge-backend-arancia/internal/application/feeds"
+	"challenge-backend-arancia/internal/application/tenants"
 	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/application/webhooks"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/caldav"
 	"challenge-backend-arancia/internal/config"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/events/natspub"
+	"challenge-backend-arancia/internal/graphapi"
+	"challenge-backend-arancia/internal/grpcapi"
+	"challenge-backend-arancia/internal/health"
 	"challenge-backend-arancia/internal/httpapi"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/metrics"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/ratelimit"
 	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tlsconfig"
+	"challenge-backend-arancia/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	cfg := config.FromEnv()
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// The logger is built from the configuration, so its errors are
+		// logged with the default handler options.
+		slog.New(slog.NewJSONHandler(os.Stdout, nil)).Error("fatal", slog.String("error", err.Error()))
+		os.Exit(2)
+	}
 
 	gin.SetMode(cfg.GinMode)
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: parseLogLevel(cfg.LogLevel),
-	}))
+	// The level follows LOG_LEVEL reloads.
+	level := new(slog.LevelVar)
+	level.Set(parseLogLevel(cfg.LogLevel))
+	logger := slog.New(tracing.LogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: level,
+	})))
+	// Code without a request-scoped logger (logging.FromContext) logs here too.
+	slog.SetDefault(logger)
+
+	if err := run(cfg, logger, level); err != nil {
+		logger.Error("fatal", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+// run starts the servers and blocks until a signal shuts them down, which
+// returns nil, or one of them fails. Resources are released before it returns.
+func run(cfg config.Config, logger *slog.Logger, level *slog.LevelVar) error {
+	store := config.NewStore(cfg)
+	store.Subscribe(func(c config.Config) { level.Set(parseLogLevel(c.LogLevel)) })
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.TracingSampleRatio, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	// Registered first so spans of the shutdown itself are flushed too.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("tracing_shutdown_failed", slog.String("error", err.Error()))
+		}
+	}()
 
 	db, err := boltdb.Open(cfg.DBPath)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("open database: %w", err)
 	}
 	defer func() { _ = db.Close() }()
 
 	repo, err := boltdb.NewTodoRepository(db)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("todo repository: %w", err)
+	}
+	webhookRepo, err := boltdb.NewWebhookRepository(db)
+	if err != nil {
+		return fmt.Errorf("webhook repository: %w", err)
+	}
+	deliveryRepo, err := boltdb.NewDeliveryRepository(db)
+	if err != nil {
+		return fmt.Errorf("delivery repository: %w", err)
+	}
+	webhookSvc, err := webhooks.NewService(webhookRepo, deliveryRepo, todos.UUIDGenerator{})
+	if err != nil {
+		return fmt.Errorf("webhook service: %w", err)
+	}
+	policy := webhooks.DefaultRetryPolicy()
+	policy.MaxAttempts = cfg.WebhookMaxAttempts
+	dispatcher, err := webhooks.NewDispatcher(webhookRepo, deliveryRepo,
+		webhooks.NewHTTPClient(cfg.WebhookTimeout, cfg.WebhookAllowPrivate), policy, logger)
+	if err != nil {
+		return fmt.Errorf("webhook dispatcher: %w", err)
+	}
+
+	bus := events.NewBus()
+	sinks, closeSinks, err := eventSinks(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("event sinks: %w", err)
+	}
+	defer closeSinks()
+	outbox, err := boltdb.NewOutboxRepository(db)
+	if err != nil {
+		return fmt.Errorf("outbox repository: %w", err)
+	}
+	relay, err := events.NewRelay(outbox, append([]events.Sink{
+		{Name: "streams", Publisher: bus},
+		{Name: "webhooks", Publisher: webhookSvc},
+	}, sinks...), cfg.OutboxMaxAttempts, logger)
+	if err != nil {
+		return fmt.Errorf("outbox relay: %w", err)
+	}
+
+	var (
+		m        *metrics.Metrics
+		todoRepo ports.TodoRepository = repo
+	)
+	if cfg.MetricsAddr != "" {
+		if m, err = metrics.New(db); err != nil {
+			return fmt.Errorf("metrics: %w", err)
+		}
+		todoRepo = m.InstrumentRepository(repo)
+	}
+
+	svc, err := todos.NewService(todoRepo, todos.UUIDGenerator{},
+		todos.WithQuota(todos.Quota{MaxTodos: cfg.QuotaMaxTodos, MaxAnonymousTodos: cfg.QuotaMaxAnonymousTodos}),
+	)
+	if err != nil {
+		return fmt.Errorf("todo service: %w", err)
+	}
+	tenantRepo, err := boltdb.NewTenantRepository(db)
+	if err != nil {
+		return fmt.Errorf("tenant repository: %w", err)
 	}
-	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	tenantSvc, err := tenants.NewService(tenantRepo, repo)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("tenant service: %w", err)
 	}
 
+	var verifier *auth.Verifier
+	if cfg.AuthTokenSecret != "" {
+		verifier, err = auth.NewVerifier([]byte(cfg.AuthTokenSecret))
+		if err != nil {
+			return fmt.Errorf("token verifier: %w", err)
+		}
+	}
+	uiKey := []byte(cfg.UISessionKey)
+	if len(uiKey) == 0 {
+		uiKey = make([]byte, 32)
+		if _, err := rand.Read(uiKey); err != nil {
+			return fmt.Errorf("ui session key: %w", err)
+		}
+		logger.Info("ui_session_key_generated")
+	}
+	resolvers, grpcResolvers, err := tenantResolvers(cfg)
+	if err != nil {
+		return err
+	}
+
+	// The limiter is installed even when RATE_LIMIT_RPS is zero, so a reload
+	// can turn it on.
+	limiter := ratelimit.New(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	store.Subscribe(func(c config.Config) { limiter.SetLimit(c.RateLimitRPS, c.RateLimitBurst) })
+	limitKey := httpapi.RateLimitKey(cfg.RateLimitKey)
+	cors := httpapi.NewCORS(cfg.CORSAllowedOrigins)
+	store.Subscribe(func(c config.Config) { cors.SetOrigins(c.CORSAllowedOrigins) })
+	features := httpapi.NewFeatures(cfg.DisabledFeatures)
+	store.Subscribe(func(c config.Config) { features.SetDisabled(c.DisabledFeatures) })
+
+	graph, err := graphapi.NewHandler(graphapi.Options{TodoService: svc, Events: bus, TokenVerifier: verifier})
+	if err != nil {
+		return fmt.Errorf("graphql handler: %w", err)
+	}
+	dav, err := caldav.NewHandler(caldav.Options{TodoService: svc})
+	if err != nil {
+		return fmt.Errorf("caldav handler: %w", err)
+	}
+	feedTokenRepo, err := boltdb.NewFeedTokenRepository(db)
+	if err != nil {
+		return fmt.Errorf("feed token repository: %w", err)
+	}
+	feedSvc, err := feeds.NewService(feedTokenRepo)
+	if err != nil {
+		return fmt.Errorf("feed service: %w", err)
+	}
+
+	// The listeners serve TLS, with HTTP/2, when a certificate is configured.
+	var (
+		certs     *tlsconfig.Reloader
+		tlsConfig *tls.Config
+	)
+	if cfg.TLSCertFile != "" {
+		certs, err = tlsconfig.NewReloader(tlsconfig.Options{
+			CertFile:     cfg.TLSCertFile,
+			KeyFile:      cfg.TLSKeyFile,
+			ClientCAFile: cfg.TLSClientCAFile,
+			ClientAuth:   tlsconfig.ClientAuth(cfg.TLSClientAuth),
+		})
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		tlsConfig = certs.Config()
+	}
+
+	var compaction health.Heartbeat
+	checks := healthChecks(cfg, db, dispatcher, relay, &compaction)
+	sampler := &logging.Sampler{Rate: cfg.LogSampleRate}
+	store.Subscribe(func(c config.Config) { sampler.SetRate(c.LogSampleRate) })
 	server := &http.Server{
 		Addr: fmt.Sprintf(":%s", cfg.Port),
 		Handler: httpapi.NewRouter(httpapi.RouterOptions{
-			TodoService: svc,
-			Ready: func(ctx context.Context) error {
-				_, err := repo.List(ctx)
-				return err
-			},
-			Logger: logger,
+			TodoService:        svc,
+			Health:             checks,
+			Logger:             logger,
+			LogSampler:         sampler,
+			Metrics:            m,
+			TenantResolvers:    resolvers,
+			RequireTenant:      cfg.TenantRequired,
+			TokenVerifier:      verifier,
+			RateLimiter:        limiter,
+			RateLimitKey:       limitKey,
+			RateLimitAPIKeys:   cfg.RateLimitAPIKeys,
+			TrustedProxies:     cfg.TrustedProxies,
+			CORS:               cors,
+			Features:           features,
+			LegacyDeprecatedAt: cfg.LegacyDeprecatedAt,
+			LegacySunset:       cfg.LegacySunset,
+			Events:             bus,
+			GraphQL:            graph,
+			CalDAV:             dav,
+			FeedService:        feedSvc,
+			WebhookService:     webhookSvc,
+			TenantService:      tenantSvc,
+			AdminToken:         cfg.AdminToken,
+			Settings:           store.Settings,
+			UISessionKey:       uiKey,
+			UIInsecureCookies:  !cfg.UICookieSecure,
 		}),
+		TLSConfig:         tlsConfig,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	// The admin listener stays nil when METRICS_ADDR is empty.
+	var adminServer *http.Server
+	if m != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		adminServer = &http.Server{
+			Addr:              cfg.MetricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+	}
+	grpcServer, err := grpcapi.NewServer(grpcapi.Options{
+		TodoService:      svc,
+		Logger:           logger,
+		LogSampler:       sampler,
+		TenantResolvers:  grpcResolvers,
+		RequireTenant:    cfg.TenantRequired,
+		TokenVerifier:    verifier,
+		TLSConfig:        tlsConfig,
+		Events:           bus,
+		RateLimiter:      limiter,
+		RateLimitKey:     cfg.RateLimitKey,
+		RateLimitAPIKeys: cfg.RateLimitAPIKeys,
+	})
+	if err != nil {
+		return fmt.Errorf("grpc server: %w", err)
+	}
+	grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("grpc listener: %w", err)
+	}
+	// Background workers are stopped before the deferred db.Close.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer func() {
+		stopWorkers()
+		workers.Wait()
+	}()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(workerCtx, time.Second)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		compactChanges(workerCtx, repo, cfg.ChangesRetention, &compaction, logger)
+	}()
+
+	if certs != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			reloadCertificates(workerCtx, certs, logger)
+		}()
+	}
 
-	errCh := make(chan error, 1)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	workers.Add(1)
 	go func() {
+		defer workers.Done()
+		reloadConfig(workerCtx, store, hup, logger)
+	}()
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx, cfg.OutboxPollInterval)
+	}()
+	// End change streams first so Shutdown is not held up by long-lived
+	// SSE/WebSocket connections and WatchTodos calls. The relay is stopped before the bus closes;
+	// events it has not relayed yet stay in the outbox for the next start.
+	stopEvents := sync.OnceFunc(func() {
+		stopRelay()
+		<-relayDone
+		bus.Close()
+	})
+	server.RegisterOnShutdown(stopEvents)
+	defer stopEvents()
+
+	errCh := make(chan error, 3)
+	go func() {
+		if tlsConfig != nil {
+			// The certificate comes from tlsConfig.
+			errCh <- server.ListenAndServeTLS("", "")
+			return
+		}
 		errCh <- server.ListenAndServe()
 	}()
+	if adminServer != nil {
+		go func() {
+			errCh <- adminServer.ListenAndServe()
+		}()
+	}
+	go func() {
+		errCh <- grpcServer.Serve(grpcListener)
+	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	checks.MarkStarted()
 
 	select {
 	case <-quit:
+		// Fail readiness first and keep serving while load balancers take
+		// the pod out of rotation; a second signal skips the wait.
+		checks.Drain()
+		logger.Info("draining", slog.Duration("delay", cfg.ShutdownDrainDelay))
+		select {
+		case <-time.After(cfg.ShutdownDrainDelay):
+		case <-quit:
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		_ = server.Shutdown(ctx)
+		shutdown(ctx, server, adminServer, grpcServer)
 	case err := <-errCh:
 		if !errors.Is(err, http.ErrServerClosed) {
-			panic(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// shutdown gracefully stops the servers, giving in-flight requests until ctx
+// is done before the gRPC server drops its remaining calls. adminServer may
+// be nil.
+func shutdown(ctx context.Context, httpServer, adminServer *http.Server, grpcServer *grpc.Server) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = httpServer.Shutdown(ctx)
+		if adminServer != nil {
+			// Metrics stay scrapeable while the API drains.
+			_ = adminServer.Shutdown(ctx)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			grpcServer.Stop()
+		}
+	}()
+	wg.Wait()
+}
+
+// configWatchInterval is how often the config file and the TLS certificate
+// files are checked for changes.
+const configWatchInterval = 5 * time.Second
+
+// reloadConfig reloads the configuration on SIGHUP and whenever its file
+// changes, until ctx is done. Reloadable settings are applied to store and
+// logged; the others are logged as waiting for a restart. An invalid
+// configuration is logged and leaves the current one in place.
+func reloadConfig(ctx context.Context, store *config.Store, hup <-chan os.Signal, logger *slog.Logger) {
+	fileChanged := make(chan struct{}, 1)
+	if path := store.Current().File(); path != "" {
+		go config.Watch(ctx, path, configWatchInterval, func() {
+			select {
+			case fileChanged <- struct{}{}:
+			default:
+			}
+		})
+	}
+	for {
+		var trigger string
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			trigger = "sighup"
+		case <-fileChanged:
+			trigger = "file"
+		}
+
+		changes, err := reload(store)
+		if err != nil {
+			logger.Error("config_reload_failed",
+				slog.String("trigger", trigger),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		logConfigChanges(logger, trigger, changes)
+	}
+}
+
+func reload(store *config.Store) ([]config.Change, error) {
+	next, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	return store.Apply(next)
+}
+
+// reloadCertificates reads the TLS certificate, key and client CAs again
+// whenever one of their files changes, until ctx is done. Kubernetes updates
+// a mounted Secret atomically, but the files may still be seen out of step;
+// the failed reload that causes is retried on the next change.
+func reloadCertificates(ctx context.Context, certs *tlsconfig.Reloader, logger *slog.Logger) {
+	changed := make(chan struct{}, 1)
+	for _, path := range certs.Files() {
+		go config.Watch(ctx, path, configWatchInterval, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
 		}
+		if err := certs.Reload(); err != nil {
+			logger.Error("tls_reload_failed", slog.String("error", err.Error()))
+			continue
+		}
+		leaf := certs.Leaf()
+		logger.Info("tls_reloaded",
+			slog.String("subject", leaf.Subject.String()),
+			slog.Time("not_after", leaf.NotAfter),
+		)
 	}
 }
 
+// logConfigChanges logs the settings a reload applied and those that need
+// a restart, each with its old and new value.
+func logConfigChanges(logger *slog.Logger, trigger string, changes []config.Change) {
+	var applied, restart []any
+	for _, c := range changes {
+		attr := slog.Group(c.Name, slog.Any("old", c.Old), slog.Any("new", c.New))
+		if c.Reloadable {
+			applied = append(applied, attr)
+		} else {
+			restart = append(restart, attr)
+		}
+	}
+	logger.Info("config_reloaded",
+		slog.String("trigger", trigger),
+		slog.Group("changed", applied...),
+	)
+	if len(restart) > 0 {
+		logger.Warn("config_restart_required",
+			slog.String("trigger", trigger),
+			slog.Group("settings", restart...),
+		)
+	}
+}
+
+// compactChanges trims the change feed to retention once an hour until ctx
+// is done, recording each pass on pass.
+func compactChanges(ctx context.Context, repo *boltdb.TodoRepository, retention time.Duration, pass *health.Heartbeat, logger *slog.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := repo.CompactChanges(ctx, time.Now().Add(-retention))
+		if ctx.Err() == nil {
+			pass.Beat(err)
+		}
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("changes_compaction_failed", slog.String("error", err.Error()))
+		case n > 0:
+			logger.Info("changes_compacted", slog.Int("entries", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// healthChecks registers the checks behind /readyz and /startupz. Only the
+// database is required: a filling disk or a failing worker is reported by
+// /readyz?verbose without taking the pod out of rotation, as serving reads
+// is still better than serving nothing.
+func healthChecks(cfg config.Config, db *bolt.DB, dispatcher *webhooks.Dispatcher, relay *events.Relay, compaction *health.Heartbeat) *health.Registry {
+	reg := health.NewRegistry(cfg.HealthCacheTTL, time.Second)
+	reg.Register(health.Check{
+		Name: "bolt",
+		Run:  func(ctx context.Context) error { return boltdb.Ping(ctx, db) },
+	})
+	reg.Register(health.Check{
+		Name:     "disk",
+		Run:      health.DiskSpace(filepath.Dir(cfg.DBPath), uint64(cfg.HealthMinFreeMB)<<20),
+		Optional: true,
+	})
+	reg.Register(health.Check{
+		Name:     "event_relay",
+		Run:      health.Fresh(relay.LastPass, time.Minute),
+		Optional: true,
+	})
+	// A pass waits for up to a batch of slow webhook endpoints.
+	reg.Register(health.Check{
+		Name:     "webhook_dispatcher",
+		Run:      health.Fresh(dispatcher.LastPass, 5*time.Minute),
+		Optional: true,
+	})
+	reg.Register(health.Check{
+		Name:     "changes_compaction",
+		Run:      health.Fresh(compaction.Last, 2*time.Hour),
+		Optional: true,
+	})
+	return reg
+}
+
+// eventSinks connects the optional event destinations named in EVENT_SINKS.
+// The returned func releases their connections.
+func eventSinks(cfg config.Config, logger *slog.Logger) ([]events.Sink, func(), error) {
+	var (
+		out     []events.Sink
+		closers []func()
+	)
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+	for _, name := range cfg.EventSinks {
+		switch name {
+		case "log":
+			out = append(out, events.Sink{Name: name, Publisher: events.NewLogPublisher(logger)})
+		case "nats":
+			conn, err := nats.Connect(cfg.NATSURL, nats.Name("todo-api"), nats.MaxReconnects(-1))
+			if err != nil {
+				closeAll()
+				return nil, nil, fmt.Errorf("connect to NATS: %w", err)
+			}
+			closers = append(closers, conn.Close)
+			pub, err := natspub.New(conn, cfg.NATSSubjectPrefix)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			out = append(out, events.Sink{Name: name, Publisher: pub})
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unknown event sink %q", name)
+		}
+	}
+	return out, closeAll, nil
+}
+
+// tenantResolvers builds the tenant resolvers of the HTTP and gRPC APIs from
+// TENANT_SOURCES. gRPC reads the tenant header from metadata and the
+// subdomain from :authority.
+func tenantResolvers(cfg config.Config) ([]httpapi.TenantResolver, []grpcapi.TenantResolver, error) {
+	var (
+		out     []httpapi.TenantResolver
+		grpcOut []grpcapi.TenantResolver
+	)
+	for _, src := range cfg.TenantSources {
+		switch src {
+		case "header":
+			out = append(out, httpapi.HeaderTenantResolver(cfg.TenantHeader))
+			grpcOut = append(grpcOut, grpcapi.MetadataTenantResolver(cfg.TenantHeader))
+		case "subdomain":
+			if cfg.TenantBaseDomain == "" {
+				return nil, nil, errors.New("TENANT_SOURCES=subdomain requires TENANT_BASE_DOMAIN")
+			}
+			out = append(out, httpapi.SubdomainTenantResolver(cfg.TenantBaseDomain))
+			grpcOut = append(grpcOut, grpcapi.AuthorityTenantResolver(cfg.TenantBaseDomain))
+		case "token":
+			if cfg.AuthTokenSecret == "" && cfg.TLSClientCAFile == "" {
+				return nil, nil, errors.New("TENANT_SOURCES=token requires AUTH_TOKEN_SECRET or TLS_CLIENT_CA_FILE")
+			}
+			out = append(out, httpapi.ClaimTenantResolver())
+			grpcOut = append(grpcOut, grpcapi.ClaimTenantResolver())
+		default:
+			return nil, nil, fmt.Errorf("unknown tenant source %q", src)
+		}
+	}
+	return out, grpcOut, nil
+}
+
+// parseLogLevel maps a LOG_LEVEL, which config.Load validated.
 func parseLogLevel(v string) slog.Level {
 	switch v {
 	case "debug":
@@ -84,11 +665,7 @@ func parseLogLevel(v string) slog.Level {
 		return slog.LevelWarn
 	case "error":
 		return slog.LevelError
-	case "info", "":
-		return slog.LevelInfo
 	default:
-		log.Printf("unknown LOG_LEVEL=%q, defaulting to info", v)
 		return slog.LevelInfo
 	}
 }
-
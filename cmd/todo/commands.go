@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"challenge-backend-arancia/pkg/todoclient"
+)
+
+// shortIDLen is the length of the IDs printed in tables; any unique prefix
+// is accepted where an ID is expected.
+const shortIDLen = 8
+
+// importBatch is the number of todos imported per sync, the most the
+// server accepts.
+const importBatch = 500
+
+var addCommand = command{
+	name:  "add",
+	usage: "[-o table|json] <title>...",
+	help:  "Add an open todo; the arguments are joined into its title.",
+	setup: func(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+		format := formatFlag(fs)
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) == 0 {
+				return usagef("a title is required")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			td, err := api.CreateTodo(ctx, strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			return c.print(*format, []todoclient.Todo{td})
+		}
+	},
+}
+
+var lsCommand = command{
+	name:  "ls",
+	usage: "[-open | -done] [-q text] [-o table|json]",
+	help:  "List todos, optionally filtered by state or title.",
+	setup: func(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+		open := fs.Bool("open", false, "only list open todos")
+		done := fs.Bool("done", false, "only list completed todos")
+		query := fs.String("q", "", "only list todos whose title contains `text`, ignoring case")
+		format := formatFlag(fs)
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) > 0 {
+				return usagef("unexpected arguments %q", args)
+			}
+			if *open && *done {
+				return usagef("-open and -done are exclusive")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			list, err := api.ListTodos(ctx)
+			if err != nil {
+				return err
+			}
+			q := strings.ToLower(*query)
+			kept := list[:0]
+			for _, td := range list {
+				switch {
+				case *open && td.Completed, *done && !td.Completed:
+				case q != "" && !strings.Contains(strings.ToLower(td.Title), q):
+				default:
+					kept = append(kept, td)
+				}
+			}
+			return c.print(*format, kept)
+		}
+	},
+}
+
+var doneCommand = command{
+	name:  "done",
+	usage: "<id>...",
+	help:  "Mark todos as completed.",
+	ids:   true,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return setCompleted(true)
+	},
+}
+
+var undoCommand = command{
+	name:  "undo",
+	usage: "<id>...",
+	help:  "Reopen completed todos.",
+	ids:   true,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return setCompleted(false)
+	},
+}
+
+func setCompleted(completed bool) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) == 0 {
+			return usagef("an id is required")
+		}
+		api, err := c.client()
+		if err != nil {
+			return err
+		}
+		list, err := api.ListTodos(ctx)
+		if err != nil {
+			return err
+		}
+		for _, arg := range args {
+			td, err := findTodo(list, arg)
+			if err != nil {
+				return err
+			}
+			if td, err = api.UpdateTodo(ctx, td.ID, td.Title, completed); err != nil {
+				return err
+			}
+			fmt.Fprintln(c.stdout, row(td))
+		}
+		return nil
+	}
+}
+
+var editCommand = command{
+	name:  "edit",
+	usage: "<id> <title>...",
+	help:  "Change the title of a todo.",
+	ids:   true,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) < 2 {
+				return usagef("an id and a title are required")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			list, err := api.ListTodos(ctx)
+			if err != nil {
+				return err
+			}
+			td, err := findTodo(list, args[0])
+			if err != nil {
+				return err
+			}
+			if td, err = api.UpdateTodo(ctx, td.ID, strings.Join(args[1:], " "), td.Completed); err != nil {
+				return err
+			}
+			fmt.Fprintln(c.stdout, row(td))
+			return nil
+		}
+	},
+}
+
+var rmCommand = command{
+	name:  "rm",
+	usage: "<id>...",
+	help:  "Delete todos.",
+	ids:   true,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) == 0 {
+				return usagef("an id is required")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			list, err := api.ListTodos(ctx)
+			if err != nil {
+				return err
+			}
+			for _, arg := range args {
+				td, err := findTodo(list, arg)
+				if err != nil {
+					return err
+				}
+				// A retried delete may find the todo already gone.
+				if err := api.DeleteTodo(ctx, td.ID); err != nil && !errors.Is(err, todoclient.ErrNotFound) {
+					return err
+				}
+				fmt.Fprintf(c.stdout, "deleted %s\n", row(td))
+			}
+			return nil
+		}
+	},
+}
+
+var exportCommand = command{
+	name:  "export",
+	usage: "[-f file]",
+	help:  "Write every todo as a JSON array, which import reads back.",
+	setup: func(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+		file := fs.String("f", "", "write to `file` instead of stdout")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) > 0 {
+				return usagef("unexpected arguments %q", args)
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			list, err := api.ListTodos(ctx)
+			if err != nil {
+				return err
+			}
+			if *file == "" {
+				return writeJSON(c.stdout, list)
+			}
+			f, err := os.Create(*file)
+			if err != nil {
+				return err
+			}
+			if err := writeJSON(f, list); err != nil {
+				_ = f.Close()
+				return err
+			}
+			return f.Close()
+		}
+	},
+}
+
+var importCommand = command{
+	name:  "import",
+	usage: "[-new-ids] [file | -]",
+	help: `Create the todos of a JSON array written by export, read from file or stdin.
+
+Todos keep their IDs, so importing a file twice leaves a single copy; one
+that was changed on the server since is reported as a conflict and left as
+is. With -new-ids every todo is created anew. Subtasks are then moved under
+their parents, or the copies of their parents.`,
+	setup: func(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+		newIDs := fs.Bool("new-ids", false, "create copies with new IDs")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) > 1 {
+				return usagef("at most one file is allowed")
+			}
+			var r io.Reader = c.stdin
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			var list []todoclient.Todo
+			if err := json.NewDecoder(r).Decode(&list); err != nil {
+				return fmt.Errorf("read todos: %w", err)
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			return c.importTodos(ctx, api, list, *newIDs)
+		}
+	},
+}
+
+// importTodos creates list in batches of sync creations, which apply again
+// as no-ops, then moves the subtasks under their parents, and reports the
+// todos that were not imported or attached.
+func (c *cli) importTodos(ctx context.Context, api *todoclient.Client, list []todoclient.Todo, newIDs bool) error {
+	counts := map[todoclient.MutationStatus]int{}
+	// ids maps the IDs in list to those the todos were imported as, and
+	// parents the latter to the parent each todo has on the server.
+	ids := make(map[string]string, len(list))
+	parents := make(map[string]string, len(list))
+	for start := 0; start < len(list); start += importBatch {
+		batch := list[start:min(start+importBatch, len(list))]
+		muts := make([]todoclient.Mutation, 0, len(batch))
+		for _, td := range batch {
+			m := todoclient.Mutation{Op: todoclient.OpCreate, ID: td.ID, Title: td.Title, Completed: td.Completed}
+			if newIDs {
+				m.ID = ""
+			}
+			muts = append(muts, m)
+		}
+		res, err := api.Sync(ctx, "", muts)
+		if err != nil {
+			return err
+		}
+		for i, r := range res.Results {
+			counts[r.Status]++
+			switch r.Status {
+			case todoclient.MutationApplied, todoclient.MutationMerged:
+				if r.Todo != nil && batch[i].ID != "" {
+					ids[batch[i].ID] = r.Todo.ID
+					parents[r.Todo.ID] = r.Todo.ParentID
+				}
+			case todoclient.MutationConflict:
+				fmt.Fprintf(c.stderr, "conflict: %s was changed on the server (%s)\n", r.ID, strings.Join(r.Conflict.Fields, ", "))
+			case todoclient.MutationRejected:
+				msgs := make([]string, 0, len(r.Errors))
+				for _, fe := range r.Errors {
+					msgs = append(msgs, fe.Detail)
+				}
+				fmt.Fprintf(c.stderr, "rejected: %q: %s\n", batch[i].Title, strings.Join(msgs, "; "))
+			}
+		}
+	}
+
+	// Sync creates top-level todos, so subtasks are attached once all of
+	// their parents exist.
+	detached := 0
+	for _, td := range list {
+		id, ok := ids[td.ID]
+		if !ok || td.ParentID == "" {
+			continue
+		}
+		parentID, ok := ids[td.ParentID]
+		if !ok && !newIDs {
+			// The parent was not imported but may be on the server already.
+			parentID, ok = td.ParentID, true
+		}
+		switch {
+		case !ok:
+			fmt.Fprintf(c.stderr, "not attached: %q: its parent was not imported\n", td.Title)
+			detached++
+		case parents[id] != parentID:
+			if _, err := api.MoveTodo(ctx, id, parentID); err != nil {
+				fmt.Fprintf(c.stderr, "not attached: %q: %v\n", td.Title, err)
+				detached++
+			}
+		}
+	}
+
+	imported := counts[todoclient.MutationApplied] + counts[todoclient.MutationMerged]
+	fmt.Fprintf(c.stdout, "imported %d of %d todos\n", imported, len(list))
+	if imported < len(list) {
+		return fmt.Errorf("%d todos were not imported", len(list)-imported)
+	}
+	if detached > 0 {
+		return fmt.Errorf("%d subtasks were not attached to their parents", detached)
+	}
+	return nil
+}
+
+var idsCommand = command{
+	name:   "__ids",
+	help:   "Print the ID prefixes and titles of the todos, for shell completion.",
+	hidden: true,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return func(ctx context.Context, c *cli, _ []string) error {
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			list, err := api.ListTodos(ctx)
+			if err != nil {
+				return err
+			}
+			for _, td := range list {
+				fmt.Fprintf(c.stdout, "%s\t%s\n", shortID(td.ID), td.Title)
+			}
+			return nil
+		}
+	},
+}
+
+// findTodo returns the todo whose ID is id or, failing that, starts with it.
+func findTodo(list []todoclient.Todo, id string) (todoclient.Todo, error) {
+	var matches []todoclient.Todo
+	for _, td := range list {
+		if td.ID == id {
+			return td, nil
+		}
+		if strings.HasPrefix(td.ID, id) {
+			matches = append(matches, td)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return todoclient.Todo{}, fmt.Errorf("no todo with ID %q", id)
+	case 1:
+		return matches[0], nil
+	default:
+		return todoclient.Todo{}, fmt.Errorf("ID %q is ambiguous: it matches %d todos", id, len(matches))
+	}
+}
+
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", "table", "output `format`: table or json")
+}
+
+// print writes list in format.
+func (c *cli) print(format string, list []todoclient.Todo) error {
+	switch format {
+	case "json":
+		return writeJSON(c.stdout, list)
+	case "table":
+		w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDONE\tTITLE")
+		for _, td := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", shortID(td.ID), check(td.Completed), td.Title)
+		}
+		return w.Flush()
+	default:
+		return usagef("unknown format %q", format)
+	}
+}
+
+func writeJSON(w io.Writer, list []todoclient.Todo) error {
+	if list == nil {
+		list = []todoclient.Todo{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+// row is a one-line description of td.
+func row(td todoclient.Todo) string {
+	return fmt.Sprintf("%s %s %s", shortID(td.ID), check(td.Completed), td.Title)
+}
+
+func check(completed bool) string {
+	if completed {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLen {
+		return id[:shortIDLen]
+	}
+	return id
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var completionCommand = command{
+	name:  "completion",
+	usage: "bash | zsh | fish",
+	help: `Print a shell completion script.
+
+  bash:  source <(todo completion bash)
+  zsh:   todo completion zsh > "${fpath[1]}/_todo"
+  fish:  todo completion fish > ~/.config/fish/completions/todo.fish
+
+Todo IDs are completed by asking the server with the current profile.`,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return func(_ context.Context, c *cli, args []string) error {
+			if len(args) != 1 {
+				return usagef("a shell is required")
+			}
+			switch args[0] {
+			case "bash":
+				return bashCompletion(c.stdout)
+			case "zsh":
+				return zshCompletion(c.stdout)
+			case "fish":
+				return fishCompletion(c.stdout)
+			default:
+				return usagef("unsupported shell %q", args[0])
+			}
+		}
+	},
+}
+
+// completed describes a command for completion scripts.
+type completed struct {
+	name, help string
+	flags      []string
+	ids        bool
+	// words are the fixed first arguments, such as profile actions.
+	words []string
+}
+
+// completions lists the visible commands with their flags.
+func completions() []completed {
+	var out []completed
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		cmd.setup(fs)
+		cc := completed{name: cmd.name, help: firstLine(cmd.help), ids: cmd.ids}
+		fs.VisitAll(func(f *flag.Flag) { cc.flags = append(cc.flags, "-"+f.Name) })
+		sort.Strings(cc.flags)
+		switch cmd.name {
+		case "profile":
+			cc.words = []string{"ls", "use", "set", "rm"}
+		case "completion":
+			cc.words = []string{"bash", "zsh", "fish"}
+		}
+		out = append(out, cc)
+	}
+	return out
+}
+
+func bashCompletion(w io.Writer) error {
+	var b strings.Builder
+	names := make([]string, 0, len(commands))
+	for _, cc := range completions() {
+		names = append(names, cc.name)
+	}
+	fmt.Fprintf(&b, `# bash completion for todo
+_todo() {
+	local cur=${COMP_WORDS[COMP_CWORD]} cmd="" i
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		case ${COMP_WORDS[i]} in
+		-config|-profile|-server) ((i++)) ;;
+		-*) ;;
+		*) cmd=${COMP_WORDS[i]}; break ;;
+		esac
+	done
+	if [[ -z $cmd ]]; then
+		COMPREPLY=($(compgen -W "-config -profile -server %s" -- "$cur"))
+		return
+	fi
+	case $cmd in
+`, strings.Join(names, " "))
+	for _, cc := range completions() {
+		words := append(append([]string{}, cc.flags...), cc.words...)
+		fmt.Fprintf(&b, "\t%s)\n", cc.name)
+		if cc.ids {
+			b.WriteString("\t\tif [[ $cur != -* ]]; then\n")
+			b.WriteString("\t\t\tCOMPREPLY=($(compgen -W \"$(todo __ids 2>/dev/null | cut -f1)\" -- \"$cur\"))\n")
+			b.WriteString("\t\t\treturn\n\t\tfi\n")
+		}
+		if cc.name == "profile" {
+			b.WriteString("\t\tif ((COMP_CWORD > i + 1)) && [[ $cur != -* ]]; then\n")
+			b.WriteString("\t\t\tCOMPREPLY=($(compgen -W \"$(todo profile ls 2>/dev/null | awk 'NR > 1 { print ($1 == \"*\") ? $2 : $1 }')\" -- \"$cur\"))\n")
+			b.WriteString("\t\t\treturn\n\t\tfi\n")
+		}
+		fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\t;;\n", strings.Join(words, " "))
+	}
+	b.WriteString("\tesac\n}\ncomplete -o default -F _todo todo\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func zshCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(`#compdef todo
+# zsh completion for todo
+_todo_ids() {
+	local -a ids
+	ids=(${(f)"$(todo __ids 2>/dev/null | sed 's/\t/:/')"})
+	_describe 'todo' ids
+}
+
+_todo() {
+	local -a commands
+	commands=(
+`)
+	for _, cc := range completions() {
+		fmt.Fprintf(&b, "\t\t%s\n", zshQuote(cc.name+":"+cc.help))
+	}
+	b.WriteString(`	)
+	_arguments -C \
+		'-config[config file]:file:_files' \
+		'-profile[profile to use]:profile:' \
+		'-server[server URL]:url:' \
+		'1:command:->command' \
+		'*::argument:->argument'
+	case $state in
+	command)
+		_describe 'command' commands
+		;;
+	argument)
+		case $words[1] in
+`)
+	for _, cc := range completions() {
+		fmt.Fprintf(&b, "\t\t%s)\n", cc.name)
+		var specs []string
+		for _, f := range cc.flags {
+			specs = append(specs, zshQuote(f))
+		}
+		switch {
+		case cc.ids:
+			specs = append(specs, "'*:todo:_todo_ids'")
+		case len(cc.words) > 0:
+			specs = append(specs, zshQuote("1:argument:("+strings.Join(cc.words, " ")+")"))
+		}
+		if len(specs) > 0 {
+			fmt.Fprintf(&b, "\t\t\t_arguments %s\n", strings.Join(specs, " "))
+		}
+		b.WriteString("\t\t\t;;\n")
+	}
+	b.WriteString("\t\tesac\n\t\t;;\n\tesac\n}\n\n_todo \"$@\"\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func fishCompletion(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# fish completion for todo\n")
+	b.WriteString("complete -c todo -f\n")
+	b.WriteString("complete -c todo -n __fish_use_subcommand -o config -r -d 'config file'\n")
+	b.WriteString("complete -c todo -n __fish_use_subcommand -o profile -x -d 'profile to use'\n")
+	b.WriteString("complete -c todo -n __fish_use_subcommand -o server -x -d 'server URL'\n")
+	for _, cc := range completions() {
+		fmt.Fprintf(&b, "complete -c todo -n __fish_use_subcommand -a %s -d %s\n", cc.name, fishQuote(cc.help))
+		cond := "'__fish_seen_subcommand_from " + cc.name + "'"
+		for _, f := range cc.flags {
+			fmt.Fprintf(&b, "complete -c todo -n %s -o %s\n", cond, strings.TrimPrefix(f, "-"))
+		}
+		if cc.ids {
+			fmt.Fprintf(&b, "complete -c todo -n %s -a '(todo __ids 2>/dev/null)'\n", cond)
+		}
+		if len(cc.words) > 0 {
+			fmt.Fprintf(&b, "complete -c todo -n %s -a %s\n", cond, fishQuote(strings.Join(cc.words, " ")))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
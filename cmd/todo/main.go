@@ -0,0 +1,216 @@
+// Command todo is a command-line client for the todo API.
+//
+//	todo add buy milk
+//	todo ls -open
+//	todo done 1a2b
+//
+// Servers and credentials are kept in profiles in ~/.todo.yaml; see
+// `todo profile`.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"challenge-backend-arancia/pkg/todoclient"
+)
+
+// userAgent identifies the CLI to the server.
+const userAgent = "todo-cli"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], &cli{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	})
+	stop()
+	os.Exit(code)
+}
+
+// cli is the state shared by the commands.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	getenv         func(string) string
+
+	// Set by the global flags.
+	configPath string
+	profile    string
+	server     string
+}
+
+// command is a subcommand of todo.
+type command struct {
+	name  string
+	usage string
+	help  string
+	// ids is set when the arguments are todo IDs, for shell completion.
+	ids bool
+	// hidden commands are left out of the usage and completion.
+	hidden bool
+	// setup declares the flags of the command and returns the function
+	// that runs it once they are parsed.
+	setup func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error
+}
+
+// usageError is a misuse of the command line; it exits with status 2.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// commands is set by init: completion refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		addCommand,
+		lsCommand,
+		doneCommand,
+		undoCommand,
+		editCommand,
+		rmCommand,
+		exportCommand,
+		importCommand,
+		tuiCommand,
+		profileCommand,
+		completionCommand,
+		idsCommand,
+	}
+}
+
+func lookup(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// run runs the command line args and returns the exit status.
+func run(ctx context.Context, args []string, c *cli) int {
+	global := flag.NewFlagSet("todo", flag.ContinueOnError)
+	global.SetOutput(c.stderr)
+	global.StringVar(&c.configPath, "config", "", "config file (default $TODO_CONFIG or ~/.todo.yaml)")
+	global.StringVar(&c.profile, "profile", "", "profile to use (default $TODO_PROFILE or the current one)")
+	global.StringVar(&c.server, "server", "", "server URL, overriding the profile")
+	global.Usage = func() { c.usage(global) }
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if global.NArg() == 0 {
+		c.usage(global)
+		return 2
+	}
+
+	name := global.Arg(0)
+	if name == "help" {
+		c.usage(global)
+		return 0
+	}
+	cmd, ok := lookup(name)
+	if !ok {
+		fmt.Fprintf(c.stderr, "todo: unknown command %q\n", name)
+		c.usage(global)
+		return 2
+	}
+	fs := flag.NewFlagSet("todo "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: todo %s %s\n\n%s\n", cmd.name, cmd.usage, cmd.help)
+		if hasFlags(fs) {
+			fmt.Fprintln(c.stderr)
+			fs.PrintDefaults()
+		}
+	}
+	exec := cmd.setup(fs)
+	if err := fs.Parse(global.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	err := exec(ctx, c, fs.Args())
+	var uerr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &uerr):
+		fmt.Fprintf(c.stderr, "todo %s: %s\n", cmd.name, uerr.msg)
+		fs.Usage()
+		return 2
+	default:
+		fmt.Fprintf(c.stderr, "todo %s: %s\n", cmd.name, err)
+		return 1
+	}
+}
+
+func (c *cli) usage(global *flag.FlagSet) {
+	fmt.Fprintln(c.stderr, "usage: todo [flags] <command> [arguments]")
+	fmt.Fprintln(c.stderr, "\ncommands:")
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintf(c.stderr, "  %-11s %s\n", cmd.name, firstLine(cmd.help))
+		}
+	}
+	fmt.Fprintln(c.stderr, "\nflags:")
+	global.PrintDefaults()
+	fmt.Fprintln(c.stderr, "\nRun 'todo <command> -h' for the flags of a command.")
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	n := 0
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	return n > 0
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// dotfile returns the path and content of the config file.
+func (c *cli) dotfile() (string, Dotfile, error) {
+	path := c.configPath
+	if path == "" {
+		var err error
+		if path, err = dotfilePath(c.getenv); err != nil {
+			return "", Dotfile{}, err
+		}
+	}
+	d, err := loadDotfile(path)
+	return path, d, err
+}
+
+// client builds an API client for the selected profile.
+func (c *cli) client() (*todoclient.Client, error) {
+	_, d, err := c.dotfile()
+	if err != nil {
+		return nil, err
+	}
+	p, err := d.resolve(c.profile, c.getenv)
+	if err != nil {
+		return nil, err
+	}
+	if c.server != "" {
+		p.Server = c.server
+	}
+	return p.client(userAgent)
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/httpapi"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/pkg/todoclient"
+)
+
+type harness struct {
+	t      *testing.T
+	config string
+	server string
+}
+
+func newServer(t *testing.T) string {
+	t.Helper()
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	srv := httptest.NewServer(httpapi.NewRouter(httpapi.RouterOptions{TodoService: svc}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// newHarness runs commands with a profile "local" pointing at a fresh
+// server.
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{t: t, config: filepath.Join(t.TempDir(), "todo.yaml"), server: newServer(t)}
+	h.mustRun("profile", "set", "local", "-server", h.server)
+	return h
+}
+
+func (h *harness) run(args ...string) (code int, stdout, stderr string) {
+	return h.runInput("", args...)
+}
+
+func (h *harness) runInput(stdin string, args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	c := &cli{
+		stdin:  strings.NewReader(stdin),
+		stdout: &out,
+		stderr: &errOut,
+		getenv: func(string) string { return "" },
+	}
+	code := run(context.Background(), append([]string{"-config", h.config}, args...), c)
+	return code, out.String(), errOut.String()
+}
+
+func (h *harness) mustRun(args ...string) string {
+	h.t.Helper()
+	code, out, errOut := h.run(args...)
+	if code != 0 {
+		h.t.Fatalf("todo %s: expected exit 0, got %d: %s", strings.Join(args, " "), code, errOut)
+	}
+	return out
+}
+
+func (h *harness) list(args ...string) []todoclient.Todo {
+	h.t.Helper()
+	var list []todoclient.Todo
+	if err := json.Unmarshal([]byte(h.mustRun(append([]string{"ls", "-o", "json"}, args...)...)), &list); err != nil {
+		h.t.Fatalf("decode ls: %v", err)
+	}
+	return list
+}
+
+func TestRun_TodoLifecycle(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness(t)
+	h.mustRun("add", "buy", "milk")
+	h.mustRun("add", "walk the dog")
+	list := h.list()
+	if len(list) != 2 {
+		t.Fatalf("expected 2 todos, got %+v", list)
+	}
+	milk := list[0]
+	if milk.Title != "buy milk" {
+		milk = list[1]
+	}
+
+	if out := h.mustRun("done", milk.ID[:6]); !strings.Contains(out, "[x] buy milk") {
+		t.Fatalf("expected the todo to be completed, got %q", out)
+	}
+	if open := h.list("-open"); len(open) != 1 || open[0].Title != "walk the dog" {
+		t.Fatalf("expected only the dog to be open, got %+v", open)
+	}
+	if found := h.list("-q", "MILK"); len(found) != 1 || found[0].ID != milk.ID || !found[0].Completed {
+		t.Fatalf("expected the completed milk todo, got %+v", found)
+	}
+	h.mustRun("undo", milk.ID)
+	h.mustRun("edit", milk.ID, "buy", "oat", "milk")
+	if done := h.list("-done"); len(done) != 0 {
+		t.Fatalf("expected no completed todos, got %+v", done)
+	}
+	table := h.mustRun("ls")
+	if !strings.Contains(table, shortID(milk.ID)) || !strings.Contains(table, "buy oat milk") {
+		t.Fatalf("expected the edited todo in the table, got:\n%s", table)
+	}
+
+	h.mustRun("rm", milk.ID)
+	if code, _, errOut := h.run("done", milk.ID); code != 1 || !strings.Contains(errOut, "no todo") {
+		t.Fatalf("expected the deleted todo to be unknown, got %d: %s", code, errOut)
+	}
+	if code, _, errOut := h.run("add", strings.Repeat("x", 1000)); code != 1 || !strings.Contains(errOut, "title") {
+		t.Fatalf("expected the validation error, got %d: %s", code, errOut)
+	}
+}
+
+func TestRun_ExportImport(t *testing.T) {
+	t.Parallel()
+
+	src := newHarness(t)
+	src.mustRun("add", "one")
+	src.mustRun("add", "two")
+	src.mustRun("done", src.list()[0].ID)
+	api, err := todoclient.New(todoclient.Options{BaseURL: src.server})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	parent := src.list("-q", "two")[0]
+	sub, err := api.CreateTodo(context.Background(), "subtask of two")
+	if err != nil {
+		t.Fatalf("create subtask: %v", err)
+	}
+	if _, err := api.MoveTodo(context.Background(), sub.ID, parent.ID); err != nil {
+		t.Fatalf("move subtask: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "todos.json")
+	src.mustRun("export", "-f", file)
+
+	dst := newHarness(t)
+	for i := 0; i < 2; i++ {
+		if out := dst.mustRun("import", file); !strings.Contains(out, "imported 3 of 3") {
+			t.Fatalf("expected every todo to be imported, got %q", out)
+		}
+	}
+	want, got := src.list(), dst.list()
+	if len(got) != 3 {
+		t.Fatalf("expected importing twice to keep one copy, got %+v", got)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Title != want[i].Title || got[i].Completed != want[i].Completed || got[i].ParentID != want[i].ParentID {
+			t.Fatalf("expected %+v, got %+v", want[i], got[i])
+		}
+	}
+
+	exported, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read export: %v", err)
+	}
+	dst.mustRun("import", "-new-ids", file)
+	code, _, _ := dst.runInput(string(exported), "import", "-new-ids", "-")
+	all := dst.list()
+	if code != 0 || len(all) != 9 {
+		t.Fatalf("expected -new-ids to create copies, got %d, %+v", code, all)
+	}
+	titles := make(map[string]string, len(all))
+	for _, td := range all {
+		titles[td.ID] = td.Title
+	}
+	copies := 0
+	for _, td := range all {
+		if td.Title != "subtask of two" || td.ID == sub.ID {
+			continue
+		}
+		copies++
+		if titles[td.ParentID] != "two" || td.ParentID == parent.ID {
+			t.Fatalf("expected the copy of the subtask under a copy of its parent, got %+v", td)
+		}
+	}
+	if copies != 2 {
+		t.Fatalf("expected two copies of the subtask, got %d", copies)
+	}
+}
+
+func TestRun_Profiles(t *testing.T) {
+	t.Parallel()
+
+	h := newHarness(t)
+	h.mustRun("profile", "set", "prod", "-server", "https://todo.example.com", "-token", "s3cret", "-tenant", "acme")
+	h.mustRun("profile", "use", "prod")
+	out := h.mustRun("profile", "ls")
+	if !strings.Contains(out, "*  prod") || !strings.Contains(out, "token") || strings.Contains(out, "s3cret") {
+		t.Fatalf("expected prod current without its token, got:\n%s", out)
+	}
+	info, err := os.Stat(h.config)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the config file to be private, got %v, %v", info.Mode(), err)
+	}
+
+	// -profile selects another profile for one command.
+	h.mustRun("-profile", "local", "add", "local todo")
+	h.mustRun("profile", "rm", "prod")
+	if code, _, errOut := h.run("-profile", "prod", "ls"); code != 1 || !strings.Contains(errOut, "unknown profile") {
+		t.Fatalf("expected the removed profile to be unknown, got %d: %s", code, errOut)
+	}
+}
+
+func TestProfile_ResolveAppliesEnvironment(t *testing.T) {
+	t.Parallel()
+
+	d := Dotfile{Current: "a", Profiles: map[string]Profile{
+		"a": {Server: "http://a", Token: "ta"},
+		"b": {Server: "http://b"},
+	}}
+	env := map[string]string{"TODO_PROFILE": "b", "TODO_TOKEN": "env"}
+	p, err := d.resolve("", func(k string) string { return env[k] })
+	if err != nil || p.Server != "http://b" || p.Token != "env" {
+		t.Fatalf("expected profile b with the env token, got %+v, %v", p, err)
+	}
+	p, err = Dotfile{}.resolve("", func(string) string { return "" })
+	if err != nil || p.Server != defaultServer {
+		t.Fatalf("expected the default server, got %+v, %v", p, err)
+	}
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	t.Parallel()
+
+	h := &harness{t: t, config: filepath.Join(t.TempDir(), "todo.yaml")}
+	for _, args := range [][]string{
+		{},
+		{"frobnicate"},
+		{"ls", "-open", "-done"},
+		{"add"},
+		{"edit", "abc"},
+		{"completion", "tcsh"},
+		{"profile", "set"},
+	} {
+		if code, _, _ := h.run(args...); code != 2 {
+			t.Fatalf("todo %q: expected exit 2, got %d", args, code)
+		}
+	}
+}
+
+func TestFindTodo(t *testing.T) {
+	t.Parallel()
+
+	list := []todoclient.Todo{{ID: "abc1"}, {ID: "abc2"}, {ID: "abc"}}
+	if td, err := findTodo(list, "abc"); err != nil || td.ID != "abc" {
+		t.Fatalf("expected the exact match, got %+v, %v", td, err)
+	}
+	if td, err := findTodo(list, "abc2"); err != nil || td.ID != "abc2" {
+		t.Fatalf("expected abc2, got %+v, %v", td, err)
+	}
+	if _, err := findTodo(list[:2], "ab"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected an ambiguous prefix, got %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	t.Parallel()
+
+	h := &harness{t: t, config: filepath.Join(t.TempDir(), "todo.yaml")}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		out := h.mustRun("completion", shell)
+		for _, want := range []string{"done", "import", "new-ids", "__ids"} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("%s: expected %q in the script", shell, want)
+			}
+		}
+		if strings.Contains(out, "__ids)") || strings.Contains(out, "-a __ids") {
+			t.Fatalf("%s: expected the hidden command not to be offered", shell)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	got := parseKeys([]byte("j\x1b[Ak \x1b[1;5Bq\x1b"))
+	want := []key{keyDown, keyUp, keyUp, keyToggle, keyDown, keyQuit, keyQuit}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	t.Parallel()
+
+	b := &browser{}
+	b.setTodos([]todoclient.Todo{
+		{ID: "1", Title: "one", Completed: true},
+		{ID: "2", Title: "two"},
+		{ID: "3", Title: "three"},
+	})
+	b.move(2)
+	if td, _ := b.selected(); td.ID != "3" {
+		t.Fatalf("expected the cursor on 3, got %+v", td)
+	}
+	b.move(5)
+	if b.cursor != 2 {
+		t.Fatalf("expected the cursor to stop at the end, got %d", b.cursor)
+	}
+
+	b.toggleOpenOnly()
+	if td, _ := b.selected(); td.ID != "3" || len(b.visible()) != 2 {
+		t.Fatalf("expected the cursor to stay on 3 among the open todos, got %+v", td)
+	}
+	b.replace(todoclient.Todo{ID: "3", Title: "three", Completed: true})
+	if td, _ := b.selected(); td.ID != "2" {
+		t.Fatalf("expected the cursor to move to the last open todo, got %+v", td)
+	}
+
+	// A screen of 5 rows has room for a single todo.
+	b.toggleOpenOnly()
+	b.cursor = 2
+	var out bytes.Buffer
+	if err := b.render(&out, 40, 5); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	screen := out.String()
+	if !strings.Contains(screen, "> [x] 3  three") || strings.Contains(screen, "two") {
+		t.Fatalf("expected only the selected todo on screen, got %q", screen)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"challenge-backend-arancia/pkg/todoclient"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultServer is used when no profile names a server.
+const defaultServer = "http://localhost:8080"
+
+// Profile is a server and the credentials used with it.
+type Profile struct {
+	Server string `yaml:"server"`
+	// Token is a bearer token; APIKey is sent as X-API-Key.
+	Token  string `yaml:"token,omitempty"`
+	APIKey string `yaml:"api_key,omitempty"`
+	Tenant string `yaml:"tenant,omitempty"`
+	// CAFile verifies the server; CertFile and KeyFile authenticate the
+	// client when the server requires mutual TLS.
+	CAFile   string `yaml:"ca_file,omitempty"`
+	CertFile string `yaml:"cert_file,omitempty"`
+	KeyFile  string `yaml:"key_file,omitempty"`
+}
+
+// Dotfile holds the profiles, by name, and the one used by default.
+type Dotfile struct {
+	Current  string             `yaml:"current,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// dotfilePath is $TODO_CONFIG, or ~/.todo.yaml.
+func dotfilePath(getenv func(string) string) (string, error) {
+	if p := getenv("TODO_CONFIG"); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locate the config file: %w", err)
+	}
+	return filepath.Join(home, ".todo.yaml"), nil
+}
+
+// loadDotfile reads the dotfile at path; a missing file has no profiles.
+func loadDotfile(path string) (Dotfile, error) {
+	var d Dotfile
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return d, err
+	}
+	if err := yaml.Unmarshal(b, &d); err != nil {
+		return d, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return d, nil
+}
+
+// save writes the dotfile readable by its owner only, as it holds
+// credentials.
+func (d Dotfile) save(path string) error {
+	b, err := yaml.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// names returns the profile names, sorted.
+func (d Dotfile) names() []string {
+	names := make([]string, 0, len(d.Profiles))
+	for name := range d.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve picks the profile named name, else $TODO_PROFILE, else the
+// current one, and applies the TODO_SERVER, TODO_TOKEN, TODO_API_KEY and
+// TODO_TENANT overrides. Only an explicitly named profile must exist.
+func (d Dotfile) resolve(name string, getenv func(string) string) (Profile, error) {
+	explicit := name != ""
+	if name == "" {
+		name = getenv("TODO_PROFILE")
+		explicit = name != ""
+	}
+	if name == "" {
+		name = d.Current
+	}
+	p, ok := d.Profiles[name]
+	if !ok && explicit {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	for env, field := range map[string]*string{
+		"TODO_SERVER":  &p.Server,
+		"TODO_TOKEN":   &p.Token,
+		"TODO_API_KEY": &p.APIKey,
+		"TODO_TENANT":  &p.Tenant,
+	} {
+		if v := getenv(env); v != "" {
+			*field = v
+		}
+	}
+	if p.Server == "" {
+		p.Server = defaultServer
+	}
+	return p, nil
+}
+
+// client builds an API client for the profile.
+func (p Profile) client(userAgent string) (*todoclient.Client, error) {
+	var auths []todoclient.Authenticator
+	if p.Token != "" {
+		auths = append(auths, todoclient.BearerToken(p.Token))
+	}
+	if p.APIKey != "" {
+		auths = append(auths, todoclient.APIKey(p.APIKey))
+	}
+	opts := todoclient.Options{
+		BaseURL:   p.Server,
+		Tenant:    p.Tenant,
+		UserAgent: userAgent,
+	}
+	if len(auths) > 0 {
+		opts.Auth = todoclient.Chain(auths...)
+	}
+	if p.CAFile != "" || p.CertFile != "" {
+		tlsCfg, err := p.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsCfg
+		opts.HTTPClient = &http.Client{Transport: transport}
+	}
+	return todoclient.New(opts)
+}
+
+func (p Profile) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if p.CAFile != "" {
+		pem, err := os.ReadFile(p.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s holds no PEM certificates", p.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if p.CertFile != "" || p.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.CertFile, p.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
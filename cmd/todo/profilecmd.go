@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+var profileCommand = command{
+	name:  "profile",
+	usage: "ls | use <name> | set <name> [flags] | rm <name>",
+	help: `Manage the profiles of the config file.
+
+  ls          list the profiles; * marks the current one
+  use <name>  make a profile the current one
+  set <name>  create or update a profile from the flags below; the first
+              profile created becomes the current one
+  rm <name>   delete a profile
+
+set flags:
+  -server url    -token token    -api-key key    -tenant id
+  -ca-file file  -cert-file file -key-file file
+
+A flag given an empty value clears the setting. TODO_SERVER, TODO_TOKEN,
+TODO_API_KEY and TODO_TENANT override the profile in use.`,
+	setup: func(*flag.FlagSet) func(context.Context, *cli, []string) error {
+		return func(_ context.Context, c *cli, args []string) error {
+			if len(args) == 0 {
+				return usagef("an action is required")
+			}
+			path, d, err := c.dotfile()
+			if err != nil {
+				return err
+			}
+			action, args := args[0], args[1:]
+			if action == "ls" {
+				if len(args) > 0 {
+					return usagef("unexpected arguments %q", args)
+				}
+				return listProfiles(c.stdout, d)
+			}
+			if len(args) == 0 {
+				return usagef("a profile name is required")
+			}
+			name := args[0]
+			switch action {
+			case "use":
+				if _, ok := d.Profiles[name]; !ok {
+					return fmt.Errorf("unknown profile %q", name)
+				}
+				d.Current = name
+			case "set":
+				p, err := setProfile(c, d.Profiles[name], args[1:])
+				if err != nil {
+					return err
+				}
+				if d.Profiles == nil {
+					d.Profiles = map[string]Profile{}
+				}
+				d.Profiles[name] = p
+				if d.Current == "" {
+					d.Current = name
+				}
+			case "rm":
+				if _, ok := d.Profiles[name]; !ok {
+					return fmt.Errorf("unknown profile %q", name)
+				}
+				delete(d.Profiles, name)
+				if d.Current == name {
+					d.Current = ""
+				}
+			default:
+				return usagef("unknown action %q", action)
+			}
+			return d.save(path)
+		}
+	},
+}
+
+// setProfile applies the flags in args to p.
+func setProfile(c *cli, p Profile, args []string) (Profile, error) {
+	fs := flag.NewFlagSet("todo profile set", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&p.Server, "server", p.Server, "server `url`")
+	fs.StringVar(&p.Token, "token", p.Token, "bearer `token`")
+	fs.StringVar(&p.APIKey, "api-key", p.APIKey, "API `key`")
+	fs.StringVar(&p.Tenant, "tenant", p.Tenant, "tenant `id`")
+	fs.StringVar(&p.CAFile, "ca-file", p.CAFile, "CA certificates verifying the server")
+	fs.StringVar(&p.CertFile, "cert-file", p.CertFile, "client certificate for mutual TLS")
+	fs.StringVar(&p.KeyFile, "key-file", p.KeyFile, "client key for mutual TLS")
+	if err := fs.Parse(args); err != nil {
+		return p, usagef("%v", err)
+	}
+	if fs.NArg() > 0 {
+		return p, usagef("unexpected arguments %q", fs.Args())
+	}
+	return p, nil
+}
+
+func listProfiles(w io.Writer, d Dotfile) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tNAME\tSERVER\tAUTH\tTENANT")
+	for _, name := range d.names() {
+		p := d.Profiles[name]
+		current := ""
+		if name == d.Current {
+			current = "*"
+		}
+		server := p.Server
+		if server == "" {
+			server = defaultServer
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", current, name, server, authKind(p), p.Tenant)
+	}
+	return tw.Flush()
+}
+
+// authKind names the credentials of p without printing them.
+func authKind(p Profile) string {
+	switch {
+	case p.Token != "" && p.APIKey != "":
+		return "token+api-key"
+	case p.Token != "":
+		return "token"
+	case p.APIKey != "":
+		return "api-key"
+	case p.CertFile != "":
+		return "certificate"
+	default:
+		return "none"
+	}
+}
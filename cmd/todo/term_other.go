@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package main
+
+import "errors"
+
+var errNoTerminal = errors.New("raw terminal mode is not supported on this platform")
+
+func makeRaw(int) (func() error, error) {
+	return nil, errNoTerminal
+}
+
+func terminalSize(int) (int, int, error) {
+	return 0, 0, errNoTerminal
+}
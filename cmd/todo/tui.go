@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"challenge-backend-arancia/pkg/todoclient"
+)
+
+// requestTimeout bounds each API call of the interactive mode, which has no
+// overall deadline.
+const requestTimeout = 10 * time.Second
+
+var tuiCommand = command{
+	name:  "tui",
+	usage: "[-open]",
+	help: `Browse and toggle todos interactively.
+
+  j, down   next todo          k, up   previous todo
+  g, home   first todo         G, end  last todo
+  space, x  toggle completion  r       reload
+  o         show open todos only, or all of them again
+  q, ctrl-c quit`,
+	setup: func(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+		open := fs.Bool("open", false, "start with open todos only")
+		return func(ctx context.Context, c *cli, args []string) error {
+			if len(args) > 0 {
+				return usagef("unexpected arguments %q", args)
+			}
+			in, ok := c.stdin.(*os.File)
+			if !ok {
+				return errors.New("interactive mode needs a terminal")
+			}
+			api, err := c.client()
+			if err != nil {
+				return err
+			}
+			return runTUI(ctx, api, in, c.stdout, *open)
+		}
+	},
+}
+
+// key is a key press the browser reacts to.
+type key int
+
+const (
+	keyUp key = iota
+	keyDown
+	keyTop
+	keyBottom
+	keyToggle
+	keyReload
+	keyOpenOnly
+	keyQuit
+)
+
+// parseKeys decodes the keys in a read from a raw terminal. Unknown keys
+// and escape sequences are dropped.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			seq := b
+			if len(seq) >= 3 && (seq[1] == '[' || seq[1] == 'O') {
+				// Skip the parameters, such as modifiers, to the final byte.
+				n := 2
+				for n < len(seq)-1 && (seq[n] < 0x40 || seq[n] > 0x7e) {
+					n++
+				}
+				switch seq[n] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				case 'H':
+					keys = append(keys, keyTop)
+				case 'F':
+					keys = append(keys, keyBottom)
+				}
+				b = b[n+1:]
+				continue
+			}
+			if len(seq) == 1 {
+				keys = append(keys, keyQuit)
+			}
+			b = b[1:]
+			continue
+		}
+		switch b[0] {
+		case 'k':
+			keys = append(keys, keyUp)
+		case 'j':
+			keys = append(keys, keyDown)
+		case 'g':
+			keys = append(keys, keyTop)
+		case 'G':
+			keys = append(keys, keyBottom)
+		case ' ', 'x', '\r', '\n':
+			keys = append(keys, keyToggle)
+		case 'r':
+			keys = append(keys, keyReload)
+		case 'o':
+			keys = append(keys, keyOpenOnly)
+		case 'q', 0x03, 0x04:
+			keys = append(keys, keyQuit)
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// browser is the state of the interactive mode.
+type browser struct {
+	all      []todoclient.Todo
+	openOnly bool
+	// cursor is the index of the selected todo in visible, top the first
+	// visible row.
+	cursor, top int
+	status      string
+}
+
+// visible returns the todos shown.
+func (b *browser) visible() []todoclient.Todo {
+	if !b.openOnly {
+		return b.all
+	}
+	var out []todoclient.Todo
+	for _, td := range b.all {
+		if !td.Completed {
+			out = append(out, td)
+		}
+	}
+	return out
+}
+
+// selected returns the todo under the cursor.
+func (b *browser) selected() (todoclient.Todo, bool) {
+	v := b.visible()
+	if b.cursor < 0 || b.cursor >= len(v) {
+		return todoclient.Todo{}, false
+	}
+	return v[b.cursor], true
+}
+
+// setTodos replaces the todos, keeping the cursor on the selected one if
+// it is still visible.
+func (b *browser) setTodos(list []todoclient.Todo) {
+	cur, _ := b.selected()
+	b.all = list
+	b.clamp()
+	b.focus(cur.ID)
+}
+
+// toggleOpenOnly shows open todos only, or all of them again.
+func (b *browser) toggleOpenOnly() {
+	cur, _ := b.selected()
+	b.openOnly = !b.openOnly
+	b.cursor = 0
+	b.focus(cur.ID)
+}
+
+// focus moves the cursor to the todo id if it is visible.
+func (b *browser) focus(id string) {
+	for i, td := range b.visible() {
+		if td.ID == id {
+			b.cursor = i
+			return
+		}
+	}
+}
+
+// replace updates one todo in place.
+func (b *browser) replace(td todoclient.Todo) {
+	for i := range b.all {
+		if b.all[i].ID == td.ID {
+			b.all[i] = td
+		}
+	}
+	b.clamp()
+}
+
+func (b *browser) move(delta int) {
+	b.cursor += delta
+	b.clamp()
+}
+
+func (b *browser) clamp() {
+	n := len(b.visible())
+	b.cursor = max(0, min(b.cursor, n-1))
+}
+
+// render draws the browser on a screen of width by height cells.
+func (b *browser) render(w io.Writer, width, height int) error {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H\x1b[2J")
+
+	v := b.visible()
+	title := fmt.Sprintf("todos: %d, %d open", len(b.all), countOpen(b.all))
+	if b.openOnly {
+		title += " (showing open)"
+	}
+	fmt.Fprintln(&buf, "\x1b[1m"+truncate(title, width)+"\x1b[0m")
+
+	// The title, blank line and two status lines leave rows for the list.
+	rows := max(1, height-4)
+	if b.cursor < b.top {
+		b.top = b.cursor
+	}
+	if b.cursor >= b.top+rows {
+		b.top = b.cursor - rows + 1
+	}
+	b.top = max(0, min(b.top, len(v)-rows))
+	buf.WriteString("\n")
+	if len(v) == 0 {
+		fmt.Fprintln(&buf, "  nothing to do")
+	}
+	for i := b.top; i < len(v) && i < b.top+rows; i++ {
+		line := truncate(fmt.Sprintf("%s %s  %s", check(v[i].Completed), shortID(v[i].ID), v[i].Title), width-2)
+		if i == b.cursor {
+			fmt.Fprintln(&buf, "\x1b[7m> "+line+"\x1b[0m")
+		} else {
+			fmt.Fprintln(&buf, "  "+line)
+		}
+	}
+
+	fmt.Fprintf(&buf, "\x1b[%d;1H", height-1)
+	fmt.Fprintln(&buf, "\x1b[2m"+truncate(b.status, width)+"\x1b[0m")
+	buf.WriteString("\x1b[2m" + truncate("j/k move  space toggle  o open only  r reload  q quit", width) + "\x1b[0m")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 || len(r) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(r[:width-1]) + "…"
+}
+
+func countOpen(list []todoclient.Todo) int {
+	n := 0
+	for _, td := range list {
+		if !td.Completed {
+			n++
+		}
+	}
+	return n
+}
+
+// runTUI runs the interactive mode on the terminal in until a quit key is
+// pressed or ctx is done.
+func runTUI(ctx context.Context, api *todoclient.Client, in *os.File, out io.Writer, openOnly bool) error {
+	fd := int(in.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("interactive mode needs a terminal: %w", err)
+	}
+	defer restore()
+	// Use the alternate screen and hide the cursor while browsing.
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	b := &browser{openOnly: openOnly}
+	reload := func() {
+		reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+		list, err := api.ListTodos(reqCtx)
+		if err != nil {
+			b.status = "reload failed: " + err.Error()
+			return
+		}
+		b.setTodos(list)
+		b.status = "loaded at " + time.Now().Format("15:04:05")
+	}
+	toggle := func() {
+		td, ok := b.selected()
+		if !ok {
+			return
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+		updated, err := api.UpdateTodo(reqCtx, td.ID, td.Title, !td.Completed)
+		switch {
+		case errors.Is(err, todoclient.ErrNotFound):
+			b.status = "it was deleted meanwhile"
+			reload()
+		case err != nil:
+			b.status = "toggle failed: " + err.Error()
+		default:
+			b.replace(updated)
+			b.status = row(updated)
+		}
+	}
+
+	keys := make(chan []key)
+	readErr := make(chan error, 1)
+	// The reader is left blocked on the terminal when the mode ends; the
+	// process exits right after.
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case keys <- parseKeys(buf[:n]):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	reload()
+	for {
+		width, height, err := terminalSize(fd)
+		if err != nil || width == 0 || height == 0 {
+			width, height = 80, 24
+		}
+		if err := b.render(out, width, height); err != nil {
+			return err
+		}
+		var pressed []key
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case pressed = <-keys:
+		}
+		for _, k := range pressed {
+			switch k {
+			case keyUp:
+				b.move(-1)
+			case keyDown:
+				b.move(1)
+			case keyTop:
+				b.cursor = 0
+			case keyBottom:
+				b.cursor = len(b.visible()) - 1
+				b.clamp()
+			case keyToggle:
+				toggle()
+			case keyReload:
+				reload()
+			case keyOpenOnly:
+				b.toggleOpenOnly()
+			case keyQuit:
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package feeds issues the tokens that let calendar and feed reader apps poll
+// read-only exports of the todos. Such apps can only be given a URL, so the
+// token travels in it instead of an Authorization header.
+package feeds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/ports"
+)
+
+// ErrAnonymous is returned to callers without an authenticated subject:
+// feeds hold the todos of one user.
+var ErrAnonymous = errors.New("feed tokens are issued to authenticated users")
+
+// Owner is the user a feed token was issued to.
+type Owner struct {
+	Tenant  string
+	Subject string
+}
+
+type Service struct {
+	tokens ports.FeedTokenRepository
+}
+
+func NewService(tokens ports.FeedTokenRepository) (*Service, error) {
+	if tokens == nil {
+		return nil, errors.New("nil feed token repo")
+	}
+	return &Service{tokens: tokens}, nil
+}
+
+// Issue returns a new feed token for the user of ctx, revoking their previous
+// one. Only a hash is stored, so the token cannot be shown again.
+func (s *Service) Issue(ctx context.Context) (string, error) {
+	sub, err := subject(ctx)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if err := s.tokens.Put(ctx, sub, hash(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Revoke revokes the feed token of the user of ctx, failing with
+// ports.ErrNotFound if they have none.
+func (s *Service) Revoke(ctx context.Context) error {
+	sub, err := subject(ctx)
+	if err != nil {
+		return err
+	}
+	return s.tokens.Delete(ctx, sub)
+}
+
+// Resolve returns the owner of token, or ports.ErrNotFound for unknown and
+// revoked tokens, and for those once shared by anonymous callers.
+func (s *Service) Resolve(ctx context.Context, token string) (Owner, error) {
+	if token == "" {
+		return Owner{}, ports.ErrNotFound
+	}
+	tenant, sub, err := s.tokens.Lookup(ctx, hash(token))
+	if err != nil {
+		return Owner{}, err
+	}
+	if sub == "" {
+		return Owner{}, ports.ErrNotFound
+	}
+	return Owner{Tenant: tenant, Subject: sub}, nil
+}
+
+// hash needs no salt or stretching: tokens are random, not passwords.
+func hash(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// subject returns the authenticated subject of ctx, or ErrAnonymous.
+func subject(ctx context.Context) (string, error) {
+	if c, ok := auth.FromContext(ctx); ok && c.Subject != "" {
+		return c.Subject, nil
+	}
+	return "", ErrAnonymous
+}
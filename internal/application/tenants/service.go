@@ -0,0 +1,80 @@
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+// Export is a full dump of a tenant's data, used when offboarding.
+type Export struct {
+	Tenant domain.Tenant
+	Todos  []domain.Todo
+}
+
+type Service struct {
+	tenants ports.TenantRepository
+	todos   ports.TodoRepository
+	now     func() time.Time
+}
+
+func NewService(tenants ports.TenantRepository, todos ports.TodoRepository) (*Service, error) {
+	if tenants == nil {
+		return nil, errors.New("nil tenant repo")
+	}
+	if todos == nil {
+		return nil, errors.New("nil todo repo")
+	}
+	return &Service{tenants: tenants, todos: todos, now: time.Now}, nil
+}
+
+func (s *Service) List(ctx context.Context) ([]domain.Tenant, error) {
+	return s.tenants.List(ctx)
+}
+
+func (s *Service) Get(ctx context.Context, id string) (domain.Tenant, error) {
+	return s.tenants.Get(ctx, id)
+}
+
+// Provision creates a new tenant with empty storage.
+func (s *Service) Provision(ctx context.Context, id string) (domain.Tenant, error) {
+	t := domain.Tenant{ID: id, CreatedAt: s.now().UTC()}
+	if err := t.Validate(); err != nil {
+		return domain.Tenant{}, err
+	}
+	if err := s.tenants.Create(ctx, t); err != nil {
+		return domain.Tenant{}, err
+	}
+	return t, nil
+}
+
+// Deprovision deletes the tenant and all of its data. It cannot be undone.
+// The default tenant serves requests naming no tenant, so it fails with
+// ports.ErrConflict instead.
+func (s *Service) Deprovision(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("missing id")
+	}
+	if id == tenancy.DefaultID {
+		return fmt.Errorf("tenant %q cannot be deprovisioned: %w", id, ports.ErrConflict)
+	}
+	return s.tenants.Delete(ctx, id)
+}
+
+// Export returns every todo stored for the tenant.
+func (s *Service) Export(ctx context.Context, id string) (Export, error) {
+	t, err := s.tenants.Get(ctx, id)
+	if err != nil {
+		return Export{}, err
+	}
+	todos, err := s.todos.List(tenancy.WithID(ctx, id))
+	if err != nil {
+		return Export{}, err
+	}
+	return Export{Tenant: t, Todos: todos}, nil
+}
@@ -0,0 +1,151 @@
+package todos
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tracing"
+)
+
+const (
+	// DefaultChangesLimit and MaxChangesLimit bound a page of the change feed.
+	DefaultChangesLimit = 100
+	MaxChangesLimit     = 1000
+
+	// syncTokenVersion prefixes every token so the format can change later.
+	// snapshotTokenVersion marks the tokens of an initial sync that is not
+	// complete yet: they also carry the last todo ID returned.
+	syncTokenVersion     = 1
+	snapshotTokenVersion = 2
+)
+
+// ErrInvalidSyncToken indicates a sync token that was not issued by Changes.
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// ChangePage is a page of the change feed. Token is passed back as since to
+// get the next page (More) or, once caught up, later changes.
+type ChangePage struct {
+	Changes []domain.Change
+	Token   string
+	More    bool
+}
+
+// Changes returns the todos written since the sync point encoded in token,
+// or every todo when token is empty. Tokens that predate the retained change
+// history fail with ports.ErrResyncRequired.
+//
+// The initial sync pages through the todos in ID order and ends with the sync
+// point of its first page, so the todos written while it ran come again next.
+func (s *Service) Changes(ctx context.Context, token string, limit int) (_ ChangePage, err error) {
+	ctx, span := startSpan(ctx, "Changes")
+	defer func() { tracing.End(span, err) }()
+
+	since, after, err := decodeSyncToken(token)
+	if err != nil {
+		return ChangePage{}, invalidSyncToken()
+	}
+	if limit <= 0 {
+		limit = DefaultChangesLimit
+	}
+	limit = min(limit, MaxChangesLimit)
+	if after != "" {
+		return s.snapshotAfter(ctx, since, after, limit)
+	}
+	set, err := s.repo.Changes(ctx, since, limit)
+	if errors.Is(err, ports.ErrResyncRequired) {
+		// Clients offline for longer than the retention have to download
+		// every todo again; frequent warnings call for a longer retention.
+		logging.FromContext(ctx).WarnContext(ctx, "sync_token_expired", slog.Uint64("since", since))
+	}
+	if err != nil {
+		return ChangePage{}, err
+	}
+	if since == 0 && set.More {
+		return ChangePage{Changes: set.Changes, Token: encodeSnapshotToken(set.Seq, set.Changes[len(set.Changes)-1].Todo.ID), More: true}, nil
+	}
+	return ChangePage{Changes: set.Changes, Token: encodeSyncToken(set.Seq), More: set.More}, nil
+}
+
+// SyncToken returns the token Changes would end an initial sync with now,
+// without listing any todo.
+func (s *Service) SyncToken(ctx context.Context) (_ string, err error) {
+	ctx, span := startSpan(ctx, "SyncToken")
+	defer func() { tracing.End(span, err) }()
+
+	set, err := s.repo.Changes(ctx, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	return encodeSyncToken(set.Seq), nil
+}
+
+// snapshotAfter continues an initial sync started at the sync point seq after
+// the todo ID after.
+func (s *Service) snapshotAfter(ctx context.Context, seq uint64, after string, limit int) (ChangePage, error) {
+	list, err := s.repo.ListAfter(ctx, after, limit+1, nil)
+	if err != nil {
+		return ChangePage{}, err
+	}
+	if len(list) <= limit {
+		return ChangePage{Changes: asChanges(list), Token: encodeSyncToken(seq)}, nil
+	}
+	list = list[:limit]
+	return ChangePage{Changes: asChanges(list), Token: encodeSnapshotToken(seq, list[limit-1].ID), More: true}, nil
+}
+
+func asChanges(list []domain.Todo) []domain.Change {
+	out := make([]domain.Change, 0, len(list))
+	for _, td := range list {
+		out = append(out, domain.Change{Todo: td})
+	}
+	return out
+}
+
+func invalidSyncToken() error {
+	return &domain.ValidationError{Violations: []domain.FieldViolation{{
+		Field:  "since",
+		Rule:   domain.RuleFormat,
+		Detail: "since must be a token returned by a previous call",
+		Err:    ErrInvalidSyncToken,
+	}}}
+}
+
+func encodeSyncToken(seq uint64) string {
+	b := make([]byte, 9)
+	b[0] = syncTokenVersion
+	binary.BigEndian.PutUint64(b[1:], seq)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func encodeSnapshotToken(seq uint64, after string) string {
+	b := make([]byte, 9, 9+len(after))
+	b[0] = snapshotTokenVersion
+	binary.BigEndian.PutUint64(b[1:], seq)
+	return base64.RawURLEncoding.EncodeToString(append(b, after...))
+}
+
+// decodeSyncToken returns the sync point of token and, for an unfinished
+// initial sync, the last todo ID it returned.
+func decodeSyncToken(token string) (uint64, string, error) {
+	if token == "" {
+		return 0, "", nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) < 9 {
+		return 0, "", ErrInvalidSyncToken
+	}
+	seq := binary.BigEndian.Uint64(b[1:9])
+	switch {
+	case b[0] == syncTokenVersion && len(b) == 9:
+		return seq, "", nil
+	case b[0] == snapshotTokenVersion && len(b) > 9:
+		return seq, string(b[9:]), nil
+	}
+	return 0, "", ErrInvalidSyncToken
+}
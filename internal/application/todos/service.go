@@ -3,69 +3,352 @@ package todos
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"time"
 
+	"challenge-backend-arancia/internal/auth"
 	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/logging"
 	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+	"challenge-backend-arancia/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Quota bounds how much a single user may store. Zero values mean unlimited.
+//
+// Todos are the only resource users store: the API has no lists, each user
+// has one per tenant, so there is no quota on them. Anonymous callers, only
+// possible without authentication, cannot be told apart: their todos count
+// towards MaxAnonymousTodos, shared by all of them in a tenant, or MaxTodos
+// when it is zero.
+type Quota struct {
+	MaxTodos          int
+	MaxAnonymousTodos int
+}
+
+// limit returns the number of todos owner may own.
+func (q Quota) limit(owner string) int {
+	if owner == "" && q.MaxAnonymousTodos > 0 {
+		return q.MaxAnonymousTodos
+	}
+	return q.MaxTodos
+}
+
+// Option configures optional Service behaviour.
+type Option func(*Service)
+
+// WithQuota enforces q for every user, scoped to their tenant.
+func WithQuota(q Quota) Option {
+	return func(s *Service) { s.quota = q }
+}
+
+var tracer = tracing.Tracer("application/todos")
+
 type Service struct {
 	repo  ports.TodoRepository
 	idGen ports.IDGenerator
+	quota Quota
+	now   func() time.Time
 }
 
-func NewService(repo ports.TodoRepository, idGen ports.IDGenerator) (*Service, error) {
+func NewService(repo ports.TodoRepository, idGen ports.IDGenerator, opts ...Option) (*Service, error) {
 	if repo == nil {
 		return nil, errors.New("nil repo")
 	}
 	if idGen == nil {
 		return nil, errors.New("nil id generator")
 	}
-	return &Service{repo: repo, idGen: idGen}, nil
+	s := &Service{repo: repo, idGen: idGen, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
-func (s *Service) List(ctx context.Context) ([]domain.Todo, error) {
+func (s *Service) List(ctx context.Context) (_ []domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "List")
+	defer func() { tracing.End(span, err) }()
+
 	return s.repo.List(ctx)
 }
 
+// ListAfter returns up to limit todos match accepts with an ID greater than
+// afterID, in ID order, and whether more follow. A nil match accepts all.
+func (s *Service) ListAfter(ctx context.Context, afterID string, limit int, match func(domain.Todo) bool) (_ []domain.Todo, more bool, err error) {
+	ctx, span := startSpan(ctx, "ListAfter", attribute.Int("limit", limit))
+	defer func() { tracing.End(span, err) }()
+
+	page, err := s.repo.ListAfter(ctx, afterID, limit+1, match)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(page) > limit {
+		return page[:limit], true, nil
+	}
+	return page, false, nil
+}
+
+// Count returns how many todos match accepts, or how many there are for a
+// nil match.
+func (s *Service) Count(ctx context.Context, match func(domain.Todo) bool) (_ int, err error) {
+	ctx, span := startSpan(ctx, "Count")
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.Count(ctx, match)
+}
+
+func (s *Service) Get(ctx context.Context, id string) (_ domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "Get", attribute.String("todo.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if id == "" {
+		return domain.Todo{}, errors.New("missing id")
+	}
+	return s.repo.Get(ctx, id)
+}
+
 func (s *Service) Create(ctx context.Context, title string) (domain.Todo, error) {
+	return s.Add(ctx, NewTodo{Title: title})
+}
+
+// Add creates a todo, unlike Create possibly as a subtask. It fails with
+// ports.ErrNotFound when the parent does not exist.
+func (s *Service) Add(ctx context.Context, n NewTodo) (_ domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "Create", attribute.String("todo.parent_id", n.ParentID))
+	defer func() { tracing.End(span, err) }()
+
 	td := domain.Todo{
-		ID:        s.idGen.NewID(),
-		Title:     title,
-		Completed: false,
+		ID:           s.idGen.NewID(),
+		Title:        n.Title,
+		Completed:    false,
+		Owner:        owner(ctx),
+		ParentID:     n.ParentID,
+		AutoComplete: n.AutoComplete,
 	}
-	if err := td.Validate(); err != nil {
+	s.stamp(&td, domain.Todo{})
+	if err := td.Check(); err != nil {
 		return domain.Todo{}, err
 	}
 	if td.ID == "" {
 		return domain.Todo{}, errors.New("generated empty id")
 	}
-	if err := s.repo.Create(ctx, td); err != nil {
+	if err := s.repo.CreateWithin(ctx, td, s.quota.limit(td.Owner), s.event(ctx, domain.EventTodoCreated)); err != nil {
 		return domain.Todo{}, err
 	}
+	td.Version = 1
 	return td, nil
 }
 
 func (s *Service) Update(ctx context.Context, id string, title string, completed bool) (domain.Todo, error) {
-	td := domain.Todo{
-		ID:        id,
-		Title:     title,
-		Completed: completed,
-	}
-	if td.ID == "" {
+	return s.Edit(ctx, id, Edit{Title: title, Completed: completed})
+}
+
+// Edit is Update, also setting AutoComplete and checking Version. Setting
+// AutoComplete rolls the todo up only once its subtasks change next:
+// explicit writes are never overridden.
+func (s *Service) Edit(ctx context.Context, id string, e Edit) (_ domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "Update", attribute.String("todo.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if id == "" {
 		return domain.Todo{}, errors.New("missing id")
 	}
-	if err := td.Validate(); err != nil {
+	for attempt := 0; ; attempt++ {
+		td, err := s.edit(ctx, id, e)
+		// Without a version the last writer wins, so a concurrent write is
+		// retried instead of reported.
+		if errors.Is(err, ports.ErrConflict) && e.Version == 0 {
+			if attempt+1 < maxSyncAttempts {
+				continue
+			}
+			logContention(ctx, "edit", id)
+		}
+		return td, err
+	}
+}
+
+func (s *Service) edit(ctx context.Context, id string, e Edit) (domain.Todo, error) {
+	td, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	if e.Version != 0 && e.Version != td.Version {
+		return domain.Todo{}, ports.ErrConflict
+	}
+	event := domain.EventTodoUpdated
+	if e.Completed && !td.Completed {
+		event = domain.EventTodoCompleted
+	}
+	prev := td
+	td.Title = e.Title
+	td.Completed = e.Completed
+	if e.AutoComplete != nil {
+		td.AutoComplete = *e.AutoComplete
+	}
+	if err := td.Check(); err != nil {
 		return domain.Todo{}, err
 	}
-	if err := s.repo.Update(ctx, td); err != nil {
+	s.stamp(&td, prev)
+	// td.Version makes the write fail if the todo changed since it was read.
+	if err := s.repo.Update(ctx, td, s.event(ctx, event)); err != nil {
 		return domain.Todo{}, err
 	}
+	td.Version++
 	return td, nil
 }
 
-func (s *Service) Delete(ctx context.Context, id string) error {
+func (s *Service) Delete(ctx context.Context, id string) (err error) {
+	ctx, span := startSpan(ctx, "Delete", attribute.String("todo.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	if id == "" {
 		return errors.New("missing id")
 	}
-	return s.repo.Delete(ctx, id)
+	return s.repo.Delete(ctx, id, 0, s.event(ctx, domain.EventTodoDeleted))
+}
+
+// Precondition makes a Put or DeleteIf conditional on the stored todo, like
+// HTTP's If-Match and If-None-Match. The zero value always holds.
+type Precondition struct {
+	// Version requires the todo to exist at this version.
+	Version uint64
+	// Absent requires the todo not to exist.
+	Absent bool
+}
+
+// Put creates the todo with td.ID or replaces its title and completion, for
+// clients that name todos themselves (CalDAV). It reports whether the todo
+// was created and fails with ports.ErrConflict when pre does not hold.
+func (s *Service) Put(ctx context.Context, td domain.Todo, pre Precondition) (_ domain.Todo, _ bool, err error) {
+	ctx, span := startSpan(ctx, "Put", attribute.String("todo.id", td.ID))
+	defer func() { tracing.End(span, err) }()
+
+	if !clientIDPattern.MatchString(td.ID) {
+		return domain.Todo{}, false, &domain.ValidationError{Violations: []domain.FieldViolation{{
+			Field:  "id",
+			Rule:   domain.RuleFormat,
+			Detail: "id must be 1 to 64 letters, digits, '-' or '_'",
+		}}}
+	}
+	if err := td.Check(); err != nil {
+		return domain.Todo{}, false, err
+	}
+	for attempt := 0; ; attempt++ {
+		out, created, err := s.put(ctx, td, pre)
+		// Without a precondition the last writer wins, so a concurrent
+		// write is retried instead of reported.
+		if errors.Is(err, ports.ErrConflict) && pre == (Precondition{}) {
+			if attempt+1 < maxSyncAttempts {
+				continue
+			}
+			logContention(ctx, "put", td.ID)
+		}
+		return out, created, err
+	}
+}
+
+func (s *Service) put(ctx context.Context, td domain.Todo, pre Precondition) (domain.Todo, bool, error) {
+	cur, err := s.repo.Get(ctx, td.ID)
+	if errors.Is(err, ports.ErrNotFound) {
+		if pre.Version != 0 {
+			return domain.Todo{}, false, ports.ErrConflict
+		}
+		td.Owner = owner(ctx)
+		s.stamp(&td, domain.Todo{})
+		if err := s.repo.CreateWithin(ctx, td, s.quota.limit(td.Owner), s.event(ctx, domain.EventTodoCreated)); err != nil {
+			return domain.Todo{}, false, err
+		}
+		td.Version = 1
+		return td, true, nil
+	}
+	if err != nil {
+		return domain.Todo{}, false, err
+	}
+	if pre.Absent || (pre.Version != 0 && pre.Version != cur.Version) {
+		return domain.Todo{}, false, ports.ErrConflict
+	}
+	if len(diff(td, cur)) == 0 {
+		return cur, false, nil
+	}
+
+	event := domain.EventTodoUpdated
+	if td.Completed && !cur.Completed {
+		event = domain.EventTodoCompleted
+	}
+	next := cur
+	next.Title = td.Title
+	next.Completed = td.Completed
+	s.stamp(&next, cur)
+	// cur.Version makes the write fail if the todo changed since it was read.
+	if err := s.repo.Update(ctx, next, s.event(ctx, event)); err != nil {
+		return domain.Todo{}, false, err
+	}
+	next.Version++
+	return next, false, nil
+}
+
+// DeleteIf deletes the todo if it is at version, failing with
+// ports.ErrConflict otherwise. A zero version deletes it unconditionally.
+func (s *Service) DeleteIf(ctx context.Context, id string, version uint64) (err error) {
+	ctx, span := startSpan(ctx, "DeleteIf", attribute.String("todo.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if id == "" {
+		return errors.New("missing id")
+	}
+	return s.repo.Delete(ctx, id, version, s.event(ctx, domain.EventTodoDeleted))
+}
+
+// stamp records the time of a write turning prev into td; prev is the zero
+// Todo for creates.
+func (s *Service) stamp(td *domain.Todo, prev domain.Todo) {
+	now := s.now().UTC()
+	if prev.ID == "" {
+		td.CreatedAt = now
+	}
+	td.UpdatedAt = now
+	switch {
+	case !td.Completed:
+		td.CompletedAt = time.Time{}
+	case !prev.Completed:
+		td.CompletedAt = now
+	}
+}
+
+// event describes a write for the repository to record in its outbox, which
+// fills in the todo as written.
+func (s *Service) event(ctx context.Context, typ domain.EventType) domain.Event {
+	return domain.Event{
+		ID:         s.idGen.NewID(),
+		Type:       typ,
+		Tenant:     tenancy.FromContext(ctx),
+		OccurredAt: s.now().UTC(),
+	}
+}
+
+// logContention warns that a write to a todo kept conflicting with
+// concurrent ones and was given up after maxSyncAttempts.
+func logContention(ctx context.Context, op, id string) {
+	logging.FromContext(ctx).WarnContext(ctx, "todo_write_contended",
+		slog.String("op", op),
+		slog.String("todo_id", id),
+		slog.Int("attempts", maxSyncAttempts),
+	)
+}
+
+// startSpan starts the span of a Service method.
+func startSpan(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("tenant", tenancy.FromContext(ctx)))
+	return tracer.Start(ctx, "todos.Service."+method, trace.WithAttributes(attrs...))
+}
+
+// owner returns the authenticated subject of ctx, if any.
+func owner(ctx context.Context) string {
+	if c, ok := auth.FromContext(ctx); ok {
+		return c.Subject
+	}
+	return ""
 }
@@ -3,10 +3,14 @@ package todos
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"testing"
 
+	"challenge-backend-arancia/internal/auth"
 	"challenge-backend-arancia/internal/domain"
 	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
 )
 
 type fakeIDGen struct{ id string }
@@ -15,13 +19,15 @@ func (f fakeIDGen) NewID() string { return f.id }
 
 type fakeRepo struct {
 	todos   map[string]domain.Todo
+	history map[string]domain.Todo
+	events  []domain.Event
 	creates int
 	updates int
 	deletes int
 }
 
 func newFakeRepo() *fakeRepo {
-	return &fakeRepo{todos: map[string]domain.Todo{}}
+	return &fakeRepo{todos: map[string]domain.Todo{}, history: map[string]domain.Todo{}}
 }
 
 func (r *fakeRepo) List(ctx context.Context) ([]domain.Todo, error) {
@@ -32,6 +38,28 @@ func (r *fakeRepo) List(ctx context.Context) ([]domain.Todo, error) {
 	return out, nil
 }
 
+func (r *fakeRepo) ListAfter(ctx context.Context, afterID string, limit int, match func(domain.Todo) bool) ([]domain.Todo, error) {
+	all, _ := r.List(ctx)
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	out := []domain.Todo{}
+	for _, td := range all {
+		if td.ID > afterID && len(out) < limit && (match == nil || match(td)) {
+			out = append(out, td)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) Count(ctx context.Context, match func(domain.Todo) bool) (int, error) {
+	n := 0
+	for _, td := range r.todos {
+		if match == nil || match(td) {
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (r *fakeRepo) Get(ctx context.Context, id string) (domain.Todo, error) {
 	td, ok := r.todos[id]
 	if !ok {
@@ -40,33 +68,112 @@ func (r *fakeRepo) Get(ctx context.Context, id string) (domain.Todo, error) {
 	return td, nil
 }
 
-func (r *fakeRepo) Create(ctx context.Context, todo domain.Todo) error {
+func (r *fakeRepo) GetVersion(ctx context.Context, id string, version uint64) (domain.Todo, error) {
+	td, ok := r.history[fmt.Sprintf("%s/%d", id, version)]
+	if !ok {
+		return domain.Todo{}, ports.ErrNotFound
+	}
+	return td, nil
+}
+
+func (r *fakeRepo) Create(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
+	return r.CreateWithin(ctx, todo, 0, events...)
+}
+
+func (r *fakeRepo) CreateWithin(ctx context.Context, todo domain.Todo, maxOwned int, events ...domain.Event) error {
 	r.creates++
 	if _, ok := r.todos[todo.ID]; ok {
 		return ports.ErrConflict
 	}
+	if maxOwned > 0 {
+		n := 0
+		for _, td := range r.todos {
+			if td.Owner == todo.Owner {
+				n++
+			}
+		}
+		if n >= maxOwned {
+			return &domain.QuotaError{Resource: "todos", Limit: maxOwned}
+		}
+	}
+	todo.Version = 1
 	r.todos[todo.ID] = todo
+	r.record(todo, events)
 	return nil
 }
 
-func (r *fakeRepo) Update(ctx context.Context, todo domain.Todo) error {
+func (r *fakeRepo) Update(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
 	r.updates++
-	if _, ok := r.todos[todo.ID]; !ok {
+	prev, ok := r.todos[todo.ID]
+	if !ok {
 		return ports.ErrNotFound
 	}
+	if todo.Version != 0 && todo.Version != prev.Version {
+		return ports.ErrConflict
+	}
+	todo.Version = prev.Version + 1
 	r.todos[todo.ID] = todo
+	r.record(todo, events)
 	return nil
 }
 
-func (r *fakeRepo) Delete(ctx context.Context, id string) error {
+func (r *fakeRepo) Delete(ctx context.Context, id string, version uint64, events ...domain.Event) error {
 	r.deletes++
-	if _, ok := r.todos[id]; !ok {
+	prev, ok := r.todos[id]
+	if !ok {
 		return ports.ErrNotFound
 	}
+	if version != 0 && version != prev.Version {
+		return ports.ErrConflict
+	}
 	delete(r.todos, id)
+	prev.Version++
+	r.record(prev, events)
 	return nil
 }
 
+// Changes reports no changes; the feed itself is covered by the boltdb tests.
+func (r *fakeRepo) Changes(ctx context.Context, since uint64, limit int) (domain.ChangeSet, error) {
+	return domain.ChangeSet{Seq: since}, nil
+}
+
+// Children and ReorderChildren know of no subtasks; they are covered by the
+// boltdb tests.
+func (r *fakeRepo) Children(ctx context.Context, parentID string) ([]domain.Todo, error) {
+	if _, ok := r.todos[parentID]; !ok {
+		return nil, ports.ErrNotFound
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) ChildrenOf(ctx context.Context, parentIDs []string) (map[string][]domain.Todo, error) {
+	out := make(map[string][]domain.Todo)
+	for _, id := range parentIDs {
+		if _, ok := r.todos[id]; ok {
+			out[id] = nil
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) ReorderChildren(ctx context.Context, parentID string, ids []string) error {
+	if _, ok := r.todos[parentID]; !ok {
+		return ports.ErrNotFound
+	}
+	if len(ids) > 0 {
+		return ports.ErrConflict
+	}
+	return nil
+}
+
+func (r *fakeRepo) record(todo domain.Todo, events []domain.Event) {
+	r.history[fmt.Sprintf("%s/%d", todo.ID, todo.Version)] = todo
+	for _, ev := range events {
+		ev.Todo = todo
+		r.events = append(r.events, ev)
+	}
+}
+
 func TestService_Create_ValidatesAndPersists(t *testing.T) {
 	t.Parallel()
 
@@ -121,6 +228,55 @@ func TestService_Update_NotFound(t *testing.T) {
 	}
 }
 
+// racingRepo lets another writer update the todo right before the first
+// update of the service.
+type racingRepo struct {
+	*fakeRepo
+	raced bool
+}
+
+func (r *racingRepo) Update(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
+	if !r.raced {
+		r.raced = true
+		other := r.todos[todo.ID]
+		other.Title = "changed elsewhere"
+		if err := r.fakeRepo.Update(ctx, other); err != nil {
+			return err
+		}
+	}
+	return r.fakeRepo.Update(ctx, todo, events...)
+}
+
+func TestService_Edit_RetriesUnlessVersioned(t *testing.T) {
+	t.Parallel()
+
+	repo := &racingRepo{fakeRepo: newFakeRepo()}
+	repo.todos["1"] = domain.Todo{ID: "1", Title: "a", Version: 1}
+	svc, err := NewService(repo, fakeIDGen{id: "id-1"})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	td, err := svc.Edit(context.Background(), "1", Edit{Title: "b"})
+	if err != nil {
+		t.Fatalf("expected a plain edit to win over a concurrent write, got %v", err)
+	}
+	if td.Title != "b" || td.Version != 3 || repo.todos["1"].Title != "b" {
+		t.Fatalf("unexpected todo %+v, stored %+v", td, repo.todos["1"])
+	}
+
+	repo.raced = false
+	if _, err := svc.Edit(context.Background(), "1", Edit{Title: "c", Version: 3}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a versioned edit racing another write, got %v", err)
+	}
+	if _, err := svc.Edit(context.Background(), "1", Edit{Title: "c", Version: 1}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a stale version, got %v", err)
+	}
+	if td, err := svc.Edit(context.Background(), "1", Edit{Title: "c", Version: 4}); err != nil || td.Version != 5 {
+		t.Fatalf("expected the current version to be accepted, got %+v, %v", td, err)
+	}
+}
+
 func TestService_Delete_NotFound(t *testing.T) {
 	t.Parallel()
 
@@ -135,3 +291,144 @@ func TestService_Delete_NotFound(t *testing.T) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
+
+type seqIDGen struct{ n int }
+
+func (g *seqIDGen) NewID() string {
+	g.n++
+	return fmt.Sprintf("id-%d", g.n)
+}
+
+func TestService_Create_EnforcesPerUserQuota(t *testing.T) {
+	t.Parallel()
+
+	repo := newFakeRepo()
+	svc, err := NewService(repo, &seqIDGen{}, WithQuota(Quota{MaxTodos: 1}))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	alice := auth.WithClaims(context.Background(), auth.Claims{Subject: "alice"})
+	bob := auth.WithClaims(context.Background(), auth.Claims{Subject: "bob"})
+
+	if _, err := svc.Create(alice, "first"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := svc.Create(alice, "second"); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := svc.Create(bob, "first"); err != nil {
+		t.Fatalf("expected quota to be per user, got %v", err)
+	}
+	if len(repo.todos) != 2 {
+		t.Fatalf("expected 2 todos, got %d", len(repo.todos))
+	}
+}
+
+func TestService_Create_AnonymousQuota(t *testing.T) {
+	t.Parallel()
+
+	repo := newFakeRepo()
+	svc, err := NewService(repo, &seqIDGen{}, WithQuota(Quota{MaxTodos: 1, MaxAnonymousTodos: 2}))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Create(ctx, "anonymous"); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	if _, err := svc.Create(ctx, "one too many"); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("expected the anonymous callers to share a quota, got %v", err)
+	}
+}
+
+func TestService_RecordsEventsWithWrites(t *testing.T) {
+	t.Parallel()
+
+	repo := newFakeRepo()
+	svc, err := NewService(repo, fakeIDGen{id: "id-1"})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := tenancy.WithID(context.Background(), "acme")
+
+	if _, err := svc.Create(ctx, "buy milk"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := svc.Update(ctx, "id-1", "buy milk", true); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := svc.Delete(ctx, "id-1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := svc.Delete(ctx, "id-1"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	want := []domain.EventType{domain.EventTodoCreated, domain.EventTodoCompleted, domain.EventTodoDeleted}
+	if len(repo.events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), repo.events)
+	}
+	for i, ev := range repo.events {
+		if ev.Type != want[i] || ev.Tenant != "acme" || ev.Todo.ID != "id-1" || ev.ID == "" {
+			t.Fatalf("event %d: unexpected %+v", i, ev)
+		}
+		if ev.Todo.Version != uint64(i+1) {
+			t.Fatalf("event %d: expected version %d, got %d", i, i+1, ev.Todo.Version)
+		}
+	}
+}
+
+func TestService_Put(t *testing.T) {
+	t.Parallel()
+
+	repo := newFakeRepo()
+	svc, err := NewService(repo, fakeIDGen{id: "unused"})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	ctx := auth.WithClaims(context.Background(), auth.Claims{Subject: "alice"})
+
+	td, created, err := svc.Put(ctx, domain.Todo{ID: "a", Title: "buy milk"}, Precondition{Absent: true})
+	if err != nil || !created || td.Version != 1 || td.Owner != "alice" {
+		t.Fatalf("expected created todo at version 1 owned by alice, got %+v created=%v err=%v", td, created, err)
+	}
+	if _, _, err := svc.Put(ctx, domain.Todo{ID: "a", Title: "x"}, Precondition{Absent: true}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for an existing todo, got %v", err)
+	}
+
+	td, created, err = svc.Put(ctx, domain.Todo{ID: "a", Title: "buy milk", Completed: true}, Precondition{Version: 1})
+	if err != nil || created || !td.Completed || td.Version != 2 {
+		t.Fatalf("expected completed todo at version 2, got %+v created=%v err=%v", td, created, err)
+	}
+	if got := repo.events[len(repo.events)-1].Type; got != domain.EventTodoCompleted {
+		t.Fatalf("expected %s event, got %s", domain.EventTodoCompleted, got)
+	}
+	if _, _, err := svc.Put(ctx, domain.Todo{ID: "a", Title: "stale"}, Precondition{Version: 1}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a stale version, got %v", err)
+	}
+
+	// Unchanged todos are not written again.
+	updates := repo.updates
+	if td, _, err := svc.Put(ctx, domain.Todo{ID: "a", Title: "buy milk", Completed: true}, Precondition{}); err != nil || td.Version != 2 {
+		t.Fatalf("expected unchanged todo at version 2, got %+v err=%v", td, err)
+	}
+	if repo.updates != updates {
+		t.Fatalf("expected no write for an unchanged todo")
+	}
+
+	var verr *domain.ValidationError
+	if _, _, err := svc.Put(ctx, domain.Todo{ID: "a/b", Title: "x"}, Precondition{}); !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError for a bad id, got %v", err)
+	}
+
+	if err := svc.DeleteIf(ctx, "a", 1); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a stale delete, got %v", err)
+	}
+	if err := svc.DeleteIf(ctx, "a", 2); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
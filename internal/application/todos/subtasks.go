@@ -0,0 +1,114 @@
+package todos
+
+import (
+	"context"
+	"errors"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// NewTodo describes a todo for Add.
+type NewTodo struct {
+	Title string
+	// ParentID makes the todo the last subtask of an existing one.
+	ParentID     string
+	AutoComplete bool
+}
+
+// Edit replaces the user-editable fields of a todo. A nil AutoComplete
+// leaves it as it is.
+type Edit struct {
+	Title        string
+	Completed    bool
+	AutoComplete *bool
+	// Version, when set, makes the edit fail with ports.ErrConflict unless
+	// the todo is at that version. Without it the last writer wins.
+	Version uint64
+}
+
+// Move makes the todo id the last subtask of parentID, or a top-level todo
+// for an empty parentID. It fails with a *domain.ValidationError matching
+// domain.ErrInvalidParent for moves under its own subtasks or too deep, and
+// with ports.ErrNotFound when either todo does not exist.
+func (s *Service) Move(ctx context.Context, id, parentID string) (_ domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "Move", attribute.String("todo.id", id), attribute.String("todo.parent_id", parentID))
+	defer func() { tracing.End(span, err) }()
+
+	if id == "" {
+		return domain.Todo{}, errors.New("missing id")
+	}
+	for attempt := 0; ; attempt++ {
+		td, err := s.move(ctx, id, parentID)
+		// Moves are not conditional, so a concurrent edit is retried
+		// instead of reported.
+		if errors.Is(err, ports.ErrConflict) {
+			if attempt+1 < maxSyncAttempts {
+				continue
+			}
+			logContention(ctx, "move", id)
+		}
+		return td, err
+	}
+}
+
+func (s *Service) move(ctx context.Context, id, parentID string) (domain.Todo, error) {
+	cur, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	if cur.ParentID == parentID {
+		return cur, nil
+	}
+	next := cur
+	next.ParentID = parentID
+	if err := next.Check(); err != nil {
+		return domain.Todo{}, err
+	}
+	s.stamp(&next, cur)
+	// cur.Version makes the write fail if the todo changed since it was read.
+	if err := s.repo.Update(ctx, next, s.event(ctx, domain.EventTodoUpdated)); err != nil {
+		return domain.Todo{}, err
+	}
+	next.Version++
+	return next, nil
+}
+
+// Subtasks returns the subtasks of the todo id in their order.
+func (s *Service) Subtasks(ctx context.Context, id string) (_ []domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "Subtasks", attribute.String("todo.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if id == "" {
+		return nil, errors.New("missing id")
+	}
+	return s.repo.Children(ctx, id)
+}
+
+// SubtasksOf returns the subtasks of each of the todos ids, read at once.
+// Todos that do not exist are left out.
+func (s *Service) SubtasksOf(ctx context.Context, ids []string) (_ map[string][]domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "SubtasksOf", attribute.Int("todo.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+
+	return s.repo.ChildrenOf(ctx, ids)
+}
+
+// ReorderSubtasks puts the subtasks of the todo id in the order of ids, which
+// must list each of them once, and returns them. Otherwise it fails with
+// ports.ErrConflict: the subtasks changed since the caller listed them.
+func (s *Service) ReorderSubtasks(ctx context.Context, id string, ids []string) (_ []domain.Todo, err error) {
+	ctx, span := startSpan(ctx, "ReorderSubtasks", attribute.String("todo.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	if id == "" {
+		return nil, errors.New("missing id")
+	}
+	if err := s.repo.ReorderChildren(ctx, id, ids); err != nil {
+		return nil, err
+	}
+	return s.repo.Children(ctx, id)
+}
@@ -0,0 +1,66 @@
+package todos
+
+import (
+	"context"
+	"testing"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+func TestService_Move(t *testing.T) {
+	t.Parallel()
+
+	repo := newFakeRepo()
+	repo.todos["p"] = domain.Todo{ID: "p", Title: "parent", Version: 1}
+	repo.todos["c"] = domain.Todo{ID: "c", Title: "child", Version: 1}
+	svc, err := NewService(repo, fakeIDGen{id: "e"})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	moved, err := svc.Move(context.Background(), "c", "p")
+	if err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if moved.ParentID != "p" || moved.Version != 2 || repo.todos["c"].ParentID != "p" {
+		t.Fatalf("expected c under p at version 2, got %+v", moved)
+	}
+	if len(repo.events) != 1 || repo.events[0].Type != domain.EventTodoUpdated {
+		t.Fatalf("expected a todo.updated event, got %+v", repo.events)
+	}
+
+	// Moving under the current parent writes nothing.
+	if _, err := svc.Move(context.Background(), "c", "p"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if repo.updates != 1 {
+		t.Fatalf("expected 1 update call, got %d", repo.updates)
+	}
+}
+
+func TestService_EditKeepsParentAndSetsAutoComplete(t *testing.T) {
+	t.Parallel()
+
+	repo := newFakeRepo()
+	repo.todos["c"] = domain.Todo{ID: "c", Title: "child", ParentID: "p", Version: 1}
+	svc, err := NewService(repo, fakeIDGen{id: "e"})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	on := true
+	td, err := svc.Edit(context.Background(), "c", Edit{Title: "renamed", AutoComplete: &on})
+	if err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if td.ParentID != "p" || !td.AutoComplete || td.Title != "renamed" {
+		t.Fatalf("unexpected todo: %+v", td)
+	}
+	td, err = svc.Update(context.Background(), "c", "again", true)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !td.AutoComplete {
+		t.Fatalf("expected Update to leave AutoComplete set, got %+v", td)
+	}
+}
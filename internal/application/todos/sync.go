@@ -0,0 +1,373 @@
+package todos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tracing"
+)
+
+// MaxSyncMutations bounds the mutations accepted by a single Sync call.
+const MaxSyncMutations = 500
+
+// maxSyncAttempts bounds how often a mutation is re-merged when another write
+// lands between reading the todo and writing it back.
+const maxSyncAttempts = 3
+
+// clientIDPattern restricts the IDs clients may choose for offline creates.
+// Generated IDs (UUIDs) match it too.
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// MutationOp is the kind of write an offline client made.
+type MutationOp string
+
+const (
+	OpCreate MutationOp = "create"
+	OpUpdate MutationOp = "update"
+	OpDelete MutationOp = "delete"
+)
+
+// Mutation is a write a client made offline. BaseVersion is the version of
+// the todo the client edited; it is ignored for creates. Title and Completed
+// are the client's state of the todo and are ignored for deletes.
+type Mutation struct {
+	Op          MutationOp
+	ID          string
+	BaseVersion uint64
+	Title       string
+	Completed   bool
+}
+
+// SyncStatus is the outcome of a single mutation.
+type SyncStatus string
+
+const (
+	// SyncApplied means the mutation was written as sent, or was already in
+	// effect.
+	SyncApplied SyncStatus = "applied"
+	// SyncMerged means the todo changed on the server since the client's base
+	// version and the client's edits were merged with those changes.
+	SyncMerged SyncStatus = "merged"
+	// SyncConflict means both sides changed the same field, or one side
+	// deleted a todo the other edited. Nothing was written.
+	SyncConflict SyncStatus = "conflict"
+	// SyncRejected means the mutation is invalid. Nothing was written.
+	SyncRejected SyncStatus = "rejected"
+)
+
+// Conflict describes both sides of a mutation that could not be merged.
+type Conflict struct {
+	// Fields lists the fields both sides changed to different values. It is
+	// empty when the conflict is a deletion on one side.
+	Fields []string
+	// Base is the version the client started from, nil if it is no longer
+	// retained.
+	Base *domain.Todo
+	// Client is the state the client proposed, nil for deletes.
+	Client *domain.Todo
+	// Server is the current state, nil if the todo was deleted.
+	Server *domain.Todo
+}
+
+// MutationResult reports what Sync did with one mutation.
+type MutationResult struct {
+	ID     string
+	Status SyncStatus
+	// Todo is the todo as stored after the mutation, nil if it does not
+	// exist. Set for applied and merged mutations.
+	Todo     *domain.Todo
+	Conflict *Conflict
+	// Err explains a rejected mutation (a *domain.ValidationError or a
+	// *domain.QuotaError).
+	Err error
+}
+
+// SyncResult is the outcome of Sync: one result per mutation, in order, and
+// the server's changes since the client's sync token.
+type SyncResult struct {
+	Results []MutationResult
+	Changes ChangePage
+}
+
+// Sync applies mutations made offline, merging each with the changes made on
+// the server since the version the client started from, then returns the
+// server's changes since token, including those the mutations made.
+//
+// Updates are merged field by field against the base version: a field only
+// one side changed takes that side's value, so a title edited offline and a
+// completion made online both survive. Fields both sides changed to
+// different values are reported as a conflict and the mutation is not
+// written; the client resolves it and sends a new update based on the server
+// version.
+func (s *Service) Sync(ctx context.Context, token string, mutations []Mutation, limit int) (_ SyncResult, err error) {
+	ctx, span := startSpan(ctx, "Sync")
+	defer func() { tracing.End(span, err) }()
+
+	// Reject a bad token before writing anything.
+	if _, _, err := decodeSyncToken(token); err != nil {
+		return SyncResult{}, invalidSyncToken()
+	}
+	if len(mutations) > MaxSyncMutations {
+		return SyncResult{}, &domain.ValidationError{Violations: []domain.FieldViolation{{
+			Field:  "mutations",
+			Rule:   domain.RuleMaxLength,
+			Detail: fmt.Sprintf("at most %d mutations are allowed per sync", MaxSyncMutations),
+		}}}
+	}
+
+	out := SyncResult{Results: make([]MutationResult, 0, len(mutations))}
+	for _, m := range mutations {
+		res, err := s.apply(ctx, m)
+		if err != nil {
+			return SyncResult{}, err
+		}
+		out.Results = append(out.Results, res)
+	}
+
+	page, err := s.Changes(ctx, token, limit)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	out.Changes = page
+	return out, nil
+}
+
+// apply applies a single mutation, retrying when a concurrent write makes the
+// conditional write fail.
+func (s *Service) apply(ctx context.Context, m Mutation) (MutationResult, error) {
+	if err := validateMutation(m); err != nil {
+		return MutationResult{ID: m.ID, Status: SyncRejected, Err: err}, nil
+	}
+	for attempt := 0; ; attempt++ {
+		var (
+			res MutationResult
+			err error
+		)
+		switch m.Op {
+		case OpCreate:
+			res, err = s.syncCreate(ctx, m)
+		case OpUpdate:
+			res, err = s.syncUpdate(ctx, m)
+		case OpDelete:
+			res, err = s.syncDelete(ctx, m)
+		}
+		if errors.Is(err, ports.ErrConflict) {
+			if attempt+1 < maxSyncAttempts {
+				continue
+			}
+			logContention(ctx, "sync_"+string(m.Op), m.ID)
+		}
+		return res, err
+	}
+}
+
+func (s *Service) syncCreate(ctx context.Context, m Mutation) (MutationResult, error) {
+	td := domain.Todo{ID: m.ID, Title: m.Title, Completed: m.Completed, Owner: owner(ctx)}
+	if td.ID == "" {
+		td.ID = s.idGen.NewID()
+	}
+
+	// A create the client retries after losing the response is applied.
+	server, err := s.repo.Get(ctx, td.ID)
+	switch {
+	case err == nil:
+		if fields := diff(td, server); len(fields) > 0 {
+			return conflict(td.ID, fields, nil, &td, &server), nil
+		}
+		return applied(server, SyncApplied), nil
+	case !errors.Is(err, ports.ErrNotFound):
+		return MutationResult{}, err
+	}
+
+	s.stamp(&td, domain.Todo{})
+	if err := s.repo.CreateWithin(ctx, td, s.quota.limit(td.Owner), s.event(ctx, domain.EventTodoCreated)); err != nil {
+		var quota *domain.QuotaError
+		if errors.As(err, &quota) {
+			return MutationResult{ID: td.ID, Status: SyncRejected, Err: err}, nil
+		}
+		return MutationResult{}, err
+	}
+	td.Version = 1
+	return applied(td, SyncApplied), nil
+}
+
+func (s *Service) syncUpdate(ctx context.Context, m Mutation) (MutationResult, error) {
+	client := domain.Todo{ID: m.ID, Title: m.Title, Completed: m.Completed}
+	base, err := s.base(ctx, m)
+	if err != nil {
+		return MutationResult{}, err
+	}
+	server, err := s.repo.Get(ctx, m.ID)
+	if errors.Is(err, ports.ErrNotFound) {
+		return conflict(m.ID, nil, base, &client, nil), nil
+	}
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if m.BaseVersion > server.Version {
+		return rejectBaseVersion(m), nil
+	}
+
+	merged, fields := merge(base, client, server)
+	if len(fields) > 0 {
+		return conflict(m.ID, fields, base, &client, &server), nil
+	}
+	status := SyncApplied
+	if m.BaseVersion != server.Version {
+		status = SyncMerged
+	}
+	if len(diff(merged, server)) == 0 {
+		return applied(server, status), nil
+	}
+
+	event := domain.EventTodoUpdated
+	if merged.Completed && !server.Completed {
+		event = domain.EventTodoCompleted
+	}
+	s.stamp(&merged, server)
+	if err := s.repo.Update(ctx, merged, s.event(ctx, event)); err != nil {
+		return MutationResult{}, err
+	}
+	merged.Version++
+	return applied(merged, status), nil
+}
+
+func (s *Service) syncDelete(ctx context.Context, m Mutation) (MutationResult, error) {
+	server, err := s.repo.Get(ctx, m.ID)
+	if errors.Is(err, ports.ErrNotFound) {
+		// Already deleted, by this client or another one.
+		return MutationResult{ID: m.ID, Status: SyncApplied}, nil
+	}
+	if err != nil {
+		return MutationResult{}, err
+	}
+	if m.BaseVersion > server.Version {
+		return rejectBaseVersion(m), nil
+	}
+	if m.BaseVersion != server.Version {
+		// The todo was edited since the client saw it; deleting it would
+		// silently drop those edits.
+		base, err := s.base(ctx, m)
+		if err != nil {
+			return MutationResult{}, err
+		}
+		return conflict(m.ID, nil, base, nil, &server), nil
+	}
+	if err := s.repo.Delete(ctx, m.ID, m.BaseVersion, s.event(ctx, domain.EventTodoDeleted)); err != nil {
+		return MutationResult{}, err
+	}
+	return MutationResult{ID: m.ID, Status: SyncApplied}, nil
+}
+
+// base returns the version m was based on, or nil if it is not retained.
+func (s *Service) base(ctx context.Context, m Mutation) (*domain.Todo, error) {
+	td, err := s.repo.GetVersion(ctx, m.ID, m.BaseVersion)
+	if errors.Is(err, ports.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &td, nil
+}
+
+// merge combines the client's and the server's edits of base field by field.
+// It returns the server todo with the merged fields and the fields both sides
+// changed to different values. Without a base every differing field
+// conflicts, since it is unknown which side changed it.
+func merge(base *domain.Todo, client, server domain.Todo) (domain.Todo, []string) {
+	merged := server
+	var conflicts []string
+	if base == nil {
+		return merged, diff(client, server)
+	}
+	switch {
+	case client.Title == base.Title || client.Title == server.Title:
+	case server.Title == base.Title:
+		merged.Title = client.Title
+	default:
+		conflicts = append(conflicts, "title")
+	}
+	switch {
+	case client.Completed == base.Completed || client.Completed == server.Completed:
+	case server.Completed == base.Completed:
+		merged.Completed = client.Completed
+	default:
+		conflicts = append(conflicts, "completed")
+	}
+	return merged, conflicts
+}
+
+// diff lists the user-editable fields in which a and b differ.
+func diff(a, b domain.Todo) []string {
+	var fields []string
+	if a.Title != b.Title {
+		fields = append(fields, "title")
+	}
+	if a.Completed != b.Completed {
+		fields = append(fields, "completed")
+	}
+	return fields
+}
+
+func validateMutation(m Mutation) error {
+	var violations []domain.FieldViolation
+	switch m.Op {
+	case OpCreate:
+		if m.ID != "" && !clientIDPattern.MatchString(m.ID) {
+			violations = append(violations, domain.FieldViolation{
+				Field:  "id",
+				Rule:   domain.RuleFormat,
+				Detail: "id must be 1 to 64 letters, digits, '-' or '_'",
+			})
+		}
+	case OpUpdate, OpDelete:
+		if m.ID == "" {
+			violations = append(violations, domain.FieldViolation{Field: "id", Rule: domain.RuleRequired, Detail: "id must not be empty"})
+		}
+		if m.BaseVersion == 0 {
+			violations = append(violations, domain.FieldViolation{Field: "base_version", Rule: domain.RuleRequired, Detail: "base_version must not be empty"})
+		}
+	default:
+		violations = append(violations, domain.FieldViolation{
+			Field:  "op",
+			Rule:   domain.RuleFormat,
+			Detail: "op must be one of create, update or delete",
+		})
+	}
+	if m.Op == OpCreate || m.Op == OpUpdate {
+		var verr *domain.ValidationError
+		if err := (domain.Todo{Title: m.Title}).Check(); errors.As(err, &verr) {
+			violations = append(violations, verr.Violations...)
+		}
+	}
+	if len(violations) > 0 {
+		return &domain.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+func applied(td domain.Todo, status SyncStatus) MutationResult {
+	return MutationResult{ID: td.ID, Status: status, Todo: &td}
+}
+
+func conflict(id string, fields []string, base, client, server *domain.Todo) MutationResult {
+	return MutationResult{ID: id, Status: SyncConflict, Conflict: &Conflict{
+		Fields: fields,
+		Base:   base,
+		Client: client,
+		Server: server,
+	}}
+}
+
+func rejectBaseVersion(m Mutation) MutationResult {
+	return MutationResult{ID: m.ID, Status: SyncRejected, Err: &domain.ValidationError{Violations: []domain.FieldViolation{{
+		Field:  "base_version",
+		Rule:   domain.RuleFormat,
+		Detail: "base_version is newer than the stored version",
+	}}}}
+}
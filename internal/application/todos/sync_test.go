@@ -0,0 +1,142 @@
+package todos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// syncNow is the clock of the service newSyncFixture returns.
+var syncNow = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newSyncFixture returns a service whose repo holds todo "t1" at version 2:
+// created as "buy milk", then retitled "buy oat milk" online.
+
+func newSyncFixture(t *testing.T) (*Service, *fakeRepo) {
+	t.Helper()
+
+	repo := newFakeRepo()
+	svc, err := NewService(repo, &seqIDGen{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	svc.now = func() time.Time { return syncNow }
+	ctx := context.Background()
+	if err := repo.Create(ctx, domain.Todo{ID: "t1", Title: "buy milk"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := repo.Update(ctx, domain.Todo{ID: "t1", Title: "buy oat milk"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	return svc, repo
+}
+
+func TestService_Sync_MergesDisjointFieldEdits(t *testing.T) {
+	t.Parallel()
+
+	svc, repo := newSyncFixture(t)
+	ctx := context.Background()
+
+	// Offline, the client completed version 1 without touching the title.
+	res, err := svc.Sync(ctx, "", []Mutation{{Op: OpUpdate, ID: "t1", BaseVersion: 1, Title: "buy milk", Completed: true}}, 0)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	got := res.Results[0]
+	if got.Status != SyncMerged || got.Todo == nil {
+		t.Fatalf("expected merged result, got %+v", got)
+	}
+	want := domain.Todo{ID: "t1", Title: "buy oat milk", Completed: true, Version: 3, UpdatedAt: syncNow, CompletedAt: syncNow}
+	if *got.Todo != want || repo.todos["t1"] != want {
+		t.Fatalf("expected %+v stored and returned, got %+v and %+v", want, *got.Todo, repo.todos["t1"])
+	}
+	if ev := repo.events[len(repo.events)-1]; ev.Type != domain.EventTodoCompleted {
+		t.Fatalf("expected a completed event, got %s", ev.Type)
+	}
+}
+
+func TestService_Sync_ReportsConflictingEdits(t *testing.T) {
+	t.Parallel()
+
+	svc, repo := newSyncFixture(t)
+	ctx := context.Background()
+
+	res, err := svc.Sync(ctx, "", []Mutation{
+		{Op: OpUpdate, ID: "t1", BaseVersion: 1, Title: "buy soy milk"},
+		{Op: OpDelete, ID: "t1", BaseVersion: 1},
+	}, 0)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	edit := res.Results[0]
+	if edit.Status != SyncConflict || edit.Conflict == nil {
+		t.Fatalf("expected conflict, got %+v", edit)
+	}
+	c := edit.Conflict
+	if !reflect.DeepEqual(c.Fields, []string{"title"}) {
+		t.Fatalf("expected title to conflict, got %v", c.Fields)
+	}
+	if c.Base == nil || c.Base.Title != "buy milk" || c.Client.Title != "buy soy milk" || c.Server.Title != "buy oat milk" {
+		t.Fatalf("expected base, client and server versions, got %+v %+v %+v", c.Base, c.Client, c.Server)
+	}
+
+	// Deleting a todo edited since the client saw it would lose the edit.
+	if del := res.Results[1]; del.Status != SyncConflict || del.Conflict.Server == nil {
+		t.Fatalf("expected delete conflict, got %+v", del)
+	}
+	if td := repo.todos["t1"]; td.Version != 2 {
+		t.Fatalf("expected conflicts to leave the todo unchanged, got %+v", td)
+	}
+}
+
+func TestService_Sync_AppliesCreatesAndDeletesIdempotently(t *testing.T) {
+	t.Parallel()
+
+	svc, repo := newSyncFixture(t)
+	ctx := context.Background()
+
+	muts := []Mutation{
+		{Op: OpCreate, ID: "offline-1", Title: "call mom"},
+		{Op: OpDelete, ID: "t1", BaseVersion: 2},
+		{Op: OpCreate, ID: "bad/id", Title: "x"},
+	}
+	for i := 0; i < 2; i++ {
+		res, err := svc.Sync(ctx, "", muts, 0)
+		if err != nil {
+			t.Fatalf("sync %d: %v", i+1, err)
+		}
+		if res.Results[0].Status != SyncApplied || res.Results[0].Todo.ID != "offline-1" {
+			t.Fatalf("sync %d: expected create applied, got %+v", i+1, res.Results[0])
+		}
+		if res.Results[1].Status != SyncApplied {
+			t.Fatalf("sync %d: expected delete applied, got %+v", i+1, res.Results[1])
+		}
+		if r := res.Results[2]; r.Status != SyncRejected || !errors.As(r.Err, new(*domain.ValidationError)) {
+			t.Fatalf("sync %d: expected invalid id to be rejected, got %+v", i+1, r)
+		}
+	}
+	if repo.creates != 2 || repo.deletes != 1 {
+		t.Fatalf("expected retries to write nothing, got %d creates and %d deletes", repo.creates, repo.deletes)
+	}
+	if _, ok := repo.todos["t1"]; ok {
+		t.Fatalf("expected t1 to be deleted")
+	}
+}
+
+func TestService_Sync_RejectsInvalidTokenBeforeWriting(t *testing.T) {
+	t.Parallel()
+
+	svc, repo := newSyncFixture(t)
+	_, err := svc.Sync(context.Background(), "bogus", []Mutation{{Op: OpCreate, Title: "x"}}, 0)
+	if !errors.Is(err, ErrInvalidSyncToken) {
+		t.Fatalf("expected ErrInvalidSyncToken, got %v", err)
+	}
+	if repo.creates != 1 {
+		t.Fatalf("expected no writes, got %d creates", repo.creates)
+	}
+}
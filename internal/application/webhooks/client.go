@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned by the clients of NewHTTPClient for
+// addresses deliveries may not reach.
+var ErrForbiddenAddress = errors.New("webhook address not allowed")
+
+// nonPublicPrefixes are the ranges, besides those netip.Addr classifies,
+// that are not routable on the internet.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// NewHTTPClient returns a client to send deliveries with. Unless allowPrivate
+// is set, it refuses to connect to loopback, private, link-local (cloud
+// metadata endpoints among them) and other non-public addresses, so webhooks
+// cannot reach the services next to this one. The check runs on the address
+// dialed, after DNS resolution and for every redirect, so names resolving to
+// such addresses are refused too, however often they change.
+func NewHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = dialPublicOnly
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: it would connect to the receiver instead, unchecked.
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	return nil
+}
+
+// isPublic reports whether ip is a unicast address routable on the internet.
+func isPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
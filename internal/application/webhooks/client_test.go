@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient_RefusesPrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	t.Cleanup(srv.Close)
+
+	_, err := NewHTTPClient(time.Second, false).Get(srv.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("expected ErrForbiddenAddress for %s, got %v", srv.URL, err)
+	}
+
+	resp, err := NewHTTPClient(time.Second, true).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected private addresses allowed, got %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestIsPublic(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]bool{
+		"93.184.216.34":      true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"fe80::1":            false,
+		"fd00:ec2::254":      false,
+		"0.0.0.0":            false,
+		"100.64.0.1":         false,
+		"224.0.0.1":          false,
+		"::ffff:127.0.0.1":   false,
+		"64:ff9b::a9fe:a9fe": false,
+		"255.255.255.255":    false,
+	}
+	for addr, want := range cases {
+		if got := isPublic(netip.MustParseAddr(addr)); got != want {
+			t.Fatalf("%s: expected public=%v, got %v", addr, want, got)
+		}
+	}
+}
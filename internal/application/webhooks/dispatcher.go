@@ -0,0 +1,212 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/health"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+// RetryPolicy controls how failed deliveries are retried. The delay before
+// attempt n+1 is BaseDelay*2^(n-1), capped at MaxDelay; after MaxAttempts
+// failures the delivery is dead-lettered.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 8, BaseDelay: 10 * time.Second, MaxDelay: time.Hour}
+}
+
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempts && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+const (
+	// dispatchBatch bounds how many due deliveries one pass picks up.
+	dispatchBatch = 50
+	// dispatchConcurrency bounds parallel requests so one slow receiver does not
+	// hold up the others.
+	dispatchConcurrency = 4
+)
+
+// Dispatcher sends queued deliveries and records the outcome of every attempt.
+type Dispatcher struct {
+	hooks      ports.WebhookRepository
+	deliveries ports.DeliveryRepository
+	client     *http.Client
+	policy     RetryPolicy
+	logger     *slog.Logger
+	now        func() time.Time
+	pass       health.Heartbeat
+}
+
+func NewDispatcher(hooks ports.WebhookRepository, deliveries ports.DeliveryRepository, client *http.Client, policy RetryPolicy, logger *slog.Logger) (*Dispatcher, error) {
+	if hooks == nil {
+		return nil, errors.New("nil webhook repo")
+	}
+	if deliveries == nil {
+		return nil, errors.New("nil delivery repo")
+	}
+	if client == nil {
+		return nil, errors.New("nil http client")
+	}
+	if policy.MaxAttempts < 1 {
+		return nil, errors.New("max attempts must be positive")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Dispatcher{
+		hooks:      hooks,
+		deliveries: deliveries,
+		client:     client,
+		policy:     policy,
+		logger:     logger,
+		now:        time.Now,
+	}, nil
+}
+
+// Run dispatches due deliveries every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := d.DispatchDue(ctx)
+		if ctx.Err() == nil {
+			d.pass.Beat(err)
+		}
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error("webhook_dispatch_failed", slog.String("error", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastPass returns when the last pass of Run ended and its error, for a
+// health.Fresh check.
+func (d *Dispatcher) LastPass() (time.Time, error) {
+	return d.pass.Last()
+}
+
+// DispatchDue makes one attempt for every delivery that is due and returns how
+// many were attempted.
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	due, err := d.deliveries.Due(ctx, d.now(), dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, dispatchConcurrency)
+	)
+	for _, del := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(del domain.Delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := d.dispatch(ctx, del); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(del)
+	}
+	wg.Wait()
+	return len(due), firstErr
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, del domain.Delivery) error {
+	ctx = tenancy.WithID(ctx, del.Tenant)
+	hook, err := d.hooks.Get(ctx, del.WebhookID)
+	if errors.Is(err, ports.ErrNotFound) {
+		// Deleted while the delivery was in flight; the delete removed it too.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	attempt := d.send(ctx, hook, del)
+	del.Attempts = append(del.Attempts, attempt)
+	switch {
+	case attempt.Error == "":
+		del.Status = domain.DeliverySucceeded
+		del.Failures = 0
+	case del.Failures+1 >= d.policy.MaxAttempts:
+		del.Status = domain.DeliveryDead
+		del.Failures++
+		d.logger.Warn("webhook_delivery_dead",
+			slog.String("tenant", del.Tenant),
+			slog.String("webhook_id", del.WebhookID),
+			slog.String("delivery_id", del.ID),
+			slog.String("error", attempt.Error),
+		)
+	default:
+		del.Failures++
+		del.NextAttemptAt = attempt.At.Add(d.policy.backoff(del.Failures))
+	}
+
+	if err := d.deliveries.Update(ctx, del); err != nil && !errors.Is(err, ports.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, hook domain.Webhook, del domain.Delivery) (attempt domain.DeliveryAttempt) {
+	start := d.now()
+	attempt.At = start.UTC()
+	defer func() { attempt.Duration = d.now().Sub(start) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-api-webhooks/1")
+	req.Header.Set(HeaderEvent, string(del.EventType))
+	req.Header.Set(HeaderDelivery, del.ID)
+	req.Header.Set(HeaderTimestamp, fmt.Sprint(start.Unix()))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, start, del.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return attempt
+}
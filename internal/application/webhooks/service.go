@@ -0,0 +1,194 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+// payload is the JSON body of every delivery.
+type payload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Tenant     string      `json:"tenant"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Todo       payloadTodo `json:"todo"`
+}
+
+type payloadTodo struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	Version   uint64 `json:"version"`
+}
+
+// Service manages webhook subscriptions and turns domain events into queued
+// deliveries. Sending is done by Dispatcher.
+type Service struct {
+	hooks      ports.WebhookRepository
+	deliveries ports.DeliveryRepository
+	idGen      ports.IDGenerator
+	now        func() time.Time
+}
+
+func NewService(hooks ports.WebhookRepository, deliveries ports.DeliveryRepository, idGen ports.IDGenerator) (*Service, error) {
+	if hooks == nil {
+		return nil, errors.New("nil webhook repo")
+	}
+	if deliveries == nil {
+		return nil, errors.New("nil delivery repo")
+	}
+	if idGen == nil {
+		return nil, errors.New("nil id generator")
+	}
+	return &Service{hooks: hooks, deliveries: deliveries, idGen: idGen, now: time.Now}, nil
+}
+
+func (s *Service) List(ctx context.Context) ([]domain.Webhook, error) {
+	return s.hooks.List(ctx)
+}
+
+func (s *Service) Get(ctx context.Context, id string) (domain.Webhook, error) {
+	return s.hooks.Get(ctx, id)
+}
+
+// Create subscribes url to the given event types. An empty secret is replaced
+// by a random one; callers must hand it to the subscriber as it is the only
+// way to verify signatures.
+func (s *Service) Create(ctx context.Context, url string, events []domain.EventType, secret string) (domain.Webhook, error) {
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return domain.Webhook{}, err
+		}
+	}
+	w := domain.Webhook{
+		ID:        s.idGen.NewID(),
+		URL:       url,
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: s.now().UTC(),
+	}
+	if err := w.Validate(); err != nil {
+		return domain.Webhook{}, err
+	}
+	if err := s.hooks.Create(ctx, w); err != nil {
+		return domain.Webhook{}, err
+	}
+	return w, nil
+}
+
+func (s *Service) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("missing id")
+	}
+	return s.hooks.Delete(ctx, id)
+}
+
+// Deliveries returns the delivery log of a webhook, optionally filtered by
+// status (DeliveryDead lists the dead letters).
+func (s *Service) Deliveries(ctx context.Context, webhookID string, status domain.DeliveryStatus) ([]domain.Delivery, error) {
+	if _, err := s.hooks.Get(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	all, err := s.deliveries.List(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if status == "" {
+		return all, nil
+	}
+	out := make([]domain.Delivery, 0, len(all))
+	for _, d := range all {
+		if d.Status == status {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// Redeliver queues a delivery for an immediate new attempt with a fresh retry
+// budget, whatever its status. Its attempt log is kept.
+func (s *Service) Redeliver(ctx context.Context, webhookID, id string) (domain.Delivery, error) {
+	d, err := s.deliveries.Get(ctx, webhookID, id)
+	if err != nil {
+		return domain.Delivery{}, err
+	}
+	d.Status = domain.DeliveryPending
+	d.Failures = 0
+	d.NextAttemptAt = s.now().UTC()
+	if err := s.deliveries.Update(ctx, d); err != nil {
+		return domain.Delivery{}, err
+	}
+	return d, nil
+}
+
+// Publish queues a delivery of ev for every webhook of its tenant subscribed
+// to its type. It implements ports.EventPublisher.
+func (s *Service) Publish(ctx context.Context, ev domain.Event) error {
+	ctx = tenancy.WithID(ctx, ev.Tenant)
+	hooks, err := s.hooks.List(ctx)
+	if errors.Is(err, ports.ErrUnknownTenant) {
+		// Deprovisioned since the event was recorded; nobody to notify.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := s.now().UTC()
+	var out []domain.Delivery
+	for _, w := range hooks {
+		if !w.Wants(ev.Type) {
+			continue
+		}
+		// Reusing the event ID makes a redelivered event (the outbox relay
+		// is at-least-once) collide with the deliveries it already queued.
+		id := ev.ID
+		if id == "" {
+			id = s.idGen.NewID()
+		}
+		body, err := json.Marshal(payload{
+			ID:         id,
+			Type:       string(ev.Type),
+			Tenant:     ev.Tenant,
+			OccurredAt: ev.OccurredAt,
+			Todo:       payloadTodo{ID: ev.Todo.ID, Title: ev.Todo.Title, Completed: ev.Todo.Completed, Version: ev.Todo.Version},
+		})
+		if err != nil {
+			return err
+		}
+		out = append(out, domain.Delivery{
+			ID:            id,
+			WebhookID:     w.ID,
+			EventType:     ev.Type,
+			Payload:       body,
+			Status:        domain.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	err = s.deliveries.Create(ctx, out...)
+	if errors.Is(err, ports.ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
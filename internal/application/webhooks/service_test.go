@@ -0,0 +1,301 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+type seqIDGen struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (g *seqIDGen) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n++
+	return fmt.Sprintf("id-%d", g.n)
+}
+
+type fakeHooks struct {
+	hooks map[string]domain.Webhook
+}
+
+func (r *fakeHooks) List(ctx context.Context) ([]domain.Webhook, error) {
+	out := make([]domain.Webhook, 0, len(r.hooks))
+	for _, w := range r.hooks {
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+func (r *fakeHooks) Get(ctx context.Context, id string) (domain.Webhook, error) {
+	w, ok := r.hooks[id]
+	if !ok {
+		return domain.Webhook{}, ports.ErrNotFound
+	}
+	return w, nil
+}
+
+func (r *fakeHooks) Create(ctx context.Context, w domain.Webhook) error {
+	r.hooks[w.ID] = w
+	return nil
+}
+
+func (r *fakeHooks) Delete(ctx context.Context, id string) error {
+	delete(r.hooks, id)
+	return nil
+}
+
+type fakeDeliveries struct {
+	mu         sync.Mutex
+	deliveries map[string]domain.Delivery
+}
+
+func (r *fakeDeliveries) Create(ctx context.Context, deliveries ...domain.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range deliveries {
+		if _, ok := r.deliveries[d.ID]; ok {
+			return ports.ErrConflict
+		}
+	}
+	for _, d := range deliveries {
+		r.deliveries[d.ID] = d
+	}
+	return nil
+}
+
+func (r *fakeDeliveries) Get(ctx context.Context, webhookID, id string) (domain.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok || d.WebhookID != webhookID {
+		return domain.Delivery{}, ports.ErrNotFound
+	}
+	return d, nil
+}
+
+func (r *fakeDeliveries) List(ctx context.Context, webhookID string) ([]domain.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Delivery
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (r *fakeDeliveries) Update(ctx context.Context, d domain.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[d.ID] = d
+	return nil
+}
+
+func (r *fakeDeliveries) Due(ctx context.Context, now time.Time, limit int) ([]domain.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Delivery
+	for _, d := range r.deliveries {
+		if d.Status == domain.DeliveryPending && !d.NextAttemptAt.After(now) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+type fixture struct {
+	svc        *Service
+	dispatcher *Dispatcher
+	deliveries *fakeDeliveries
+	now        time.Time
+}
+
+func newFixture(t *testing.T, policy RetryPolicy) *fixture {
+	t.Helper()
+
+	hooks := &fakeHooks{hooks: map[string]domain.Webhook{}}
+	deliveries := &fakeDeliveries{deliveries: map[string]domain.Delivery{}}
+	svc, err := NewService(hooks, deliveries, &seqIDGen{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	d, err := NewDispatcher(hooks, deliveries, http.DefaultClient, policy, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new dispatcher: %v", err)
+	}
+	f := &fixture{svc: svc, dispatcher: d, deliveries: deliveries, now: time.Now()}
+	svc.now = func() time.Time { return f.now }
+	d.now = func() time.Time { return f.now }
+	return f
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	t.Parallel()
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header, body: body}
+	}))
+	t.Cleanup(receiver.Close)
+
+	f := newFixture(t, DefaultRetryPolicy())
+	ctx := context.Background()
+	hook, err := f.svc.Create(ctx, receiver.URL, []domain.EventType{domain.EventTodoCreated}, "")
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	if hook.Secret == "" {
+		t.Fatalf("expected a generated secret")
+	}
+
+	if err := f.svc.Publish(ctx, domain.Event{Type: domain.EventTodoUpdated, Tenant: "default"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := f.svc.Publish(ctx, domain.Event{Type: domain.EventTodoCreated, Tenant: "default", Todo: domain.Todo{ID: "t1", Title: "x"}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if n, err := f.dispatcher.DispatchDue(ctx); err != nil || n != 1 {
+		t.Fatalf("expected only the subscribed event to be delivered, got %d, %v", n, err)
+	}
+
+	req := <-got
+	if !Verify(hook.Secret, req.header.Get(HeaderSignature), req.body, time.Now(), time.Minute) {
+		t.Fatalf("signature %q does not verify", req.header.Get(HeaderSignature))
+	}
+	if req.header.Get(HeaderEvent) != string(domain.EventTodoCreated) {
+		t.Fatalf("expected event header, got %q", req.header.Get(HeaderEvent))
+	}
+	var body struct {
+		ID   string `json:"id"`
+		Todo struct {
+			ID string `json:"id"`
+		} `json:"todo"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	if body.ID != req.header.Get(HeaderDelivery) || body.Todo.ID != "t1" {
+		t.Fatalf("unexpected payload %s", req.body)
+	}
+
+	list, err := f.svc.Deliveries(ctx, hook.ID, domain.DeliverySucceeded)
+	if err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	if len(list) != 1 || len(list[0].Attempts) != 1 || list[0].Attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("expected one successful attempt, got %+v", list)
+	}
+}
+
+func TestDispatcher_RetriesThenDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(receiver.Close)
+
+	f := newFixture(t, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute})
+	ctx := context.Background()
+	hook, err := f.svc.Create(ctx, receiver.URL, []domain.EventType{domain.EventTodoCreated}, "s")
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	if err := f.svc.Publish(ctx, domain.Event{Type: domain.EventTodoCreated, Tenant: "default"}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for i, wait := range []time.Duration{time.Second, 2 * time.Second} {
+		if n, _ := f.dispatcher.DispatchDue(ctx); n != 1 {
+			t.Fatalf("attempt %d: expected one delivery, got %d", i+1, n)
+		}
+		if n, _ := f.dispatcher.DispatchDue(ctx); n != 0 {
+			t.Fatalf("attempt %d: expected backoff before retrying", i+1)
+		}
+		f.now = f.now.Add(wait)
+	}
+	if n, _ := f.dispatcher.DispatchDue(ctx); n != 1 {
+		t.Fatalf("expected final attempt")
+	}
+
+	dead, err := f.svc.Deliveries(ctx, hook.ID, domain.DeliveryDead)
+	if err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	if len(dead) != 1 || len(dead[0].Attempts) != 3 {
+		t.Fatalf("expected one dead delivery with 3 attempts, got %+v", dead)
+	}
+
+	d, err := f.svc.Redeliver(ctx, hook.ID, dead[0].ID)
+	if err != nil {
+		t.Fatalf("redeliver: %v", err)
+	}
+	if d.Status != domain.DeliveryPending || d.Failures != 0 {
+		t.Fatalf("expected pending delivery with a fresh budget, got %+v", d)
+	}
+	if n, _ := f.dispatcher.DispatchDue(ctx); n != 1 {
+		t.Fatalf("expected redelivery to be attempted")
+	}
+}
+
+func TestService_Publish_IgnoresRedeliveredEvents(t *testing.T) {
+	t.Parallel()
+
+	f := newFixture(t, DefaultRetryPolicy())
+	ctx := context.Background()
+	if _, err := f.svc.Create(ctx, "http://example.test", []domain.EventType{domain.EventTodoCreated}, "s"); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	ev := domain.Event{ID: "ev-1", Type: domain.EventTodoCreated, Tenant: "default"}
+	for i := 0; i < 2; i++ {
+		if err := f.svc.Publish(ctx, ev); err != nil {
+			t.Fatalf("publish %d: %v", i+1, err)
+		}
+	}
+	if n := len(f.deliveries.deliveries); n != 1 {
+		t.Fatalf("expected one delivery, got %d", n)
+	}
+}
+
+func TestVerify_RejectsTamperingAndReplays(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+	sig := Sign("secret", ts, body)
+
+	if !Verify("secret", sig, body, ts.Add(time.Minute), 5*time.Minute) {
+		t.Fatalf("expected signature to verify")
+	}
+	if Verify("other", sig, body, ts, 5*time.Minute) {
+		t.Fatalf("expected wrong secret to fail")
+	}
+	if Verify("secret", sig, []byte(`{"id":"2"}`), ts, 5*time.Minute) {
+		t.Fatalf("expected tampered body to fail")
+	}
+	if Verify("secret", sig, body, ts.Add(time.Hour), 5*time.Minute) {
+		t.Fatalf("expected stale signature to fail")
+	}
+}
@@ -0,0 +1,19 @@
+package auth
+
+import "crypto/x509"
+
+// ClaimsFromCertificate maps a verified TLS client certificate to the claims
+// of its holder. The subject is the common name or, for certificates without
+// one, the first URI SAN such as a SPIFFE ID; the tenant is the first
+// organization (O), if any. It reports false when the certificate names no
+// subject.
+func ClaimsFromCertificate(cert *x509.Certificate) (Claims, bool) {
+	c := Claims{Subject: cert.Subject.CommonName}
+	if c.Subject == "" && len(cert.URIs) > 0 {
+		c.Subject = cert.URIs[0].String()
+	}
+	if len(cert.Subject.Organization) > 0 {
+		c.Tenant = cert.Subject.Organization[0]
+	}
+	return c, c.Subject != ""
+}
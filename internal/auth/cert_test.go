@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+)
+
+func TestClaimsFromCertificate(t *testing.T) {
+	t.Parallel()
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"acme"}}}
+	if c, ok := ClaimsFromCertificate(cert); !ok || c.Subject != "billing" || c.Tenant != "acme" {
+		t.Fatalf("unexpected claims: %+v, %v", c, ok)
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.org/ns/prod/sa/billing")
+	cert = &x509.Certificate{URIs: []*url.URL{spiffe}}
+	if c, ok := ClaimsFromCertificate(cert); !ok || c.Subject != spiffe.String() || c.Tenant != "" {
+		t.Fatalf("expected the URI SAN as subject, got %+v", c)
+	}
+
+	if _, ok := ClaimsFromCertificate(&x509.Certificate{}); ok {
+		t.Fatalf("expected a certificate without a subject to be rejected")
+	}
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifier_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	v, err := NewVerifier([]byte("s3cret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	tok, err := v.Sign(Claims{Subject: "alice", Tenant: "acme"})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	got, err := v.Verify(tok)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got.Subject != "alice" || got.Tenant != "acme" {
+		t.Fatalf("unexpected claims: %+v", got)
+	}
+}
+
+func TestVerifier_RejectsTamperedAndExpired(t *testing.T) {
+	t.Parallel()
+
+	v, _ := NewVerifier([]byte("s3cret"))
+	other, _ := NewVerifier([]byte("other"))
+
+	forged, _ := other.Sign(Claims{Subject: "mallory"})
+	if _, err := v.Verify(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for foreign signature, got %v", err)
+	}
+
+	expired, _ := v.Sign(Claims{Subject: "alice", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	if _, err := v.Verify(expired); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for expired token, got %v", err)
+	}
+
+	if _, err := v.Verify("not-a-token"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for garbage, got %v", err)
+	}
+}
@@ -0,0 +1,644 @@
+// Package caldav serves the todos of a tenant as a CalDAV task list (RFC 4791)
+// so calendar and reminders apps can sync them. Each todo is a VTODO stored at
+// <uid>.ics in the collection. The UID is the todo ID, except for todos
+// created by clients under UIDs that are not valid IDs (such as
+// "uuid@host"): those keep the UID and get an ID derived from it (see
+// todoID). Writes go through todos.Service, so they are validated, versioned
+// and emit events like those of the other APIs. Authenticated users see the
+// todos they own; without authentication the collection holds every todo of
+// the tenant.
+//
+// The server exposes a fixed hierarchy under its prefix:
+//
+//	/               service root
+//	/principal/     the current user (current-user-principal)
+//	/calendars/     calendar home (calendar-home-set)
+//	/calendars/todos/         the task list
+//	/calendars/todos/<id>.ics a todo
+package caldav
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+// DefaultPrefix is the path the handler is mounted at unless Options.Prefix
+// says otherwise.
+const DefaultPrefix = "/dav"
+
+const (
+	// maxBody bounds request bodies; a todo is far smaller.
+	maxBody = 64 << 10
+
+	// syncTokenPrefix turns change feed tokens into the URIs WebDAV sync
+	// tokens must be (RFC 6578, section 3.2).
+	syncTokenPrefix = "urn:x-todos:sync:"
+
+	// listPage is the number of todos read at a time to list the collection.
+	listPage = 500
+
+	collectionName = "todos"
+	displayName    = "Todos"
+	itemType       = "text/calendar; charset=utf-8; component=VTODO"
+)
+
+type Options struct {
+	TodoService *todos.Service
+
+	// Prefix is the path the handler is mounted at, without a trailing
+	// slash. Empty uses DefaultPrefix.
+	Prefix string
+}
+
+type handler struct {
+	svc    *todos.Service
+	prefix string
+	now    func() time.Time
+}
+
+// NewHandler returns the CalDAV server. Requests must already be scoped to a
+// tenant (and authenticated) like those of the HTTP API.
+func NewHandler(opts Options) (http.Handler, error) {
+	if opts.TodoService == nil {
+		return nil, errors.New("nil todo service")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	return &handler{svc: opts.TodoService, prefix: strings.TrimSuffix(opts.Prefix, "/"), now: time.Now}, nil
+}
+
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindPrincipal
+	kindHome
+	kindCollection
+	kindItem
+)
+
+// resource is a node of the hierarchy a response describes.
+type resource struct {
+	kind kind
+	// todo is set for items.
+	todo domain.Todo
+	// token is the sync token of the collection.
+	token string
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	k, name, ok := h.route(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	id := todoID(name)
+	allow := "OPTIONS, PROPFIND"
+	switch k {
+	case kindCollection:
+		allow += ", REPORT"
+	case kindItem:
+		allow += ", GET, HEAD, PUT, DELETE"
+	}
+
+	switch {
+	case r.Method == http.MethodOptions:
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == "PROPFIND":
+		h.propfind(w, r, k, id)
+	case r.Method == "REPORT" && k == kindCollection:
+		h.report(w, r)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && k == kindItem:
+		h.get(w, r, id)
+	case r.Method == http.MethodPut && k == kindItem:
+		h.put(w, r, name)
+	case r.Method == http.MethodDelete && k == kindItem:
+		h.delete(w, r, id)
+	default:
+		w.Header().Set("Allow", allow)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// route maps a request path to a resource of the hierarchy. For items it
+// also returns the resource name without ".ics", the UID of the todo.
+func (h *handler) route(p string) (kind, string, bool) {
+	rel, ok := strings.CutPrefix(p, h.prefix)
+	if !ok {
+		return 0, "", false
+	}
+	switch strings.TrimSuffix(rel, "/") {
+	case "":
+		return kindRoot, "", true
+	case "/principal":
+		return kindPrincipal, "", true
+	case "/calendars":
+		return kindHome, "", true
+	case "/calendars/" + collectionName:
+		return kindCollection, "", true
+	}
+	name, ok := strings.CutPrefix(rel, "/calendars/"+collectionName+"/")
+	if !ok || strings.Contains(name, "/") {
+		return 0, "", false
+	}
+	uid, ok := strings.CutSuffix(name, ".ics")
+	if !ok || uid == "" {
+		return 0, "", false
+	}
+	return kindItem, uid, true
+}
+
+// plainUID matches the UIDs that serve as todo IDs as they are.
+var plainUID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// todoID returns the ID of the todo with the given UID. UIDs that are not
+// valid IDs are hashed; the todo then keeps its UID in domain.Todo.UID.
+func todoID(uid string) string {
+	if plainUID.MatchString(uid) {
+		return uid
+	}
+	sum := sha256.Sum256([]byte(uid))
+	return "ical-" + hex.EncodeToString(sum[:16])
+}
+
+func (h *handler) href(res resource) string {
+	switch res.kind {
+	case kindPrincipal:
+		return h.prefix + "/principal/"
+	case kindHome:
+		return h.prefix + "/calendars/"
+	case kindCollection:
+		return h.prefix + "/calendars/" + collectionName + "/"
+	case kindItem:
+		return h.itemHref(res.todo)
+	}
+	return h.prefix + "/"
+}
+
+func (h *handler) itemHref(td domain.Todo) string {
+	return h.prefix + "/calendars/" + collectionName + "/" + url.PathEscape(icalUID(td)) + ".ics"
+}
+
+func (h *handler) propfind(w http.ResponseWriter, r *http.Request, k kind, id string) {
+	var req propfind
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, "invalid PROPFIND body", http.StatusBadRequest)
+		return
+	}
+	// Depth: infinity is served as 1, the hierarchy is that shallow anyway.
+	children := r.Header.Get("Depth") != "0"
+
+	var resources []resource
+	switch k {
+	case kindItem:
+		td, err := h.todo(r, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resources = append(resources, resource{kind: kindItem, todo: td})
+	case kindCollection:
+		// The token is read first, so changes made while listing are
+		// reported again by the next sync rather than missed.
+		token, err := h.svc.SyncToken(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		resources = append(resources, resource{kind: kindCollection, token: token})
+		if children {
+			err = h.list(r, nil, func(td domain.Todo) {
+				resources = append(resources, resource{kind: kindItem, todo: td})
+			})
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+	case kindHome:
+		resources = append(resources, resource{kind: kindHome})
+		if children {
+			token, err := h.svc.SyncToken(r.Context())
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			resources = append(resources, resource{kind: kindCollection, token: token})
+		}
+	case kindRoot:
+		resources = append(resources, resource{kind: kindRoot})
+		if children {
+			resources = append(resources, resource{kind: kindPrincipal}, resource{kind: kindHome})
+		}
+	case kindPrincipal:
+		resources = append(resources, resource{kind: kindPrincipal})
+	}
+
+	stamp := h.now()
+	ms := multistatus{}
+	for _, res := range resources {
+		names := req.Prop
+		if req.AllProp != nil || req.PropName != nil || len(names) == 0 {
+			names = allProps(res.kind)
+		}
+		ms.Responses = append(ms.Responses, h.propResponse(res, names, req.PropName != nil, stamp))
+	}
+	writeMultistatus(w, ms)
+}
+
+// list calls fn with the todos of the collection matching match (all of them
+// if nil) in ID order, reading them a page at a time.
+func (h *handler) list(r *http.Request, match func(domain.Todo) bool, fn func(domain.Todo)) error {
+	mine := inCollection(r)
+	if match != nil {
+		owned := mine
+		mine = func(td domain.Todo) bool { return owned(td) && match(td) }
+	}
+	after := ""
+	for {
+		page, more, err := h.svc.ListAfter(r.Context(), after, listPage, mine)
+		if err != nil {
+			return err
+		}
+		for _, td := range page {
+			fn(td)
+		}
+		if !more {
+			return nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// todo returns the todo id if it is in the collection of the caller. Others
+// fail with ports.ErrNotFound, as if they did not exist.
+func (h *handler) todo(r *http.Request, id string) (domain.Todo, error) {
+	td, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	if !inCollection(r)(td) {
+		return domain.Todo{}, ports.ErrNotFound
+	}
+	return td, nil
+}
+
+// writable fails with ports.ErrNotFound when the todo id exists outside the
+// collection of the caller, so that PUT and DELETE cannot reach it. Owners
+// never change, so the check holds for the write that follows.
+func (h *handler) writable(r *http.Request, id string) error {
+	td, err := h.svc.Get(r.Context(), id)
+	switch {
+	case errors.Is(err, ports.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case !inCollection(r)(td):
+		return ports.ErrNotFound
+	}
+	return nil
+}
+
+// inCollection returns the predicate for the todos in the collection of the
+// caller: those they own if authenticated, all of them otherwise.
+func inCollection(r *http.Request) func(domain.Todo) bool {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		return func(domain.Todo) bool { return true }
+	}
+	return func(td domain.Todo) bool { return td.Owner == claims.Subject }
+}
+
+func (h *handler) report(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		http.Error(w, "invalid REPORT body", http.StatusBadRequest)
+		return
+	}
+	var root struct{ XMLName xml.Name }
+	if err := xml.Unmarshal(body, &root); err != nil {
+		http.Error(w, "invalid REPORT body", http.StatusBadRequest)
+		return
+	}
+
+	var ms multistatus
+	switch root.XMLName {
+	case calName("calendar-query"):
+		var q calendarQuery
+		if err := xml.Unmarshal(body, &q); err != nil {
+			http.Error(w, "invalid calendar-query", http.StatusBadRequest)
+			return
+		}
+		ms, err = h.calendarQuery(r, q)
+	case calName("calendar-multiget"):
+		var q calendarMultiget
+		if err := xml.Unmarshal(body, &q); err != nil {
+			http.Error(w, "invalid calendar-multiget", http.StatusBadRequest)
+			return
+		}
+		ms, err = h.calendarMultiget(r, q)
+	case davName("sync-collection"):
+		var q syncCollection
+		if err := xml.Unmarshal(body, &q); err != nil {
+			http.Error(w, "invalid sync-collection", http.StatusBadRequest)
+			return
+		}
+		ms, err = h.syncCollection(r, q)
+	default:
+		writeCondition(w, http.StatusForbidden, davName("supported-report"))
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeMultistatus(w, ms)
+}
+
+func (h *handler) calendarQuery(r *http.Request, q calendarQuery) (multistatus, error) {
+	names := reportProps(q.Prop, q.AllProp != nil)
+	stamp := h.now()
+	ms := multistatus{Responses: []response{}}
+	var match func(domain.Todo) bool
+	if q.Filter.Name != "" {
+		match = func(td domain.Todo) bool { return q.Filter.matches(td, stamp) }
+	}
+	err := h.list(r, match, func(td domain.Todo) {
+		ms.Responses = append(ms.Responses, h.propResponse(resource{kind: kindItem, todo: td}, names, false, stamp))
+	})
+	if err != nil {
+		return multistatus{}, err
+	}
+	return ms, nil
+}
+
+func (h *handler) calendarMultiget(r *http.Request, q calendarMultiget) (multistatus, error) {
+	names := reportProps(q.Prop, q.AllProp != nil)
+	stamp := h.now()
+	ms := multistatus{Responses: []response{}}
+	for _, href := range q.Hrefs {
+		var td domain.Todo
+		err := ports.ErrNotFound
+		if u, perr := url.Parse(href); perr == nil {
+			if k, uid, ok := h.route(u.Path); ok && k == kindItem {
+				td, err = h.todo(r, todoID(uid))
+			}
+		}
+		switch {
+		case errors.Is(err, ports.ErrNotFound):
+			ms.Responses = append(ms.Responses, response{Href: href, Status: statusLine(http.StatusNotFound)})
+		case err != nil:
+			return multistatus{}, err
+		default:
+			ms.Responses = append(ms.Responses, h.propResponse(resource{kind: kindItem, todo: td}, names, false, stamp))
+		}
+	}
+	return ms, nil
+}
+
+// syncCollection reports the todos of the collection written since the
+// client's sync token (RFC 6578), from the change feed shared with
+// GET /v1/todos/changes.
+func (h *handler) syncCollection(r *http.Request, q syncCollection) (multistatus, error) {
+	token, ok := strings.CutPrefix(q.SyncToken, syncTokenPrefix)
+	if !ok && q.SyncToken != "" {
+		return multistatus{}, errInvalidSyncToken
+	}
+	limit := 0
+	if q.Limit != nil {
+		if *q.Limit <= 0 {
+			return multistatus{}, errInvalidLimit
+		}
+		limit = *q.Limit
+	}
+
+	names := reportProps(q.Prop, false)
+	stamp := h.now()
+	mine := inCollection(r)
+	ms := multistatus{Responses: []response{}}
+	for {
+		page, err := h.svc.Changes(r.Context(), token, limit)
+		if err != nil {
+			return multistatus{}, err
+		}
+		for _, ch := range page.Changes {
+			switch {
+			case !mine(ch.Todo):
+				continue
+			case ch.Deleted:
+				ms.Responses = append(ms.Responses, response{Href: h.itemHref(ch.Todo), Status: statusLine(http.StatusNotFound)})
+				continue
+			}
+			ms.Responses = append(ms.Responses, h.propResponse(resource{kind: kindItem, todo: ch.Todo}, names, false, stamp))
+		}
+		token = page.Token
+		if !page.More {
+			break
+		}
+		if q.Limit != nil {
+			// A truncated result names the collection with 507 (RFC 6578,
+			// section 3.6); the client continues from the returned token.
+			ms.Responses = append(ms.Responses, response{
+				Href:   h.href(resource{kind: kindCollection}),
+				Status: statusLine(http.StatusInsufficientStorage),
+			})
+			break
+		}
+	}
+	ms.SyncToken = syncTokenPrefix + token
+	return ms, nil
+}
+
+func (h *handler) get(w http.ResponseWriter, r *http.Request, id string) {
+	td, err := h.todo(r, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	tag := etag(td)
+	w.Header().Set("ETag", tag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	body := encodeTodo(td, h.now())
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+func (h *handler) put(w http.ResponseWriter, r *http.Request, uid string) {
+	id := todoID(uid)
+	if err := h.writable(r, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	pre, ok := h.precondition(r, id)
+	if !ok {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		writeCondition(w, http.StatusForbidden, calName("max-resource-size"))
+		return
+	}
+	vt, err := decodeTodo(body)
+	switch {
+	case errors.Is(err, errUnsupportedComponent):
+		writeCondition(w, http.StatusForbidden, calName("supported-calendar-component"))
+		return
+	case err != nil:
+		writeCondition(w, http.StatusForbidden, calName("valid-calendar-data"))
+		return
+	case vt.uid != "" && vt.uid != uid:
+		// The UID names the resource; see route.
+		writeCondition(w, http.StatusForbidden, calName("valid-calendar-object-resource"))
+		return
+	}
+
+	td := domain.Todo{ID: id, Title: vt.summary, Completed: vt.completed}
+	if id != uid {
+		td.UID = uid
+	}
+	_, created, err := h.svc.Put(r.Context(), td, pre)
+	var verr *domain.ValidationError
+	switch {
+	case errors.Is(err, ports.ErrConflict) && pre != (todos.Precondition{}):
+		w.WriteHeader(http.StatusPreconditionFailed)
+	case errors.As(err, &verr):
+		writeCondition(w, http.StatusForbidden, calName("valid-calendar-object-resource"))
+	case err != nil:
+		writeError(w, err)
+	case created:
+		// No ETag: the stored todo keeps only part of what was uploaded, so
+		// clients must fetch it again (RFC 4791, section 5.3.4).
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.writable(r, id); err != nil {
+		writeError(w, err)
+		return
+	}
+	pre, ok := h.precondition(r, id)
+	if !ok || pre.Absent {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+	err := h.svc.DeleteIf(r.Context(), id, pre.Version)
+	switch {
+	case errors.Is(err, ports.ErrConflict):
+		w.WriteHeader(http.StatusPreconditionFailed)
+	case err != nil:
+		writeError(w, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// precondition translates If-Match and If-None-Match into a
+// todos.Precondition. It reports false for a precondition that cannot hold.
+func (h *handler) precondition(r *http.Request, id string) (todos.Precondition, bool) {
+	var pre todos.Precondition
+	if inm := strings.TrimSpace(r.Header.Get("If-None-Match")); inm == "*" {
+		pre.Absent = true
+	}
+	im := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch {
+	case im == "":
+	case im == "*":
+		td, err := h.todo(r, id)
+		if err != nil {
+			return pre, false
+		}
+		pre.Version = td.Version
+	default:
+		v, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(im, "W/"), `"`), 10, 64)
+		if err != nil || v == 0 {
+			return pre, false
+		}
+		pre.Version = v
+	}
+	return pre, !(pre.Absent && pre.Version != 0)
+}
+
+func etag(td domain.Todo) string {
+	return `"` + strconv.FormatUint(td.Version, 10) + `"`
+}
+
+func etagMatches(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errInvalidSyncToken = errors.New("invalid sync token")
+	errInvalidLimit     = errors.New("nresults must be positive")
+)
+
+// writeError maps service errors to status codes. CalDAV clients show the
+// status, so the bodies are plain text.
+func writeError(w http.ResponseWriter, err error) {
+	var (
+		verr  *domain.ValidationError
+		quota *domain.QuotaError
+	)
+	switch {
+	case errors.Is(err, errInvalidSyncToken), errors.Is(err, todos.ErrInvalidSyncToken), errors.Is(err, ports.ErrResyncRequired):
+		// The client discards its state and syncs from scratch.
+		writeCondition(w, http.StatusForbidden, davName("valid-sync-token"))
+	case errors.Is(err, errInvalidLimit):
+		writeCondition(w, http.StatusForbidden, davName("number-of-matches-within-limits"))
+	case errors.As(err, &verr):
+		http.Error(w, verr.Error(), http.StatusBadRequest)
+	case errors.As(err, &quota):
+		writeCondition(w, http.StatusInsufficientStorage, davName("quota-not-exceeded"))
+	case errors.Is(err, ports.ErrUnknownTenant), errors.Is(err, ports.ErrNotFound):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, ports.ErrConflict):
+		http.Error(w, "the todo was changed concurrently; retry", http.StatusConflict)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// decodeBody decodes an optional XML request body into v.
+func decodeBody(r *http.Request, v any) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	return xml.Unmarshal(body, v)
+}
@@ -0,0 +1,369 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+const collection = "/dav/calendars/todos/"
+
+func newTestHandler(t *testing.T) (http.Handler, *todos.Service) {
+	t.Helper()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	h, err := NewHandler(Options{TodoService: svc})
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	return h, svc
+}
+
+func do(h http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// doAs is do for a request authenticated as subject.
+func doAs(h http.Handler, subject, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	req = req.WithContext(auth.WithClaims(req.Context(), auth.Claims{Subject: subject}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func vcalendar(uid, summary, status string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\nBEGIN:VTODO\r\nUID:" + uid +
+		"\r\nSUMMARY:" + summary + "\r\nSTATUS:" + status + "\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+func TestNewHandler_RejectsNilService(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewHandler(Options{}); err == nil {
+		t.Fatalf("expected error for nil todo service")
+	}
+}
+
+func TestCalDAV_Discovery(t *testing.T) {
+	t.Parallel()
+
+	h, _ := newTestHandler(t)
+
+	rec := do(h, http.MethodOptions, collection, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("DAV"), "calendar-access") || !strings.Contains(rec.Header().Get("Allow"), "REPORT") {
+		t.Fatalf("unexpected OPTIONS response: %d %v", rec.Code, rec.Header())
+	}
+
+	rec = do(h, "PROPFIND", "/dav/", `<?xml version="1.0"?><propfind xmlns="DAV:"><prop><current-user-principal/></prop></propfind>`, "Depth", "0")
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), `>/dav/principal/</href>`) {
+		t.Fatalf("expected the principal, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(h, "PROPFIND", "/dav/principal/", `<propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><prop><C:calendar-home-set/><C:calendar-user-address-set/></prop></propfind>`, "Depth", "0")
+	body := rec.Body.String()
+	if !strings.Contains(body, `<calendar-home-set xmlns="urn:ietf:params:xml:ns:caldav"><href xmlns="DAV:">/dav/calendars/</href>`) ||
+		!strings.Contains(body, "404 Not Found") {
+		t.Fatalf("expected the home set and a 404 propstat, got %s", body)
+	}
+
+	rec = do(h, "PROPFIND", "/dav/calendars/", "", "Depth", "1")
+	body = rec.Body.String()
+	if !strings.Contains(body, "<href>"+collection+"</href>") || !strings.Contains(body, `<comp xmlns="urn:ietf:params:xml:ns:caldav" name="VTODO"/>`) {
+		t.Fatalf("expected the todo collection, got %s", body)
+	}
+
+	if rec := do(h, "PROPFIND", "/dav/calendars/other/", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec := do(h, http.MethodPost, collection, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestCalDAV_ItemLifecycle(t *testing.T) {
+	t.Parallel()
+
+	h, svc := newTestHandler(t)
+	ctx := context.Background()
+
+	rec := do(h, http.MethodPut, collection+"t1.ics", vcalendar("t1", "buy milk", "NEEDS-ACTION"), "If-None-Match", "*")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	// Clients and the REST API see the same todo.
+	td, err := svc.Get(ctx, "t1")
+	if err != nil || td.Title != "buy milk" || td.Completed {
+		t.Fatalf("unexpected stored todo %+v, err %v", td, err)
+	}
+	if rec := do(h, http.MethodPut, collection+"t1.ics", vcalendar("t1", "again", "NEEDS-ACTION"), "If-None-Match", "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d for an existing todo, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+
+	rec = do(h, http.MethodGet, collection+"t1.ics", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"1"` || !strings.Contains(rec.Body.String(), "SUMMARY:buy milk\r\n") {
+		t.Fatalf("unexpected GET response: %d %v %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+	if rec := do(h, http.MethodGet, collection+"t1.ics", "", "If-None-Match", `"1"`); rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status %d, got %d", http.StatusNotModified, rec.Code)
+	}
+
+	// A change through the service moves the ETag, so stale writes fail.
+	if _, err := svc.Update(ctx, "t1", "buy oat milk", false); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if rec := do(h, http.MethodPut, collection+"t1.ics", vcalendar("t1", "buy milk", "COMPLETED"), "If-Match", `"1"`); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d for a stale ETag, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+	if rec := do(h, http.MethodPut, collection+"t1.ics", vcalendar("t1", "buy oat milk", "COMPLETED"), "If-Match", `"2"`); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+	if td, _ := svc.Get(ctx, "t1"); !td.Completed || td.Version != 3 {
+		t.Fatalf("expected completed todo at version 3, got %+v", td)
+	}
+
+	for name, tc := range map[string]struct {
+		target, body, cond string
+	}{
+		"uid mismatch": {collection + "t2.ics", vcalendar("other", "x", "NEEDS-ACTION"), "valid-calendar-object-resource"},
+		"empty title":  {collection + "t2.ics", vcalendar("t2", "", "NEEDS-ACTION"), "valid-calendar-object-resource"},
+		"event":        {collection + "t2.ics", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:t2\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n", "supported-calendar-component"},
+		"garbage":      {collection + "t2.ics", "not ical", "valid-calendar-data"},
+	} {
+		rec := do(h, http.MethodPut, tc.target, tc.body)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "<"+tc.cond+" ") {
+			t.Fatalf("%s: expected 403 with %s, got %d: %s", name, tc.cond, rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := do(h, http.MethodDelete, collection+"t1.ics", "", "If-Match", `"2"`); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d for a stale delete, got %d", http.StatusPreconditionFailed, rec.Code)
+	}
+	if rec := do(h, http.MethodDelete, collection+"t1.ics", "", "If-Match", `"3"`); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec := do(h, http.MethodGet, collection+"t1.ics", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d after delete, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestCalDAV_Reports(t *testing.T) {
+	t.Parallel()
+
+	h, svc := newTestHandler(t)
+	ctx := context.Background()
+	open, err := svc.Create(ctx, "call mom")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	done, err := svc.Create(ctx, "pay rent")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := svc.Update(ctx, done.ID, done.Title, true); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// Apple Reminders asks for the open todos like this.
+	rec := do(h, "REPORT", collection, `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+		<D:prop><D:getetag/><C:calendar-data/></D:prop>
+		<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="VTODO">
+			<C:prop-filter name="COMPLETED"><C:is-not-defined/></C:prop-filter>
+		</C:comp-filter></C:comp-filter></C:filter>
+	</C:calendar-query>`, "Depth", "1")
+	body := rec.Body.String()
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(body, open.ID+".ics") || strings.Contains(body, done.ID+".ics") {
+		t.Fatalf("expected only the open todo, got %d: %s", rec.Code, body)
+	}
+	if !strings.Contains(body, "SUMMARY:call mom") {
+		t.Fatalf("expected calendar data, got %s", body)
+	}
+
+	rec = do(h, "REPORT", collection, `<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+		<D:prop><D:getetag/></D:prop>
+		<D:href>`+collection+done.ID+`.ics</D:href><D:href>`+collection+`missing.ics</D:href>
+	</C:calendar-multiget>`)
+	body = rec.Body.String()
+	if !strings.Contains(body, `<getetag xmlns="DAV:">&#34;2&#34;</getetag>`) || !strings.Contains(body, "404 Not Found") {
+		t.Fatalf("expected the ETag and a 404 response, got %s", body)
+	}
+
+	// An initial sync returns every todo and a token for later syncs.
+	tokenRE := regexp.MustCompile(`<sync-token[^>]*>([^<]+)</sync-token>`)
+	sync := func(token string) string {
+		t.Helper()
+		rec := do(h, "REPORT", collection, `<D:sync-collection xmlns:D="DAV:"><D:sync-token>`+token+`</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+		if rec.Code != http.StatusMultiStatus {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusMultiStatus, rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+	body = sync("")
+	m := tokenRE.FindStringSubmatch(body)
+	if m == nil || !strings.Contains(body, open.ID) || !strings.Contains(body, done.ID) {
+		t.Fatalf("expected both todos and a token, got %s", body)
+	}
+
+	if err := svc.Delete(ctx, open.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	body = sync(m[1])
+	if strings.Contains(body, done.ID) || !strings.Contains(body, "<href>"+collection+open.ID+".ics</href><status>HTTP/1.1 404 Not Found</status>") {
+		t.Fatalf("expected only the deletion, got %s", body)
+	}
+
+	rec = do(h, "REPORT", collection, `<D:sync-collection xmlns:D="DAV:"><D:sync-token>bogus</D:sync-token><D:prop/></D:sync-collection>`)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "valid-sync-token") {
+		t.Fatalf("expected a valid-sync-token error, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The collection's ctag and sync token follow the change feed.
+	rec = do(h, "PROPFIND", collection, `<propfind xmlns="DAV:" xmlns:CS="http://calendarserver.org/ns/"><prop><CS:getctag/><sync-token/></prop></propfind>`, "Depth", "0")
+	if got := tokenRE.FindStringSubmatch(rec.Body.String()); got == nil || got[1] == m[1] {
+		t.Fatalf("expected a new sync token after the delete, got %s", rec.Body.String())
+	}
+}
+
+func TestCalDAV_ClientUIDs(t *testing.T) {
+	t.Parallel()
+
+	h, svc := newTestHandler(t)
+
+	// Clients name resources after UIDs that are not valid todo IDs.
+	for _, tc := range []struct{ target, uid string }{
+		{collection + "0b8e2f4c-1d3a-4c5e-9f7a-2b6d8e0c4a1f@example.com.ics", "0b8e2f4c-1d3a-4c5e-9f7a-2b6d8e0c4a1f@example.com"},
+		{collection + "a%20b.ics", "a b"},
+	} {
+		if rec := do(h, http.MethodPut, tc.target, vcalendar(tc.uid, "buy milk", "NEEDS-ACTION"), "If-None-Match", "*"); rec.Code != http.StatusCreated {
+			t.Fatalf("%s: expected status %d, got %d: %s", tc.uid, http.StatusCreated, rec.Code, rec.Body.String())
+		}
+		rec := do(h, http.MethodGet, tc.target, "")
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "UID:"+tc.uid+"\r\n") {
+			t.Fatalf("%s: expected the todo with its UID, got %d: %s", tc.uid, rec.Code, rec.Body.String())
+		}
+		if rec := do(h, http.MethodPut, tc.target, vcalendar(tc.uid, "buy oat milk", "COMPLETED"), "If-Match", `"1"`); rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: expected status %d, got %d: %s", tc.uid, http.StatusNoContent, rec.Code, rec.Body.String())
+		}
+	}
+	if td, err := svc.Get(context.Background(), todoID("a b")); err != nil || td.UID != "a b" || !td.Completed {
+		t.Fatalf("expected the todo under its derived ID, got %+v, %v", td, err)
+	}
+
+	rec := do(h, "PROPFIND", collection, "", "Depth", "1")
+	body := rec.Body.String()
+	if !strings.Contains(body, "<href>"+collection+"0b8e2f4c-1d3a-4c5e-9f7a-2b6d8e0c4a1f@example.com.ics</href>") || !strings.Contains(body, "<href>"+collection+"a%20b.ics</href>") {
+		t.Fatalf("expected the resources under the names they were stored at, got %s", body)
+	}
+
+	token := regexp.MustCompile(`<sync-token[^>]*>([^<]+)</sync-token>`).FindStringSubmatch(body)
+	if token == nil {
+		t.Fatalf("expected a sync token, got %s", body)
+	}
+	if rec := do(h, http.MethodDelete, collection+"a%20b.ics", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	rec = do(h, "REPORT", collection, `<D:sync-collection xmlns:D="DAV:"><D:sync-token>`+token[1]+`</D:sync-token><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+	if !strings.Contains(rec.Body.String(), "<href>"+collection+"a%20b.ics</href><status>HTTP/1.1 404 Not Found</status>") {
+		t.Fatalf("expected the deletion under the name of the resource, got %s", rec.Body.String())
+	}
+}
+
+func TestCalDAV_OwnerScoped(t *testing.T) {
+	t.Parallel()
+
+	h, _ := newTestHandler(t)
+	for _, owner := range []string{"alice", "bob"} {
+		if rec := doAs(h, owner, http.MethodPut, collection+owner+".ics", vcalendar(owner, owner+"'s todo", "NEEDS-ACTION")); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doAs(h, "alice", "PROPFIND", collection, "", "Depth", "1")
+	if body := rec.Body.String(); !strings.Contains(body, "alice.ics") || strings.Contains(body, "bob.ics") {
+		t.Fatalf("expected only the todos of alice, got %s", body)
+	}
+	rec = doAs(h, "alice", "REPORT", collection, `<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop></C:calendar-query>`)
+	if body := rec.Body.String(); !strings.Contains(body, "alice.ics") || strings.Contains(body, "bob.ics") {
+		t.Fatalf("expected only the todos of alice, got %s", body)
+	}
+	rec = doAs(h, "alice", "REPORT", collection, `<D:sync-collection xmlns:D="DAV:"><D:sync-token/><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+	body := rec.Body.String()
+	if !strings.Contains(body, "alice.ics") || strings.Contains(body, "bob.ics") {
+		t.Fatalf("expected only the todos of alice, got %s", body)
+	}
+
+	token := regexp.MustCompile(`<sync-token[^>]*>([^<]+)</sync-token>`).FindStringSubmatch(body)
+	if rec := doAs(h, "bob", http.MethodDelete, collection+"bob.ics", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	rec = doAs(h, "alice", "REPORT", collection, `<D:sync-collection xmlns:D="DAV:"><D:sync-token>`+token[1]+`</D:sync-token><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+	if strings.Contains(rec.Body.String(), "bob.ics") {
+		t.Fatalf("expected the deletion of another user's todo to be left out, got %s", rec.Body.String())
+	}
+}
+
+func TestCalDAV_ItemsOfOtherUsersAreHidden(t *testing.T) {
+	t.Parallel()
+
+	h, svc := newTestHandler(t)
+	if rec := doAs(h, "bob", http.MethodPut, collection+"bob.ics", vcalendar("bob", "bob's todo", "NEEDS-ACTION")); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	for _, tc := range []struct {
+		method, body string
+		headers      []string
+	}{
+		{method: http.MethodGet},
+		{method: http.MethodHead},
+		{method: "PROPFIND", headers: []string{"Depth", "0"}},
+		{method: http.MethodPut, body: vcalendar("bob", "taken over", "COMPLETED")},
+		{method: http.MethodPut, body: vcalendar("bob", "taken over", "COMPLETED"), headers: []string{"If-Match", "*"}},
+		{method: http.MethodDelete},
+	} {
+		if rec := doAs(h, "alice", tc.method, collection+"bob.ics", tc.body, tc.headers...); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s %v: expected status %d, got %d", tc.method, tc.headers, http.StatusNotFound, rec.Code)
+		}
+	}
+	rec := doAs(h, "alice", "REPORT", collection, `<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop><D:getetag/></D:prop><D:href>`+collection+`bob.ics</D:href></C:calendar-multiget>`)
+	if body := rec.Body.String(); !strings.Contains(body, "404") || strings.Contains(body, "getetag>") {
+		t.Fatalf("expected the todo of bob to be reported missing, got %s", body)
+	}
+
+	td, err := svc.Get(context.Background(), "bob")
+	if err != nil || td.Title != "bob's todo" || td.Completed {
+		t.Fatalf("expected the todo of bob untouched, got %+v, %v", td, err)
+	}
+	if rec := doAs(h, "bob", http.MethodGet, collection+"bob.ics", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected the owner to read it, got %d", rec.Code)
+	}
+}
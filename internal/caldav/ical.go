@@ -0,0 +1,288 @@
+package caldav
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+const (
+	prodID = "-//challenge-backend-arancia//todos//EN"
+
+	// maxLineOctets is the longest content line allowed before folding.
+	maxLineOctets = 75
+
+	icalTime = "20060102T150405Z"
+)
+
+var (
+	errInvalidCalendar = errors.New("invalid iCalendar data")
+	// errUnsupportedComponent rejects calendar objects that are not todos.
+	errUnsupportedComponent = errors.New("only VTODO components are supported")
+)
+
+// property is an iCalendar content line of a VTODO. Values are unescaped.
+type property struct {
+	name  string
+	value string
+}
+
+// vtodo is the part of a VTODO a todo is built from.
+type vtodo struct {
+	uid       string
+	summary   string
+	completed bool
+}
+
+// icalUID returns the iCalendar UID of td.
+func icalUID(td domain.Todo) string {
+	if td.UID != "" {
+		return td.UID
+	}
+	return td.ID
+}
+
+// todoProperties returns the properties a todo is served with. stamp is the
+// time of the response; it stands in for DTSTAMP and COMPLETED of todos
+// written before the service recorded write times.
+func todoProperties(td domain.Todo, stamp time.Time) []property {
+	dtstamp := stamp
+	if !td.UpdatedAt.IsZero() {
+		dtstamp = td.UpdatedAt
+	}
+	props := []property{
+		{"UID", icalUID(td)},
+		{"DTSTAMP", icalUTC(dtstamp)},
+		{"SEQUENCE", strconv.FormatUint(max(td.Version, 1)-1, 10)},
+		{"SUMMARY", td.Title},
+	}
+	if !td.CreatedAt.IsZero() {
+		props = append(props, property{"CREATED", icalUTC(td.CreatedAt)})
+	}
+	if !td.UpdatedAt.IsZero() {
+		props = append(props, property{"LAST-MODIFIED", icalUTC(td.UpdatedAt)})
+	}
+	if td.Completed {
+		completed := stamp
+		if !td.CompletedAt.IsZero() {
+			completed = td.CompletedAt
+		}
+		props = append(props,
+			property{"STATUS", "COMPLETED"},
+			property{"COMPLETED", icalUTC(completed)},
+			property{"PERCENT-COMPLETE", "100"},
+		)
+	} else {
+		props = append(props, property{"STATUS", "NEEDS-ACTION"})
+	}
+	return props
+}
+
+func icalUTC(t time.Time) string { return t.UTC().Format(icalTime) }
+
+// encodeTodo renders td as an iCalendar object holding a single VTODO.
+func encodeTodo(td domain.Todo, stamp time.Time) []byte {
+	return encodeCalendar("", stamp, td)
+}
+
+// Calendar renders todos as a single iCalendar object named name, as served
+// to calendar apps subscribed to a feed rather than syncing over CalDAV.
+func Calendar(name string, todos []domain.Todo, stamp time.Time) []byte {
+	return encodeCalendar(name, stamp, todos...)
+}
+
+func encodeCalendar(name string, stamp time.Time, todos ...domain.Todo) []byte {
+	var b bytes.Buffer
+	writeLine(&b, "BEGIN", "VCALENDAR")
+	writeLine(&b, "VERSION", "2.0")
+	writeLine(&b, "PRODID", prodID)
+	if name != "" {
+		// NAME is RFC 7986; most clients still only know X-WR-CALNAME.
+		writeLine(&b, "NAME", escapeText(name))
+		writeLine(&b, "X-WR-CALNAME", escapeText(name))
+	}
+	for _, td := range todos {
+		writeLine(&b, "BEGIN", "VTODO")
+		for _, p := range todoProperties(td, stamp) {
+			v := p.value
+			if p.name == "SUMMARY" {
+				v = escapeText(v)
+			}
+			writeLine(&b, p.name, v)
+		}
+		writeLine(&b, "END", "VTODO")
+	}
+	writeLine(&b, "END", "VCALENDAR")
+	return b.Bytes()
+}
+
+// writeLine writes a content line, folded after maxLineOctets without
+// splitting UTF-8 sequences.
+func writeLine(b *bytes.Buffer, name, value string) {
+	line := name + ":" + value
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation line counts towards its length.
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isRuneStart(c byte) bool { return c&0xC0 != 0x80 }
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// decodeTodo parses an iCalendar object uploaded by a client. It must hold
+// one todo; time zones are ignored, as are recurrence overrides and any
+// property a todo has no field for.
+func decodeTodo(data []byte) (vtodo, error) {
+	lines, err := unfold(data)
+	if err != nil {
+		return vtodo{}, err
+	}
+
+	var (
+		stack  []string
+		todos  []vtodo
+		cur    vtodo
+		status string
+		done   bool
+		skip   bool
+	)
+	for _, line := range lines {
+		name, value, ok := splitLine(line)
+		if !ok {
+			return vtodo{}, fmt.Errorf("%w: malformed line %q", errInvalidCalendar, line)
+		}
+		switch name {
+		case "BEGIN":
+			comp := strings.ToUpper(value)
+			switch {
+			case len(stack) == 0 && comp != "VCALENDAR":
+				return vtodo{}, fmt.Errorf("%w: expected VCALENDAR, got %s", errInvalidCalendar, comp)
+			case len(stack) == 1 && comp == "VTODO":
+				cur, status, done, skip = vtodo{}, "", false, false
+			case len(stack) == 1 && comp != "VTIMEZONE" && !strings.HasPrefix(comp, "X-"):
+				return vtodo{}, errUnsupportedComponent
+			}
+			stack = append(stack, comp)
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(value) {
+				return vtodo{}, fmt.Errorf("%w: unbalanced END:%s", errInvalidCalendar, value)
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 1 && strings.EqualFold(value, "VTODO") && !skip {
+				if status != "" {
+					cur.completed = status == "COMPLETED"
+				} else {
+					cur.completed = done
+				}
+				todos = append(todos, cur)
+			}
+			continue
+		}
+		// Only properties of the VTODO itself, not of its alarms.
+		if len(stack) != 2 || stack[1] != "VTODO" {
+			continue
+		}
+		switch name {
+		case "UID":
+			cur.uid = value
+		case "SUMMARY":
+			cur.summary = unescapeText(value)
+		case "STATUS":
+			status = strings.ToUpper(value)
+		case "COMPLETED":
+			done = true
+		case "RECURRENCE-ID":
+			skip = true
+		}
+	}
+	if len(stack) != 0 {
+		return vtodo{}, fmt.Errorf("%w: missing END:%s", errInvalidCalendar, stack[len(stack)-1])
+	}
+	if len(todos) != 1 {
+		return vtodo{}, fmt.Errorf("%w: expected one VTODO, got %d", errInvalidCalendar, len(todos))
+	}
+	return todos[0], nil
+}
+
+// unfold splits data into content lines, joining folded continuation lines.
+func unfold(data []byte) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 4096), maxBody)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidCalendar, err)
+	}
+	return lines, nil
+}
+
+// splitLine returns the upper-cased name and the value of a content line,
+// dropping its parameters.
+func splitLine(line string) (string, string, bool) {
+	// The value starts at the first colon outside a quoted parameter value.
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if quoted {
+				continue
+			}
+			name, _, _ := strings.Cut(line[:i], ";")
+			if name == "" {
+				return "", "", false
+			}
+			return strings.ToUpper(name), line[i+1:], true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,114 @@
+package caldav
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+func TestEncodeTodo_FoldsAndEscapes(t *testing.T) {
+	t.Parallel()
+
+	title := strings.Repeat("é", 50) + ", milk; eggs\\bread"
+	data := encodeTodo(domain.Todo{ID: "a", Title: title, Completed: true, Version: 3}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Fatalf("line longer than %d octets: %q", maxLineOctets, line)
+		}
+	}
+	for _, want := range []string{"UID:a\r\n", "SEQUENCE:2\r\n", "STATUS:COMPLETED\r\n", "COMPLETED:20240501T120000Z\r\n", `\, milk\; eggs\\bread`} {
+		if !strings.Contains(strings.ReplaceAll(string(data), "\r\n ", ""), want) {
+			t.Fatalf("expected %q in:\n%s", want, data)
+		}
+	}
+
+	vt, err := decodeTodo(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if vt.uid != "a" || vt.summary != title || !vt.completed {
+		t.Fatalf("round trip mismatch: %+v", vt)
+	}
+}
+
+func TestDecodeTodo(t *testing.T) {
+	t.Parallel()
+
+	const thunderbird = "BEGIN:VCALENDAR\r\nPRODID:-//Mozilla.org/NONSGML Mozilla Calendar V1.1//EN\r\nVERSION:2.0\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Rome\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VTODO\r\nUID:abc-1\r\nSUMMARY;LANGUAGE=en:call\r\n  mom\r\nSTATUS:NEEDS-ACTION\r\n" +
+		"BEGIN:VALARM\r\nSUMMARY:ignored\r\nEND:VALARM\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+
+	cases := []struct {
+		name string
+		data string
+		want vtodo
+		err  error
+	}{
+		{name: "thunderbird", data: thunderbird, want: vtodo{uid: "abc-1", summary: "call mom"}},
+		{
+			name: "completed without status",
+			data: "BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:x\nSUMMARY:done\nCOMPLETED:20240101T000000Z\nEND:VTODO\nEND:VCALENDAR\n",
+			want: vtodo{uid: "x", summary: "done", completed: true},
+		},
+		{
+			name: "status wins",
+			data: "BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:x\nSUMMARY:s\nSTATUS:IN-PROCESS\nCOMPLETED:20240101T000000Z\nEND:VTODO\nEND:VCALENDAR\n",
+			want: vtodo{uid: "x", summary: "s"},
+		},
+		{name: "event", data: "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nEND:VEVENT\nEND:VCALENDAR\n", err: errUnsupportedComponent},
+		{name: "not a calendar", data: "hello", err: errInvalidCalendar},
+		{name: "unbalanced", data: "BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:x\nEND:VCALENDAR\n", err: errInvalidCalendar},
+		{name: "no todo", data: "BEGIN:VCALENDAR\nEND:VCALENDAR\n", err: errInvalidCalendar},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := decodeTodo([]byte(tc.data))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCalendar_UsesRecordedTimes(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 4, 1, 9, 0, 0, 0, time.UTC)
+	done := time.Date(2024, 4, 2, 18, 30, 0, 0, time.UTC)
+	data := string(Calendar("Todos", []domain.Todo{
+		{ID: "a", Title: "a", Completed: true, Version: 2, CreatedAt: created, UpdatedAt: done, CompletedAt: done},
+		{ID: "b", Title: "b", Version: 1},
+	}, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+
+	for _, want := range []string{
+		"X-WR-CALNAME:Todos\r\n",
+		"UID:a\r\nDTSTAMP:20240402T183000Z\r\n",
+		"CREATED:20240401T090000Z\r\nLAST-MODIFIED:20240402T183000Z\r\n",
+		"COMPLETED:20240402T183000Z\r\n",
+		// Todos written before times were recorded fall back to the stamp.
+		"UID:b\r\nDTSTAMP:20240501T120000Z\r\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Fatalf("expected %q in:\n%s", want, data)
+		}
+	}
+	if n := strings.Count(data, "BEGIN:VTODO"); n != 2 {
+		t.Fatalf("expected 2 todos, got %d", n)
+	}
+}
@@ -0,0 +1,213 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// allProps lists the properties returned for allprop and propname requests.
+// calendar-data is only returned when asked for.
+func allProps(k kind) []xml.Name {
+	names := []xml.Name{davName("resourcetype"), davName("current-user-principal")}
+	switch k {
+	case kindPrincipal:
+		names = append(names, davName("displayname"), davName("principal-URL"), calName("calendar-home-set"))
+	case kindCollection:
+		names = append(names,
+			davName("displayname"),
+			davName("current-user-privilege-set"),
+			davName("supported-report-set"),
+			davName("sync-token"),
+			calName("supported-calendar-component-set"),
+			xml.Name{Space: nsCS, Local: "getctag"},
+		)
+	case kindItem:
+		names = append(names, davName("getetag"), davName("getcontenttype"), davName("current-user-privilege-set"))
+	}
+	return names
+}
+
+// reportProps returns the properties a report asks for, defaulting to the
+// ETag and data clients need to sync.
+func reportProps(names propNames, all bool) []xml.Name {
+	if all {
+		return append(allProps(kindItem), calName("calendar-data"))
+	}
+	if len(names) == 0 {
+		return []xml.Name{davName("getetag"), calName("calendar-data")}
+	}
+	return names
+}
+
+// propResponse describes res with the given properties: those it has under
+// 200, the others under 404. With namesOnly the values are left out.
+func (h *handler) propResponse(res resource, names []xml.Name, namesOnly bool, stamp time.Time) response {
+	var found, missing []propValue
+	for _, name := range names {
+		v, ok := h.prop(res, name, stamp)
+		switch {
+		case !ok:
+			missing = append(missing, propValue{XMLName: name})
+		case namesOnly:
+			found = append(found, propValue{XMLName: name})
+		default:
+			found = append(found, propValue{XMLName: name, Inner: v})
+		}
+	}
+	resp := response{Href: h.href(res)}
+	if len(found) > 0 || len(missing) == 0 {
+		resp.Propstat = append(resp.Propstat, propstat{Prop: propValues{Props: found}, Status: statusLine(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstat = append(resp.Propstat, propstat{Prop: propValues{Props: missing}, Status: statusLine(http.StatusNotFound)})
+	}
+	return resp
+}
+
+// prop returns the value of a property of res as XML.
+func (h *handler) prop(res resource, name xml.Name, stamp time.Time) (string, bool) {
+	k := res.kind
+	switch name {
+	case davName("resourcetype"):
+		switch k {
+		case kindItem:
+			return "", true
+		case kindCollection:
+			return `<collection xmlns="DAV:"/><calendar xmlns="` + nsCal + `"/>`, true
+		case kindPrincipal:
+			return `<collection xmlns="DAV:"/><principal xmlns="DAV:"/>`, true
+		}
+		return `<collection xmlns="DAV:"/>`, true
+	case davName("current-user-principal"):
+		return hrefXML(h.href(resource{kind: kindPrincipal})), true
+	case davName("displayname"):
+		switch k {
+		case kindCollection:
+			return displayName, true
+		case kindPrincipal:
+			return "Todos user", true
+		}
+	case davName("principal-URL"):
+		if k == kindPrincipal {
+			return hrefXML(h.href(resource{kind: kindPrincipal})), true
+		}
+	case calName("calendar-home-set"):
+		if k == kindPrincipal {
+			return hrefXML(h.href(resource{kind: kindHome})), true
+		}
+	case davName("current-user-privilege-set"):
+		if k == kindCollection || k == kindItem {
+			var b strings.Builder
+			for _, p := range []string{"read", "write", "write-content", "bind", "unbind"} {
+				b.WriteString(`<privilege xmlns="DAV:"><` + p + `/></privilege>`)
+			}
+			return b.String(), true
+		}
+	case davName("supported-report-set"):
+		if k == kindCollection {
+			var b strings.Builder
+			for _, r := range []xml.Name{calName("calendar-query"), calName("calendar-multiget"), davName("sync-collection")} {
+				b.WriteString(`<supported-report xmlns="DAV:"><report><` + r.Local + ` xmlns="` + r.Space + `"/></report></supported-report>`)
+			}
+			return b.String(), true
+		}
+	case davName("sync-token"), xml.Name{Space: nsCS, Local: "getctag"}:
+		if k == kindCollection {
+			return escape(syncTokenPrefix + res.token), true
+		}
+	case calName("supported-calendar-component-set"):
+		if k == kindCollection {
+			return `<comp xmlns="` + nsCal + `" name="VTODO"/>`, true
+		}
+	case davName("getetag"):
+		if k == kindItem {
+			return escape(etag(res.todo)), true
+		}
+	case davName("getcontenttype"):
+		if k == kindItem {
+			return escape(itemType), true
+		}
+	case calName("calendar-data"):
+		if k == kindItem {
+			return escape(string(encodeTodo(res.todo, stamp))), true
+		}
+	}
+	return "", false
+}
+
+// matches evaluates a calendar-query filter (RFC 4791, section 9.7) against
+// the VTODO of td. Time ranges always match: todos carry no dates, and a
+// VTODO without dates overlaps every range (section 9.9).
+func (f compFilter) matches(td domain.Todo, stamp time.Time) bool {
+	if !strings.EqualFold(f.Name, "VCALENDAR") || f.IsNotDefined != nil {
+		return false
+	}
+	for _, c := range f.Comps {
+		if !c.matchesTodo(td, stamp) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f compFilter) matchesTodo(td domain.Todo, stamp time.Time) bool {
+	if !strings.EqualFold(f.Name, "VTODO") {
+		// The calendar object holds nothing but the VTODO.
+		return f.IsNotDefined != nil
+	}
+	if f.IsNotDefined != nil {
+		return false
+	}
+	for _, c := range f.Comps {
+		// Todos have no subcomponents such as alarms.
+		if c.IsNotDefined == nil {
+			return false
+		}
+	}
+	props := todoProperties(td, stamp)
+	for _, pf := range f.Props {
+		if !pf.matches(props) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f propFilter) matches(props []property) bool {
+	var values []string
+	for _, p := range props {
+		if strings.EqualFold(p.name, f.Name) {
+			values = append(values, p.value)
+		}
+	}
+	if f.IsNotDefined != nil {
+		return len(values) == 0
+	}
+	if len(values) == 0 {
+		return false
+	}
+	if f.TextMatch == nil {
+		return true
+	}
+	for _, v := range values {
+		if f.TextMatch.matches(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches implements the i;octet and the default i;ascii-casemap collations.
+func (m textMatch) matches(v string) bool {
+	var hit bool
+	if m.Collation == "i;octet" {
+		hit = strings.Contains(v, m.Value)
+	} else {
+		hit = strings.Contains(strings.ToLower(v), strings.ToLower(m.Value))
+	}
+	return hit != (m.Negate == "yes")
+}
@@ -0,0 +1,154 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// XML namespaces of WebDAV, CalDAV and the calendarserver.org extensions
+// (getctag) Apple clients rely on.
+const (
+	nsDAV = "DAV:"
+	nsCal = "urn:ietf:params:xml:ns:caldav"
+	nsCS  = "http://calendarserver.org/ns/"
+)
+
+func davName(local string) xml.Name { return xml.Name{Space: nsDAV, Local: local} }
+func calName(local string) xml.Name { return xml.Name{Space: nsCal, Local: local} }
+
+// propNames collects the names of the properties a request asks for, ignoring
+// their content (such as the component selection of calendar-data).
+type propNames []xml.Name
+
+func (p *propNames) UnmarshalXML(d *xml.Decoder, _ xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			*p = append(*p, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// propfind is the body of a PROPFIND request. An empty body asks for all
+// properties.
+type propfind struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     propNames `xml:"DAV: prop"`
+}
+
+type calendarQuery struct {
+	XMLName xml.Name   `xml:"urn:ietf:params:xml:ns:caldav calendar-query"`
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    propNames  `xml:"DAV: prop"`
+	Filter  compFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+type compFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *struct{}    `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	Comps        []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	Props        []propFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+}
+
+type propFilter struct {
+	Name         string     `xml:"name,attr"`
+	IsNotDefined *struct{}  `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TextMatch    *textMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+type textMatch struct {
+	Value     string `xml:",chardata"`
+	Collation string `xml:"collation,attr"`
+	Negate    string `xml:"negate-condition,attr"`
+}
+
+type calendarMultiget struct {
+	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:caldav calendar-multiget"`
+	AllProp *struct{} `xml:"DAV: allprop"`
+	Prop    propNames `xml:"DAV: prop"`
+	Hrefs   []string  `xml:"DAV: href"`
+}
+
+type syncCollection struct {
+	XMLName   xml.Name  `xml:"DAV: sync-collection"`
+	SyncToken string    `xml:"DAV: sync-token"`
+	Limit     *int      `xml:"DAV: limit>nresults"`
+	Prop      propNames `xml:"DAV: prop"`
+}
+
+// multistatus is the body of a 207 response.
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+	SyncToken string     `xml:"sync-token,omitempty"`
+}
+
+type response struct {
+	Href     string     `xml:"href"`
+	Status   string     `xml:"status,omitempty"`
+	Propstat []propstat `xml:"propstat"`
+}
+
+type propstat struct {
+	Prop   propValues `xml:"prop"`
+	Status string     `xml:"status"`
+}
+
+type propValues struct {
+	Props []propValue
+}
+
+// propValue is a property with its value as raw XML.
+type propValue struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// escape returns s as XML character data.
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func hrefXML(href string) string {
+	return `<href xmlns="DAV:">` + escape(href) + `</href>`
+}
+
+func writeMultistatus(w http.ResponseWriter, ms multistatus) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	if err := xml.NewEncoder(&b).Encode(ms); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = w.Write(b.Bytes())
+}
+
+// writeCondition reports a failed WebDAV precondition (RFC 4918, section 16).
+func writeCondition(w http.ResponseWriter, code int, cond xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(xml.Header + `<error xmlns="DAV:"><` + cond.Local + ` xmlns="` + cond.Space + `"/></error>`))
+}
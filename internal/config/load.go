@@ -0,0 +1,371 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Source is where a setting was read from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Problem is an invalid setting.
+type Problem struct {
+	Setting string
+	Message string
+}
+
+// Error lists every problem found while loading the configuration, so they
+// can all be fixed at once.
+type Error struct {
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, p.Setting+" "+p.Message)
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// ConfigFileEnv names the config file when the -config flag is not given.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// Redacted replaces the value of secrets in Settings.
+const Redacted = "[REDACTED]"
+
+// setting is a field of Config as described by its tags.
+type setting struct {
+	name       string
+	def        string
+	secret     bool
+	allowEmpty bool
+	reload     bool
+	index      int
+}
+
+var settings = func() []setting {
+	t := reflect.TypeOf(Config{})
+	var out []setting
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		out = append(out, setting{
+			name:       name,
+			def:        t.Field(i).Tag.Get("default"),
+			secret:     strings.Contains(opts, "secret"),
+			allowEmpty: strings.Contains(opts, "allowempty"),
+			reload:     strings.Contains(opts, "reload"),
+			index:      i,
+		})
+	}
+	return out
+}()
+
+// Load builds the configuration from defaults, the config file (-config or
+// CONFIG_FILE), the environment read through lookupEnv and args, the
+// command-line flags, each overriding the previous ones, and validates it.
+// It returns flag.ErrHelp when args ask for usage, which is written to
+// stderr.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	flags, file, err := parseFlags(args, os.Stderr)
+	if err != nil {
+		return Config{}, err
+	}
+	if file == "" {
+		file, _ = lookupEnv(ConfigFileEnv)
+	}
+	var fileValues map[string]string
+	if file != "" {
+		if fileValues, err = readFile(file); err != nil {
+			return Config{}, err
+		}
+	}
+
+	cfg := Config{sources: map[string]Source{}, file: file}
+	var v validator
+	for key := range fileValues {
+		if !knownKey(strings.ToUpper(key)) {
+			v.addf(key, "in %s is not a known setting", file)
+		}
+	}
+	for _, s := range settings {
+		raw, src, err := resolve(s, flags, lookupEnv, fileValues)
+		if err != nil {
+			v.addf(s.name, "%v", err)
+			continue
+		}
+		if err := set(reflect.ValueOf(&cfg).Elem().Field(s.index), raw); err != nil {
+			v.addf(s.name, "from %s: %v", src, err)
+			continue
+		}
+		cfg.sources[s.name] = src
+	}
+	// Validation problems of settings that could not be parsed would only
+	// repeat those.
+	var verr *Error
+	if errors.As(cfg.Validate(), &verr) {
+		for _, p := range verr.Problems {
+			if !v.has(p.Setting) {
+				v.problems = append(v.problems, p)
+			}
+		}
+	}
+	if err := v.err(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// resolve returns the raw value of s from the source of highest precedence
+// that sets it.
+func resolve(s setting, flags map[string]string, lookupEnv func(string) (string, bool), file map[string]string) (string, Source, error) {
+	sources := []struct {
+		src    Source
+		lookup func(string) (string, bool)
+	}{
+		{SourceFlag, func(name string) (string, bool) { v, ok := flags[name]; return v, ok }},
+		{SourceEnv, func(name string) (string, bool) {
+			v, ok := lookupEnv(name)
+			v = strings.TrimSpace(v)
+			// An empty variable is treated as unset unless it turns the
+			// setting off.
+			return v, ok && (v != "" || s.allowEmpty)
+		}},
+		{SourceFile, func(name string) (string, bool) { v, ok := file[strings.ToLower(name)]; return v, ok }},
+	}
+	for _, src := range sources {
+		v, ok := src.lookup(s.name)
+		path, fromFile := "", false
+		if s.secret {
+			path, fromFile = src.lookup(s.name + "_FILE")
+		}
+		switch {
+		case ok && fromFile:
+			return "", "", fmt.Errorf("and %s_FILE are both set in %s", s.name, src.src)
+		case fromFile:
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return "", "", fmt.Errorf("from %s_FILE: %w", s.name, err)
+			}
+			return strings.TrimSpace(string(b)), src.src, nil
+		case ok:
+			return v, src.src, nil
+		}
+	}
+	return s.def, SourceDefault, nil
+}
+
+func knownKey(name string) bool {
+	for _, s := range settings {
+		if name == s.name || (s.secret && name == s.name+"_FILE") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFlags returns the settings set by args, by name, and the -config
+// flag. Secrets also get a -*-file flag.
+func parseFlags(args []string, usage io.Writer) (map[string]string, string, error) {
+	fs := flag.NewFlagSet("todo-api", flag.ContinueOnError)
+	fs.SetOutput(usage)
+	file := fs.String("config", "", "YAML (.yaml, .yml) or TOML (.toml) config file; also "+ConfigFileEnv)
+	values := map[string]string{}
+	define := func(name, help string) {
+		fs.Func(flagName(name), help, func(v string) error {
+			values[name] = v
+			return nil
+		})
+	}
+	for _, s := range settings {
+		help := "env " + s.name
+		if s.def != "" {
+			help += " (default " + strconv.Quote(s.def) + ")"
+		}
+		define(s.name, help)
+		if s.secret {
+			define(s.name+"_FILE", "file holding "+s.name+"; env "+s.name+"_FILE")
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+	if fs.NArg() > 0 {
+		return nil, "", fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	return values, *file, nil
+}
+
+// flagName turns DB_PATH into db-path.
+func flagName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "_", "-")
+}
+
+// readFile reads the flat key/value map of a config file. Lists are joined
+// with commas like in the environment.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	raw := map[string]any{}
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	out := make(map[string]string, len(raw))
+	for k, v := range raw {
+		s, err := fileValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s: %w", path, k, err)
+		}
+		out[strings.ToLower(k)] = s
+	}
+	return out, nil
+}
+
+func fileValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case time.Time:
+		return v.Format(time.DateOnly), nil
+	case toml.LocalDate:
+		return v.String(), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := fileValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", errors.New("nested tables are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// set parses raw into the field f.
+func set(f reflect.Value, raw string) error {
+	switch f.Interface().(type) {
+	case string:
+		f.SetString(raw)
+	case []string:
+		f.Set(reflect.ValueOf(splitList(raw)))
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		f.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		f.SetInt(int64(n))
+	case float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		f.SetFloat(n)
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 10s or 1h30m", raw)
+		}
+		f.SetInt(int64(d))
+	case time.Time:
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a date such as 2027-04-19", raw)
+		}
+		f.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Type())
+	}
+	return nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// Setting is the effective value of a setting and where it came from.
+type Setting struct {
+	Name   string `json:"name"`
+	Value  any    `json:"value"`
+	Source Source `json:"source"`
+}
+
+// Settings lists the effective configuration in declaration order, with
+// secrets redacted, for operators to check what a running instance uses.
+func (c Config) Settings() []Setting {
+	rv := reflect.ValueOf(c)
+	out := make([]Setting, 0, len(settings))
+	for _, s := range settings {
+		var value any
+		switch v := rv.Field(s.index).Interface().(type) {
+		case time.Duration:
+			value = v.String()
+		case time.Time:
+			value = v.Format(time.DateOnly)
+		case []string:
+			value = append([]string{}, v...)
+		default:
+			value = v
+		}
+		if s.secret && value != "" {
+			value = Redacted
+		}
+		src := c.sources[s.name]
+		if src == "" {
+			src = SourceDefault
+		}
+		out = append(out, Setting{Name: s.name, Value: value, Source: src})
+	}
+	return out
+}
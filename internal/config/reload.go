@@ -0,0 +1,145 @@
+package config
+
+import (
+	"context"
+	"maps"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Change is a setting whose value differs between two configurations, with
+// secrets redacted as in Settings.
+type Change struct {
+	Name string
+	Old  any
+	New  any
+	// Reloadable reports whether a Store applies the change; the others
+	// only take effect after a restart.
+	Reloadable bool
+}
+
+// Diff lists the settings whose values differ from old in next, in
+// declaration order.
+func Diff(old, next Config) []Change {
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(next)
+	oldSettings, nextSettings := old.Settings(), next.Settings()
+	var out []Change
+	for i, s := range settings {
+		if reflect.DeepEqual(ov.Field(s.index).Interface(), nv.Field(s.index).Interface()) {
+			continue
+		}
+		out = append(out, Change{
+			Name:       s.name,
+			Old:        oldSettings[i].Value,
+			New:        nextSettings[i].Value,
+			Reloadable: s.reload,
+		})
+	}
+	return out
+}
+
+// Store holds the configuration of a running instance as an immutable
+// snapshot, replaced whole when reloadable settings change so readers never
+// see half of a reload.
+type Store struct {
+	mu   sync.Mutex // serializes Apply and Subscribe
+	cur  atomic.Pointer[Config]
+	subs []func(Config)
+}
+
+// NewStore returns a Store holding cfg.
+func NewStore(cfg Config) *Store {
+	s := &Store{}
+	s.cur.Store(&cfg)
+	return s
+}
+
+// Current returns the current snapshot.
+func (s *Store) Current() Config {
+	return *s.cur.Load()
+}
+
+// Settings lists the settings of the current snapshot, as Config.Settings.
+func (s *Store) Settings() []Setting {
+	return s.Current().Settings()
+}
+
+// Subscribe calls fn with every snapshot Apply stores from now on, in order.
+// fn must not call Apply.
+func (s *Store) Subscribe(fn func(Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, fn)
+}
+
+// Apply stores a snapshot with the reloadable settings of next and notifies
+// the subscribers. It returns every change next makes, including those left
+// for a restart, or the problems of the new snapshot, which is then dropped.
+func (s *Store) Apply(next Config) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := *s.cur.Load()
+	changes := Diff(cur, next)
+	snap := cur
+	snap.sources = maps.Clone(cur.sources)
+	dst, src := reflect.ValueOf(&snap).Elem(), reflect.ValueOf(next)
+	applied := false
+	for _, st := range settings {
+		if !st.reload || !changed(changes, st.name) {
+			continue
+		}
+		dst.Field(st.index).Set(src.Field(st.index))
+		snap.sources[st.name] = next.sources[st.name]
+		applied = true
+	}
+	if !applied {
+		return changes, nil
+	}
+	// Reloadable settings may depend on ones that are not.
+	if err := snap.Validate(); err != nil {
+		return nil, err
+	}
+	s.cur.Store(&snap)
+	for _, fn := range s.subs {
+		fn(snap)
+	}
+	return changes, nil
+}
+
+func changed(changes []Change, name string) bool {
+	for _, c := range changes {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch calls changed each time the file at path is modified, checking
+// every interval until ctx is done. The path is resolved on every check, so
+// the symlink swaps of Kubernetes ConfigMap volumes are seen too.
+func Watch(ctx context.Context, path string, interval time.Duration, changed func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last, _ := os.Stat(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			// Missing while being replaced; the next check sees the new file.
+			continue
+		}
+		if last == nil || !fi.ModTime().Equal(last.ModTime()) || fi.Size() != last.Size() {
+			last = fi
+			changed()
+		}
+	}
+}
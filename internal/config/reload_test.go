@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStore_AppliesReloadableSettings(t *testing.T) {
+	t.Parallel()
+
+	file := writeFile(t, "todo.yaml", "log_level: info\nport: 8080\n")
+	cfg, err := Load([]string{"-config", file}, env(nil))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	store := NewStore(cfg)
+	var got []Config
+	store.Subscribe(func(c Config) { got = append(got, c) })
+
+	if err := os.WriteFile(file, []byte("log_level: debug\nport: 9000\ncors_allowed_origins: [https://app.example.com]\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	next, err := Load([]string{"-config", file}, env(nil))
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	changes, err := store.Apply(next)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	want := map[string]bool{"LOG_LEVEL": true, "PORT": false, "CORS_ALLOWED_ORIGINS": true}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for _, c := range changes {
+		if reloadable, ok := want[c.Name]; !ok || c.Reloadable != reloadable {
+			t.Fatalf("unexpected change %+v", c)
+		}
+	}
+
+	cur := store.Current()
+	if cur.LogLevel != "debug" || len(cur.CORSAllowedOrigins) != 1 {
+		t.Fatalf("expected reloadable settings applied, got %+v", cur)
+	}
+	if cur.Port != "8080" {
+		t.Fatalf("expected PORT to wait for a restart, got %s", cur.Port)
+	}
+	if len(got) != 1 || got[0].LogLevel != "debug" {
+		t.Fatalf("expected subscribers to get the new snapshot once, got %d", len(got))
+	}
+
+	// Applying the same configuration again only reports the pending restart.
+	changes, err = store.Apply(next)
+	if err != nil || len(changes) != 1 || changes[0].Name != "PORT" || len(got) != 1 {
+		t.Fatalf("expected only PORT pending without notifying, got %+v, %v", changes, err)
+	}
+}
+
+func TestStore_RejectsInvalidSnapshot(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := Load(nil, env(map[string]string{"RATE_LIMIT_RPS": "0", "RATE_LIMIT_BURST": "0"}))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	store := NewStore(cfg)
+	next := cfg
+	next.RateLimitRPS = 5
+	if _, err := store.Apply(next); err == nil {
+		t.Fatalf("expected enabling rate limiting without a burst to fail")
+	}
+	if store.Current().RateLimitRPS != 0 {
+		t.Fatalf("expected the current snapshot to be kept")
+	}
+}
+
+func TestWatch_ReportsFileChanges(t *testing.T) {
+	t.Parallel()
+
+	file := writeFile(t, "todo.yaml", "port: 8080\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 1)
+	go Watch(ctx, file, 10*time.Millisecond, func() { changed <- struct{}{} })
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(file, []byte("port: 9000\nlog_level: debug\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expected the change to be reported")
+	}
+}
@@ -0,0 +1,18 @@
+package domain
+
+// Change is an entry of a tenant's change feed: a todo written after a sync
+// point, in its current state, or a tombstone if it has since been deleted.
+type Change struct {
+	// Todo is the current state; tombstones only carry the ID, UID and
+	// owner.
+	Todo    Todo
+	Deleted bool
+}
+
+// ChangeSet is a page of the change feed. Seq is the sync point to ask for
+// the next page (More) or, once caught up, for later changes.
+type ChangeSet struct {
+	Changes []Change
+	Seq     uint64
+	More    bool
+}
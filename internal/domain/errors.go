@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validation rules reported in FieldViolation.Rule. They are part of the API
+// contract, so never rename them.
+const (
+	RuleRequired  = "required"
+	RuleMaxLength = "max_length"
+	RuleFormat    = "format"
+	RuleCycle     = "cycle"
+	RuleMaxDepth  = "max_depth"
+)
+
+// FieldViolation describes why a single field is invalid.
+type FieldViolation struct {
+	Field  string
+	Rule   string
+	Detail string
+	// Err is the sentinel for this kind of violation (e.g. ErrInvalidTitle).
+	Err error
+}
+
+// ValidationError is returned when an entity violates one or more invariants.
+// errors.Is matches the sentinel of every violation it carries.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Detail)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	out := make([]error, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		if v.Err != nil {
+			out = append(out, v.Err)
+		}
+	}
+	return out
+}
+
+func invalid(field, rule, detail string, sentinel error) *ValidationError {
+	return &ValidationError{Violations: []FieldViolation{{
+		Field:  field,
+		Rule:   rule,
+		Detail: detail,
+		Err:    sentinel,
+	}}}
+}
+
+// QuotaError reports a write rejected because the user reached a quota.
+// errors.Is matches ErrQuotaExceeded.
+type QuotaError struct {
+	Resource string
+	Limit    int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: at most %d %s allowed", ErrQuotaExceeded, e.Limit, e.Resource)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
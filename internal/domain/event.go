@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// EventType names a change that happened to a Todo.
+type EventType string
+
+const (
+	EventTodoCreated EventType = "todo.created"
+	EventTodoUpdated EventType = "todo.updated"
+	// EventTodoCompleted is emitted instead of EventTodoUpdated when an update
+	// marks a todo as completed.
+	EventTodoCompleted EventType = "todo.completed"
+	EventTodoDeleted   EventType = "todo.deleted"
+)
+
+// Event records a successful change to a Todo. For deletions Todo holds the
+// last known state. Todo.Version orders the events of one todo: every event
+// carries the version written by the change that produced it.
+type Event struct {
+	// ID is unique per event and stable across redeliveries, so consumers
+	// can drop duplicates.
+	ID string
+	// Seq is assigned by the publisher and increases monotonically.
+	Seq        uint64
+	Type       EventType
+	Tenant     string
+	Todo       Todo
+	OccurredAt time.Time
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxDepth is the number of levels a tree of todos may have: top-level todos,
+// their subtasks, the subtasks of those and so on.
+const MaxDepth = 4
+
+// ErrInvalidParent indicates a parent that would make a todo a subtask of
+// itself or nest todos deeper than MaxDepth.
+var ErrInvalidParent = errors.New("invalid parent")
+
+func parentCycle() *ValidationError {
+	return invalid("parent_id", RuleCycle, "a todo cannot be a subtask of itself or of its own subtasks", ErrInvalidParent)
+}
+
+// ValidateParent checks that the todo id may become a subtask of the todo
+// whose ancestry is given: the new parent first, then its parent, up to a
+// top-level todo. height is the number of levels of the subtree rooted at
+// id, 1 for a todo without subtasks.
+// Violations are reported as a *ValidationError matching ErrInvalidParent.
+func ValidateParent(id string, ancestry []string, height int) error {
+	for _, a := range ancestry {
+		if a == id {
+			return parentCycle()
+		}
+	}
+	if len(ancestry)+height > MaxDepth {
+		return invalid("parent_id", RuleMaxDepth, fmt.Sprintf("todos can be nested at most %d levels deep", MaxDepth), ErrInvalidParent)
+	}
+	return nil
+}
+
+// Progress returns the share of subtasks completed as a percentage rounded
+// down. ok is false for a todo without subtasks.
+func Progress(subtasks []Todo) (percent int, ok bool) {
+	if len(subtasks) == 0 {
+		return 0, false
+	}
+	done := 0
+	for _, td := range subtasks {
+		if td.Completed {
+			done++
+		}
+	}
+	return done * 100 / len(subtasks), true
+}
+
+// RollUp applies the completion rule of AutoComplete to t after a change to
+// its subtasks: t is completed if all of them are and open otherwise. It
+// reports whether t changed; todos without AutoComplete or subtasks never do.
+func (t Todo) RollUp(subtasks []Todo) (Todo, bool) {
+	if !t.AutoComplete || len(subtasks) == 0 {
+		return t, false
+	}
+	done, _ := Progress(subtasks)
+	completed := done == 100
+	if completed == t.Completed {
+		return t, false
+	}
+	t.Completed = completed
+	return t, true
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTodoCheck_OwnParent(t *testing.T) {
+	t.Parallel()
+
+	err := Todo{ID: "a", Title: "plan trip", ParentID: "a"}.Check()
+	if !errors.Is(err, ErrInvalidParent) {
+		t.Fatalf("expected ErrInvalidParent, got %v", err)
+	}
+	assertViolation(t, err, "parent_id", RuleCycle)
+}
+
+func TestValidateParent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		id       string
+		ancestry []string
+		height   int
+		rule     string
+	}{
+		{name: "top level", id: "a", height: MaxDepth},
+		{name: "leaf at max depth", id: "d", ancestry: []string{"c", "b", "a"}, height: 1},
+		{name: "under itself", id: "a", ancestry: []string{"a"}, height: 1, rule: RuleCycle},
+		{name: "under own subtask", id: "a", ancestry: []string{"c", "b", "a"}, height: 3, rule: RuleCycle},
+		{name: "too deep", id: "e", ancestry: []string{"d", "c", "b", "a"}, height: 1, rule: RuleMaxDepth},
+		{name: "subtree too deep", id: "x", ancestry: []string{"b", "a"}, height: 3, rule: RuleMaxDepth},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateParent(tc.id, tc.ancestry, tc.height)
+			if tc.rule == "" {
+				if err != nil {
+					t.Fatalf("expected nil, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidParent) {
+				t.Fatalf("expected ErrInvalidParent, got %v", err)
+			}
+			assertViolation(t, err, "parent_id", tc.rule)
+		})
+	}
+}
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := Progress(nil); ok {
+		t.Fatalf("expected no progress without subtasks")
+	}
+	got, ok := Progress([]Todo{{Completed: true}, {}, {}})
+	if !ok || got != 33 {
+		t.Fatalf("expected 33, got %d", got)
+	}
+	if got, _ := Progress([]Todo{{Completed: true}, {Completed: true}}); got != 100 {
+		t.Fatalf("expected 100, got %d", got)
+	}
+}
+
+func TestTodoRollUp(t *testing.T) {
+	t.Parallel()
+
+	done := []Todo{{Completed: true}, {Completed: true}}
+	open := []Todo{{Completed: true}, {}}
+
+	if _, changed := (Todo{}).RollUp(done); changed {
+		t.Fatalf("expected todos without AutoComplete to stay open")
+	}
+	if _, changed := (Todo{AutoComplete: true}).RollUp(nil); changed {
+		t.Fatalf("expected todos without subtasks to stay as they are")
+	}
+	td, changed := Todo{AutoComplete: true}.RollUp(done)
+	if !changed || !td.Completed {
+		t.Fatalf("expected the todo to complete, got %+v", td)
+	}
+	td, changed = td.RollUp(open)
+	if !changed || td.Completed {
+		t.Fatalf("expected the todo to reopen, got %+v", td)
+	}
+	if _, changed := td.RollUp(open); changed {
+		t.Fatalf("expected no change for an open todo with open subtasks")
+	}
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// MaxTenantIDLen is the maximum length allowed for a Tenant ID.
+	MaxTenantIDLen = 63
+)
+
+var (
+	// ErrInvalidTenantID indicates a tenant ID that is empty, too long or contains
+	// characters other than lowercase letters, digits and inner hyphens.
+	ErrInvalidTenantID = errors.New("invalid tenant id")
+)
+
+// Tenant is an isolated owner of todos. Every tenant has its own storage.
+type Tenant struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// Validate checks invariants for a Tenant.
+func (t Tenant) Validate() error {
+	return ValidateTenantID(t.ID)
+}
+
+// ValidateTenantID checks that id is usable as a tenant identifier. IDs double as
+// DNS labels (subdomain resolution), so the same rules apply.
+// Violations are reported as a *ValidationError matching ErrInvalidTenantID.
+func ValidateTenantID(id string) error {
+	switch {
+	case id == "":
+		return invalid("id", RuleRequired, "tenant id must not be empty", ErrInvalidTenantID)
+	case len(id) > MaxTenantIDLen:
+		return invalid("id", RuleMaxLength, fmt.Sprintf("tenant id must be at most %d characters", MaxTenantIDLen), ErrInvalidTenantID)
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-' && i > 0 && i < len(id)-1:
+		default:
+			return invalid("id", RuleFormat, "tenant id must be lowercase letters, digits and inner hyphens", ErrInvalidTenantID)
+		}
+	}
+	return nil
+}
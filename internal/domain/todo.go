@@ -2,7 +2,9 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,6 +15,10 @@ const (
 var (
 	// ErrInvalidTitle indicates a title that is empty (after trim) or exceeds MaxTitleLen.
 	ErrInvalidTitle = errors.New("invalid title")
+
+	// ErrQuotaExceeded indicates that a write would take a user over one of
+	// their storage quotas.
+	ErrQuotaExceeded = errors.New("quota exceeded")
 )
 
 // Todo is the core entity of the system.
@@ -20,14 +26,55 @@ type Todo struct {
 	ID        string
 	Title     string
 	Completed bool
+	// UID is the iCalendar UID of a todo created over CalDAV under a UID
+	// that is not a valid ID, empty when the ID is the UID.
+	UID string
+	// Owner is the subject of the user who created the todo, empty for
+	// anonymous callers.
+	Owner string
+	// ParentID is the todo this one is a subtask of, empty for top-level
+	// todos. Moves are checked with ValidateParent.
+	ParentID string
+	// AutoComplete completes the todo once all its subtasks are completed,
+	// and reopens it when one of them is reopened or an open one is added
+	// (see RollUp).
+	AutoComplete bool
+	// Version counts the writes to the todo, starting at 1. It is assigned
+	// by the repository.
+	Version uint64
+	// CreatedAt, UpdatedAt and CompletedAt are set by the service on each
+	// write. CompletedAt is zero while the todo is open; all three are zero
+	// for todos written before they were recorded.
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt time.Time
 }
 
 // Validate checks invariants for a Todo.
-// At domain level we keep it minimal: only validates Title.
+// At domain level we keep it minimal: validates Title, and that a todo is not
+// its own parent. Deeper checks of the parent need the whole tree; see
+// ValidateParent.
+// It returns ErrInvalidTitle or ErrInvalidParent; Check reports the same
+// with the field and rule violated.
 func (t Todo) Validate() error {
+	var verr *ValidationError
+	if errors.As(t.Check(), &verr) {
+		return verr.Violations[0].Err
+	}
+	return nil
+}
+
+// Check is Validate, reporting violations as a *ValidationError matching
+// ErrInvalidTitle or ErrInvalidParent.
+func (t Todo) Check() error {
 	title := strings.TrimSpace(t.Title)
-	if title == "" || len(title) > MaxTitleLen {
-		return ErrInvalidTitle
+	switch {
+	case title == "":
+		return invalid("title", RuleRequired, "title must not be empty", ErrInvalidTitle)
+	case len(title) > MaxTitleLen:
+		return invalid("title", RuleMaxLength, fmt.Sprintf("title must be at most %d characters", MaxTitleLen), ErrInvalidTitle)
+	case t.ParentID != "" && t.ParentID == t.ID:
+		return parentCycle()
 	}
 	return nil
 }
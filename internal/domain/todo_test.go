@@ -1,6 +1,10 @@
 package domain
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+)
 
 func TestTodoValidate_TitleRequired(t *testing.T) {
 	t.Parallel()
@@ -33,3 +37,31 @@ func TestTodoValidate_OK(t *testing.T) {
 		t.Fatalf("expected nil, got %v", err)
 	}
 }
+
+func TestTodoCheck_ReportsViolations(t *testing.T) {
+	t.Parallel()
+
+	err := Todo{ID: "x", Title: "   "}.Check()
+	if !errors.Is(err, ErrInvalidTitle) {
+		t.Fatalf("expected ErrInvalidTitle, got %v", err)
+	}
+	assertViolation(t, err, "title", RuleRequired)
+
+	err = Todo{ID: "x", Title: strings.Repeat("a", MaxTitleLen+1)}.Check()
+	if !errors.Is(err, ErrInvalidTitle) {
+		t.Fatalf("expected ErrInvalidTitle, got %v", err)
+	}
+	assertViolation(t, err, "title", RuleMaxLength)
+}
+
+func assertViolation(t *testing.T, err error, field, rule string) {
+	t.Helper()
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %T", err)
+	}
+	if len(verr.Violations) != 1 || verr.Violations[0].Field != field || verr.Violations[0].Rule != rule {
+		t.Fatalf("expected %s/%s violation, got %+v", field, rule, verr.Violations)
+	}
+}
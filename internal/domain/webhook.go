@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+var (
+	// ErrInvalidWebhook indicates a webhook subscription with a bad URL, secret or
+	// event type list.
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
+
+// WebhookEventTypes are the event types a webhook may subscribe to.
+var WebhookEventTypes = []EventType{EventTodoCreated, EventTodoUpdated, EventTodoCompleted, EventTodoDeleted}
+
+// Webhook is a subscription that receives signed event notifications over HTTP.
+type Webhook struct {
+	ID     string
+	URL    string
+	Events []EventType
+	// Secret keys the HMAC-SHA256 signature of every delivery.
+	Secret    string
+	CreatedAt time.Time
+}
+
+// Validate checks invariants for a Webhook.
+func (w Webhook) Validate() error {
+	verr := &ValidationError{}
+	add := func(field, rule, detail string) {
+		verr.Violations = append(verr.Violations, FieldViolation{Field: field, Rule: rule, Detail: detail, Err: ErrInvalidWebhook})
+	}
+
+	if u, err := url.Parse(w.URL); w.URL == "" {
+		add("url", RuleRequired, "url must not be empty")
+	} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("url", RuleFormat, "url must be an absolute http or https URL")
+	}
+	if w.Secret == "" {
+		add("secret", RuleRequired, "secret must not be empty")
+	}
+	if len(w.Events) == 0 {
+		add("events", RuleRequired, "events must list at least one event type")
+	}
+	for i, et := range w.Events {
+		if !et.subscribable() {
+			add(fmt.Sprintf("events[%d]", i), RuleFormat, fmt.Sprintf("unknown event type %q", et))
+		}
+	}
+
+	if len(verr.Violations) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Wants reports whether the webhook is subscribed to et.
+func (w Webhook) Wants(et EventType) bool {
+	for _, want := range w.Events {
+		if want == et {
+			return true
+		}
+	}
+	return false
+}
+
+func (et EventType) subscribable() bool {
+	for _, known := range WebhookEventTypes {
+		if et == known {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the lifecycle state of a webhook delivery.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are queued for their next attempt.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded deliveries got a 2xx response.
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead deliveries ran out of attempts. They stay in the dead-letter
+	// list until redelivered manually.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// DeliveryAttempt logs one HTTP request made for a delivery.
+type DeliveryAttempt struct {
+	At         time.Time
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
+
+// Delivery is one event sent (or to be sent) to one webhook.
+type Delivery struct {
+	ID        string
+	WebhookID string
+	Tenant    string
+	EventType EventType
+	// Payload is the exact request body, rendered once so every attempt and
+	// redelivery sends identical bytes.
+	Payload  []byte
+	Status   DeliveryStatus
+	Attempts []DeliveryAttempt
+	// Failures counts failed attempts since the delivery was last queued; it
+	// drives the retry backoff and dead-lettering.
+	Failures      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
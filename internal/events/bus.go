@@ -0,0 +1,160 @@
+// Package events provides an in-process publish/subscribe bus for domain events.
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+const (
+	// DefaultHistory is how many recent events are kept for resuming subscribers.
+	DefaultHistory = 1024
+	// DefaultBuffer is how many undelivered events a subscriber may queue before
+	// it is considered too slow and dropped.
+	DefaultBuffer = 64
+)
+
+// ErrClosed is returned when publishing to or subscribing on a closed Bus.
+var ErrClosed = errors.New("event bus closed")
+
+// Filter selects the events a subscriber is interested in.
+type Filter func(domain.Event) bool
+
+// Bus fans events out to subscribers. It never blocks publishers: subscribers
+// that fall DefaultBuffer events behind are disconnected with Lagged set, and
+// can resume from their last seen Seq while it is still in the history.
+type Bus struct {
+	mu      sync.Mutex
+	seq     uint64
+	history []domain.Event // ring buffer, oldest at history[start]
+	start   int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		history: make([]domain.Event, 0, DefaultHistory),
+		subs:    map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription is a live stream of events.
+type Subscription struct {
+	bus    *Bus
+	filter Filter
+	ch     chan domain.Event
+	once   sync.Once
+
+	// Gap reports that events after the requested resume point were evicted
+	// from the history; the subscriber should resynchronize its state.
+	Gap bool
+	// Lagged reports that the subscription was dropped for falling behind.
+	// Only meaningful once C is closed.
+	Lagged bool
+}
+
+// C delivers matching events in Seq order. It is closed when the subscription
+// ends: Close, Bus.Close or the subscriber lagging behind.
+func (s *Subscription) C() <-chan domain.Event {
+	return s.ch
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+// Publish assigns the next Seq to event and delivers it to subscribers.
+func (b *Bus) Publish(_ context.Context, event domain.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+	b.seq++
+	event.Seq = b.seq
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, event)
+	} else {
+		b.history[b.start] = event
+		b.start = (b.start + 1) % len(b.history)
+	}
+
+	for s := range b.subs {
+		b.deliver(s, event)
+	}
+	return nil
+}
+
+// Subscribe starts a subscription for events matching filter (nil matches all).
+// A non-zero after replays retained events with a greater Seq first.
+func (b *Bus) Subscribe(filter Filter, after uint64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	s := &Subscription{bus: b, filter: filter}
+	var replay []domain.Event
+	if after > 0 {
+		n := len(b.history)
+		if after > b.seq || (n > 0 && after+1 < b.history[b.start].Seq) {
+			s.Gap = true
+		} else {
+			for i := 0; i < n; i++ {
+				if ev := b.history[(b.start+i)%n]; ev.Seq > after {
+					replay = append(replay, ev)
+				}
+			}
+		}
+	}
+
+	// Room for the whole replay on top of the live buffer, so resuming never
+	// counts as lagging.
+	s.ch = make(chan domain.Event, DefaultBuffer+len(replay))
+	b.subs[s] = struct{}{}
+	for _, ev := range replay {
+		b.deliver(s, ev)
+	}
+	return s, nil
+}
+
+// Close ends every subscription and rejects further use of the bus. It is meant
+// to be called on server shutdown so streaming handlers return promptly.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// deliver queues ev for s, dropping s if its buffer is full. Callers must hold
+// b.mu.
+func (b *Bus) deliver(s *Subscription, ev domain.Event) {
+	if s.filter != nil && !s.filter(ev) {
+		return
+	}
+	select {
+	case s.ch <- ev:
+	default:
+		s.Lagged = true
+		b.drop(s)
+	}
+}
+
+// drop removes s and closes its channel. Callers must hold b.mu.
+func (b *Bus) drop(s *Subscription) {
+	delete(b.subs, s)
+	s.once.Do(func() { close(s.ch) })
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+func TestBus_FilterResumeAndLag(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	bus := NewBus()
+
+	acme, err := bus.Subscribe(func(ev domain.Event) bool { return ev.Tenant == "acme" }, 0)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	_ = bus.Publish(ctx, domain.Event{Type: domain.EventTodoCreated, Tenant: "acme"})
+	_ = bus.Publish(ctx, domain.Event{Type: domain.EventTodoCreated, Tenant: "globex"})
+	_ = bus.Publish(ctx, domain.Event{Type: domain.EventTodoDeleted, Tenant: "acme"})
+
+	if ev := <-acme.C(); ev.Seq != 1 {
+		t.Fatalf("expected seq 1, got %+v", ev)
+	}
+	if ev := <-acme.C(); ev.Seq != 3 || ev.Type != domain.EventTodoDeleted {
+		t.Fatalf("expected filtered seq 3, got %+v", ev)
+	}
+
+	// resume replays retained events after the given seq
+	resumed, err := bus.Subscribe(nil, 1)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if resumed.Gap {
+		t.Fatalf("expected no gap")
+	}
+	if ev := <-resumed.C(); ev.Seq != 2 {
+		t.Fatalf("expected replay from seq 2, got %+v", ev)
+	}
+
+	// a subscriber that never reads is dropped instead of blocking publishers
+	slow, _ := bus.Subscribe(nil, 0)
+	for i := 0; i < DefaultBuffer+1; i++ {
+		_ = bus.Publish(ctx, domain.Event{Tenant: "acme"})
+	}
+	n := 0
+	for range slow.C() {
+		n++
+	}
+	if !slow.Lagged || n != DefaultBuffer {
+		t.Fatalf("expected slow subscriber to lag after %d events, got lagged=%v n=%d", DefaultBuffer, slow.Lagged, n)
+	}
+
+	// resuming from before the retained history reports a gap
+	if s, _ := bus.Subscribe(nil, 1_000_000); !s.Gap {
+		t.Fatalf("expected gap for unknown seq")
+	}
+
+	bus.Close()
+	if _, ok := <-acme.C(); ok {
+		// drain anything still buffered
+		for range acme.C() {
+		}
+	}
+	if err := bus.Publish(ctx, domain.Event{}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// LogPublisher writes every event to a structured log, one line per event.
+type LogPublisher struct {
+	logger *slog.Logger
+}
+
+func NewLogPublisher(logger *slog.Logger) *LogPublisher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event domain.Event) error {
+	p.logger.LogAttrs(ctx, slog.LevelInfo, "domain_event",
+		slog.String("event_id", event.ID),
+		slog.String("type", string(event.Type)),
+		slog.String("tenant", event.Tenant),
+		slog.String("todo_id", event.Todo.ID),
+		slog.Uint64("version", event.Todo.Version),
+		slog.Time("occurred_at", event.OccurredAt),
+	)
+	return nil
+}
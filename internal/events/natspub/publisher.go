@@ -0,0 +1,81 @@
+// Package natspub publishes domain events to NATS.
+package natspub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/nats-io/nats.go"
+)
+
+// flushTimeout bounds how long Publish waits for the server to confirm it
+// received a message.
+const flushTimeout = 5 * time.Second
+
+// message is the JSON body of every NATS message.
+type message struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Tenant     string      `json:"tenant"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Todo       messageTodo `json:"todo"`
+}
+
+type messageTodo struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	Version   uint64 `json:"version"`
+}
+
+// Publisher sends every event to "<prefix>.<tenant>.<event type>", e.g.
+// "todos.acme.todo.created". The event ID is set as Nats-Msg-Id so JetStream
+// streams drop redelivered duplicates.
+type Publisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+func New(conn *nats.Conn, subjectPrefix string) (*Publisher, error) {
+	if conn == nil {
+		return nil, errors.New("nil nats connection")
+	}
+	if subjectPrefix == "" {
+		return nil, errors.New("empty subject prefix")
+	}
+	return &Publisher{conn: conn, prefix: subjectPrefix}, nil
+}
+
+// Publish returns once the server has received the message.
+func (p *Publisher) Publish(ctx context.Context, event domain.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	body, err := json.Marshal(message{
+		ID:         event.ID,
+		Type:       string(event.Type),
+		Tenant:     event.Tenant,
+		OccurredAt: event.OccurredAt,
+		Todo: messageTodo{
+			ID:        event.Todo.ID,
+			Title:     event.Todo.Title,
+			Completed: event.Todo.Completed,
+			Version:   event.Todo.Version,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(p.prefix + "." + event.Tenant + "." + string(event.Type))
+	msg.Header.Set(nats.MsgIdHdr, event.ID)
+	msg.Data = body
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return p.conn.FlushTimeout(flushTimeout)
+}
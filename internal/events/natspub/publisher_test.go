@@ -0,0 +1,69 @@
+package natspub
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestPublisher_PublishesToTenantSubject(t *testing.T) {
+	t.Parallel()
+
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: server.RANDOM_PORT, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	go ns.Start()
+	t.Cleanup(ns.Shutdown)
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatalf("nats server not ready")
+	}
+
+	conn, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	sub, err := conn.SubscribeSync("todos.acme.>")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	pub, err := New(conn, "todos")
+	if err != nil {
+		t.Fatalf("new publisher: %v", err)
+	}
+	err = pub.Publish(context.Background(), domain.Event{
+		ID:     "ev-1",
+		Type:   domain.EventTodoCompleted,
+		Tenant: "acme",
+		Todo:   domain.Todo{ID: "t1", Title: "x", Completed: true, Version: 2},
+	})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("next msg: %v", err)
+	}
+	if msg.Subject != "todos.acme.todo.completed" {
+		t.Fatalf("expected subject todos.acme.todo.completed, got %q", msg.Subject)
+	}
+	if got := msg.Header.Get(nats.MsgIdHdr); got != "ev-1" {
+		t.Fatalf("expected Nats-Msg-Id ev-1, got %q", got)
+	}
+	var body message
+	if err := json.Unmarshal(msg.Data, &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Todo.ID != "t1" || body.Todo.Version != 2 || !body.Todo.Completed {
+		t.Fatalf("unexpected body %s", msg.Data)
+	}
+}
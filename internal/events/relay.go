@@ -0,0 +1,228 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"challenge-backend-arancia/internal/health"
+	"challenge-backend-arancia/internal/ports"
+)
+
+const (
+	// relayBatch bounds how many outbox entries one pass publishes to a sink.
+	relayBatch = 100
+	// DefaultRelayAttempts is how many times an entry is published to a sink
+	// before it is dead-lettered for that sink.
+	DefaultRelayAttempts = 10
+	// relayRetryDelay is the wait before retrying a sink after its first
+	// failure; it doubles with every further one, up to relayMaxRetryDelay.
+	relayRetryDelay    = time.Second
+	relayMaxRetryDelay = 5 * time.Minute
+)
+
+// Sink is a destination of the relay. Name keys the progress of the sink
+// through the outbox, so it must stay the same across restarts.
+type Sink struct {
+	Name      string
+	Publisher ports.EventPublisher
+}
+
+// Relay moves events from the outbox to its sinks.
+//
+// Each sink has its own position in the outbox and entries are removed once
+// every sink handled them, so every event reaches every sink at least once: a
+// crash leads to the event being published again, but a failing sink does not
+// make the others see it twice. Entries are published to a sink one at a time
+// in commit order and a failure stops that sink for the pass, so events of
+// the same todo are never reordered. The failed entry is retried with
+// exponential backoff; after maxAttempts failures it is dead-lettered for the
+// sink, so one event the sink always rejects cannot stall it for good.
+type Relay struct {
+	outbox      ports.Outbox
+	sinks       []Sink
+	names       []string
+	maxAttempts int
+	logger      *slog.Logger
+	now         func() time.Time
+	pass        health.Heartbeat
+	// failing tracks the sinks whose last publish failed and unpruned is set
+	// while entries all sinks handled may be left in the outbox. Only Run, or
+	// a caller of Drain, touches them, one pass at a time.
+	failing  map[string]*sinkFailure
+	unpruned bool
+}
+
+// sinkFailure is an entry a sink failed to publish and when to retry it.
+type sinkFailure struct {
+	seq      uint64
+	attempts int
+	retryAt  time.Time
+}
+
+func NewRelay(outbox ports.Outbox, sinks []Sink, maxAttempts int, logger *slog.Logger) (*Relay, error) {
+	if outbox == nil {
+		return nil, errors.New("nil outbox")
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("no sinks")
+	}
+	if maxAttempts < 1 {
+		return nil, errors.New("max attempts must be positive")
+	}
+	names := make([]string, 0, len(sinks))
+	seen := map[string]bool{}
+	for _, s := range sinks {
+		if s.Name == "" || s.Publisher == nil {
+			return nil, errors.New("sinks need a name and a publisher")
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("duplicate sink %q", s.Name)
+		}
+		seen[s.Name] = true
+		names = append(names, s.Name)
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Relay{
+		outbox:      outbox,
+		sinks:       sinks,
+		names:       names,
+		maxAttempts: maxAttempts,
+		logger:      logger,
+		now:         time.Now,
+		failing:     map[string]*sinkFailure{},
+		// A previous process may have stopped between an ack and a prune.
+		unpruned: true,
+	}, nil
+}
+
+// Run drains the outbox every interval until ctx is done.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.Drain(ctx)
+		if ctx.Err() == nil {
+			r.pass.Beat(err)
+		}
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("outbox_relay_failed", slog.String("error", err.Error()))
+		}
+		if err == nil && n == relayBatch {
+			// More is probably waiting; do not sleep on a backlog.
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastPass returns when the last pass of Run ended and its error, for a
+// health.Fresh check.
+func (r *Relay) LastPass() (time.Time, error) {
+	return r.pass.Last()
+}
+
+// Drain publishes one batch of pending events to every sink not waiting for
+// a retry, then removes the events all sinks handled. Pruning is a write, so
+// it is skipped while no sink moves on. It returns the most events handled
+// by a sink.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	var (
+		most int
+		errs []error
+	)
+	for _, s := range r.sinks {
+		n, err := r.drainSink(ctx, s)
+		most = max(most, n)
+		if n > 0 {
+			r.unpruned = true
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.Name, err))
+		}
+	}
+	if r.unpruned {
+		if err := r.outbox.Prune(context.WithoutCancel(ctx), r.names...); err != nil {
+			errs = append(errs, err)
+		} else {
+			r.unpruned = false
+		}
+	}
+	return most, errors.Join(errs...)
+}
+
+func (r *Relay) drainSink(ctx context.Context, s Sink) (int, error) {
+	failed := r.failing[s.Name]
+	if failed != nil && r.now().Before(failed.retryAt) {
+		return 0, nil
+	}
+	pending, err := r.outbox.Pending(ctx, s.Name, relayBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	var pubErr error
+	for _, e := range pending {
+		if pubErr = s.Publisher.Publish(ctx, e.Event); pubErr != nil {
+			break
+		}
+		n++
+	}
+	// Ack with a fresh context: the events went out even if ctx was
+	// canceled meanwhile, and acking them avoids needless redelivery.
+	ackCtx := context.WithoutCancel(ctx)
+	if n > 0 {
+		if err := r.outbox.Ack(ackCtx, s.Name, pending[n-1].Seq); err != nil {
+			return 0, err
+		}
+	}
+	if pubErr == nil {
+		delete(r.failing, s.Name)
+		return n, nil
+	}
+	if ctx.Err() != nil {
+		// Shutting down is not the fault of the entry.
+		return n, pubErr
+	}
+
+	entry := pending[n]
+	if failed == nil || failed.seq != entry.Seq {
+		failed = &sinkFailure{seq: entry.Seq}
+		r.failing[s.Name] = failed
+	}
+	failed.attempts++
+	if failed.attempts < r.maxAttempts {
+		failed.retryAt = r.now().Add(retryDelay(failed.attempts))
+		return n, pubErr
+	}
+
+	if err := r.outbox.DeadLetter(ackCtx, s.Name, entry, pubErr.Error()); err != nil {
+		return n, err
+	}
+	delete(r.failing, s.Name)
+	r.logger.Error("outbox_entry_dead_lettered",
+		slog.String("sink", s.Name),
+		slog.Uint64("seq", entry.Seq),
+		slog.String("event_id", entry.Event.ID),
+		slog.Int("attempts", failed.attempts),
+		slog.String("error", pubErr.Error()))
+	return n + 1, nil
+}
+
+// retryDelay is the wait after the given number of consecutive failures.
+func retryDelay(attempts int) time.Duration {
+	d := relayRetryDelay
+	for i := 1; i < attempts && d < relayMaxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, relayMaxRetryDelay)
+}
@@ -0,0 +1,232 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+type fakeOutbox struct {
+	entries []ports.OutboxEntry
+	cursors map[string]uint64
+	dead    []ports.OutboxDeadLetter
+	prunes  int
+}
+
+func newFakeOutbox(ids ...string) *fakeOutbox {
+	o := &fakeOutbox{cursors: map[string]uint64{}}
+	for i, id := range ids {
+		o.entries = append(o.entries, ports.OutboxEntry{Seq: uint64(i + 1), Event: domain.Event{ID: id}})
+	}
+	return o
+}
+
+func (o *fakeOutbox) Pending(ctx context.Context, sink string, limit int) ([]ports.OutboxEntry, error) {
+	var out []ports.OutboxEntry
+	for _, e := range o.entries {
+		if e.Seq > o.cursors[sink] && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (o *fakeOutbox) Ack(ctx context.Context, sink string, seq uint64) error {
+	o.cursors[sink] = max(o.cursors[sink], seq)
+	return nil
+}
+
+func (o *fakeOutbox) DeadLetter(ctx context.Context, sink string, entry ports.OutboxEntry, reason string) error {
+	o.dead = append(o.dead, ports.OutboxDeadLetter{Sink: sink, Entry: entry, Reason: reason})
+	return o.Ack(ctx, sink, entry.Seq)
+}
+
+func (o *fakeOutbox) Prune(ctx context.Context, sinks ...string) error {
+	o.prunes++
+	kept := o.entries[:0]
+	for _, e := range o.entries {
+		for _, s := range sinks {
+			if e.Seq > o.cursors[s] {
+				kept = append(kept, e)
+				break
+			}
+		}
+	}
+	o.entries = kept
+	return nil
+}
+
+// flakyPublisher fails the first attempt of every event in failOnce and
+// every attempt of those in failAlways.
+type flakyPublisher struct {
+	failOnce   map[string]bool
+	failAlways map[string]bool
+	published  []string
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, ev domain.Event) error {
+	if p.failAlways[ev.ID] {
+		return errors.New("rejected")
+	}
+	if p.failOnce[ev.ID] {
+		delete(p.failOnce, ev.ID)
+		return errors.New("unavailable")
+	}
+	p.published = append(p.published, ev.ID)
+	return nil
+}
+
+func newTestRelay(t *testing.T, outbox ports.Outbox, maxAttempts int, sinks ...Sink) *Relay {
+	t.Helper()
+
+	relay, err := NewRelay(outbox, sinks, maxAttempts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new relay: %v", err)
+	}
+	return relay
+}
+
+// skipBackoff makes the clock of the relay jump past the longest backoff on
+// every reading, so retries are always due.
+func skipBackoff(r *Relay) {
+	now := time.Now()
+	r.now = func() time.Time {
+		now = now.Add(relayMaxRetryDelay + time.Second)
+		return now
+	}
+}
+
+func assertPublished(t *testing.T, pub *flakyPublisher, want ...string) {
+	t.Helper()
+
+	if len(pub.published) != len(want) {
+		t.Fatalf("expected %v published, got %v", want, pub.published)
+	}
+	for i, id := range pub.published {
+		if id != want[i] {
+			t.Fatalf("expected %v published, got %v", want, pub.published)
+		}
+	}
+}
+
+func TestRelay_RedeliversInOrderAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	outbox := newFakeOutbox("a", "b", "c")
+	pub := &flakyPublisher{failOnce: map[string]bool{"b": true}}
+	relay := newTestRelay(t, outbox, DefaultRelayAttempts, Sink{Name: "test", Publisher: pub})
+
+	ctx := context.Background()
+	if n, err := relay.Drain(ctx); err == nil || n != 1 {
+		t.Fatalf("expected one event relayed before the failure, got %d, %v", n, err)
+	}
+	if len(outbox.entries) != 2 {
+		t.Fatalf("expected failed and later events to stay in the outbox, got %+v", outbox.entries)
+	}
+	// The failed event waits for its backoff.
+	if n, err := relay.Drain(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing relayed before the retry is due, got %d, %v", n, err)
+	}
+	skipBackoff(relay)
+	if n, err := relay.Drain(ctx); err != nil || n != 2 {
+		t.Fatalf("expected the rest to be relayed, got %d, %v", n, err)
+	}
+	if len(outbox.entries) != 0 {
+		t.Fatalf("expected empty outbox, got %+v", outbox.entries)
+	}
+	assertPublished(t, pub, "a", "b", "c")
+}
+
+func TestRelay_PrunesOnlyAfterProgress(t *testing.T) {
+	t.Parallel()
+
+	outbox := newFakeOutbox("a")
+	relay := newTestRelay(t, outbox, DefaultRelayAttempts, Sink{Name: "test", Publisher: &flakyPublisher{}})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := relay.Drain(ctx); err != nil {
+			t.Fatalf("drain: %v", err)
+		}
+	}
+	if outbox.prunes != 1 || len(outbox.entries) != 0 {
+		t.Fatalf("expected a single prune for the one delivery, got %d, %+v", outbox.prunes, outbox.entries)
+	}
+
+	outbox.entries = append(outbox.entries, ports.OutboxEntry{Seq: 2, Event: domain.Event{ID: "b"}})
+	if _, err := relay.Drain(ctx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if outbox.prunes != 2 || len(outbox.entries) != 0 {
+		t.Fatalf("expected a prune after the next delivery, got %d, %+v", outbox.prunes, outbox.entries)
+	}
+}
+
+func TestRelay_FailingSinkDoesNotDuplicateOthers(t *testing.T) {
+	t.Parallel()
+
+	outbox := newFakeOutbox("a", "b", "c")
+	healthy := &flakyPublisher{}
+	nats := &flakyPublisher{failAlways: map[string]bool{"a": true, "b": true, "c": true}}
+	relay := newTestRelay(t, outbox, DefaultRelayAttempts,
+		Sink{Name: "streams", Publisher: healthy},
+		Sink{Name: "nats", Publisher: nats})
+	skipBackoff(relay)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := relay.Drain(ctx); err == nil {
+			t.Fatalf("pass %d: expected the nats sink to fail", i)
+		}
+	}
+	assertPublished(t, healthy, "a", "b", "c")
+	if len(outbox.entries) != 3 {
+		t.Fatalf("expected entries kept for the failing sink, got %+v", outbox.entries)
+	}
+
+	// Once the sink recovers it catches up, and the others still see nothing twice.
+	nats.failAlways = nil
+	if _, err := relay.Drain(ctx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	assertPublished(t, nats, "a", "b", "c")
+	assertPublished(t, healthy, "a", "b", "c")
+	if len(outbox.entries) != 0 {
+		t.Fatalf("expected empty outbox, got %+v", outbox.entries)
+	}
+}
+
+func TestRelay_DeadLettersAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	outbox := newFakeOutbox("a", "poison", "c")
+	pub := &flakyPublisher{failAlways: map[string]bool{"poison": true}}
+	relay := newTestRelay(t, outbox, 3, Sink{Name: "nats", Publisher: pub})
+	skipBackoff(relay)
+
+	ctx := context.Background()
+	for i := 1; i < 3; i++ {
+		if _, err := relay.Drain(ctx); err == nil {
+			t.Fatalf("attempt %d: expected the poison event to fail", i)
+		}
+	}
+	if _, err := relay.Drain(ctx); err != nil {
+		t.Fatalf("expected the poison event dead-lettered, got %v", err)
+	}
+	if len(outbox.dead) != 1 || outbox.dead[0].Entry.Event.ID != "poison" || outbox.dead[0].Sink != "nats" || outbox.dead[0].Reason != "rejected" {
+		t.Fatalf("unexpected dead letters %+v", outbox.dead)
+	}
+	if _, err := relay.Drain(ctx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	assertPublished(t, pub, "a", "c")
+	if len(outbox.entries) != 0 {
+		t.Fatalf("expected empty outbox, got %+v", outbox.entries)
+	}
+}
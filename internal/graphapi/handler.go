@@ -0,0 +1,270 @@
+// Package graphapi serves the todo GraphQL API (see schema.graphqls) over the
+// same application services as the HTTP API.
+//
+// generated.go, models_gen.go and the resolver stubs in schema.resolvers.go
+// are generated by gqlgen from schema.graphqls and gqlgen.yml.
+package graphapi
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Default limits of an operation. Complexity counts one per field, with the
+// fields below a todos connection counted once per requested todo.
+const (
+	DefaultMaxDepth      = 10
+	DefaultMaxComplexity = 1000
+)
+
+// Error codes reported in the extensions of GraphQL errors. They are part of
+// the API contract, so never rename them.
+const (
+	codeBadUserInput    = "BAD_USER_INPUT"
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeNotFound        = "NOT_FOUND"
+	codeUnknownTenant   = "UNKNOWN_TENANT"
+	codeConflict        = "CONFLICT"
+	codeQuotaExceeded   = "QUOTA_EXCEEDED"
+	codeDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+	codeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+var (
+	errUnauthenticated = errors.New("mine requires a bearer token")
+	errInvalidCursor   = errors.New("invalid cursor")
+)
+
+type Options struct {
+	TodoService *todos.Service
+
+	// Events enables subscriptions.
+	Events *events.Bus
+
+	// TokenVerifier makes subscriptions require a bearer token, like the
+	// HTTP API does when it has one. Browsers cannot send headers with
+	// WebSocket upgrades, so the token may also come in the Authorization
+	// field of the connection_init payload; it then sets the tenant unless
+	// the upgrade request named one, which it must match.
+	TokenVerifier *auth.Verifier
+
+	// MaxDepth and MaxComplexity bound every operation. Zero uses
+	// DefaultMaxDepth and DefaultMaxComplexity.
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// NewHandler returns the GraphQL endpoint: queries and mutations over GET and
+// POST, subscriptions over WebSocket. Requests must already be scoped to a
+// tenant (and authenticated) like those of the HTTP API, except for
+// WebSocket upgrades, which TokenVerifier checks instead.
+func NewHandler(opts Options) (http.Handler, error) {
+	if opts.TodoService == nil {
+		return nil, errors.New("nil todo service")
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	if opts.MaxComplexity <= 0 {
+		opts.MaxComplexity = DefaultMaxComplexity
+	}
+
+	cfg := Config{Resolvers: &Resolver{svc: opts.TodoService, events: opts.Events}}
+	cfg.Complexity.Query.Todos = func(childComplexity int, _ *TodoFilter, first *int, _ *string) int {
+		n := defaultPageSize
+		if first != nil {
+			n = *first
+		}
+		return 1 + childComplexity*max(n, 1)
+	}
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              authenticateInit(opts.TokenVerifier),
+	})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New(1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(depthLimit(opts.MaxDepth))
+	srv.Use(extension.FixedComplexityLimit(opts.MaxComplexity))
+	srv.SetErrorPresenter(presentError)
+	srv.SetRecoverFunc(recoverPanic)
+	return srv, nil
+}
+
+// recoverPanic logs the panic value r of a resolver with the stack that
+// raised it and returns the error the client gets instead.
+func recoverPanic(ctx context.Context, r any) error {
+	logging.FromContext(ctx).ErrorContext(ctx, "request_panicked",
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return gqlerror.Errorf("internal error")
+}
+
+// authenticateInit checks the connection_init message of a subscription
+// connection against v. Without a verifier every connection is accepted.
+func authenticateInit(v *auth.Verifier) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		if v == nil {
+			return ctx, nil, nil
+		}
+		if h := payload.Authorization(); h != "" {
+			token, ok := strings.CutPrefix(h, "Bearer ")
+			if !ok {
+				return nil, nil, errors.New("the authorization must use the Bearer scheme")
+			}
+			claims, err := v.Verify(strings.TrimSpace(token))
+			if err != nil {
+				return nil, nil, errors.New("the bearer token is invalid or expired")
+			}
+			ctx = logging.With(ctx, slog.String("user", claims.Subject))
+			ctx = auth.WithClaims(ctx, claims)
+		}
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			return nil, nil, errors.New("a bearer token is required")
+		}
+		if claims.Tenant == "" {
+			return nil, nil, errors.New("the token is not bound to a tenant")
+		}
+		if id, named := tenancy.Lookup(ctx); named && id != claims.Tenant {
+			return nil, nil, errors.New("the token is not valid for this tenant")
+		}
+		ctx = logging.With(ctx, slog.String("tenant", claims.Tenant))
+		return tenancy.WithID(ctx, claims.Tenant), nil, nil
+	}
+}
+
+// presentError maps service errors to GraphQL errors with a machine readable
+// extensions.code, the counterpart of the HTTP API's problem types.
+// Unexpected errors are logged and masked as "internal error".
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gerr := graphql.DefaultErrorPresenter(ctx, err)
+	var (
+		verr  *domain.ValidationError
+		quota *domain.QuotaError
+		ext   = map[string]any{}
+	)
+	switch {
+	case errors.As(err, &verr):
+		ext["code"] = codeBadUserInput
+		violations := make([]map[string]any, 0, len(verr.Violations))
+		for _, v := range verr.Violations {
+			violations = append(violations, map[string]any{"field": v.Field, "rule": v.Rule, "detail": v.Detail})
+		}
+		ext["violations"] = violations
+	case errors.As(err, &quota):
+		ext["code"] = codeQuotaExceeded
+	case errors.Is(err, errUnauthenticated):
+		ext["code"] = codeUnauthenticated
+	case errors.Is(err, ports.ErrUnknownTenant):
+		ext["code"] = codeUnknownTenant
+		gerr.Message = "the tenant does not exist or has been deprovisioned"
+	case errors.Is(err, ports.ErrNotFound):
+		ext["code"] = codeNotFound
+		gerr.Message = "todo not found"
+	case errors.Is(err, ports.ErrConflict):
+		ext["code"] = codeConflict
+		gerr.Message = "the todo was changed concurrently; retry"
+	default:
+		var known *gqlerror.Error
+		if errors.As(err, &known) {
+			// Parse, validation and limit errors, already client facing.
+			return gerr
+		}
+		logging.FromContext(ctx).ErrorContext(ctx, "request_failed",
+			slog.String("error", err.Error()),
+			slog.String("path", gerr.Path.String()),
+		)
+		ext["code"] = codeInternal
+		gerr.Message = "internal error"
+	}
+	for k, v := range gerr.Extensions {
+		ext[k] = v
+	}
+	gerr.Extensions = ext
+	return gerr
+}
+
+// badInput reports an invalid argument.
+func badInput(field, detail string) error {
+	return &domain.ValidationError{Violations: []domain.FieldViolation{{
+		Field:  field,
+		Rule:   domain.RuleFormat,
+		Detail: detail,
+	}}}
+}
+
+// depthLimit rejects operations nested deeper than its value. Introspection
+// fields are not counted, so GraphiQL and code generators keep working.
+type depthLimit int
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit(0)
+
+func (depthLimit) ExtensionName() string { return "DepthLimit" }
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (d depthLimit) MutateOperationContext(_ context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	if rc.Operation == nil {
+		return nil
+	}
+	if depth := selectionDepth(rc.Operation.SelectionSet); depth > int(d) {
+		err := gqlerror.Errorf("operation has depth %d, over the limit of %d", depth, int(d))
+		err.Extensions = map[string]any{"code": codeDepthLimit}
+		return err
+	}
+	return nil
+}
+
+func selectionDepth(set ast.SelectionSet) int {
+	deepest := 0
+	for _, sel := range set {
+		var depth int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if len(sel.Name) >= 2 && sel.Name[:2] == "__" {
+				continue
+			}
+			depth = 1 + selectionDepth(sel.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selectionDepth(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			// Validation has rejected fragment cycles by now.
+			if sel.Definition != nil {
+				depth = selectionDepth(sel.Definition.SelectionSet)
+			}
+		}
+		deepest = max(deepest, depth)
+	}
+	return deepest
+}
@@ -0,0 +1,412 @@
+package graphapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+type todoJSON struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	Version   int    `json:"version"`
+}
+
+type gqlError struct {
+	Message    string         `json:"message"`
+	Extensions map[string]any `json:"extensions"`
+}
+
+func newTestClient(t *testing.T, bus *events.Bus, opts Options) (*client.Client, *todos.Service) {
+	t.Helper()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	opts.TodoService = svc
+	opts.Events = bus
+	h, err := NewHandler(opts)
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	return client.New(h), svc
+}
+
+// errorsOf runs query and returns the errors of the response.
+func errorsOf(t *testing.T, c *client.Client, query string, opts ...client.Option) []gqlError {
+	t.Helper()
+	resp, err := c.RawPost(query, opts...)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	var errs []gqlError
+	if len(resp.Errors) > 0 {
+		if err := json.Unmarshal(resp.Errors, &errs); err != nil {
+			t.Fatalf("decode errors: %v", err)
+		}
+	}
+	return errs
+}
+
+func TestNewHandler_RejectsNilService(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewHandler(Options{}); err == nil {
+		t.Fatalf("expected error for nil todo service")
+	}
+}
+
+func TestGraphQL_Mutations(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newTestClient(t, nil, Options{})
+
+	var created struct{ CreateTodo todoJSON }
+	c.MustPost(`mutation($t: String!) { createTodo(input: {title: $t}) { id title completed version } }`,
+		&created, client.Var("t", "buy milk"))
+	if created.CreateTodo.ID == "" || created.CreateTodo.Title != "buy milk" || created.CreateTodo.Version != 1 {
+		t.Fatalf("unexpected created todo: %+v", created.CreateTodo)
+	}
+	id := created.CreateTodo.ID
+
+	var toggled struct{ ToggleTodo todoJSON }
+	c.MustPost(`mutation($id: ID!) { toggleTodo(id: $id) { completed version } }`, &toggled, client.Var("id", id))
+	if !toggled.ToggleTodo.Completed || toggled.ToggleTodo.Version != 2 {
+		t.Fatalf("expected toggled todo at version 2, got %+v", toggled.ToggleTodo)
+	}
+
+	// Omitted fields keep their value.
+	var updated struct{ UpdateTodo todoJSON }
+	c.MustPost(`mutation($id: ID!) { updateTodo(input: {id: $id, title: "buy oat milk"}) { title completed } }`,
+		&updated, client.Var("id", id))
+	if updated.UpdateTodo.Title != "buy oat milk" || !updated.UpdateTodo.Completed {
+		t.Fatalf("unexpected updated todo: %+v", updated.UpdateTodo)
+	}
+
+	var deleted struct{ DeleteTodo string }
+	c.MustPost(`mutation($id: ID!) { deleteTodo(id: $id) }`, &deleted, client.Var("id", id))
+	if deleted.DeleteTodo != id {
+		t.Fatalf("expected deleted id %q, got %q", id, deleted.DeleteTodo)
+	}
+
+	var got struct{ Todo *todoJSON }
+	c.MustPost(`query($id: ID!) { todo(id: $id) { id } }`, &got, client.Var("id", id))
+	if got.Todo != nil {
+		t.Fatalf("expected null for a deleted todo, got %+v", got.Todo)
+	}
+
+	errs := errorsOf(t, c, `mutation($id: ID!) { toggleTodo(id: $id) { id } }`, client.Var("id", id))
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeNotFound {
+		t.Fatalf("expected a NOT_FOUND error, got %+v", errs)
+	}
+
+	errs = errorsOf(t, c, `mutation { createTodo(input: {title: ""}) { id } }`)
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeBadUserInput || errs[0].Extensions["violations"] == nil {
+		t.Fatalf("expected a BAD_USER_INPUT error with violations, got %+v", errs)
+	}
+}
+
+func TestGraphQL_ConcurrentTogglesAreNotLost(t *testing.T) {
+	t.Parallel()
+
+	c, svc := newTestClient(t, nil, Options{})
+	td, err := svc.Create(context.Background(), "buy milk")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	const toggles = 8
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		applied int
+	)
+	for i := 0; i < toggles; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.RawPost(`mutation($id: ID!) { toggleTodo(id: $id) { id } }`, client.Var("id", td.ID))
+			if err != nil {
+				t.Errorf("post: %v", err)
+				return
+			}
+			var errs []gqlError
+			_ = json.Unmarshal(resp.Errors, &errs)
+			switch {
+			case len(errs) == 0:
+				mu.Lock()
+				applied++
+				mu.Unlock()
+			case errs[0].Extensions["code"] != codeConflict:
+				t.Errorf("expected success or a CONFLICT error, got %+v", errs)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := svc.Get(context.Background(), td.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	// Every applied toggle wrote a version and flipped the todo once.
+	if applied == 0 || got.Version != uint64(1+applied) || got.Completed != (applied%2 == 1) {
+		t.Fatalf("expected %d toggles applied in turn, got %+v", applied, got)
+	}
+}
+
+func TestPresentError_LogsInternalErrorsAndPanics(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	gerr := presentError(ctx, errors.New("disk on fire"))
+	if gerr.Message != "internal error" || gerr.Extensions["code"] != codeInternal {
+		t.Fatalf("expected a masked internal error, got %+v", gerr)
+	}
+	if out := buf.String(); !strings.Contains(out, "request_failed") || !strings.Contains(out, "disk on fire") {
+		t.Fatalf("expected the error to be logged, got %s", out)
+	}
+
+	buf.Reset()
+	var gqlErr *gqlerror.Error
+	if err := recoverPanic(ctx, "boom"); !errors.As(err, &gqlErr) || gqlErr.Message != "internal error" {
+		t.Fatalf("expected a masked internal error, got %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "request_panicked") || !strings.Contains(out, "boom") || !strings.Contains(out, "graphapi.recoverPanic") {
+		t.Fatalf("expected the panic to be logged with its stack, got %s", out)
+	}
+}
+
+func TestGraphQL_TodosPaginationAndFilter(t *testing.T) {
+	t.Parallel()
+
+	c, svc := newTestClient(t, nil, Options{})
+	ctx := context.Background()
+	for _, title := range []string{"Buy milk", "call mom", "buy bread", "pay rent", "BUY eggs"} {
+		td, err := svc.Create(ctx, title)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if title == "pay rent" {
+			if _, err := svc.Update(ctx, td.ID, td.Title, true); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+		}
+	}
+
+	type page struct {
+		Todos struct {
+			Edges []struct {
+				Cursor string
+				Node   todoJSON
+			}
+			PageInfo struct {
+				HasNextPage bool
+				EndCursor   *string
+			}
+			TotalCount int
+		}
+	}
+	const query = `query($first: Int, $after: String, $filter: TodoFilter) {
+		todos(first: $first, after: $after, filter: $filter) {
+			edges { cursor node { id title } }
+			pageInfo { hasNextPage endCursor }
+			totalCount
+		}
+	}`
+
+	seen := map[string]bool{}
+	var after *string
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("pagination did not end")
+		}
+		var p page
+		c.MustPost(query, &p, client.Var("first", 2), client.Var("after", after))
+		if p.Todos.TotalCount != 5 {
+			t.Fatalf("expected totalCount 5, got %d", p.Todos.TotalCount)
+		}
+		for _, e := range p.Todos.Edges {
+			if seen[e.Node.ID] {
+				t.Fatalf("todo %s returned twice", e.Node.ID)
+			}
+			seen[e.Node.ID] = true
+		}
+		if !p.Todos.PageInfo.HasNextPage {
+			break
+		}
+		after = p.Todos.PageInfo.EndCursor
+	}
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 todos across pages, got %d", len(seen))
+	}
+
+	var p page
+	c.MustPost(query, &p, client.Var("filter", map[string]any{"titleContains": "buy", "completed": false}))
+	if p.Todos.TotalCount != 3 || len(p.Todos.Edges) != 3 || p.Todos.PageInfo.HasNextPage {
+		t.Fatalf("expected the 3 open buy todos, got %+v", p.Todos)
+	}
+
+	errs := errorsOf(t, c, `{ todos(after: "nope") { totalCount } }`)
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeBadUserInput {
+		t.Fatalf("expected a BAD_USER_INPUT error for a bad cursor, got %+v", errs)
+	}
+	errs = errorsOf(t, c, `{ todos(filter: {mine: true}) { totalCount } }`)
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeUnauthenticated {
+		t.Fatalf("expected an UNAUTHENTICATED error for mine without a token, got %+v", errs)
+	}
+}
+
+func TestGraphQL_Limits(t *testing.T) {
+	t.Parallel()
+
+	c, _ := newTestClient(t, nil, Options{MaxDepth: 3, MaxComplexity: 50})
+
+	errs := errorsOf(t, c, `{ todos(first: 1) { edges { node { id } } } }`)
+	if len(errs) != 1 || errs[0].Extensions["code"] != codeDepthLimit {
+		t.Fatalf("expected a depth limit error, got %+v", errs)
+	}
+
+	// Introspection does not count towards the depth limit.
+	if errs := errorsOf(t, c, `{ __schema { types { name fields { name type { name } } } } }`); len(errs) != 0 {
+		t.Fatalf("expected introspection to pass, got %+v", errs)
+	}
+
+	errs = errorsOf(t, c, `{ todos(first: 100) { totalCount pageInfo { hasNextPage } } }`)
+	if len(errs) != 1 || errs[0].Extensions["code"] != "COMPLEXITY_LIMIT_EXCEEDED" {
+		t.Fatalf("expected a complexity limit error, got %+v", errs)
+	}
+	if errs := errorsOf(t, c, `{ todos(first: 5) { totalCount } }`); len(errs) != 0 {
+		t.Fatalf("expected a small page to pass, got %+v", errs)
+	}
+}
+
+func TestGraphQL_Subscription(t *testing.T) {
+	t.Parallel()
+
+	bus := events.NewBus()
+	c, svc := newTestClient(t, bus, Options{})
+	td, err := svc.Create(context.Background(), "buy milk")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	sub := c.Websocket(`subscription { todoEvents { id type todo { id title } } }`)
+	defer func() { _ = sub.Close() }()
+	defer keepPublishing(bus, domain.Event{Type: domain.EventTodoCreated, Tenant: tenancy.DefaultID, Todo: td})()
+
+	var msg struct {
+		TodoEvents struct {
+			ID   string
+			Type string
+			Todo todoJSON
+		}
+	}
+	if err := sub.Next(&msg); err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if msg.TodoEvents.Type != string(TodoEventTypeCreated) || msg.TodoEvents.Todo.ID != td.ID || msg.TodoEvents.ID == "" {
+		t.Fatalf("unexpected event: %+v", msg.TodoEvents)
+	}
+}
+
+// keepPublishing publishes ev every few milliseconds until the returned
+// function is called. The resolver subscribes to the bus asynchronously, so
+// a single event may be published before anyone listens. Events reach the bus
+// through the outbox relay in production.
+func keepPublishing(bus *events.Bus, ev domain.Event) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = bus.Publish(context.Background(), ev)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func TestGraphQL_SubscriptionAuthentication(t *testing.T) {
+	t.Parallel()
+
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	sign := func(c auth.Claims) string {
+		token, err := verifier.Sign(c)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+	bus := events.NewBus()
+	c, _ := newTestClient(t, bus, Options{TokenVerifier: verifier})
+	const query = `subscription { todoEvents { type todo { id } } }`
+
+	for name, payload := range map[string]map[string]any{
+		"anonymous":      nil,
+		"invalid token":  {"Authorization": "Bearer nope"},
+		"wrong scheme":   {"Authorization": "Basic " + sign(auth.Claims{Subject: "alice", Tenant: "acme"})},
+		"without tenant": {"Authorization": "Bearer " + sign(auth.Claims{Subject: "alice"})},
+	} {
+		sub := c.WebsocketWithPayload(query, payload)
+		var msg map[string]any
+		if err := sub.Next(&msg); err == nil {
+			t.Fatalf("%s: expected the connection to be refused, got %v", name, msg)
+		}
+		_ = sub.Close()
+	}
+
+	sub := c.WebsocketWithPayload(query, map[string]any{"Authorization": "Bearer " + sign(auth.Claims{Subject: "alice", Tenant: "acme"})})
+	defer func() { _ = sub.Close() }()
+	defer keepPublishing(bus, domain.Event{Type: domain.EventTodoCreated, Tenant: "acme", Todo: domain.Todo{ID: "t1"}})()
+
+	var msg struct {
+		TodoEvents struct {
+			Type string
+			Todo todoJSON
+		}
+	}
+	if err := sub.Next(&msg); err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if msg.TodoEvents.Todo.ID != "t1" {
+		t.Fatalf("expected the event of tenant acme, got %+v", msg.TodoEvents)
+	}
+}
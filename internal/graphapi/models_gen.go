@@ -0,0 +1,100 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graphapi
+
+import (
+	"challenge-backend-arancia/internal/domain"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type CreateTodoInput struct {
+	Title string `json:"title"`
+}
+
+type Mutation struct {
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
+type Query struct {
+}
+
+type Subscription struct {
+}
+
+type TodoConnection struct {
+	Edges    []*TodoEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+	// Number of todos matching the filter, across all pages.
+	TotalCount int `json:"totalCount"`
+}
+
+type TodoEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *domain.Todo `json:"node"`
+}
+
+type TodoFilter struct {
+	Completed *bool `json:"completed,omitempty"`
+	// Case-insensitive substring of the title.
+	TitleContains *string `json:"titleContains,omitempty"`
+	// Only todos owned by the authenticated user. Requires a bearer token.
+	Mine *bool `json:"mine,omitempty"`
+}
+
+// Fields left out keep their current value.
+type UpdateTodoInput struct {
+	ID        string  `json:"id"`
+	Title     *string `json:"title,omitempty"`
+	Completed *bool   `json:"completed,omitempty"`
+}
+
+type TodoEventType string
+
+const (
+	TodoEventTypeCreated   TodoEventType = "CREATED"
+	TodoEventTypeUpdated   TodoEventType = "UPDATED"
+	TodoEventTypeCompleted TodoEventType = "COMPLETED"
+	TodoEventTypeDeleted   TodoEventType = "DELETED"
+)
+
+var AllTodoEventType = []TodoEventType{
+	TodoEventTypeCreated,
+	TodoEventTypeUpdated,
+	TodoEventTypeCompleted,
+	TodoEventTypeDeleted,
+}
+
+func (e TodoEventType) IsValid() bool {
+	switch e {
+	case TodoEventTypeCreated, TodoEventTypeUpdated, TodoEventTypeCompleted, TodoEventTypeDeleted:
+		return true
+	}
+	return false
+}
+
+func (e TodoEventType) String() string {
+	return string(e)
+}
+
+func (e *TodoEventType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TodoEventType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TodoEventType", str)
+	}
+	return nil
+}
+
+func (e TodoEventType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,121 @@
+package graphapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/ports"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Page sizes of the todos connection.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// editAttempts is how many times a mutation is applied to a todo that keeps
+// changing concurrently before it fails with a conflict.
+const editAttempts = 3
+
+// cursorPrefix namespaces cursors so they stay opaque to clients.
+const cursorPrefix = "todo:"
+
+// Resolver resolves the schema against todos.Service, like the HTTP handlers.
+// It holds no per-request state.
+type Resolver struct {
+	svc    *todos.Service
+	events *events.Bus
+}
+
+var eventTypes = map[domain.EventType]TodoEventType{
+	domain.EventTodoCreated:   TodoEventTypeCreated,
+	domain.EventTodoUpdated:   TodoEventTypeUpdated,
+	domain.EventTodoCompleted: TodoEventTypeCompleted,
+	domain.EventTodoDeleted:   TodoEventTypeDeleted,
+}
+
+// edit applies change to the current todo id and writes it back at the
+// version it was read at, starting over when a concurrent write got there
+// first, so neither write is lost.
+func (r *mutationResolver) edit(ctx context.Context, id string, change func(*domain.Todo)) (*domain.Todo, error) {
+	for attempt := 1; ; attempt++ {
+		td, err := r.svc.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		change(&td)
+		td, err = r.svc.Edit(ctx, id, todos.Edit{Title: td.Title, Completed: td.Completed, Version: td.Version})
+		if errors.Is(err, ports.ErrConflict) && attempt < editAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &td, nil
+	}
+}
+
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + id))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", err
+	}
+	id, ok := strings.CutPrefix(string(b), cursorPrefix)
+	if !ok {
+		return "", errInvalidCursor
+	}
+	return id, nil
+}
+
+// todoFilter returns a predicate for the todos selected by f, nil when it
+// selects all of them.
+func todoFilter(ctx context.Context, f *TodoFilter) (func(domain.Todo) bool, error) {
+	if f == nil || (f.Completed == nil && f.TitleContains == nil && (f.Mine == nil || !*f.Mine)) {
+		return nil, nil
+	}
+	owner := ""
+	if f.Mine != nil && *f.Mine {
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			return nil, errUnauthenticated
+		}
+		owner = claims.Subject
+	}
+	title := ""
+	if f.TitleContains != nil {
+		title = strings.ToLower(*f.TitleContains)
+	}
+	return func(td domain.Todo) bool {
+		switch {
+		case f.Completed != nil && td.Completed != *f.Completed:
+			return false
+		case owner != "" && td.Owner != owner:
+			return false
+		case title != "" && !strings.Contains(strings.ToLower(td.Title), title):
+			return false
+		}
+		return true
+	}, nil
+}
+
+// selects reports whether the field being resolved selects the named field.
+func selects(ctx context.Context, name string) bool {
+	for _, f := range graphql.CollectFieldsCtx(ctx, nil) {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package graphapi
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.49
+
+import (
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// CreateTodo is the resolver for the createTodo field.
+func (r *mutationResolver) CreateTodo(ctx context.Context, input CreateTodoInput) (*domain.Todo, error) {
+	td, err := r.svc.Create(ctx, input.Title)
+	if err != nil {
+		return nil, err
+	}
+	return &td, nil
+}
+
+// UpdateTodo is the resolver for the updateTodo field.
+func (r *mutationResolver) UpdateTodo(ctx context.Context, input UpdateTodoInput) (*domain.Todo, error) {
+	return r.edit(ctx, input.ID, func(td *domain.Todo) {
+		if input.Title != nil {
+			td.Title = *input.Title
+		}
+		if input.Completed != nil {
+			td.Completed = *input.Completed
+		}
+	})
+}
+
+// ToggleTodo is the resolver for the toggleTodo field.
+func (r *mutationResolver) ToggleTodo(ctx context.Context, id string) (*domain.Todo, error) {
+	return r.edit(ctx, id, func(td *domain.Todo) { td.Completed = !td.Completed })
+}
+
+// DeleteTodo is the resolver for the deleteTodo field.
+func (r *mutationResolver) DeleteTodo(ctx context.Context, id string) (string, error) {
+	if err := r.svc.Delete(ctx, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Todo is the resolver for the todo field.
+func (r *queryResolver) Todo(ctx context.Context, id string) (*domain.Todo, error) {
+	td, err := r.svc.Get(ctx, id)
+	if errors.Is(err, ports.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &td, nil
+}
+
+// Todos is the resolver for the todos field.
+func (r *queryResolver) Todos(ctx context.Context, filter *TodoFilter, first *int, after *string) (*TodoConnection, error) {
+	limit := defaultPageSize
+	if first != nil {
+		limit = *first
+	}
+	if limit < 0 || limit > maxPageSize {
+		return nil, badInput("first", fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+	}
+	var afterID string
+	if after != nil {
+		id, err := decodeCursor(*after)
+		if err != nil {
+			return nil, badInput("after", "after must be a cursor returned by a previous query")
+		}
+		afterID = id
+	}
+	match, err := todoFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page, more, err := r.svc.ListAfter(ctx, afterID, limit, match)
+	if err != nil {
+		return nil, err
+	}
+	conn := &TodoConnection{Edges: []*TodoEdge{}, PageInfo: &PageInfo{HasNextPage: more}}
+	for i := range page {
+		conn.Edges = append(conn.Edges, &TodoEdge{Cursor: encodeCursor(page[i].ID), Node: &page[i]})
+	}
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[n-1].Cursor
+	}
+	// Counting reads every todo when filtering, so only do it when asked.
+	if selects(ctx, "totalCount") {
+		if conn.TotalCount, err = r.svc.Count(ctx, match); err != nil {
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// TodoEvents is the resolver for the todoEvents field.
+func (r *subscriptionResolver) TodoEvents(ctx context.Context, mine *bool) (<-chan *domain.Event, error) {
+	if r.events == nil {
+		return nil, errors.New("subscriptions are disabled")
+	}
+	tenant := tenancy.FromContext(ctx)
+	filter := func(ev domain.Event) bool { return ev.Tenant == tenant }
+	if mine != nil && *mine {
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			return nil, errUnauthenticated
+		}
+		filter = func(ev domain.Event) bool {
+			return ev.Tenant == tenant && ev.Todo.Owner == claims.Subject
+		}
+	}
+
+	sub, err := r.events.Subscribe(filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *domain.Event)
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-sub.C():
+				if !ok {
+					// Lagging subscribers and shutdown end the subscription;
+					// clients resubscribe and refetch.
+					return
+				}
+				select {
+				case out <- &ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// ID is the resolver for the id field.
+func (r *todoEventResolver) ID(ctx context.Context, obj *domain.Event) (string, error) {
+	return strconv.FormatUint(obj.Seq, 10), nil
+}
+
+// Type is the resolver for the type field.
+func (r *todoEventResolver) Type(ctx context.Context, obj *domain.Event) (TodoEventType, error) {
+	typ, ok := eventTypes[obj.Type]
+	if !ok {
+		return "", fmt.Errorf("unknown event type %q", obj.Type)
+	}
+	return typ, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// TodoEvent returns TodoEventResolver implementation.
+func (r *Resolver) TodoEvent() TodoEventResolver { return &todoEventResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type todoEventResolver struct{ *Resolver }
@@ -0,0 +1,535 @@
+// Package grpcapi serves the todo.v1 gRPC API (see proto/todo/v1/todo.proto)
+// over the same application services as the HTTP API.
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/grpcapi/todov1"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/ratelimit"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Options struct {
+	TodoService *todos.Service
+	// Logger writes a line per call and, scoped to the call, is attached to
+	// its context for logging.FromContext. LogSampler keeps a share of the
+	// lines of successful calls; nil keeps them all.
+	Logger     *slog.Logger
+	LogSampler *logging.Sampler
+
+	// TenantResolvers are tried in order to find the tenant of a call. Calls
+	// matching none use the tenant of their token when TokenVerifier is set,
+	// and tenancy.DefaultID otherwise unless RequireTenant is set.
+	TenantResolvers []TenantResolver
+	RequireTenant   bool
+
+	// TokenVerifier enables bearer token authentication when set. Like the
+	// HTTP API, it then rejects anonymous calls and tokens without a tenant.
+	TokenVerifier *auth.Verifier
+
+	// RateLimiter rejects calls over the limit with ResourceExhausted when
+	// set. Clients are told apart as by the HTTP API, whose buckets they
+	// share when given the same limiter: RateLimitKey "user" by the subject
+	// of a verified token, "apikey" by an "x-api-key" metadata value listed
+	// in RateLimitAPIKeys, and by peer IP otherwise.
+	RateLimiter      *ratelimit.Limiter
+	RateLimitKey     string
+	RateLimitAPIKeys []string
+
+	// TLSConfig serves over TLS when set. Verified client certificates
+	// authenticate calls without a bearer token.
+	TLSConfig *tls.Config
+
+	// Events enables WatchTodos.
+	Events *events.Bus
+}
+
+// NewServer returns a gRPC server with the todo.v1 service and server
+// reflection registered.
+func NewServer(opts Options) (*grpc.Server, error) {
+	if opts.TodoService == nil {
+		return nil, errors.New("nil todo service")
+	}
+
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	if opts.Logger != nil {
+		unary = append(unary, logUnary(opts.Logger, opts.LogSampler))
+		stream = append(stream, logStream(opts.Logger, opts.LogSampler))
+	}
+	// Panics are recovered within the logging, so they are logged with the
+	// logger of the call.
+	unary = append(unary, recoverUnary())
+	stream = append(stream, recoverStream())
+	scope := scopeCall(opts.TokenVerifier, opts.TenantResolvers, opts.RequireTenant)
+	limit := limitCall(opts.RateLimiter, opts.RateLimitKey, opts.RateLimitAPIKeys)
+	unary = append(unary, func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := scope(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := limit(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	})
+	stream = append(stream, func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := scope(ss.Context())
+		if err != nil {
+			return err
+		}
+		if err := limit(ctx); err != nil {
+			return err
+		}
+		return handler(srv, scopedStream{ServerStream: ss, ctx: ctx})
+	})
+
+	serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+	if opts.TLSConfig != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.TLSConfig)))
+	}
+	s := grpc.NewServer(serverOpts...)
+	todov1.RegisterTodoServiceServer(s, &todoServer{svc: opts.TodoService, events: opts.Events})
+	reflection.Register(s)
+	return s, nil
+}
+
+// todoServer implements todo.v1.TodoService. Like the HTTP handlers it only
+// translates between the wire format and todos.Service.
+type todoServer struct {
+	todov1.UnimplementedTodoServiceServer
+
+	svc    *todos.Service
+	events *events.Bus
+}
+
+func (s *todoServer) ListTodos(ctx context.Context, _ *todov1.ListTodosRequest) (*todov1.ListTodosResponse, error) {
+	out, err := s.svc.List(ctx)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	resp := &todov1.ListTodosResponse{Todos: make([]*todov1.Todo, 0, len(out))}
+	for _, td := range out {
+		resp.Todos = append(resp.Todos, toProto(td))
+	}
+	return resp, nil
+}
+
+func (s *todoServer) CreateTodo(ctx context.Context, req *todov1.CreateTodoRequest) (*todov1.Todo, error) {
+	td, err := s.svc.Create(ctx, req.GetTitle())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(td), nil
+}
+
+func (s *todoServer) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoRequest) (*todov1.Todo, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id must not be empty")
+	}
+	td, err := s.svc.Update(ctx, req.GetId(), req.GetTitle(), req.GetCompleted())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(td), nil
+}
+
+func (s *todoServer) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoRequest) (*todov1.DeleteTodoResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id must not be empty")
+	}
+	if err := s.svc.Delete(ctx, req.GetId()); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &todov1.DeleteTodoResponse{}, nil
+}
+
+// WatchTodos streams the tenant's todo events, like the SSE and WebSocket
+// change streams of the HTTP API.
+func (s *todoServer) WatchTodos(req *todov1.WatchTodosRequest, stream todov1.TodoService_WatchTodosServer) error {
+	if s.events == nil {
+		return status.Error(codes.Unimplemented, "change streams are disabled")
+	}
+	ctx := stream.Context()
+	tenant := tenancy.FromContext(ctx)
+	filter := func(ev domain.Event) bool { return ev.Tenant == tenant }
+	if req.GetMine() {
+		claims, ok := auth.FromContext(ctx)
+		if !ok {
+			return status.Error(codes.Unauthenticated, "mine requires a bearer token")
+		}
+		filter = func(ev domain.Event) bool {
+			return ev.Tenant == tenant && ev.Todo.Owner == claims.Subject
+		}
+	}
+
+	sub, err := s.events.Subscribe(filter, req.GetLastEventId())
+	if err != nil {
+		return status.Error(codes.Unavailable, "the server is shutting down")
+	}
+	defer sub.Close()
+
+	// Send headers right away so clients know the subscription is live.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	if sub.Gap {
+		reset := &todov1.WatchTodosResponse{Message: &todov1.WatchTodosResponse_StreamReset{StreamReset: &todov1.StreamReset{}}}
+		if err := stream.Send(reset); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case ev, ok := <-sub.C():
+			if !ok {
+				if sub.Lagged {
+					return status.Error(codes.Aborted, "the client fell too far behind; reconnect with the last event id")
+				}
+				return status.Error(codes.Unavailable, "the server is shutting down")
+			}
+			msg := &todov1.WatchTodosResponse{Message: &todov1.WatchTodosResponse_Event{Event: toEventProto(ev)}}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProto(td domain.Todo) *todov1.Todo {
+	return &todov1.Todo{Id: td.ID, Title: td.Title, Completed: td.Completed, Version: td.Version}
+}
+
+var eventTypes = map[domain.EventType]todov1.EventType{
+	domain.EventTodoCreated:   todov1.EventType_EVENT_TYPE_CREATED,
+	domain.EventTodoUpdated:   todov1.EventType_EVENT_TYPE_UPDATED,
+	domain.EventTodoCompleted: todov1.EventType_EVENT_TYPE_COMPLETED,
+	domain.EventTodoDeleted:   todov1.EventType_EVENT_TYPE_DELETED,
+}
+
+func toEventProto(ev domain.Event) *todov1.TodoEvent {
+	return &todov1.TodoEvent{
+		Id:         ev.Seq,
+		Type:       eventTypes[ev.Type],
+		OccurredAt: timestamppb.New(ev.OccurredAt),
+		Todo:       toProto(ev.Todo),
+	}
+}
+
+// toStatus maps service errors to gRPC statuses, the counterpart of the HTTP
+// API's writeError. Validation errors carry a BadRequest detail listing every
+// violation.
+func toStatus(ctx context.Context, err error) error {
+	var (
+		verr  *domain.ValidationError
+		quota *domain.QuotaError
+	)
+	switch {
+	case errors.As(err, &verr):
+		st := status.New(codes.InvalidArgument, verr.Error())
+		br := &errdetails.BadRequest{}
+		for _, v := range verr.Violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Detail,
+			})
+		}
+		if withDetails, err := st.WithDetails(br); err == nil {
+			st = withDetails
+		}
+		return st.Err()
+	case errors.As(err, &quota):
+		return status.Error(codes.ResourceExhausted, quota.Error())
+	case errors.Is(err, ports.ErrUnknownTenant):
+		return status.Error(codes.NotFound, "the tenant does not exist or has been deprovisioned")
+	case errors.Is(err, ports.ErrNotFound):
+		return status.Error(codes.NotFound, "todo not found")
+	case errors.Is(err, ports.ErrConflict):
+		return status.Error(codes.Aborted, "the todo was changed concurrently; retry")
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		logging.FromContext(ctx).ErrorContext(ctx, "request_failed", slog.String("error", err.Error()))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// TenantResolver extracts a tenant ID from the context of a call, which
+// carries its metadata and, once authenticated, its claims. It reports false
+// when the call carries no tenant information it understands.
+type TenantResolver func(ctx context.Context) (string, bool)
+
+// MetadataTenantResolver reads the tenant from the named metadata key, the
+// gRPC counterpart of a request header.
+func MetadataTenantResolver(key string) TenantResolver {
+	key = strings.ToLower(key)
+	return func(ctx context.Context) (string, bool) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, v := range md.Get(key) {
+			if id := strings.TrimSpace(v); id != "" {
+				return id, true
+			}
+		}
+		return "", false
+	}
+}
+
+// AuthorityTenantResolver reads the tenant from the leftmost label of the
+// :authority of calls to <tenant>.<baseDomain>, the gRPC counterpart of
+// httpapi.SubdomainTenantResolver.
+func AuthorityTenantResolver(baseDomain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(ctx context.Context) (string, bool) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		v := md.Get(":authority")
+		if len(v) == 0 {
+			return "", false
+		}
+		host := v[0]
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		label, ok := strings.CutSuffix(strings.ToLower(host), suffix)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			return "", false
+		}
+		return label, true
+	}
+}
+
+// ClaimTenantResolver reads the tenant claim of the authenticated bearer token.
+func ClaimTenantResolver() TenantResolver {
+	return func(ctx context.Context) (string, bool) {
+		c, ok := auth.FromContext(ctx)
+		if !ok || c.Tenant == "" {
+			return "", false
+		}
+		return c.Tenant, true
+	}
+}
+
+// scopeCall authenticates a call and scopes its context to a tenant, applying
+// the same rules as the HTTP API's client certificate, auth and tenant
+// middleware. With a verifier, calls must be authenticated by a credential
+// bound to a tenant, which is their tenant unless a resolver agrees with it.
+func scopeCall(v *auth.Verifier, resolvers []TenantResolver, required bool) func(context.Context) (context.Context, error) {
+	return func(ctx context.Context) (context.Context, error) {
+		if p, ok := peer.FromContext(ctx); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+				claims, ok := auth.ClaimsFromCertificate(info.State.VerifiedChains[0][0])
+				if !ok {
+					return nil, status.Error(codes.Unauthenticated, "the client certificate names no subject")
+				}
+				ctx = logging.With(ctx, slog.String("user", claims.Subject))
+				ctx = auth.WithClaims(ctx, claims)
+			}
+		}
+		if v != nil {
+			md, _ := metadata.FromIncomingContext(ctx)
+			if h := md.Get("authorization"); len(h) > 0 {
+				token, ok := strings.CutPrefix(h[0], "Bearer ")
+				if !ok {
+					return nil, status.Error(codes.Unauthenticated, "the authorization metadata must use the Bearer scheme")
+				}
+				claims, err := v.Verify(strings.TrimSpace(token))
+				if err != nil {
+					return nil, status.Error(codes.Unauthenticated, "the bearer token is invalid or expired")
+				}
+				ctx = logging.With(ctx, slog.String("user", claims.Subject))
+				ctx = auth.WithClaims(ctx, claims)
+			}
+		}
+
+		claims, signedIn := auth.FromContext(ctx)
+		if v != nil {
+			if !signedIn {
+				return nil, status.Error(codes.Unauthenticated, "a bearer token is required")
+			}
+			if claims.Tenant == "" {
+				return nil, status.Error(codes.PermissionDenied, "the token is not bound to a tenant")
+			}
+		}
+
+		id := ""
+		for _, resolve := range resolvers {
+			if v, ok := resolve(ctx); ok {
+				id = v
+				break
+			}
+		}
+		if id == "" && v != nil {
+			id = claims.Tenant
+		}
+		if id == "" && !required {
+			id = tenancy.DefaultID
+		}
+		if id == "" {
+			return nil, status.Error(codes.InvalidArgument, "the call does not identify a tenant")
+		}
+		if err := domain.ValidateTenantID(id); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if signedIn && claims.Tenant != "" && claims.Tenant != id {
+			return nil, status.Error(codes.PermissionDenied, "the token is not valid for this tenant")
+		}
+		ctx = logging.With(ctx, slog.String("tenant", id))
+		return tenancy.WithID(ctx, id), nil
+	}
+}
+
+// limitCall charges a call to the rate limit of its client. It runs after
+// scopeCall, so the claims it reads have been verified.
+func limitCall(l *ratelimit.Limiter, by string, apiKeys []string) func(context.Context) error {
+	known := make(map[string]bool, len(apiKeys))
+	for _, k := range apiKeys {
+		known[k] = true
+	}
+	return func(ctx context.Context) error {
+		if l == nil {
+			return nil
+		}
+		d := l.Allow(rateLimitKey(ctx, by, known))
+		if d.Allowed {
+			return nil
+		}
+		st := status.New(codes.ResourceExhausted, "rate limit exceeded, retry later")
+		if withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(d.RetryAfter)}); err == nil {
+			st = withDetails
+		}
+		return st.Err()
+	}
+}
+
+func rateLimitKey(ctx context.Context, by string, apiKeys map[string]bool) string {
+	switch by {
+	case "user":
+		if claims, ok := auth.FromContext(ctx); ok {
+			return "user:" + claims.Subject
+		}
+	case "apikey":
+		md, _ := metadata.FromIncomingContext(ctx)
+		if k := md.Get("x-api-key"); len(k) > 0 && apiKeys[k[0]] {
+			return "apikey:" + k[0]
+		}
+	}
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip = p.Addr.String()
+		if h, _, err := net.SplitHostPort(ip); err == nil {
+			ip = h
+		}
+	}
+	return "ip:" + ip
+}
+
+// scopedStream replaces the context of a server stream.
+type scopedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s scopedStream) Context() context.Context { return s.ctx }
+
+func recoverUnary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func recoverStream() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs the panic value r with the stack that raised it and
+// returns the error the caller gets instead, which tells nothing about it.
+func recovered(ctx context.Context, r any) error {
+	logging.FromContext(ctx).ErrorContext(ctx, "request_panicked",
+		slog.Any("panic", r),
+		slog.String("stack", string(debug.Stack())),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func logUnary(logger *slog.Logger, sampler *logging.Sampler) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = logging.WithLogger(ctx, logger.With(slog.String("method", info.FullMethod)))
+		resp, err := handler(ctx, req)
+		logCall(ctx, sampler, start, err)
+		return resp, err
+	}
+}
+
+func logStream(logger *slog.Logger, sampler *logging.Sampler) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := logging.WithLogger(ss.Context(), logger.With(slog.String("method", info.FullMethod)))
+		err := handler(srv, scopedStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, sampler, start, err)
+		return err
+	}
+}
+
+// logCall logs the outcome of a call with the logger in ctx: failures the
+// server is responsible for as errors, other failures as warnings and a
+// sample of the successes.
+func logCall(ctx context.Context, sampler *logging.Sampler, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK:
+		if !sampler.Keep() {
+			return
+		}
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	logging.FromContext(ctx).LogAttrs(ctx, level,
+		"grpc_request",
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
@@ -0,0 +1,379 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/grpcapi/todov1"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ratelimit"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T, bus *events.Bus) (todov1.TodoServiceClient, *todos.Service) {
+	t.Helper()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return serve(t, Options{
+		TodoService:     svc,
+		TenantResolvers: []TenantResolver{MetadataTenantResolver("X-Tenant-Id")},
+		Events:          bus,
+	}), svc
+}
+
+// serve starts a server with opts and returns a client connected to it.
+func serve(t *testing.T, opts Options, dialOpts ...grpc.DialOption) todov1.TodoServiceClient {
+	t.Helper()
+
+	srv, err := NewServer(opts)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, dialOpts...)...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return todov1.NewTodoServiceClient(conn)
+}
+
+func TestServer_CRUD(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newClient(t, nil)
+	ctx := context.Background()
+
+	created, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "buy milk"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.GetId() == "" || created.GetVersion() != 1 {
+		t.Fatalf("unexpected todo: %v", created)
+	}
+
+	updated, err := client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: created.GetId(), Title: "buy milk", Completed: true})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !updated.GetCompleted() || updated.GetVersion() != 2 {
+		t.Fatalf("unexpected todo: %v", updated)
+	}
+
+	list, err := client.ListTodos(ctx, &todov1.ListTodosRequest{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list.GetTodos()) != 1 {
+		t.Fatalf("expected 1 todo, got %v", list.GetTodos())
+	}
+
+	if _, err := client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: created.GetId()}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: created.GetId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestServer_MapsErrors(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newClient(t, nil)
+	ctx := context.Background()
+
+	_, err := client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "  "})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	var field string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok && len(br.GetFieldViolations()) == 1 {
+			field = br.GetFieldViolations()[0].GetField()
+		}
+	}
+	if field != "title" {
+		t.Fatalf("expected a BadRequest detail for title, got %v", st.Details())
+	}
+
+	if _, err := client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: "missing", Title: "x"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	bad := metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "Not A Tenant!")
+	if _, err := client.ListTodos(bad, &todov1.ListTodosRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a bad tenant, got %v", err)
+	}
+	unknown := metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "acme")
+	if _, err := client.CreateTodo(unknown, &todov1.CreateTodoRequest{Title: "x"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for an unknown tenant, got %v", err)
+	}
+}
+
+func TestServer_LogsInternalErrorsAndPanics(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	err := toStatus(ctx, errors.New("disk on fire"))
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Fatalf("expected a masked Internal error, got %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "request_failed") || !strings.Contains(out, "disk on fire") {
+		t.Fatalf("expected the error to be logged, got %s", out)
+	}
+
+	buf.Reset()
+	_, err = recoverUnary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/todo.v1.TodoService/ListTodos"},
+		func(context.Context, any) (any, error) { panic("boom") })
+	if st := status.Convert(err); st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Fatalf("expected a masked Internal error, got %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "request_panicked") || !strings.Contains(out, "boom") || !strings.Contains(out, "grpcapi.recoverUnary") {
+		t.Fatalf("expected the panic to be logged with its stack, got %s", out)
+	}
+}
+
+func TestServer_WatchTodos(t *testing.T) {
+	t.Parallel()
+
+	bus := events.NewBus()
+	client, svc := newClient(t, bus)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchTodos(ctx, &todov1.WatchTodosRequest{})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	// The subscription starts once the stream's headers arrive.
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+
+	td, err := svc.Create(ctx, "buy milk")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// Events reach the bus through the outbox relay in production.
+	if err := bus.Publish(ctx, domain.Event{Type: domain.EventTodoCreated, Tenant: tenancy.DefaultID, Todo: td}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	ev := msg.GetEvent()
+	if ev == nil || ev.GetType() != todov1.EventType_EVENT_TYPE_CREATED || ev.GetTodo().GetId() != td.ID || ev.GetId() == 0 {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	bus.Close()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable on shutdown, got %v", err)
+	}
+}
+
+func TestScopeCall_ClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	scope := scopeCall(nil, []TenantResolver{ClaimTenantResolver()}, true)
+	// The TLS credentials fill in the verified chain; here it is faked.
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"acme"}}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+
+	ctx, err := scope(ctx)
+	if err != nil {
+		t.Fatalf("scope: %v", err)
+	}
+	if c, ok := auth.FromContext(ctx); !ok || c.Subject != "billing" {
+		t.Fatalf("expected the certificate subject as caller, got %+v", c)
+	}
+	if id := tenancy.FromContext(ctx); id != "acme" {
+		t.Fatalf("expected the certificate tenant, got %q", id)
+	}
+
+	// Unverified certificates are ignored.
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}},
+	}})
+	if _, err := scope(ctx); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected no tenant without a verified certificate, got %v", err)
+	}
+}
+
+func TestServer_TenantFromAuthorityOrToken(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	tenants, err := boltdb.NewTenantRepository(db)
+	if err != nil {
+		t.Fatalf("new tenant repo: %v", err)
+	}
+	for _, id := range []string{"acme", "globex"} {
+		if err := tenants.Create(context.Background(), domain.Tenant{ID: id}); err != nil {
+			t.Fatalf("create tenant: %v", err)
+		}
+	}
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	bearer := func(tenant string) context.Context {
+		token, err := verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenant})
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+	opts := Options{
+		TodoService:     svc,
+		TenantResolvers: []TenantResolver{AuthorityTenantResolver("todos.test")},
+		TokenVerifier:   verifier,
+	}
+
+	sub := serve(t, opts, grpc.WithAuthority("acme.todos.test:443"))
+	if _, err := sub.ListTodos(context.Background(), &todov1.ListTodosRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected anonymous calls rejected, got %v", err)
+	}
+	if _, err := sub.ListTodos(bearer("globex"), &todov1.ListTodosRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected a token of another tenant rejected, got %v", err)
+	}
+	if _, err := sub.CreateTodo(bearer("acme"), &todov1.CreateTodoRequest{Title: "a"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Without a subdomain the call acts on the tenant of its token, never
+	// on the default one.
+	bare := serve(t, opts)
+	if _, err := bare.CreateTodo(bearer("globex"), &todov1.CreateTodoRequest{Title: "b"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	for tenant, want := range map[string]string{"acme": "a", "globex": "b"} {
+		list, err := svc.List(tenancy.WithID(context.Background(), tenant))
+		if err != nil {
+			t.Fatalf("list %s: %v", tenant, err)
+		}
+		if len(list) != 1 || list[0].Title != want {
+			t.Fatalf("expected %q in tenant %s, got %+v", want, tenant, list)
+		}
+	}
+	if list, _ := svc.List(tenancy.WithID(context.Background(), tenancy.DefaultID)); len(list) != 0 {
+		t.Fatalf("expected nothing in the default tenant, got %+v", list)
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	client := serve(t, Options{
+		TodoService:      svc,
+		RateLimiter:      ratelimit.New(0.001, 2),
+		RateLimitKey:     "apikey",
+		RateLimitAPIKeys: []string{"a"},
+	})
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "a")
+	for i := 0; i < 2; i++ {
+		if _, err := client.ListTodos(ctx, &todov1.ListTodosRequest{}); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	_, err = client.ListTodos(ctx, &todov1.ListTodosRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	var retry *errdetails.RetryInfo
+	for _, d := range status.Convert(err).Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() <= 0 {
+		t.Fatalf("expected retry info, got %v", status.Convert(err).Details())
+	}
+
+	// Unknown keys share the budget of the peer IP.
+	for i := 0; i < 2; i++ {
+		_, _ = client.ListTodos(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", fmt.Sprint("random-", i)), &todov1.ListTodosRequest{})
+	}
+	if _, err := client.ListTodos(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "random-2"), &todov1.ListTodosRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected unknown API keys limited by IP, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Heartbeat records the passes of a background worker for a Fresh check.
+// The zero value is ready to use.
+type Heartbeat struct {
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
+// Beat records a pass that ended with err.
+func (h *Heartbeat) Beat(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.at = time.Now()
+	h.err = err
+}
+
+// Last returns when the last pass ended and its error; the time is zero
+// before the first pass.
+func (h *Heartbeat) Last() (time.Time, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.at, h.err
+}
+
+// Fresh returns a check that fails when the last pass of a worker failed or
+// ended more than maxAge ago, meaning it is failing or stuck.
+func Fresh(last func() (time.Time, error), maxAge time.Duration) func(context.Context) error {
+	return func(context.Context) error {
+		at, err := last()
+		switch {
+		case at.IsZero():
+			return errors.New("no pass yet")
+		case err != nil:
+			return fmt.Errorf("last pass failed: %w", err)
+		case time.Since(at) > maxAge:
+			return fmt.Errorf("no pass for %s", time.Since(at).Round(time.Second))
+		}
+		return nil
+	}
+}
+
+// errUnsupported is returned by freeBytes where free space cannot be read;
+// DiskSpace then always passes.
+var errUnsupported = errors.New("free space unsupported on this platform")
+
+// DiskSpace returns a check that fails when the file system holding path
+// has less than minFree bytes available.
+func DiskSpace(path string, minFree uint64) func(context.Context) error {
+	return func(context.Context) error {
+		free, err := freeBytes(path)
+		if errors.Is(err, errUnsupported) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%d MiB free, below %d MiB", free>>20, minFree>>20)
+		}
+		return nil
+	}
+}
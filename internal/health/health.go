@@ -0,0 +1,144 @@
+// Package health runs the named checks components register, for the
+// readiness and startup probes.
+//
+// Results are cached for a short while so frequent or concurrent probes do
+// not each hit the database, and readiness can be withdrawn ahead of a
+// graceful shutdown (see Registry.Drain).
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Check is a named dependency check.
+type Check struct {
+	Name string
+	// Run returns nil when the dependency is healthy.
+	Run func(ctx context.Context) error
+	// Optional checks are reported but do not make the service unready,
+	// for dependencies it can degrade without, like a lagging worker.
+	Optional bool
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name     string
+	Optional bool
+	Err      error
+	Duration time.Duration
+	// At is when the check ran, which is earlier than the report for a
+	// cached result.
+	At time.Time
+}
+
+// Report holds the results of every registered check, in registration order.
+type Report struct {
+	Results []Result
+	// Draining is set once the service is shutting down.
+	Draining bool
+}
+
+// Ready reports whether the service should receive traffic: it is not
+// draining and no required check failed.
+func (r Report) Ready() bool {
+	if r.Draining {
+		return false
+	}
+	return r.Healthy()
+}
+
+// Healthy reports whether every required check passed.
+func (r Report) Healthy() bool {
+	for _, res := range r.Results {
+		if res.Err != nil && !res.Optional {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry holds the checks of the service.
+type Registry struct {
+	ttl     time.Duration
+	timeout time.Duration
+	now     func() time.Time
+
+	started  atomic.Bool
+	draining atomic.Bool
+
+	// mu serializes runs, so probes arriving during one wait for its
+	// results instead of starting another.
+	mu       sync.Mutex
+	checks   []Check
+	results  []Result
+	cachedAt time.Time
+}
+
+// NewRegistry returns a registry caching results for ttl and giving each
+// check up to timeout.
+func NewRegistry(ttl, timeout time.Duration) *Registry {
+	return &Registry{ttl: ttl, timeout: timeout, now: time.Now}
+}
+
+// Register adds a check. Checks are meant to be registered at startup.
+func (r *Registry) Register(c Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+	r.results = nil
+}
+
+// MarkStarted records that the service finished starting up.
+func (r *Registry) MarkStarted() { r.started.Store(true) }
+
+// Started reports whether MarkStarted was called.
+func (r *Registry) Started() bool { return r.started.Load() }
+
+// Drain withdraws readiness for good, so load balancers stop sending traffic
+// before the servers shut down.
+func (r *Registry) Drain() { r.draining.Store(true) }
+
+// Check returns the results of every check, running them again unless the
+// last run is more recent than the cache TTL.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.results == nil || r.now().Sub(r.cachedAt) >= r.ttl {
+		r.results = r.run(ctx)
+		r.cachedAt = r.now()
+	}
+	return Report{Results: append([]Result(nil), r.results...), Draining: r.draining.Load()}
+}
+
+// run runs the checks concurrently. A probe hanging up does not cancel them,
+// so its cancellation is not cached as a failure.
+func (r *Registry) run(ctx context.Context) []Result {
+	ctx = context.WithoutCancel(ctx)
+	out := make([]Result, len(r.checks))
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+			start := r.now()
+			// A check ignoring ctx cannot hold up the probe past the timeout.
+			done := make(chan error, 1)
+			go func() { done <- c.Run(ctx) }()
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			out[i] = Result{Name: c.Name, Optional: c.Optional, Err: err, Duration: time.Since(start), At: start}
+		}()
+	}
+	wg.Wait()
+	return out
+}
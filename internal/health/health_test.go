@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistry_CachesResults(t *testing.T) {
+	t.Parallel()
+
+	var runs atomic.Int32
+	reg := NewRegistry(time.Minute, time.Second)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	reg.now = func() time.Time { return now }
+	reg.Register(Check{Name: "db", Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	for i := 0; i < 3; i++ {
+		if !reg.Check(context.Background()).Ready() {
+			t.Fatalf("expected ready")
+		}
+	}
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("expected 1 run within the TTL, got %d", n)
+	}
+
+	now = now.Add(time.Minute)
+	reg.Check(context.Background())
+	if n := runs.Load(); n != 2 {
+		t.Fatalf("expected a new run after the TTL, got %d runs", n)
+	}
+}
+
+func TestRegistry_OptionalChecksAndDraining(t *testing.T) {
+	t.Parallel()
+
+	reg := NewRegistry(0, time.Second)
+	reg.Register(Check{Name: "db", Run: func(context.Context) error { return nil }})
+	reg.Register(Check{Name: "worker", Run: func(context.Context) error { return errors.New("stuck") }, Optional: true})
+
+	report := reg.Check(context.Background())
+	if !report.Ready() {
+		t.Fatalf("expected a failing optional check not to affect readiness")
+	}
+	if got := report.Results[1]; got.Name != "worker" || got.Err == nil {
+		t.Fatalf("expected the optional failure to be reported, got %+v", got)
+	}
+
+	reg.Drain()
+	report = reg.Check(context.Background())
+	if report.Ready() || !report.Healthy() || !report.Draining {
+		t.Fatalf("expected a healthy but draining report, got %+v", report)
+	}
+}
+
+func TestRegistry_TimesOutChecks(t *testing.T) {
+	t.Parallel()
+
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	reg := NewRegistry(0, 10*time.Millisecond)
+	reg.Register(Check{Name: "db", Run: func(context.Context) error {
+		<-block // ignores ctx
+		return nil
+	}})
+
+	report := reg.Check(context.Background())
+	if report.Ready() || !errors.Is(report.Results[0].Err, context.DeadlineExceeded) {
+		t.Fatalf("expected the check to time out, got %+v", report.Results)
+	}
+}
+
+func TestFresh(t *testing.T) {
+	t.Parallel()
+
+	var hb Heartbeat
+	check := Fresh(hb.Last, time.Minute)
+	if err := check(context.Background()); err == nil {
+		t.Fatalf("expected failure before the first pass")
+	}
+	hb.Beat(nil)
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("expected fresh pass to pass, got %v", err)
+	}
+	hb.Beat(errors.New("boom"))
+	if err := check(context.Background()); err == nil {
+		t.Fatalf("expected failed pass to fail")
+	}
+
+	stale := Fresh(func() (time.Time, error) { return time.Now().Add(-time.Hour), nil }, time.Minute)
+	if err := stale(context.Background()); err == nil {
+		t.Fatalf("expected stale pass to fail")
+	}
+}
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"challenge-backend-arancia/internal/application/tenants"
+	"challenge-backend-arancia/internal/config"
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type tenantHandler struct {
+	svc *tenants.Service
+}
+
+type tenantResponse struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type createTenantRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+type tenantExportResponse struct {
+	Tenant tenantResponse `json:"tenant"`
+	Todos  []todoResponse `json:"todos"`
+}
+
+func (h tenantHandler) register(r gin.IRoutes) {
+	r.GET("/tenants", h.list)
+	r.POST("/tenants", h.create)
+	r.GET("/tenants/:id", h.get)
+	r.DELETE("/tenants/:id", h.delete)
+	r.GET("/tenants/:id/export", h.export)
+}
+
+func (h tenantHandler) list(c *gin.Context) {
+	out, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := make([]tenantResponse, 0, len(out))
+	for _, t := range out {
+		resp = append(resp, toTenantResponse(t))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h tenantHandler) create(c *gin.Context) {
+	var req createTenantRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	t, err := h.svc.Provision(c.Request.Context(), req.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toTenantResponse(t))
+}
+
+func (h tenantHandler) get(c *gin.Context) {
+	t, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toTenantResponse(t))
+}
+
+func (h tenantHandler) delete(c *gin.Context) {
+	if err := h.svc.Deprovision(c.Request.Context(), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h tenantHandler) export(c *gin.Context) {
+	exp, err := h.svc.Export(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := tenantExportResponse{
+		Tenant: toTenantResponse(exp.Tenant),
+		Todos:  make([]todoResponse, 0, len(exp.Todos)),
+	}
+	for _, td := range exp.Todos {
+		resp.Todos = append(resp.Todos, toResponse(td))
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tenant-%s.json"`, exp.Tenant.ID))
+	c.JSON(http.StatusOK, resp)
+}
+
+// configHandler dumps the effective configuration, with secrets redacted,
+// keyed by setting name.
+func configHandler(settings func() []config.Setting) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out := map[string]configSettingResponse{}
+		for _, s := range settings() {
+			out[s.Name] = configSettingResponse{Value: s.Value, Source: string(s.Source)}
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+type configSettingResponse struct {
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+func toTenantResponse(t domain.Tenant) tenantResponse {
+	return tenantResponse{ID: t.ID, CreatedAt: t.CreatedAt}
+}
+
+// adminMiddleware guards operator endpoints with a static shared token sent in
+// the X-Admin-Token header.
+func adminMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got := c.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeProblem(c, problemUnauthorized, "a valid X-Admin-Token header is required")
+			return
+		}
+		c.Next()
+	}
+}
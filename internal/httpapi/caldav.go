@@ -0,0 +1,61 @@
+package httpapi
+
+import (
+	"net/http"
+	"strings"
+
+	"challenge-backend-arancia/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// davPrefix is where the CalDAV handler is mounted; it must be built with the
+// same prefix.
+const davPrefix = "/dav"
+
+// davMethods are the methods the CalDAV handler answers.
+var davMethods = []string{
+	http.MethodOptions, "PROPFIND", "REPORT",
+	http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+}
+
+// isDAVPath reports whether p is served by the CalDAV handler.
+func isDAVPath(p string) bool {
+	return p == davPrefix || strings.HasPrefix(p, davPrefix+"/")
+}
+
+// registerCalDAV mounts h under davPrefix behind the todo middleware, and the
+// well-known URL clients discover it from (RFC 6764).
+func registerCalDAV(r *gin.Engine, h http.Handler, verifier *auth.Verifier, middleware []gin.HandlerFunc) {
+	chain := middleware
+	if verifier != nil {
+		chain = append([]gin.HandlerFunc{davChallengeMiddleware()}, middleware...)
+	}
+	dav := r.Group(davPrefix, chain...)
+	for _, m := range davMethods {
+		dav.Handle(m, "/*path", gin.WrapH(h))
+	}
+
+	wellKnown := func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, davPrefix+"/")
+	}
+	r.GET("/.well-known/caldav", wellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", wellKnown)
+}
+
+// davChallengeMiddleware asks anonymous CalDAV clients for credentials.
+// Calendar apps only send them once challenged, and only with the Basic
+// scheme, which authMiddleware accepts with the token as password.
+func davChallengeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := auth.FromContext(c.Request.Context()); !ok {
+			c.Header("WWW-Authenticate", `Basic realm="todos", charset="UTF-8"`)
+			if refuseCredentials(c) {
+				return
+			}
+			writeProblem(c, problemUnauthorized, "CalDAV requires credentials; use the bearer token as password")
+			return
+		}
+		c.Next()
+	}
+}
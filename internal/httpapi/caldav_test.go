@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/caldav"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+func TestCalDAV_Routes(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	dav, err := caldav.NewHandler(caldav.Options{TodoService: svc})
+	if err != nil {
+		t.Fatalf("new caldav handler: %v", err)
+	}
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	srv := NewRouter(RouterOptions{
+		TodoService:     svc,
+		TenantResolvers: []TenantResolver{ClaimTenantResolver()},
+		TokenVerifier:   verifier,
+		CalDAV:          dav,
+	})
+
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("PROPFIND", "/.well-known/caldav", "", nil)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/dav/" {
+		t.Fatalf("expected a redirect to /dav/, got %d %v", rec.Code, rec.Header())
+	}
+	assertMatchesSpec(t, "x-propfind", "/.well-known/caldav", rec)
+
+	// Anonymous clients are challenged for Basic credentials.
+	rec = do("PROPFIND", "/dav/", "", map[string]string{"Depth": "0"})
+	if rec.Code != http.StatusUnauthorized || !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Fatalf("expected a Basic challenge, got %d %v", rec.Code, rec.Header())
+	}
+	assertMatchesSpec(t, "x-propfind", "/dav/{path}", rec)
+
+	token, _ := verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenancy.DefaultID})
+	req := httptest.NewRequest(http.MethodPut, "/dav/calendars/todos/t1.ics", strings.NewReader(
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:t1\r\nSUMMARY:buy milk\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"))
+	req.SetBasicAuth("alice", token)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, http.MethodPut, "/dav/{path}", rec)
+
+	// The todo is owned by the token's subject, as if created over REST.
+	rec = do(http.MethodGet, "/v1/todos", "", map[string]string{"Authorization": "Bearer " + token})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"buy milk"`) {
+		t.Fatalf("expected the todo over REST, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if td, err := svc.Get(req.Context(), "t1"); err != nil || td.Owner != "alice" {
+		t.Fatalf("expected a todo owned by alice, got %+v, err %v", td, err)
+	}
+
+	req = httptest.NewRequest("REPORT", "/dav/calendars/todos/", strings.NewReader(
+		`<sync-collection xmlns="DAV:"><sync-token/><prop><getetag/></prop></sync-collection>`))
+	req.SetBasicAuth("alice", token)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "t1.ics") {
+		t.Fatalf("expected the todo in the sync report, got %d: %s", rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, "x-report", "/dav/{path}", rec)
+
+	req.SetBasicAuth("alice", "wrong")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for a bad password, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestTodos_ChangeFeed(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	srv := NewRouter(RouterOptions{TodoService: svc})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+	changes := func(query string) changesResponse {
+		t.Helper()
+		rec := do(http.MethodGet, "/v1/todos/changes"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		assertMatchesSpec(t, http.MethodGet, "/v1/todos/changes", rec)
+		var out changesResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+
+	var keep, drop todoResponse
+	for _, td := range []*todoResponse{&keep, &drop} {
+		rec := do(http.MethodPost, "/v1/todos", `{"title":"x"}`)
+		if err := json.Unmarshal(rec.Body.Bytes(), td); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+
+	full := changes("")
+	if len(full.Changes) != 2 {
+		t.Fatalf("expected a full sync of 2 todos, got %+v", full)
+	}
+	if got := changes("?since=" + full.NextToken); len(got.Changes) != 0 || got.NextToken != full.NextToken {
+		t.Fatalf("expected no changes, got %+v", got)
+	}
+
+	// The snapshot is paged too, and ends at the sync point it started at.
+	first := changes("?limit=1")
+	if len(first.Changes) != 1 || !first.HasMore {
+		t.Fatalf("expected a first page of 1 todo with more to come, got %+v", first)
+	}
+	second := changes("?limit=1&since=" + first.NextToken)
+	if len(second.Changes) != 1 || second.HasMore || second.Changes[0].ID == first.Changes[0].ID {
+		t.Fatalf("expected the other todo on the last page, got %+v", second)
+	}
+	if second.NextToken != full.NextToken {
+		t.Fatalf("expected the snapshot to end at %q, got %q", full.NextToken, second.NextToken)
+	}
+
+	do(http.MethodPut, "/v1/todos/"+keep.ID, `{"title":"y","completed":true}`)
+	do(http.MethodDelete, "/v1/todos/"+drop.ID, "")
+	delta := changes("?since=" + full.NextToken)
+	if len(delta.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", delta)
+	}
+	if c := delta.Changes[0]; c.ID != keep.ID || c.Deleted || c.Todo == nil || !c.Todo.Completed {
+		t.Fatalf("expected updated todo, got %+v", c)
+	}
+	if c := delta.Changes[1]; c.ID != drop.ID || !c.Deleted || c.Todo != nil {
+		t.Fatalf("expected tombstone, got %+v", c)
+	}
+
+	rec := do(http.MethodGet, "/v1/todos/changes?since=garbage", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a bad token, got %d", http.StatusBadRequest, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/v1/todos/changes", rec)
+
+	if _, err := repo.CompactChanges(context.Background(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	rec = do(http.MethodGet, "/v1/todos/changes?since="+full.NextToken, "")
+	if rec.Code != http.StatusGone {
+		t.Fatalf("expected status %d after compaction, got %d", http.StatusGone, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/v1/todos/changes", rec)
+	if !strings.Contains(rec.Body.String(), "urn:todo-api:problem:resync-required") {
+		t.Fatalf("expected resync-required problem, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsExposedHeaders are the response headers browser apps need to read.
+var corsExposedHeaders = strings.Join([]string{
+	"ETag", "Location", "Link", "X-Request-Id", "Retry-After",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+	"Deprecation", "Sunset",
+}, ", ")
+
+// CORS lets browser apps served from other origins call the API. Its
+// origins can be replaced while serving.
+type CORS struct {
+	mu      sync.RWMutex
+	origins map[string]bool
+}
+
+// NewCORS allows the given origins, such as "https://app.example.com"; "*"
+// allows any.
+func NewCORS(origins []string) *CORS {
+	c := &CORS{}
+	c.SetOrigins(origins)
+	return c
+}
+
+// SetOrigins replaces the allowed origins.
+func (p *CORS) SetOrigins(origins []string) {
+	set := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		set[o] = true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.origins = set
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin, or ""
+// if it is not allowed.
+func (p *CORS) allowOrigin(origin string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	switch {
+	case p.origins["*"]:
+		return "*"
+	case p.origins[origin]:
+		return origin
+	default:
+		return ""
+	}
+}
+
+// corsMiddleware answers the preflight requests of allowed origins and lets
+// their browsers read the responses. Requests of other origins are served
+// without CORS headers, so browsers hide the responses from them.
+func corsMiddleware(p *CORS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		allowed := p.allowOrigin(origin)
+		if allowed == "" {
+			c.Next()
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", allowed)
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			if req := c.GetHeader("Access-Control-Request-Headers"); req != "" {
+				h.Set("Access-Control-Allow-Headers", req)
+			}
+			h.Set("Access-Control-Max-Age", strconv.Itoa(600))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		c.Next()
+	}
+}
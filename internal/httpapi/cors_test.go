@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestCORS_AllowsConfiguredOrigins(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	cors := NewCORS([]string{"https://app.example.com"})
+	srv := NewRouter(RouterOptions{TodoService: svc, CORS: cors})
+	do := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/todos", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodOptions, "https://app.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("expected the origin to be allowed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "authorization, content-type" {
+		t.Fatalf("expected the requested headers to be allowed, got %q", got)
+	}
+
+	rec = do(http.MethodGet, "https://app.example.com")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Fatalf("expected a readable response, got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = do(http.MethodGet, "https://evil.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no CORS headers for another origin, got %q", got)
+	}
+
+	cors.SetOrigins([]string{"*"})
+	rec = do(http.MethodGet, "https://evil.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("expected any origin after SetOrigins, got %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Optional API surfaces Features can switch off.
+const (
+	FeatureGraphQL = "graphql"
+	FeatureCalDAV  = "caldav"
+	FeatureFeeds   = "feeds"
+	FeatureUI      = "ui"
+)
+
+// Features switches optional API surfaces off while serving. A nil Features
+// enables them all.
+type Features struct {
+	mu       sync.RWMutex
+	disabled map[string]bool
+}
+
+// NewFeatures disables the named features.
+func NewFeatures(disabled []string) *Features {
+	f := &Features{}
+	f.SetDisabled(disabled)
+	return f
+}
+
+// SetDisabled replaces the disabled features.
+func (f *Features) SetDisabled(disabled []string) {
+	set := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		set[name] = true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.disabled = set
+}
+
+// Enabled reports whether the named feature is on.
+func (f *Features) Enabled(name string) bool {
+	if f == nil {
+		return true
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return !f.disabled[name]
+}
+
+// featureMiddleware answers 404 on the routes of a disabled feature, as if
+// they were not mounted.
+func featureMiddleware(f *Features, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !f.Enabled(name) {
+			writeProblem(c, problemNotFound, name+" is disabled")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestFeatures_DisableRoutesWhileServing(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	features := NewFeatures([]string{FeatureGraphQL})
+	srv := NewRouter(RouterOptions{
+		TodoService: svc,
+		GraphQL:     http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }),
+		Features:    features,
+	})
+	post := func() int {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", nil))
+		return rec.Code
+	}
+
+	if code := post(); code != http.StatusNotFound {
+		t.Fatalf("expected status %d while disabled, got %d", http.StatusNotFound, code)
+	}
+	features.SetDisabled(nil)
+	if code := post(); code != http.StatusOK {
+		t.Fatalf("expected status %d once enabled, got %d", http.StatusOK, code)
+	}
+}
@@ -0,0 +1,267 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"challenge-backend-arancia/internal/application/feeds"
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/caldav"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	feedTitle = "Todos"
+
+	// feedCacheControl lets apps reuse a feed for a few minutes before
+	// revalidating it. Feeds are per user, so shared caches must not keep them.
+	feedCacheControl = "private, max-age=300"
+
+	// atomMaxEntries bounds the Atom feed to the most recent activity.
+	atomMaxEntries = 50
+)
+
+// feedHandler serves read-only exports of the todos to apps that poll a URL:
+// calendar apps subscribe to /todos.ics, feed readers to /todos.atom.
+type feedHandler struct {
+	svc *todos.Service
+	now func() time.Time
+}
+
+func registerFeeds(r *gin.Engine, todoSvc *todos.Service, feedSvc *feeds.Service, middleware []gin.HandlerFunc) {
+	h := feedHandler{svc: todoSvc, now: time.Now}
+	g := r.Group("", append([]gin.HandlerFunc{feedTokenMiddleware(feedSvc)}, middleware...)...)
+	g.GET("/todos.ics", h.ics)
+	g.GET("/todos.atom", h.atom)
+}
+
+// feedTokenMiddleware authenticates a feed request by the token in its URL
+// and scopes it to the tenant and user the token was issued to.
+func feedTokenMiddleware(svc *feeds.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		owner, err := svc.Resolve(c.Request.Context(), c.Query("token"))
+		if errors.Is(err, ports.ErrNotFound) {
+			writeProblem(c, problemUnauthorized, "the feed token is missing, unknown or revoked")
+			return
+		}
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		ctx := logging.With(c.Request.Context(), slog.String("tenant", owner.Tenant), slog.String("user", owner.Subject))
+		ctx = tenancy.WithID(ctx, owner.Tenant)
+		ctx = auth.WithClaims(ctx, auth.Claims{Subject: owner.Subject, Tenant: owner.Tenant})
+		c.Set("tenant", owner.Tenant)
+		c.Set("user", owner.Subject)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func (h feedHandler) ics(c *gin.Context) {
+	list, token, ok := h.snapshot(c)
+	if !ok {
+		return
+	}
+	h.serve(c, "text/calendar; charset=utf-8", token, list, caldav.Calendar(feedTitle, list, h.now()))
+}
+
+func (h feedHandler) atom(c *gin.Context) {
+	list, token, ok := h.snapshot(c)
+	if !ok {
+		return
+	}
+	tenant := tenancy.FromContext(c.Request.Context())
+	feed := atomFeed{
+		ID:     "urn:x-todos:" + tenant,
+		Title:  feedTitle,
+		Author: atomPerson{Name: feedTitle},
+		Link:   atomLink{Rel: "self", Href: c.Request.URL.RequestURI()},
+	}
+	for _, td := range list {
+		if !td.CreatedAt.IsZero() {
+			feed.Entries = append(feed.Entries, newAtomEntry(tenant, td, "created", td.CreatedAt))
+		}
+		if td.Completed && !td.CompletedAt.IsZero() {
+			feed.Entries = append(feed.Entries, newAtomEntry(tenant, td, "completed", td.CompletedAt))
+		}
+	}
+	sort.Slice(feed.Entries, func(i, j int) bool {
+		a, b := feed.Entries[i], feed.Entries[j]
+		if !a.Updated.Equal(b.Updated) {
+			return a.Updated.After(b.Updated)
+		}
+		return a.ID < b.ID
+	})
+	if len(feed.Entries) > atomMaxEntries {
+		feed.Entries = feed.Entries[:atomMaxEntries]
+	}
+	feed.Updated = lastModified(list)
+	if feed.Updated.IsZero() {
+		// Atom requires a date even when nothing recorded one.
+		feed.Updated = h.now().UTC()
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	if err := xml.NewEncoder(&b).Encode(feed); err != nil {
+		writeError(c, err)
+		return
+	}
+	h.serve(c, "application/atom+xml; charset=utf-8", token, list, b.Bytes())
+}
+
+// snapshot returns the todos of the feed's user with the change feed token
+// of that state. The token is read first, so a write made while listing
+// moves the ETag of the next response even if it was listed already.
+func (h feedHandler) snapshot(c *gin.Context) ([]domain.Todo, string, bool) {
+	ctx := c.Request.Context()
+	claims, _ := auth.FromContext(ctx)
+	token, err := h.svc.SyncToken(ctx)
+	if err != nil {
+		writeError(c, err)
+		return nil, "", false
+	}
+	owned := func(td domain.Todo) bool { return td.Owner == claims.Subject }
+	var list []domain.Todo
+	for after, more := "", true; more; {
+		var page []domain.Todo
+		page, more, err = h.svc.ListAfter(ctx, after, todos.MaxChangesLimit, owned)
+		if err != nil {
+			writeError(c, err)
+			return nil, "", false
+		}
+		list = append(list, page...)
+		if more {
+			after = page[len(page)-1].ID
+		}
+	}
+	return list, token, true
+}
+
+// serve writes a feed with validators for conditional requests. The ETag is
+// the change feed token, which moves on every write including deletions;
+// Last-Modified cannot account for deletions, which is why http.ServeContent
+// only falls back to If-Modified-Since without If-None-Match. The ETag is
+// weak because todos written before write times were recorded are rendered
+// with the current time.
+func (h feedHandler) serve(c *gin.Context, contentType, token string, list []domain.Todo, body []byte) {
+	w := c.Writer
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", feedCacheControl)
+	w.Header().Set("ETag", `W/"`+token+`"`)
+	http.ServeContent(w, c.Request, "", lastModified(list), bytes.NewReader(body))
+}
+
+// lastModified returns the latest write time of the todos, zero if none was
+// recorded.
+func lastModified(list []domain.Todo) time.Time {
+	var last time.Time
+	for _, td := range list {
+		if td.UpdatedAt.After(last) {
+			last = td.UpdatedAt
+		}
+	}
+	return last
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated time.Time   `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  time.Time    `xml:"updated"`
+	Category atomCategory `xml:"category"`
+	Content  atomContent  `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// newAtomEntry describes one activity on td: "created" or "completed".
+func newAtomEntry(tenant string, td domain.Todo, activity string, at time.Time) atomEntry {
+	verb := "Created"
+	if activity == "completed" {
+		verb = "Completed"
+	}
+	return atomEntry{
+		ID:       fmt.Sprintf("urn:x-todos:%s:%s:%s", tenant, url.PathEscape(td.ID), activity),
+		Title:    verb + ": " + td.Title,
+		Updated:  at.UTC(),
+		Category: atomCategory{Term: activity},
+		Content:  atomContent{Type: "text", Text: td.Title},
+	}
+}
+
+// feedTokenHandler lets users get the token that authenticates their feeds.
+type feedTokenHandler struct {
+	svc *feeds.Service
+}
+
+type feedTokenResponse struct {
+	Token   string `json:"token"`
+	ICSURL  string `json:"ics_url"`
+	AtomURL string `json:"atom_url"`
+}
+
+// register requires authentication: a token is issued to a user, and its
+// feeds hold the todos they own.
+func (h feedTokenHandler) register(r gin.IRoutes) {
+	authn := requireAuthMiddleware()
+	r.POST("/feeds/token", authn, h.issue)
+	r.DELETE("/feeds/token", authn, h.revoke)
+}
+
+func (h feedTokenHandler) issue(c *gin.Context) {
+	token, err := h.svc.Issue(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	q := "?token=" + url.QueryEscape(token)
+	c.JSON(http.StatusCreated, feedTokenResponse{Token: token, ICSURL: "/todos.ics" + q, AtomURL: "/todos.atom" + q})
+}
+
+func (h feedTokenHandler) revoke(c *gin.Context) {
+	if err := h.svc.Revoke(c.Request.Context()); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/feeds"
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newFeedService(t *testing.T, db *bolt.DB) *feeds.Service {
+	t.Helper()
+
+	tokens, err := boltdb.NewFeedTokenRepository(db)
+	if err != nil {
+		t.Fatalf("new feed token repo: %v", err)
+	}
+	svc, err := feeds.NewService(tokens)
+	if err != nil {
+		t.Fatalf("new feed service: %v", err)
+	}
+	return svc
+}
+
+func TestFeeds(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	bearer, err := verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenancy.DefaultID})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	srv := NewRouter(RouterOptions{
+		TodoService:   svc,
+		TokenVerifier: verifier,
+		FeedService:   newFeedService(t, db),
+	})
+
+	do := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+	authed := map[string]string{"Authorization": "Bearer " + bearer}
+
+	// Feeds belong to a user, so anonymous callers get no token.
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		rec := do(method, "/v1/feeds/token", "", nil)
+		assertMatchesSpec(t, method, "/v1/feeds/token", rec)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401 without a bearer token, got %d", method, rec.Code)
+		}
+	}
+
+	rec := do(http.MethodPost, "/v1/feeds/token", "", authed)
+	assertMatchesSpec(t, http.MethodPost, "/v1/feeds/token", rec)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var issued feedTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	rec = do(http.MethodPost, "/v1/todos", `{"title":"buy milk"}`, authed)
+	var created todoResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.ID == "" {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body.String())
+	}
+	do(http.MethodPut, "/v1/todos/"+created.ID, `{"title":"buy milk","completed":true}`, authed)
+	do(http.MethodPost, "/v1/todos", `{"title":"call mom"}`, authed)
+	other, err := verifier.Sign(auth.Claims{Subject: "bob", Tenant: tenancy.DefaultID})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	do(http.MethodPost, "/v1/todos", `{"title":"bob's secret"}`, map[string]string{"Authorization": "Bearer " + other})
+
+	rec = do(http.MethodGet, issued.ICSURL, "", nil)
+	assertMatchesSpec(t, http.MethodGet, "/todos.ics", rec)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, want := range []string{"X-WR-CALNAME:Todos\r\n", "SUMMARY:buy milk\r\nCREATED:", "STATUS:COMPLETED\r\n", "SUMMARY:call mom\r\n"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, rec.Body.String())
+		}
+	}
+	if strings.Contains(rec.Body.String(), "bob's secret") {
+		t.Fatalf("expected only the todos of alice, got:\n%s", rec.Body.String())
+	}
+	etag, modified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+	if etag == "" || modified == "" || rec.Header().Get("Cache-Control") != feedCacheControl {
+		t.Fatalf("expected caching headers, got %v", rec.Header())
+	}
+
+	rec = do(http.MethodGet, issued.ICSURL, "", map[string]string{"If-None-Match": etag})
+	assertMatchesSpec(t, http.MethodGet, "/todos.ics", rec)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", rec.Code)
+	}
+	rec = do(http.MethodGet, issued.AtomURL, "", map[string]string{"If-Modified-Since": modified})
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for an unchanged feed, got %d", rec.Code)
+	}
+
+	// Deletions do not move Last-Modified, but they change the ETag.
+	do(http.MethodDelete, "/v1/todos/"+created.ID, "", authed)
+	rec = do(http.MethodGet, issued.ICSURL, "", map[string]string{"If-None-Match": etag})
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "buy milk") {
+		t.Fatalf("expected the feed without the deleted todo, got %d:\n%s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(http.MethodGet, issued.AtomURL, "", nil)
+	assertMatchesSpec(t, http.MethodGet, "/todos.atom", rec)
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, `<feed xmlns="http://www.w3.org/2005/Atom">`) || !strings.Contains(body, "<title>Created: call mom</title>") {
+		t.Fatalf("expected an Atom feed of recent activity, got %d:\n%s", rec.Code, body)
+	}
+
+	for _, target := range []string{"/todos.ics", "/todos.atom?token=nope"} {
+		rec = do(http.MethodGet, target, "", nil)
+		assertMatchesSpec(t, http.MethodGet, "/todos.ics", rec)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", target, rec.Code)
+		}
+	}
+
+	rec = do(http.MethodDelete, "/v1/feeds/token", "", authed)
+	assertMatchesSpec(t, http.MethodDelete, "/v1/feeds/token", rec)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec = do(http.MethodGet, issued.ICSURL, "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a revoked token to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestGraphQL_Routes(t *testing.T) {
+	t.Parallel()
+
+	srv := fullRouter(t)
+	bearer := fullRouterBearer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"mutation { createTodo(input: {title: \"buy milk\"}) { id } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"createTodo"`) {
+		t.Fatalf("expected status %d with data, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, http.MethodPost, "/graphql", rec)
+
+	req = httptest.NewRequest(http.MethodGet, "/graphql?query="+strings.ReplaceAll("{ todos { totalCount } }", " ", "+"), nil)
+	req.Header.Set("Authorization", bearer)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"totalCount":1`) {
+		t.Fatalf("expected status %d with one todo, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, http.MethodGet, "/graphql", rec)
+
+	req = httptest.NewRequest(http.MethodGet, "/graphql?query=%7B", nil)
+	req.Header.Set("Authorization", bearer)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d for a parse error, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/graphql", rec)
+
+	// Tests run in gin's debug mode.
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphiql", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/graphiql", rec)
+}
+
+func TestGraphQL_SubscriptionsAuthenticateInConnectionInit(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(fullRouter(t))
+	defer srv.Close()
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/graphql"
+
+	// Browsers cannot send the token with the upgrade, so it is accepted
+	// and the connection_init message has to carry it.
+	for _, tc := range []struct {
+		name    string
+		payload map[string]any
+		want    string
+	}{
+		{"anonymous", nil, "connection_error"},
+		{"token", map[string]any{"Authorization": fullRouterBearer(t)}, "connection_ack"},
+	} {
+		conn, resp, err := dialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("%s: dial: %v", tc.name, err)
+		}
+		_ = resp.Body.Close()
+		if err := conn.WriteJSON(map[string]any{"type": "connection_init", "payload": tc.payload}); err != nil {
+			t.Fatalf("%s: init: %v", tc.name, err)
+		}
+		var msg struct {
+			Type string `json:"type"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("%s: read: %v", tc.name, err)
+		}
+		if msg.Type != tc.want {
+			t.Fatalf("%s: expected %s, got %s", tc.name, tc.want, msg.Type)
+		}
+		_ = conn.Close()
+	}
+}
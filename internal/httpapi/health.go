@@ -0,0 +1,86 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"challenge-backend-arancia/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthResponse is the body of /readyz and /startupz. Checks is only set for
+// ?verbose.
+type healthResponse struct {
+	Status string                `json:"status"`
+	Checks []healthCheckResponse `json:"checks,omitempty"`
+}
+
+type healthCheckResponse struct {
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	Optional   bool      `json:"optional"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS float64   `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// registerHealth mounts the probes. /healthz only tells the process is
+// serving, so a failing dependency gets the pod out of rotation rather than
+// restarted.
+func registerHealth(r gin.IRoutes, reg *health.Registry) {
+	r.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	r.GET("/readyz", func(c *gin.Context) {
+		if reg == nil {
+			c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "not_ready"})
+			return
+		}
+		report := reg.Check(c.Request.Context())
+		code, status := http.StatusOK, "ready"
+		switch {
+		case report.Draining:
+			code, status = http.StatusServiceUnavailable, "draining"
+		case !report.Ready():
+			code, status = http.StatusServiceUnavailable, "not_ready"
+		}
+		writeHealth(c, code, status, report)
+	})
+
+	r.GET("/startupz", func(c *gin.Context) {
+		if reg == nil || !reg.Started() {
+			c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "starting"})
+			return
+		}
+		report := reg.Check(c.Request.Context())
+		if !report.Healthy() {
+			writeHealth(c, http.StatusServiceUnavailable, "starting", report)
+			return
+		}
+		writeHealth(c, http.StatusOK, "started", report)
+	})
+}
+
+func writeHealth(c *gin.Context, code int, status string, report health.Report) {
+	out := healthResponse{Status: status}
+	if _, verbose := c.GetQuery("verbose"); verbose {
+		out.Checks = make([]healthCheckResponse, 0, len(report.Results))
+		for _, res := range report.Results {
+			check := healthCheckResponse{
+				Name:       res.Name,
+				Status:     "ok",
+				Optional:   res.Optional,
+				DurationMS: float64(res.Duration.Microseconds()) / 1000,
+				CheckedAt:  res.At.UTC(),
+			}
+			if res.Err != nil {
+				check.Status = "fail"
+				check.Error = res.Err.Error()
+			}
+			out.Checks = append(out.Checks, check)
+		}
+	}
+	c.JSON(code, out)
+}
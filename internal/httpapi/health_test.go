@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/health"
+)
+
+func TestHealthProbes(t *testing.T) {
+	t.Parallel()
+
+	var dbErr error
+	checks := health.NewRegistry(0, time.Second)
+	checks.Register(health.Check{Name: "bolt", Run: func(context.Context) error { return dbErr }})
+	checks.Register(health.Check{Name: "event_relay", Run: func(context.Context) error { return errors.New("no pass yet") }, Optional: true})
+	srv := NewRouter(RouterOptions{Health: checks})
+
+	probe := func(target string) (int, healthResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var body healthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode %s: %v", target, err)
+		}
+		return rec.Code, body
+	}
+
+	if code, body := probe("/startupz"); code != http.StatusServiceUnavailable || body.Status != "starting" {
+		t.Fatalf("expected starting before MarkStarted, got %d %+v", code, body)
+	}
+	checks.MarkStarted()
+	if code, body := probe("/startupz"); code != http.StatusOK || body.Status != "started" {
+		t.Fatalf("expected started, got %d %+v", code, body)
+	}
+
+	code, body := probe("/readyz")
+	if code != http.StatusOK || body.Status != "ready" || body.Checks != nil {
+		t.Fatalf("expected terse ready, got %d %+v", code, body)
+	}
+	_, body = probe("/readyz?verbose")
+	if len(body.Checks) != 2 || body.Checks[0].Status != "ok" || body.Checks[1].Status != "fail" || !body.Checks[1].Optional {
+		t.Fatalf("expected per-check results, got %+v", body.Checks)
+	}
+
+	dbErr = errors.New("database not open")
+	code, body = probe("/readyz?verbose")
+	if code != http.StatusServiceUnavailable || body.Status != "not_ready" || body.Checks[0].Error != "database not open" {
+		t.Fatalf("expected not_ready, got %d %+v", code, body)
+	}
+
+	dbErr = nil
+	checks.Drain()
+	if code, body := probe("/readyz"); code != http.StatusServiceUnavailable || body.Status != "draining" {
+		t.Fatalf("expected draining, got %d %+v", code, body)
+	}
+}
@@ -0,0 +1,28 @@
+package httpapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec describes every route registered by NewRouter. TestOpenAPI_*
+// keeps it in sync with the handlers.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders openAPISpec with Redoc.
+//
+//go:embed docs.html
+var docsPage []byte
+
+func registerDocs(r gin.IRoutes) {
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openAPISpec)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+	})
+}
@@ -0,0 +1,405 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/tenants"
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/caldav"
+	"challenge-backend-arancia/internal/config"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/graphapi"
+	"challenge-backend-arancia/internal/health"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fullRouter builds a router with every optional feature enabled so all routes
+// are registered.
+func fullRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	tenantRepo, err := boltdb.NewTenantRepository(db)
+	if err != nil {
+		t.Fatalf("new tenant repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	tenantSvc, err := tenants.NewService(tenantRepo, repo)
+	if err != nil {
+		t.Fatalf("new tenant service: %v", err)
+	}
+	webhookSvc := newWebhookService(t, db)
+	bus := events.NewBus()
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	graph, err := graphapi.NewHandler(graphapi.Options{TodoService: svc, Events: bus, TokenVerifier: verifier})
+	if err != nil {
+		t.Fatalf("new graphql handler: %v", err)
+	}
+	dav, err := caldav.NewHandler(caldav.Options{TodoService: svc})
+	if err != nil {
+		t.Fatalf("new caldav handler: %v", err)
+	}
+	feedSvc := newFeedService(t, db)
+	checks := health.NewRegistry(time.Second, time.Second)
+	checks.Register(health.Check{Name: "bolt", Run: func(ctx context.Context) error { return boltdb.Ping(ctx, db) }})
+	checks.MarkStarted()
+	cfg, err := config.Load(nil, func(name string) (string, bool) {
+		return map[string]string{"ADMIN_TOKEN": "admin"}[name], name == "ADMIN_TOKEN"
+	})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	engine, ok := NewRouter(RouterOptions{
+		TodoService:    svc,
+		Health:         checks,
+		TenantService:  tenantSvc,
+		AdminToken:     "admin",
+		Settings:       cfg.Settings,
+		Events:         bus,
+		WebhookService: webhookSvc,
+		GraphQL:        graph,
+		CalDAV:         dav,
+		FeedService:    feedSvc,
+		TokenVerifier:  verifier,
+		UISessionKey:   []byte("ui-session-key"),
+	}).(*gin.Engine)
+	if !ok {
+		t.Fatalf("NewRouter did not return a *gin.Engine")
+	}
+	return engine
+}
+
+// fullRouterBearer returns an Authorization header value accepted by the
+// router of fullRouter, for the default tenant.
+func fullRouterBearer(t *testing.T) string {
+	t.Helper()
+
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	token, err := verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenancy.DefaultID})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return "Bearer " + token
+}
+
+func TestOpenAPI_CoversAllRoutes(t *testing.T) {
+	t.Parallel()
+
+	doc := loadOpenAPI(t)
+	paths, _ := doc.root["paths"].(map[string]any)
+
+	registered := map[string]bool{}
+	for _, rt := range fullRouter(t).Routes() {
+		registered[strings.ToLower(rt.Method)+" "+openAPIPath(rt.Path)] = true
+	}
+	documented := map[string]bool{}
+	for path, item := range paths {
+		for method := range item.(map[string]any) {
+			if method == "parameters" {
+				continue
+			}
+			// WebDAV methods are documented as x-propfind and x-report.
+			documented[strings.TrimPrefix(method, "x-")+" "+path] = true
+		}
+	}
+
+	var missing, stale []string
+	for op := range registered {
+		if !documented[op] {
+			missing = append(missing, op)
+		}
+	}
+	for op := range documented {
+		if !registered[op] {
+			stale = append(stale, op)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	if len(missing) > 0 || len(stale) > 0 {
+		t.Fatalf("openapi.json out of sync with router\nundocumented: %v\nno such route: %v", missing, stale)
+	}
+}
+
+func TestOpenAPI_AdminResponsesMatchSpec(t *testing.T) {
+	t.Parallel()
+
+	srv := fullRouter(t)
+	admin := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", "admin")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assertMatchesSpec(t, http.MethodPost, "/admin/tenants", admin(http.MethodPost, "/admin/tenants", `{"id":"acme"}`))
+	assertMatchesSpec(t, http.MethodPost, "/admin/tenants", admin(http.MethodPost, "/admin/tenants", `{"id":"acme"}`))
+	assertMatchesSpec(t, http.MethodGet, "/admin/tenants", admin(http.MethodGet, "/admin/tenants", ""))
+	assertMatchesSpec(t, http.MethodGet, "/admin/config", admin(http.MethodGet, "/admin/config", ""))
+	assertMatchesSpec(t, http.MethodGet, "/admin/tenants/{id}", admin(http.MethodGet, "/admin/tenants/acme", ""))
+	assertMatchesSpec(t, http.MethodGet, "/admin/tenants/{id}/export", admin(http.MethodGet, "/admin/tenants/acme/export", ""))
+	assertMatchesSpec(t, http.MethodDelete, "/admin/tenants/{id}", admin(http.MethodDelete, "/admin/tenants/acme", ""))
+	assertMatchesSpec(t, http.MethodGet, "/admin/tenants/{id}", admin(http.MethodGet, "/admin/tenants/acme", ""))
+
+	for _, target := range []string{"/healthz", "/readyz", "/readyz?verbose", "/startupz", "/startupz?verbose", "/openapi.json", "/docs"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		assertMatchesSpec(t, http.MethodGet, strings.Split(target, "?")[0], rec)
+	}
+}
+
+// openAPIPath converts a gin route ("/todos/:id") to an OpenAPI path template.
+func openAPIPath(p string) string {
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segs[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+type openAPIDoc struct {
+	root map[string]any
+}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+
+	var root map[string]any
+	if err := json.Unmarshal(openAPISpec, &root); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+	return openAPIDoc{root: root}
+}
+
+// assertMatchesSpec checks that rec is a response documented for the operation
+// (status code and media type) and that its body validates against the schema.
+func assertMatchesSpec(t *testing.T, method, path string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	doc := loadOpenAPI(t)
+	if errs := doc.validateResponse(method, path, rec); len(errs) > 0 {
+		t.Fatalf("%s %s -> %d does not match openapi.json:\n  %s\nbody: %s",
+			method, path, rec.Code, strings.Join(errs, "\n  "), rec.Body.String())
+	}
+}
+
+func (d openAPIDoc) validateResponse(method, path string, rec *httptest.ResponseRecorder) []string {
+	paths, _ := d.root["paths"].(map[string]any)
+	item, ok := paths[path].(map[string]any)
+	if !ok {
+		return []string{"path not documented"}
+	}
+	op, ok := item[strings.ToLower(method)].(map[string]any)
+	if !ok {
+		return []string{"operation not documented"}
+	}
+	responses, _ := op["responses"].(map[string]any)
+	raw, ok := responses[strconv.Itoa(rec.Code)].(map[string]any)
+	if !ok {
+		return []string{fmt.Sprintf("status %d not documented", rec.Code)}
+	}
+	resp := d.resolve(raw)
+
+	content, _ := resp["content"].(map[string]any)
+	if len(content) == 0 {
+		if rec.Body.Len() > 0 {
+			return []string{"response documented without content but has a body"}
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		return []string{fmt.Sprintf("bad Content-Type %q", rec.Header().Get("Content-Type"))}
+	}
+	media, ok := content[mediaType].(map[string]any)
+	if !ok {
+		return []string{fmt.Sprintf("media type %q not documented", mediaType)}
+	}
+	schema, _ := media["schema"].(map[string]any)
+	if !strings.HasSuffix(mediaType, "json") {
+		return d.validate(schema, rec.Body.String(), "$")
+	}
+
+	var body any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return []string{fmt.Sprintf("body is not JSON: %v", err)}
+	}
+	return d.validate(schema, body, "$")
+}
+
+// resolve follows $ref pointers within the document.
+func (d openAPIDoc) resolve(node map[string]any) map[string]any {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node
+		}
+		var cur any = d.root
+		for _, seg := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			m, _ := cur.(map[string]any)
+			cur = m[seg]
+		}
+		node, _ = cur.(map[string]any)
+	}
+}
+
+// validate implements the subset of JSON Schema used by openapi.json.
+func (d openAPIDoc) validate(schema map[string]any, v any, at string) []string {
+	if schema == nil {
+		return nil
+	}
+	schema = d.resolve(schema)
+
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if typ, ok := schema["type"]; ok {
+		var types []string
+		switch tv := typ.(type) {
+		case string:
+			types = []string{tv}
+		case []any:
+			for _, x := range tv {
+				types = append(types, x.(string))
+			}
+		}
+		matched := false
+		for _, want := range types {
+			if jsonTypeMatches(want, v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected type %v, got %T", types, v)
+			return errs
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("%v is not one of %v", v, enum)
+		}
+	}
+
+	switch tv := v.(type) {
+	case string:
+		if n, ok := schema["maxLength"].(float64); ok && float64(len([]rune(tv))) > n {
+			fail("longer than %v", n)
+		}
+		if n, ok := schema["minLength"].(float64); ok && float64(len([]rune(tv))) < n {
+			fail("shorter than %v", n)
+		}
+		if p, ok := schema["pattern"].(string); ok && !regexp.MustCompile(p).MatchString(tv) {
+			fail("%q does not match %s", tv, p)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, tv); err != nil {
+				fail("%q is not a date-time", tv)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range tv {
+				errs = append(errs, d.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		if req, ok := schema["required"].([]any); ok {
+			for _, name := range req {
+				if _, ok := tv[name.(string)]; !ok {
+					fail("missing required property %q", name)
+				}
+			}
+		}
+		for name, val := range tv {
+			ps, ok := props[name].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					fail("unexpected property %q", name)
+				}
+				continue
+			}
+			errs = append(errs, d.validate(ps, val, at+"."+name)...)
+		}
+	}
+	return errs
+}
+
+func jsonTypeMatches(want string, v any) bool {
+	switch want {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// problemContentType is the media type of RFC 7807 error responses.
+const problemContentType = "application/problem+json"
+
+// Rules reported for request bodies that cannot be decoded. Domain rules
+// (required, max_length, ...) are defined in package domain.
+const (
+	ruleMalformedJSON = "malformed_json"
+	ruleUnknownField  = "unknown_field"
+	ruleType          = "type"
+)
+
+// problemType is a stable class of error. Its URI never changes once published,
+// so clients can switch on it.
+type problemType struct {
+	slug   string
+	status int
+	title  string
+}
+
+// problemTypePrefix makes problem types absolute URIs, as RFC 9457 asks, which
+// mean the same wherever the API is served.
+const problemTypePrefix = "urn:todo-api:problem:"
+
+func (p problemType) uri() string {
+	return problemTypePrefix + p.slug
+}
+
+var (
+	problemValidation     = problemType{"validation-error", 400, "Request validation failed"}
+	problemTenantRequired = problemType{"tenant-required", 400, "Tenant required"}
+	problemInvalidTenant  = problemType{"invalid-tenant", 400, "Invalid tenant"}
+	problemUnauthorized   = problemType{"unauthorized", 401, "Unauthorized"}
+	problemTenantMismatch = problemType{"tenant-mismatch", 403, "Tenant mismatch"}
+	problemQuotaExceeded  = problemType{"quota-exceeded", 403, "Quota exceeded"}
+	problemNotFound       = problemType{"not-found", 404, "Resource not found"}
+	problemUnknownTenant  = problemType{"unknown-tenant", 404, "Unknown tenant"}
+	problemConflict       = problemType{"conflict", 409, "Conflict"}
+	problemResyncRequired = problemType{"resync-required", 410, "Resync required"}
+	problemRateLimited    = problemType{"rate-limited", 429, "Too many requests"}
+	problemInternal       = problemType{"internal-error", 500, "Internal error"}
+	problemUnavailable    = problemType{"unavailable", 503, "Service unavailable"}
+)
+
+type problemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []fieldError `json:"errors,omitempty"`
+}
+
+type fieldError struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+// writeProblem aborts the request with an application/problem+json response.
+// The instance member is the request ID, so clients can quote it in reports.
+func writeProblem(c *gin.Context, p problemType, detail string, errs ...fieldError) {
+	reqID, _ := c.Get("request_id")
+	instance, _ := reqID.(string)
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.status, problemDetails{
+		Type:     p.uri(),
+		Title:    p.title,
+		Status:   p.status,
+		Detail:   detail,
+		Instance: instance,
+		Errors:   errs,
+	})
+}
+
+func writeValidationProblem(c *gin.Context, verr *domain.ValidationError) {
+	errs := make([]fieldError, 0, len(verr.Violations))
+	for _, v := range verr.Violations {
+		errs = append(errs, fieldError{Field: v.Field, Rule: v.Rule, Detail: v.Detail})
+	}
+	writeProblem(c, problemValidation, verr.Error(), errs...)
+}
+
+// bindJSON decodes the request body into dst, rejecting unknown fields, then
+// runs its binding rules. On failure it writes a validation problem listing
+// every offending field and returns false.
+func bindJSON(c *gin.Context, dst any) bool {
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{decodeViolation(err)}})
+		return false
+	}
+	if dec.More() {
+		writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{{
+			Rule:   ruleMalformedJSON,
+			Detail: "request body must contain a single JSON value",
+		}}})
+		return false
+	}
+
+	err := binding.Validator.ValidateStruct(dst)
+	if err == nil {
+		return true
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		writeProblem(c, problemValidation, err.Error())
+		return false
+	}
+	out := &domain.ValidationError{}
+	for _, fe := range verrs {
+		out.Violations = append(out.Violations, domain.FieldViolation{
+			Field:  fe.Field(),
+			Rule:   fe.Tag(),
+			Detail: fmt.Sprintf("%s is %s", fe.Field(), fe.Tag()),
+		})
+	}
+	writeValidationProblem(c, out)
+	return false
+}
+
+func decodeViolation(err error) domain.FieldViolation {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return domain.FieldViolation{Rule: ruleMalformedJSON, Detail: "request body must not be empty"}
+	case errors.As(err, &syntaxErr):
+		return domain.FieldViolation{Rule: ruleMalformedJSON, Detail: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset)}
+	case errors.As(err, &typeErr):
+		return domain.FieldViolation{Field: typeErr.Field, Rule: ruleType, Detail: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type)}
+	}
+	// encoding/json has no typed error for unknown fields.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		name = strings.Trim(name, `"`)
+		return domain.FieldViolation{Field: name, Rule: ruleUnknownField, Detail: fmt.Sprintf("unknown field %q", name)}
+	}
+	return domain.FieldViolation{Rule: ruleMalformedJSON, Detail: "malformed JSON"}
+}
+
+func init() {
+	// Report fields by their JSON name rather than the Go struct field name.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				return f.Name
+			}
+			return name
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/storage/boltdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProblemDetails_Validation(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	srv := NewRouter(RouterOptions{TodoService: svc})
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		field  string
+		rule   string
+	}{
+		{"empty title", http.MethodPost, "/todos", `{"title":"   "}`, 400, "title", domain.RuleRequired},
+		{"missing title", http.MethodPost, "/todos", `{}`, 400, "title", "required"},
+		{"long title", http.MethodPost, "/todos", `{"title":"` + strings.Repeat("a", domain.MaxTitleLen+1) + `"}`, 400, "title", domain.RuleMaxLength},
+		{"malformed json", http.MethodPost, "/todos", `{"title":`, 400, "", ruleMalformedJSON},
+		{"unknown field", http.MethodPost, "/todos", `{"title":"x","priority":1}`, 400, "priority", ruleUnknownField},
+		{"wrong type", http.MethodPut, "/todos/x", `{"title":"x","completed":"yes"}`, 400, "completed", ruleType},
+		{"missing completed", http.MethodPut, "/todos/x", `{"title":"x"}`, 400, "completed", "required"},
+		{"not found", http.MethodPut, "/todos/x", `{"title":"x","completed":true}`, 404, "", ""},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Request-Id", "req-123")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected status %d, got %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+				t.Fatalf("expected content type %q, got %q", problemContentType, ct)
+			}
+
+			var p problemDetails
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("unmarshal problem: %v", err)
+			}
+			if p.Status != tc.status || p.Instance != "req-123" || p.Type == "" || p.Title == "" {
+				t.Fatalf("unexpected problem: %+v", p)
+			}
+			if tc.rule == "" {
+				return
+			}
+			if p.Type != problemValidation.uri() {
+				t.Fatalf("expected type %q, got %q", problemValidation.uri(), p.Type)
+			}
+			if len(p.Errors) != 1 || p.Errors[0].Field != tc.field || p.Errors[0].Rule != tc.rule {
+				t.Fatalf("expected %s/%s error, got %+v", tc.field, tc.rule, p.Errors)
+			}
+		})
+	}
+}
+
+func TestWriteError_BareSentinels(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		err  error
+		want problemType
+	}{
+		{domain.ErrInvalidTitle, problemValidation},
+		{fmt.Errorf("import: %w", domain.ErrInvalidParent), problemValidation},
+		{domain.ErrQuotaExceeded, problemQuotaExceeded},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		writeError(c, tc.err)
+
+		var p problemDetails
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("unmarshal problem: %v", err)
+		}
+		if rec.Code != tc.want.status || p.Type != tc.want.uri() || !strings.HasPrefix(p.Type, "urn:") {
+			t.Fatalf("%v: expected %d %s, got %d %s", tc.err, tc.want.status, tc.want.uri(), rec.Code, p.Type)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/ratelimit"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestRateLimit_PerAPIKey(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	srv := NewRouter(RouterOptions{
+		TodoService:      svc,
+		RateLimiter:      ratelimit.New(0.001, 2),
+		RateLimitKey:     RateLimitByAPIKey,
+		RateLimitAPIKeys: []string{"a", "b"},
+	})
+
+	get := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := get("a"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status %d, got %d", i, http.StatusOK, rec.Code)
+		}
+	}
+
+	rec := get("a")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("missing rate limit headers: %v", rec.Header())
+	}
+	assertMatchesSpec(t, http.MethodGet, "/todos", rec)
+
+	if rec := get("b"); rec.Code != http.StatusOK {
+		t.Fatalf("expected a separate budget per API key, got %d", rec.Code)
+	}
+
+	// Unknown keys share the budget of the client IP, so a new key per
+	// request does not get around the limit.
+	for i := 0; i < 2; i++ {
+		get(fmt.Sprintf("random-%d", i))
+	}
+	if rec := get("random-2"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected unknown API keys limited by IP, got %d", rec.Code)
+	}
+}
+
+func TestRateLimit_IgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		proxies []string
+		want    int
+	}{
+		{name: "no trusted proxy", want: http.StatusTooManyRequests},
+		{name: "trusted proxy", proxies: []string{"192.0.2.0/24"}, want: http.StatusOK},
+	} {
+		srv := NewRouter(RouterOptions{
+			TodoService:    svc,
+			RateLimiter:    ratelimit.New(0.001, 2),
+			TrustedProxies: tc.proxies,
+		})
+		var rec *httptest.ResponseRecorder
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+			rec = httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+		}
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected status %d for the third spoofed address, got %d", tc.name, tc.want, rec.Code)
+		}
+	}
+}
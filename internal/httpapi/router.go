@@ -1,51 +1,204 @@
 package httpapi
 
 import (
-	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"challenge-backend-arancia/internal/application/feeds"
+	"challenge-backend-arancia/internal/application/tenants"
 	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/application/webhooks"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/config"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/health"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/metrics"
+	"challenge-backend-arancia/internal/ratelimit"
+	"challenge-backend-arancia/internal/tracing"
 
+	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RouterOptions struct {
 	TodoService *todos.Service
-	Ready       func(ctx context.Context) error
-	Logger      *slog.Logger
+	// Health backs /readyz and /startupz; without it the service never
+	// reports ready.
+	Health *health.Registry
+	// Logger writes a line per request and, scoped to the request, is
+	// attached to its context for logging.FromContext.
+	Logger *slog.Logger
+	// LogSampler keeps a share of the lines of successful requests; nil keeps
+	// them all. Requests failing with a 4xx or 5xx are always logged.
+	LogSampler *logging.Sampler
+	// Metrics records every request when set. It is not served by the
+	// router; see metrics.Metrics.Handler.
+	Metrics *metrics.Metrics
+
+	// TenantResolvers are tried in order to find the tenant of a todo request.
+	// Requests matching none use tenancy.DefaultID unless RequireTenant is set.
+	TenantResolvers []TenantResolver
+	RequireTenant   bool
+
+	// TokenVerifier enables bearer token authentication when set. The todo
+	// routes then require a token bound to a tenant, which is the tenant of
+	// the request.
+	TokenVerifier *auth.Verifier
+
+	// RateLimiter throttles the todo endpoints per client when set and
+	// given a rate. With RateLimitByAPIKey, only the X-API-Key values in
+	// RateLimitAPIKeys identify a client.
+	RateLimiter      *ratelimit.Limiter
+	RateLimitKey     RateLimitKey
+	RateLimitAPIKeys []string
+
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse
+	// proxies allowed to set the client IP in X-Forwarded-For or X-Real-IP.
+	// By default no proxy is trusted, so clients cannot pick the IP they are
+	// rate limited by; an invalid entry also trusts none.
+	TrustedProxies []string
+
+	// Events enables the /todos/events (SSE) and /todos/ws (WebSocket) change
+	// streams. StreamHeartbeat defaults to 15s.
+	Events          *events.Bus
+	StreamHeartbeat time.Duration
+
+	// GraphQL serves /graphql when set, scoped to a tenant like the todo
+	// routes. In debug mode /graphiql serves an in-browser IDE for it.
+	GraphQL http.Handler
+
+	// CalDAV serves the todos to calendar apps under /dav when set. It must
+	// be built with caldav.DefaultPrefix.
+	CalDAV http.Handler
+
+	// FeedService enables /todos.ics and /todos.atom, authenticated by the
+	// feed tokens /v1/feeds/token issues.
+	FeedService *feeds.Service
+
+	// WebhookService enables the /v1/webhooks subscription endpoints.
+	WebhookService *webhooks.Service
+
+	// CORS lets the browser origins it allows call the API when set.
+	CORS *CORS
+
+	// UISessionKey enables the web UI under /ui, encrypting its session
+	// cookies and signing its CSRF tokens. With a TokenVerifier, people can
+	// sign in with a bearer token; otherwise the UI is anonymous like the API.
+	// The cookies are Secure unless UIInsecureCookies is set, for browsers
+	// reaching the server over plain HTTP.
+	UISessionKey      []byte
+	UIInsecureCookies bool
+
+	// Features switches off the GraphQL, CalDAV, feed and UI routes while
+	// serving; nil keeps them all on.
+	Features *Features
+
+	// LegacyDeprecatedAt and LegacySunset are announced in the Deprecation
+	// and Sunset headers of the deprecated unversioned routes. A zero
+	// LegacyDeprecatedAt sends "Deprecation: true"; a zero LegacySunset omits
+	// the header.
+	LegacyDeprecatedAt time.Time
+	LegacySunset       time.Time
+
+	// AdminToken enables the /admin endpoints: the tenant endpoints when
+	// TenantService is set and /admin/config when Settings is.
+	AdminToken    string
+	TenantService *tenants.Service
+	Settings      func() []config.Setting
 }
 
 func NewRouter(opts RouterOptions) http.Handler {
+	if opts.StreamHeartbeat <= 0 {
+		opts.StreamHeartbeat = defaultHeartbeat
+	}
+
 	r := gin.New()
+	if err := r.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		_ = r.SetTrustedProxies(nil)
+	}
 	r.Use(gin.Recovery())
 	r.Use(requestIDMiddleware())
+	r.Use(tracingMiddleware())
 	if opts.Logger != nil {
-		r.Use(loggingMiddleware(opts.Logger))
+		r.Use(loggingMiddleware(opts.Logger, opts.LogSampler))
+	}
+	if opts.Metrics != nil {
+		r.Use(metricsMiddleware(opts.Metrics))
+	}
+	// Preflight requests carry no credentials.
+	if opts.CORS != nil {
+		r.Use(corsMiddleware(opts.CORS))
+	}
+	r.Use(clientCertMiddleware())
+	if opts.TokenVerifier != nil {
+		r.Use(authMiddleware(opts.TokenVerifier))
 	}
 
-	r.GET("/healthz", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	registerHealth(r, opts.Health)
+	registerDocs(r)
 
-	r.GET("/readyz", func(c *gin.Context) {
-		if opts.Ready == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready"})
-			return
+	if opts.TodoService != nil {
+		var limited []gin.HandlerFunc
+		if opts.RateLimiter != nil {
+			limited = append(limited, rateLimitMiddleware(opts.RateLimiter, opts.RateLimitKey, opts.RateLimitAPIKeys))
 		}
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 500*time.Millisecond)
-		defer cancel()
-		if err := opts.Ready(ctx); err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready"})
-			return
+		tenanted := append([]gin.HandlerFunc{tenantMiddleware(opts.TenantResolvers, opts.RequireTenant, opts.TokenVerifier != nil)}, limited...)
+
+		versions := apiVersions(opts)
+		for _, v := range versions {
+			v.api.register(r.Group("/"+v.name, tenanted...))
 		}
-		c.JSON(http.StatusOK, gin.H{"status": "ready"})
-	})
 
-	if opts.TodoService != nil {
-		todoHandler{svc: opts.TodoService}.register(r)
+		// Unversioned aliases of the v1 todo routes, kept for clients that
+		// predate versioning.
+		legacy := r.Group("", tenanted...)
+		legacy.Use(deprecationMiddleware("/"+versions[0].name, opts.LegacyDeprecatedAt, opts.LegacySunset))
+		newTodoHandlerV1(opts).register(legacy)
+
+		if opts.GraphQL != nil {
+			graph := r.Group("", featureMiddleware(opts.Features, FeatureGraphQL),
+				graphqlTenantMiddleware(opts.TenantResolvers, opts.RequireTenant, opts.TokenVerifier != nil))
+			graph.Use(limited...)
+			graph.GET("/graphql", gin.WrapH(opts.GraphQL))
+			graph.POST("/graphql", gin.WrapH(opts.GraphQL))
+			if gin.Mode() == gin.DebugMode {
+				r.GET("/graphiql", gin.WrapH(playground.Handler("Todos GraphQL", "/graphql")))
+			}
+		}
+
+		if opts.CalDAV != nil {
+			registerCalDAV(r, opts.CalDAV, opts.TokenVerifier,
+				append([]gin.HandlerFunc{featureMiddleware(opts.Features, FeatureCalDAV)}, tenanted...))
+		}
+
+		if opts.FeedService != nil {
+			// The tenant comes from the feed token instead.
+			registerFeeds(r, opts.TodoService, opts.FeedService,
+				append([]gin.HandlerFunc{featureMiddleware(opts.Features, FeatureFeeds)}, limited...))
+		}
+
+		if len(opts.UISessionKey) > 0 {
+			registerUI(r, opts.TodoService, opts.TokenVerifier, opts.UISessionKey, !opts.UIInsecureCookies,
+				featureMiddleware(opts.Features, FeatureUI), tenanted)
+		}
+	}
+
+	if opts.AdminToken != "" {
+		admin := r.Group("/admin", adminMiddleware(opts.AdminToken))
+		if opts.TenantService != nil {
+			tenantHandler{svc: opts.TenantService}.register(admin)
+		}
+		if opts.Settings != nil {
+			admin.GET("/config", configHandler(opts.Settings))
+		}
 	}
 
 	return r
@@ -64,20 +217,86 @@ func requestIDMiddleware() gin.HandlerFunc {
 	}
 }
 
-func loggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
+// loggingMiddleware attaches a logger scoped to the request ID and route to
+// the request context, and logs the outcome of every request with it once
+// the chain is done. Credential headers are redacted from debug logs.
+func loggingMiddleware(logger *slog.Logger, sampler *logging.Sampler) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		c.Next()
-
 		reqID, _ := c.Get("request_id")
-
-		logger.Info(
-			"http_request",
+		ctx := logging.WithLogger(c.Request.Context(), logger.With(
+			slog.Any("request_id", reqID),
 			slog.String("method", c.Request.Method),
 			slog.String("path", c.FullPath()),
-			slog.Int("status", c.Writer.Status()),
+		))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case !sampler.Keep():
+			return
+		}
+		// The auth and tenant middlewares add the user and tenant to the
+		// logger of the request.
+		ctx = c.Request.Context()
+		reqLogger := logging.FromContext(ctx)
+		attrs := []slog.Attr{
+			slog.Int("status", status),
 			slog.Duration("duration", time.Since(start)),
-			slog.Any("request_id", reqID),
+		}
+		if reqLogger.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, slog.Any("headers", logging.Headers(c.Request.Header)))
+		}
+		reqLogger.LogAttrs(ctx, level, "http_request", attrs...)
+	}
+}
+
+// tracingMiddleware continues the trace of the W3C traceparent header, or
+// starts a new one, with a server span around the rest of the chain.
+func tracingMiddleware() gin.HandlerFunc {
+	tracer := tracing.Tracer("httpapi")
+	return func(c *gin.Context) {
+		ctx := tracing.Propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		name := c.Request.Method
+		if route := c.FullPath(); route != "" {
+			name += " " + route
+		}
+		reqID, _ := c.Get("request_id")
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", c.FullPath()),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("http.request.id", fmt.Sprint(reqID)),
+			),
 		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// metricsMiddleware records the method, route pattern, status and latency of
+// every request.
+func metricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		m.ObserveRequest(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
 	}
 }
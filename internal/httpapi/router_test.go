@@ -1,9 +1,21 @@
 package httpapi
 
 import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/metrics"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
 )
 
 func TestHealthz(t *testing.T) {
@@ -19,3 +31,107 @@ func TestHealthz(t *testing.T) {
 		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
 	}
 }
+
+func TestMetricsMiddleware_LabelsByRoute(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	m, err := metrics.New(db)
+	if err != nil {
+		t.Fatalf("new metrics: %v", err)
+	}
+	srv := NewRouter(RouterOptions{Metrics: m})
+
+	for _, target := range []string{"/healthz", "/healthz", "/no/such/path"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`todo_http_requests_total{method="GET",route="/healthz",status="200"} 2`,
+		`todo_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestLoggingMiddleware_ScopesSamplesAndRedacts(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	bearer, err := verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenancy.DefaultID})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	var logs bytes.Buffer
+	srv := NewRouter(RouterOptions{
+		TodoService:   svc,
+		TokenVerifier: verifier,
+		Logger:        slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		LogSampler:    &logging.Sampler{Rate: 0},
+	})
+
+	for _, r := range []struct{ method, target string }{
+		{http.MethodGet, "/v1/todos"},
+		{http.MethodDelete, "/v1/todos/missing"},
+	} {
+		req := httptest.NewRequest(r.method, r.target, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		req.Header.Set("X-Request-Id", "req-1")
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// The successful request is sampled out.
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line, got %d: %s", len(lines), logs.String())
+	}
+	var entry struct {
+		Level     string            `json:"level"`
+		Msg       string            `json:"msg"`
+		RequestID string            `json:"request_id"`
+		Path      string            `json:"path"`
+		User      string            `json:"user"`
+		Tenant    string            `json:"tenant"`
+		Status    int               `json:"status"`
+		Headers   map[string]string `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("decode log: %v", err)
+	}
+	if entry.Level != "WARN" || entry.Status != http.StatusNotFound || entry.Path != "/v1/todos/:id" {
+		t.Fatalf("expected a warning for the 404, got %s", lines[0])
+	}
+	if entry.RequestID != "req-1" || entry.User != "alice" || entry.Tenant != "default" {
+		t.Fatalf("expected request-scoped attributes, got %s", lines[0])
+	}
+	if entry.Headers["Authorization"] != logging.Redacted || entry.Headers["X-Request-Id"] != "req-1" {
+		t.Fatalf("expected redacted headers, got %v", entry.Headers)
+	}
+	if strings.Contains(logs.String(), bearer) {
+		t.Fatalf("expected the token not to be logged, got %s", logs.String())
+	}
+}
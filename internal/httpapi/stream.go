@@ -0,0 +1,224 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// defaultHeartbeat is how often idle streams are pinged so proxies and clients
+// can tell a quiet stream from a dead one.
+const defaultHeartbeat = 15 * time.Second
+
+// Control messages sent on streams besides todo events.
+const (
+	// streamReset tells the client events were missed; it should refetch the
+	// list before relying on the stream again.
+	streamReset = "reset"
+	// streamLagged tells the client it was disconnected for reading too slowly;
+	// it should reconnect with the last event ID it processed.
+	streamLagged = "lagged"
+)
+
+type todoEventResponse struct {
+	ID         string       `json:"id"`
+	Type       string       `json:"type"`
+	OccurredAt time.Time    `json:"occurred_at"`
+	Todo       todoResponse `json:"todo"`
+}
+
+type streamControlResponse struct {
+	Type string `json:"type"`
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// subscribe opens an event subscription scoped to the request's tenant, and to
+// the caller's own todos with ?mine=true. It resumes after the Last-Event-ID
+// header (or ?last_event_id= for WebSocket clients, which cannot set headers).
+func (h todoHandler) subscribe(c *gin.Context) (*events.Subscription, bool) {
+	tenant := tenancy.FromContext(c.Request.Context())
+	filter := func(ev domain.Event) bool { return ev.Tenant == tenant }
+
+	if c.Query("mine") == "true" {
+		claims, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			writeProblem(c, problemUnauthorized, "mine=true requires a bearer token")
+			return nil, false
+		}
+		filter = func(ev domain.Event) bool {
+			return ev.Tenant == tenant && ev.Todo.Owner == claims.Subject
+		}
+	}
+
+	var after uint64
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{{
+				Field:  "Last-Event-ID",
+				Rule:   domain.RuleFormat,
+				Detail: "Last-Event-ID must be an event id previously sent by this stream",
+			}}})
+			return nil, false
+		}
+		after = n
+	}
+
+	sub, err := h.events.Subscribe(filter, after)
+	if err != nil {
+		writeProblem(c, problemUnavailable, "the server is shutting down")
+		return nil, false
+	}
+	return sub, true
+}
+
+// streamSSE serves todo changes as Server-Sent Events.
+func (h todoHandler) streamSSE(c *gin.Context) {
+	sub, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprint(w, "retry: 3000\n\n")
+	if sub.Gap {
+		writeSSE(w, "", streamReset, streamControlResponse{Type: streamReset})
+	}
+	w.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				if sub.Lagged {
+					writeSSE(w, "", streamLagged, streamControlResponse{Type: streamLagged})
+					w.Flush()
+				}
+				return
+			}
+			resp := toEventResponse(ev)
+			writeSSE(w, resp.ID, resp.Type, resp)
+		case <-ticker.C:
+			_, _ = fmt.Fprint(w, ": ping\n\n")
+		}
+		w.Flush()
+	}
+}
+
+func writeSSE(w gin.ResponseWriter, id, event string, data any) {
+	payload, _ := json.Marshal(data)
+	if id != "" {
+		_, _ = fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+// streamWS serves todo changes over a WebSocket, one JSON message per event.
+// The connection is read only to process control frames.
+func (h todoHandler) streamWS(c *gin.Context) {
+	sub, ok := h.subscribe(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already replied with an HTTP error.
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	readTimeout := 2 * h.heartbeat
+	_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(readTimeout))
+	})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(v any) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(h.heartbeat))
+		return conn.WriteJSON(v)
+	}
+	closeWith := func(code int, reason string) {
+		msg := websocket.FormatCloseMessage(code, reason)
+		_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	}
+
+	if sub.Gap {
+		if err := write(streamControlResponse{Type: streamReset}); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				if sub.Lagged {
+					closeWith(websocket.CloseTryAgainLater, streamLagged)
+				} else {
+					closeWith(websocket.CloseGoingAway, "server shutting down")
+				}
+				return
+			}
+			if err := write(toEventResponse(ev)); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func toEventResponse(ev domain.Event) todoEventResponse {
+	return todoEventResponse{
+		ID:         strconv.FormatUint(ev.Seq, 10),
+		Type:       string(ev.Type),
+		OccurredAt: ev.OccurredAt,
+		Todo:       toResponse(ev.Todo),
+	}
+}
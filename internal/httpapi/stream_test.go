@@ -0,0 +1,179 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/storage/boltdb"
+
+	"github.com/gorilla/websocket"
+)
+
+func newStreamServer(t *testing.T) (*httptest.Server, *events.Bus) {
+	t.Helper()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	outbox, err := boltdb.NewOutboxRepository(db)
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	bus := events.NewBus()
+	relay, err := events.NewRelay(outbox, []events.Sink{{Name: "streams", Publisher: bus}}, events.DefaultRelayAttempts, nil)
+	if err != nil {
+		t.Fatalf("new relay: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx, 5*time.Millisecond)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+
+	srv := httptest.NewServer(NewRouter(RouterOptions{
+		TodoService:     svc,
+		Events:          bus,
+		StreamHeartbeat: 50 * time.Millisecond,
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(bus.Close)
+	return srv, bus
+}
+
+func createTodo(t *testing.T, base, title string) {
+	t.Helper()
+
+	resp, err := http.Post(base+"/v1/todos", "application/json", strings.NewReader(`{"title":"`+title+`"}`))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+// readSSE returns the next event of the stream, skipping comments.
+func readSSE(t *testing.T, r *bufio.Reader) (id, event string, data todoEventResponse) {
+	t.Helper()
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("unmarshal data: %v", err)
+			}
+		case line == "" && event != "":
+			return id, event, data
+		}
+	}
+}
+
+func TestStream_SSE(t *testing.T) {
+	t.Parallel()
+
+	srv, bus := newStreamServer(t)
+
+	resp, err := http.Get(srv.URL + "/v1/todos/events")
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+
+	createTodo(t, srv.URL, "first")
+	createTodo(t, srv.URL, "second")
+
+	id, event, data := readSSE(t, r)
+	if event != "todo.created" || data.Todo.Title != "first" || id != data.ID {
+		t.Fatalf("unexpected event %s %s %+v", id, event, data)
+	}
+
+	// resuming after the first event replays the second
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/todos/events", nil)
+	req.Header.Set("Last-Event-ID", id)
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("resume stream: %v", err)
+	}
+	defer func() { _ = resumed.Body.Close() }()
+	if _, _, data := readSSE(t, bufio.NewReader(resumed.Body)); data.Todo.Title != "second" {
+		t.Fatalf("expected replay of second todo, got %+v", data)
+	}
+
+	// shutdown ends the stream once buffered events are delivered
+	bus.Close()
+	if _, _, data := readSSE(t, r); data.Todo.Title != "second" {
+		t.Fatalf("expected second todo, got %+v", data)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatalf("expected stream to end cleanly, got %v", err)
+	}
+}
+
+func TestStream_WebSocket(t *testing.T) {
+	t.Parallel()
+
+	srv, bus := newStreamServer(t)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/todos/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	createTodo(t, srv.URL, "via ws")
+
+	var ev todoEventResponse
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if ev.Type != "todo.created" || ev.Todo.Title != "via ws" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	bus.Close()
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected going-away close on shutdown, got %v", err)
+	}
+}
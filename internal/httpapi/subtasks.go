@@ -0,0 +1,132 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// addChildRequest creates a subtask from title and auto_complete, or moves
+// the existing todo id under the parent; exactly one of title and id is set.
+type addChildRequest struct {
+	Title        string `json:"title"`
+	AutoComplete bool   `json:"auto_complete"`
+	ID           string `json:"id"`
+}
+
+type reorderChildrenRequest struct {
+	IDs []string `json:"ids" binding:"required"`
+}
+
+func (h todoHandler) listChildren(c *gin.Context) {
+	out, err := h.svc.Subtasks(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	h.respondList(c, out)
+}
+
+func (h todoHandler) addChild(c *gin.Context) {
+	var req addChildRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if (req.Title == "") == (req.ID == "") {
+		writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{{
+			Field:  "title",
+			Rule:   domain.RuleRequired,
+			Detail: "exactly one of title, to create a subtask, and id, to move a todo, must be set",
+		}}})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if req.ID != "" {
+		if req.AutoComplete {
+			writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{{
+				Field:  "auto_complete",
+				Rule:   domain.RuleFormat,
+				Detail: "auto_complete applies to new subtasks only; update the todo to change it",
+			}}})
+			return
+		}
+		td, err := h.svc.Move(ctx, req.ID, c.Param("id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		h.respond(c, http.StatusOK, td)
+		return
+	}
+	td, err := h.svc.Add(ctx, todos.NewTodo{Title: req.Title, ParentID: c.Param("id"), AutoComplete: req.AutoComplete})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toResponse(td))
+}
+
+func (h todoHandler) reorderChildren(c *gin.Context) {
+	var req reorderChildrenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	out, err := h.svc.ReorderSubtasks(c.Request.Context(), c.Param("id"), req.IDs)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	h.respondList(c, out)
+}
+
+// detachChild makes a subtask a top-level todo; it is not deleted.
+func (h todoHandler) detachChild(c *gin.Context) {
+	ctx := c.Request.Context()
+	td, err := h.svc.Get(ctx, c.Param("child_id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if td.ParentID != c.Param("id") {
+		writeProblem(c, problemNotFound, "the todo is not a subtask of this one")
+		return
+	}
+	td, err = h.svc.Move(ctx, td.ID, "")
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, td)
+}
+
+// respond writes td with the progress of its subtasks.
+func (h todoHandler) respond(c *gin.Context, status int, td domain.Todo) {
+	subtasks, err := h.svc.Subtasks(c.Request.Context(), td.ID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(status, withProgress(toResponse(td), subtasks))
+}
+
+// respondList writes list, each todo with the progress of its subtasks.
+func (h todoHandler) respondList(c *gin.Context, list []domain.Todo) {
+	ids := make([]string, 0, len(list))
+	for _, td := range list {
+		ids = append(ids, td.ID)
+	}
+	subtasks, err := h.svc.SubtasksOf(c.Request.Context(), ids)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := make([]todoResponse, 0, len(list))
+	for _, td := range list {
+		resp = append(resp, withProgress(toResponse(td), subtasks[td.ID]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
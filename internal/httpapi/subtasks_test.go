@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestTodos_Subtasks(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	srv := NewRouter(RouterOptions{TodoService: svc})
+
+	do := func(method, path, spec string, body any, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		var b []byte
+		if body != nil {
+			b, _ = json.Marshal(body)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, want, rec.Code, rec.Body.String())
+		}
+		assertMatchesSpec(t, method, spec, rec)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+	}
+	type todo struct {
+		ID           string `json:"id"`
+		Completed    bool   `json:"completed"`
+		ParentID     string `json:"parent_id"`
+		AutoComplete bool   `json:"auto_complete"`
+		Progress     *int   `json:"progress"`
+	}
+
+	var parent todo
+	decode(do(http.MethodPost, "/v1/todos", "/v1/todos", map[string]any{"title": "trip", "auto_complete": true}, http.StatusCreated), &parent)
+	children := "/v1/todos/" + parent.ID + "/children"
+	var tickets, hotel todo
+	decode(do(http.MethodPost, children, "/v1/todos/{id}/children", map[string]any{"title": "tickets"}, http.StatusCreated), &tickets)
+	decode(do(http.MethodPost, "/v1/todos", "/v1/todos", map[string]any{"title": "hotel", "parent_id": parent.ID}, http.StatusCreated), &hotel)
+	if tickets.ParentID != parent.ID || hotel.ParentID != parent.ID {
+		t.Fatalf("expected subtasks of %s, got %+v and %+v", parent.ID, tickets, hotel)
+	}
+	do(http.MethodPost, children, "/v1/todos/{id}/children", map[string]any{"title": "x", "id": hotel.ID}, http.StatusBadRequest)
+	do(http.MethodPost, "/v1/todos/missing/children", "/v1/todos/{id}/children", map[string]any{"title": "x"}, http.StatusNotFound)
+	do(http.MethodPost, "/v1/todos/"+tickets.ID+"/children", "/v1/todos/{id}/children", map[string]any{"id": parent.ID}, http.StatusBadRequest)
+
+	// Completing a subtask shows as progress; completing both completes
+	// the auto-completing parent.
+	var updated todo
+	decode(do(http.MethodPut, "/v1/todos/"+tickets.ID, "/v1/todos/{id}", map[string]any{"title": "tickets", "completed": true}, http.StatusOK), &updated)
+	var list []todo
+	decode(do(http.MethodGet, "/v1/todos", "/v1/todos", nil, http.StatusOK), &list)
+	for _, td := range list {
+		if td.ID == parent.ID && (td.Progress == nil || *td.Progress != 50 || td.Completed) {
+			t.Fatalf("expected the parent open at 50%%, got %+v", td)
+		}
+		if td.ID != parent.ID && td.Progress != nil {
+			t.Fatalf("expected no progress without subtasks, got %+v", td)
+		}
+	}
+	do(http.MethodPut, "/v1/todos/"+hotel.ID, "/v1/todos/{id}", map[string]any{"title": "hotel", "completed": true}, http.StatusOK)
+	decode(do(http.MethodGet, "/v1/todos", "/v1/todos", nil, http.StatusOK), &list)
+	for _, td := range list {
+		if td.ID == parent.ID && (*td.Progress != 100 || !td.Completed) {
+			t.Fatalf("expected the parent completed at 100%%, got %+v", td)
+		}
+	}
+
+	// Reorder, with a stale list rejected.
+	do(http.MethodPut, children, "/v1/todos/{id}/children", map[string]any{"ids": []string{hotel.ID}}, http.StatusConflict)
+	decode(do(http.MethodPut, children, "/v1/todos/{id}/children", map[string]any{"ids": []string{hotel.ID, tickets.ID}}, http.StatusOK), &list)
+	if len(list) != 2 || list[0].ID != hotel.ID {
+		t.Fatalf("expected hotel first, got %+v", list)
+	}
+
+	// Detach a subtask, then move it back.
+	do(http.MethodDelete, children+"/"+parent.ID, "/v1/todos/{id}/children/{child_id}", nil, http.StatusNotFound)
+	var detached todo
+	decode(do(http.MethodDelete, children+"/"+hotel.ID, "/v1/todos/{id}/children/{child_id}", nil, http.StatusOK), &detached)
+	if detached.ParentID != "" {
+		t.Fatalf("expected a top-level todo, got %+v", detached)
+	}
+	decode(do(http.MethodPost, children, "/v1/todos/{id}/children", map[string]any{"id": hotel.ID}, http.StatusOK), &detached)
+	decode(do(http.MethodGet, children, "/v1/todos/{id}/children", nil, http.StatusOK), &list)
+	if len(list) != 2 || list[1].ID != hotel.ID {
+		t.Fatalf("expected hotel moved back last, got %+v", list)
+	}
+
+	// Deleting the parent deletes its subtasks.
+	do(http.MethodDelete, "/v1/todos/"+parent.ID, "/v1/todos/{id}", nil, http.StatusNoContent)
+	decode(do(http.MethodGet, "/v1/todos", "/v1/todos", nil, http.StatusOK), &list)
+	if len(list) != 0 {
+		t.Fatalf("expected no todos left, got %+v", list)
+	}
+}
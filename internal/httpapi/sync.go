@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ruleQuotaExceeded is reported for sync creates rejected by a quota.
+const ruleQuotaExceeded = "quota_exceeded"
+
+type syncRequest struct {
+	Since     string                `json:"since"`
+	Mutations []syncMutationRequest `json:"mutations" binding:"required"`
+}
+
+// syncMutationRequest is validated by the service, so one bad mutation is
+// rejected on its own instead of failing the whole batch.
+type syncMutationRequest struct {
+	Op          string `json:"op"`
+	ID          string `json:"id"`
+	BaseVersion uint64 `json:"base_version"`
+	Title       string `json:"title"`
+	Completed   bool   `json:"completed"`
+}
+
+type syncResponse struct {
+	Results   []syncResultResponse `json:"results"`
+	Changes   []changeResponse     `json:"changes"`
+	NextToken string               `json:"next_token"`
+	HasMore   bool                 `json:"has_more"`
+}
+
+type syncResultResponse struct {
+	ID       string            `json:"id"`
+	Status   string            `json:"status"`
+	Todo     *todoResponse     `json:"todo,omitempty"`
+	Conflict *conflictResponse `json:"conflict,omitempty"`
+	Errors   []fieldError      `json:"errors,omitempty"`
+}
+
+type conflictResponse struct {
+	Fields []string      `json:"fields"`
+	Base   *todoResponse `json:"base,omitempty"`
+	Client *todoResponse `json:"client,omitempty"`
+	Server *todoResponse `json:"server,omitempty"`
+}
+
+// sync applies a batch of offline mutations and returns the server delta
+// since the client's sync token.
+func (h todoHandler) sync(c *gin.Context) {
+	var req syncRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	muts := make([]todos.Mutation, 0, len(req.Mutations))
+	for _, m := range req.Mutations {
+		muts = append(muts, todos.Mutation{
+			Op:          todos.MutationOp(m.Op),
+			ID:          m.ID,
+			BaseVersion: m.BaseVersion,
+			Title:       m.Title,
+			Completed:   m.Completed,
+		})
+	}
+
+	out, err := h.svc.Sync(c.Request.Context(), req.Since, muts, 0)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := syncResponse{
+		Results:   make([]syncResultResponse, 0, len(out.Results)),
+		Changes:   toChangeResponses(out.Changes.Changes),
+		NextToken: out.Changes.Token,
+		HasMore:   out.Changes.More,
+	}
+	for _, r := range out.Results {
+		item := syncResultResponse{ID: r.ID, Status: string(r.Status), Todo: toResponsePtr(r.Todo)}
+		if r.Conflict != nil {
+			item.Conflict = &conflictResponse{
+				Fields: r.Conflict.Fields,
+				Base:   toResponsePtr(r.Conflict.Base),
+				Client: toResponsePtr(r.Conflict.Client),
+				Server: toResponsePtr(r.Conflict.Server),
+			}
+			if item.Conflict.Fields == nil {
+				item.Conflict.Fields = []string{}
+			}
+		}
+		if r.Err != nil {
+			item.Errors = mutationErrors(r.Err)
+		}
+		resp.Results = append(resp.Results, item)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// mutationErrors describes why a mutation was rejected.
+func mutationErrors(err error) []fieldError {
+	var verr *domain.ValidationError
+	if errors.As(err, &verr) {
+		out := make([]fieldError, 0, len(verr.Violations))
+		for _, v := range verr.Violations {
+			out = append(out, fieldError{Field: v.Field, Rule: v.Rule, Detail: v.Detail})
+		}
+		return out
+	}
+	return []fieldError{{Rule: ruleQuotaExceeded, Detail: err.Error()}}
+}
+
+func toResponsePtr(td *domain.Todo) *todoResponse {
+	if td == nil {
+		return nil
+	}
+	resp := toResponse(*td)
+	return &resp
+}
@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestTodos_Sync(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	srv := NewRouter(RouterOptions{TodoService: svc})
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+	sync := func(body string) syncResponse {
+		t.Helper()
+		rec := do(http.MethodPost, "/v1/sync", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		assertMatchesSpec(t, http.MethodPost, "/v1/sync", rec)
+		var out syncResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+
+	// The client creates two todos offline and syncs them.
+	first := sync(`{"mutations":[
+		{"op":"create","id":"a","title":"buy milk"},
+		{"op":"create","id":"b","title":"call mom"},
+		{"op":"create","id":"bad/id","title":"x"}
+	]}`)
+	if len(first.Results) != 3 || first.Results[0].Status != "applied" || first.Results[0].Todo.Version != 1 {
+		t.Fatalf("expected creates to be applied, got %+v", first.Results)
+	}
+	if r := first.Results[2]; r.Status != "rejected" || len(r.Errors) != 1 || r.Errors[0].Field != "id" {
+		t.Fatalf("expected invalid id to be rejected, got %+v", r)
+	}
+	if len(first.Changes) != 2 {
+		t.Fatalf("expected both creates in the delta, got %+v", first.Changes)
+	}
+
+	// Meanwhile, another client retitles a and completes b online.
+	do(http.MethodPut, "/v1/todos/a", `{"title":"buy oat milk","completed":false}`)
+	do(http.MethodPut, "/v1/todos/b", `{"title":"call mom","completed":true}`)
+
+	// Offline, the first client completed a and retitled b, both at version 1.
+	second := sync(`{"since":"` + first.NextToken + `","mutations":[
+		{"op":"update","id":"a","base_version":1,"title":"buy milk","completed":true},
+		{"op":"update","id":"b","base_version":1,"title":"call dad","completed":false},
+		{"op":"update","id":"a","base_version":1,"title":"buy soy milk","completed":true}
+	]}`)
+	if r := second.Results[0]; r.Status != "merged" || r.Todo.Title != "buy oat milk" || !r.Todo.Completed || r.Todo.Version != 3 {
+		t.Fatalf("expected a to merge both edits, got %+v", r)
+	}
+	if r := second.Results[1]; r.Status != "merged" || r.Todo.Title != "call dad" || !r.Todo.Completed {
+		t.Fatalf("expected b to merge both edits, got %+v", r)
+	}
+	r := second.Results[2]
+	if r.Status != "conflict" || r.Conflict == nil || len(r.Conflict.Fields) != 1 || r.Conflict.Fields[0] != "title" {
+		t.Fatalf("expected a title conflict, got %+v", r)
+	}
+	if r.Conflict.Base.Title != "buy milk" || r.Conflict.Client.Title != "buy soy milk" || r.Conflict.Server.Title != "buy oat milk" {
+		t.Fatalf("expected base, client and server versions, got %+v", r.Conflict)
+	}
+	if len(second.Changes) != 2 {
+		t.Fatalf("expected a and b in the delta, got %+v", second.Changes)
+	}
+
+	rec := do(http.MethodPost, "/v1/sync", `{"since":"garbage","mutations":[]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for a bad token, got %d", http.StatusBadRequest, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodPost, "/v1/sync", rec)
+}
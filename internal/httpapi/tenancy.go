@@ -0,0 +1,236 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// TenantResolver extracts a tenant ID from a request. It reports false when the
+// request carries no tenant information it understands.
+type TenantResolver func(r *http.Request) (string, bool)
+
+// HeaderTenantResolver reads the tenant from the named request header.
+func HeaderTenantResolver(name string) TenantResolver {
+	return func(r *http.Request) (string, bool) {
+		id := strings.TrimSpace(r.Header.Get(name))
+		return id, id != ""
+	}
+}
+
+// SubdomainTenantResolver reads the tenant from the leftmost label of the host
+// when it is a direct subdomain of baseDomain (acme.todo.example.com -> acme).
+func SubdomainTenantResolver(baseDomain string) TenantResolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(r *http.Request) (string, bool) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !strings.HasSuffix(host, suffix) {
+			return "", false
+		}
+		label := strings.TrimSuffix(host, suffix)
+		if label == "" || strings.Contains(label, ".") {
+			return "", false
+		}
+		return label, true
+	}
+}
+
+// ClaimTenantResolver reads the tenant claim of the authenticated bearer token.
+func ClaimTenantResolver() TenantResolver {
+	return func(r *http.Request) (string, bool) {
+		c, ok := auth.FromContext(r.Context())
+		if !ok || c.Tenant == "" {
+			return "", false
+		}
+		return c.Tenant, true
+	}
+}
+
+// tenantMiddleware scopes the request context to a tenant. Resolvers are tried
+// in order; tenancy.DefaultID applies when none matches unless required is set.
+// A token bound to a tenant can never act on another one. With authenticated
+// set, the tenant is always that of the token: anonymous requests and tokens
+// without a tenant claim are rejected, and resolvers can only agree with it.
+func tenantMiddleware(resolvers []TenantResolver, required, authenticated bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, signedIn := auth.FromContext(c.Request.Context())
+		if authenticated {
+			if refuseCredentials(c) {
+				return
+			}
+			if !signedIn {
+				writeProblem(c, problemUnauthorized, "a bearer token is required")
+				return
+			}
+			if claims.Tenant == "" {
+				writeProblem(c, problemTenantMismatch, "the token is not bound to a tenant")
+				return
+			}
+		}
+
+		id := ""
+		for _, resolve := range resolvers {
+			if v, ok := resolve(c.Request); ok {
+				id = v
+				break
+			}
+		}
+		if id == "" && authenticated {
+			id = claims.Tenant
+		}
+		if id == "" && !required {
+			id = tenancy.DefaultID
+		}
+		if id == "" {
+			writeProblem(c, problemTenantRequired, "the request does not identify a tenant")
+			return
+		}
+		if err := domain.ValidateTenantID(id); err != nil {
+			writeProblem(c, problemInvalidTenant, err.Error())
+			return
+		}
+		if signedIn && claims.Tenant != "" && claims.Tenant != id {
+			writeProblem(c, problemTenantMismatch, "the token is not valid for this tenant")
+			return
+		}
+
+		c.Set("tenant", id)
+		ctx := logging.With(c.Request.Context(), slog.String("tenant", id))
+		c.Request = c.Request.WithContext(tenancy.WithID(ctx, id))
+		c.Next()
+	}
+}
+
+// graphqlTenantMiddleware is tenantMiddleware, except that anonymous
+// WebSocket upgrades pass with only the tenant they name, if any, when
+// authentication is on: browsers cannot send a token with them, so GraphQL
+// subscriptions authenticate in their connection_init message instead (see
+// graphapi.Options.TokenVerifier).
+func graphqlTenantMiddleware(resolvers []TenantResolver, required, authenticated bool) gin.HandlerFunc {
+	strict := tenantMiddleware(resolvers, required, authenticated)
+	return func(c *gin.Context) {
+		if refuseCredentials(c) {
+			return
+		}
+		if _, signedIn := auth.FromContext(c.Request.Context()); !authenticated || signedIn || !websocket.IsWebSocketUpgrade(c.Request) {
+			strict(c)
+			return
+		}
+		for _, resolve := range resolvers {
+			id, ok := resolve(c.Request)
+			if !ok {
+				continue
+			}
+			if err := domain.ValidateTenantID(id); err != nil {
+				writeProblem(c, problemInvalidTenant, err.Error())
+				return
+			}
+			c.Request = c.Request.WithContext(tenancy.WithID(c.Request.Context(), id))
+			break
+		}
+		c.Next()
+	}
+}
+
+// requireAuthMiddleware rejects anonymous requests.
+func requireAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if refuseCredentials(c) {
+			return
+		}
+		if _, ok := auth.FromContext(c.Request.Context()); !ok {
+			writeProblem(c, problemUnauthorized, "a bearer token is required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientCertMiddleware attaches the claims of a TLS client certificate to
+// the request context (see auth.ClaimsFromCertificate). Only certificates
+// the server verified against its client CAs count, so without mutual TLS
+// every request passes through unchanged. A bearer token takes precedence.
+func clientCertMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := c.Request.TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			c.Next()
+			return
+		}
+		claims, ok := auth.ClaimsFromCertificate(state.VerifiedChains[0][0])
+		if !ok {
+			writeProblem(c, problemUnauthorized, "the client certificate names no subject")
+			return
+		}
+		c.Set("user", claims.Subject)
+		ctx := logging.With(c.Request.Context(), slog.String("user", claims.Subject))
+		c.Request = c.Request.WithContext(auth.WithClaims(ctx, claims))
+		c.Next()
+	}
+}
+
+// authFailureKey holds in the gin context why authMiddleware refused the
+// credentials of a request.
+const authFailureKey = "auth_failure"
+
+// authMiddleware attaches the claims of a valid bearer token to the request
+// context. Requests without a token pass through anonymously, and so do
+// those with a malformed or invalid one: public routes ignore credentials,
+// and routes needing them answer 401 (see refuseCredentials).
+func authMiddleware(v *auth.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.GetHeader("Authorization")
+		if h == "" {
+			c.Next()
+			return
+		}
+		token, ok := strings.CutPrefix(h, "Bearer ")
+		failure := "the Authorization header must use the Bearer scheme"
+		if !ok && isDAVPath(c.Request.URL.Path) {
+			// Calendar apps can only send Basic credentials, so CalDAV also
+			// accepts the token as the password; the user name is ignored.
+			_, token, ok = c.Request.BasicAuth()
+			failure = "the Authorization header must use the Bearer or Basic scheme"
+		}
+		if !ok {
+			c.Set(authFailureKey, failure)
+			c.Next()
+			return
+		}
+		claims, err := v.Verify(strings.TrimSpace(token))
+		if err != nil {
+			c.Set(authFailureKey, "the bearer token is invalid or expired")
+			c.Next()
+			return
+		}
+		c.Set("user", claims.Subject)
+		ctx := logging.With(c.Request.Context(), slog.String("user", claims.Subject))
+		c.Request = c.Request.WithContext(auth.WithClaims(ctx, claims))
+		c.Next()
+	}
+}
+
+// refuseCredentials answers 401 when authMiddleware refused the credentials
+// of the request, for routes that need authentication, and reports whether
+// it did.
+func refuseCredentials(c *gin.Context) bool {
+	failure := c.GetString(authFailureKey)
+	if failure == "" {
+		return false
+	}
+	writeProblem(c, problemUnauthorized, failure)
+	return true
+}
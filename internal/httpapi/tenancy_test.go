@@ -0,0 +1,230 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/tenants"
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+func TestTenancy_IsolationAndAdmin(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	tenantRepo, err := boltdb.NewTenantRepository(db)
+	if err != nil {
+		t.Fatalf("new tenant repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	tenantSvc, err := tenants.NewService(tenantRepo, repo)
+	if err != nil {
+		t.Fatalf("new tenant service: %v", err)
+	}
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	opts := RouterOptions{
+		TodoService:     svc,
+		TenantResolvers: []TenantResolver{HeaderTenantResolver("X-Tenant-Id"), ClaimTenantResolver()},
+		RequireTenant:   true,
+		TenantService:   tenantSvc,
+		AdminToken:      "admin",
+	}
+	anonymous := NewRouter(opts)
+	opts.TokenVerifier = verifier
+	srv := NewRouter(opts)
+
+	do := func(method, path string, body any, headers map[string]string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			_ = json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+	expect := func(rec *httptest.ResponseRecorder, code int) {
+		t.Helper()
+		if rec.Code != code {
+			t.Fatalf("expected status %d, got %d: %s", code, rec.Code, rec.Body.String())
+		}
+	}
+	bearer := func(tenant string) map[string]string {
+		token, _ := verifier.Sign(auth.Claims{Subject: "bob", Tenant: tenant})
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	// without authentication, the tenant is mandatory and must be provisioned
+	rec := httptest.NewRecorder()
+	anonymous.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos", nil))
+	expect(rec, http.StatusBadRequest)
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	req.Header.Set("X-Tenant-Id", "acme")
+	rec = httptest.NewRecorder()
+	anonymous.ServeHTTP(rec, req)
+	expect(rec, http.StatusNotFound)
+
+	// with it, the tenant comes from the token, which must name one
+	expect(do(http.MethodGet, "/todos", nil, map[string]string{"X-Tenant-Id": "acme"}), http.StatusUnauthorized)
+	expect(do(http.MethodGet, "/todos", nil, bearer("")), http.StatusForbidden)
+	expect(do(http.MethodGet, "/todos", nil, bearer("acme")), http.StatusNotFound)
+
+	// admin endpoints require the admin token
+	expect(do(http.MethodPost, "/admin/tenants", map[string]any{"id": "acme"}, nil), http.StatusUnauthorized)
+	admin := map[string]string{"X-Admin-Token": "admin"}
+	expect(do(http.MethodPost, "/admin/tenants", map[string]any{"id": "acme"}, admin), http.StatusCreated)
+	expect(do(http.MethodPost, "/admin/tenants", map[string]any{"id": "globex"}, admin), http.StatusCreated)
+	expect(do(http.MethodPost, "/admin/tenants", map[string]any{"id": "Bad_ID"}, admin), http.StatusBadRequest)
+
+	acme, globex := bearer("acme"), bearer("globex")
+	expect(do(http.MethodPost, "/todos", map[string]any{"title": "acme todo"}, acme), http.StatusCreated)
+
+	rec = do(http.MethodGet, "/todos", nil, globex)
+	expect(rec, http.StatusOK)
+	if rec.Body.String() != "[]" {
+		t.Fatalf("expected globex to see no todos, got %s", rec.Body.String())
+	}
+
+	// a token bound to globex cannot be pointed at acme
+	globex["X-Tenant-Id"] = "acme"
+	expect(do(http.MethodGet, "/todos", nil, globex), http.StatusForbidden)
+	globex["X-Tenant-Id"] = "globex"
+	expect(do(http.MethodGet, "/todos", nil, globex), http.StatusOK)
+
+	// export then offboard; the default tenant stays
+	rec = do(http.MethodGet, "/admin/tenants/acme/export", nil, admin)
+	expect(rec, http.StatusOK)
+	var exp tenantExportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &exp); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if exp.Tenant.ID != "acme" || len(exp.Todos) != 1 {
+		t.Fatalf("unexpected export: %+v", exp)
+	}
+	expect(do(http.MethodDelete, "/admin/tenants/acme", nil, admin), http.StatusNoContent)
+	expect(do(http.MethodGet, "/todos", nil, acme), http.StatusNotFound)
+	expect(do(http.MethodDelete, "/admin/tenants/"+tenancy.DefaultID, nil, admin), http.StatusConflict)
+}
+
+func TestAuth_CredentialsCheckedWhereRequired(t *testing.T) {
+	t.Parallel()
+
+	srv := fullRouter(t)
+	bearer := fullRouterBearer(t)
+	do := func(method, target, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/v1/feeds/token", bearer)
+	var issued feedTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("issue feed token: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Public routes ignore credentials, even malformed ones.
+	for _, target := range []string{"/healthz", "/openapi.json", issued.ICSURL} {
+		for _, authorization := range []string{"Bearer garbage", "Negotiate abc"} {
+			if rec := do(http.MethodGet, target, authorization); rec.Code != http.StatusOK {
+				t.Fatalf("GET %s with %q: expected status %d, got %d", target, authorization, http.StatusOK, rec.Code)
+			}
+		}
+	}
+
+	// Routes needing authentication refuse them, and Basic credentials,
+	// which only CalDAV accepts.
+	token := strings.TrimPrefix(bearer, "Bearer ")
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:"+token))
+	for _, authorization := range []string{"Bearer garbage", "Negotiate abc", basic} {
+		for _, target := range []string{"/v1/todos", "/v1/webhooks"} {
+			if rec := do(http.MethodGet, target, authorization); rec.Code != http.StatusUnauthorized {
+				t.Fatalf("GET %s with %q: expected status %d, got %d", target, authorization, http.StatusUnauthorized, rec.Code)
+			}
+		}
+	}
+	if rec := do(http.MethodGet, "/v1/todos", bearer); rec.Code != http.StatusOK {
+		t.Fatalf("expected the bearer token to be accepted, got %d", rec.Code)
+	}
+	if rec := do("PROPFIND", "/dav/", basic); rec.Code != http.StatusMultiStatus {
+		t.Fatalf("expected CalDAV to accept Basic credentials, got %d", rec.Code)
+	}
+}
+
+func TestClientCert_MapsToClaims(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	srv := NewRouter(RouterOptions{
+		TodoService:     svc,
+		TenantResolvers: []TenantResolver{HeaderTenantResolver("X-Tenant-Id"), ClaimTenantResolver()},
+	})
+
+	// The TLS stack fills in the verified chain; here it is faked.
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"globex"}}}
+	get := func(state *tls.ConnectionState) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/todos", nil)
+		req.Header.Set("X-Tenant-Id", "acme")
+		req.TLS = state
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	verified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	if code := get(verified); code != http.StatusForbidden {
+		t.Fatalf("expected the certificate tenant to conflict with the header, got %d", code)
+	}
+	// Unverified certificates are ignored, leaving the unknown acme tenant.
+	if code := get(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}); code != http.StatusNotFound {
+		t.Fatalf("expected an unverified certificate to be ignored, got %d", code)
+	}
+}
@@ -2,32 +2,67 @@ package httpapi
 
 import (
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"challenge-backend-arancia/internal/application/feeds"
 	"challenge-backend-arancia/internal/application/todos"
 	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/logging"
 	"challenge-backend-arancia/internal/ports"
 
 	"github.com/gin-gonic/gin"
 )
 
+// todoHandler serves version 1 of the todo API. The DTOs below are the v1 wire
+// format and must not change incompatibly; breaking changes go in a new version
+// (see apiVersions).
 type todoHandler struct {
 	svc *todos.Service
+	// events enables the change streams; heartbeat is their ping interval.
+	events    *events.Bus
+	heartbeat time.Duration
 }
 
 type todoResponse struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Completed    bool   `json:"completed"`
+	Version      uint64 `json:"version"`
+	ParentID     string `json:"parent_id,omitempty"`
+	AutoComplete bool   `json:"auto_complete,omitempty"`
+	// Progress is the percentage of subtasks completed. Only the todo
+	// endpoints set it, for todos with subtasks.
+	Progress *int `json:"progress,omitempty"`
+}
+
+type changesResponse struct {
+	Changes   []changeResponse `json:"changes"`
+	NextToken string           `json:"next_token"`
+	HasMore   bool             `json:"has_more"`
+}
+
+type changeResponse struct {
+	ID      string        `json:"id"`
+	Deleted bool          `json:"deleted"`
+	Todo    *todoResponse `json:"todo,omitempty"`
 }
 
 type createTodoRequest struct {
-	Title string `json:"title" binding:"required"`
+	Title        string `json:"title" binding:"required"`
+	ParentID     string `json:"parent_id"`
+	AutoComplete bool   `json:"auto_complete"`
 }
 
 type updateTodoRequest struct {
-	Title     string `json:"title" binding:"required"`
-	Completed *bool  `json:"completed" binding:"required"`
+	Title        string `json:"title" binding:"required"`
+	Completed    *bool  `json:"completed" binding:"required"`
+	AutoComplete *bool  `json:"auto_complete"`
 }
 
 func (h todoHandler) register(r gin.IRoutes) {
@@ -35,6 +70,16 @@ func (h todoHandler) register(r gin.IRoutes) {
 	r.POST("/todos", h.create)
 	r.PUT("/todos/:id", h.update)
 	r.DELETE("/todos/:id", h.delete)
+	r.GET("/todos/:id/children", h.listChildren)
+	r.POST("/todos/:id/children", h.addChild)
+	r.PUT("/todos/:id/children", h.reorderChildren)
+	r.DELETE("/todos/:id/children/:child_id", h.detachChild)
+	r.GET("/todos/changes", h.changes)
+	r.POST("/sync", h.sync)
+	if h.events != nil {
+		r.GET("/todos/events", h.streamSSE)
+		r.GET("/todos/ws", h.streamWS)
+	}
 }
 
 func (h todoHandler) list(c *gin.Context) {
@@ -43,21 +88,26 @@ func (h todoHandler) list(c *gin.Context) {
 		writeError(c, err)
 		return
 	}
+	subtasks := make(map[string][]domain.Todo)
+	for _, td := range out {
+		if td.ParentID != "" {
+			subtasks[td.ParentID] = append(subtasks[td.ParentID], td)
+		}
+	}
 	resp := make([]todoResponse, 0, len(out))
 	for _, td := range out {
-		resp = append(resp, toResponse(td))
+		resp = append(resp, withProgress(toResponse(td), subtasks[td.ID]))
 	}
 	c.JSON(http.StatusOK, resp)
 }
 
 func (h todoHandler) create(c *gin.Context) {
 	var req createTodoRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
-	td, err := h.svc.Create(c.Request.Context(), req.Title)
+	td, err := h.svc.Add(c.Request.Context(), todos.NewTodo{Title: req.Title, ParentID: req.ParentID, AutoComplete: req.AutoComplete})
 	if err != nil {
 		writeError(c, err)
 		return
@@ -69,17 +119,37 @@ func (h todoHandler) update(c *gin.Context) {
 	id := c.Param("id")
 
 	var req updateTodoRequest
-	if err := c.ShouldBindJSON(&req); err != nil || req.Completed == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	if !bindJSON(c, &req) {
+		return
+	}
+	version, ok := ifMatchVersion(c.GetHeader("If-Match"))
+	if !ok {
+		writeProblem(c, problemValidation, `If-Match must be the version of the todo, such as "3"`)
 		return
 	}
 
-	td, err := h.svc.Update(c.Request.Context(), id, req.Title, *req.Completed)
+	td, err := h.svc.Edit(c.Request.Context(), id, todos.Edit{
+		Title:        req.Title,
+		Completed:    *req.Completed,
+		AutoComplete: req.AutoComplete,
+		Version:      version,
+	})
 	if err != nil {
 		writeError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, toResponse(td))
+	h.respond(c, http.StatusOK, td)
+}
+
+// ifMatchVersion reads the todo version from an If-Match header: "3", W/"3"
+// or a bare 3. An absent header or "*" gives zero, which matches any version.
+func ifMatchVersion(header string) (uint64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	v, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	return v, err == nil && v != 0
 }
 
 func (h todoHandler) delete(c *gin.Context) {
@@ -91,19 +161,97 @@ func (h todoHandler) delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// changes serves the delta sync feed. Without since it starts returning every
+// todo; either way next_token is the since of the following call.
+func (h todoHandler) changes(c *gin.Context) {
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > todos.MaxChangesLimit {
+			writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{{
+				Field:  "limit",
+				Rule:   domain.RuleFormat,
+				Detail: fmt.Sprintf("limit must be an integer between 1 and %d", todos.MaxChangesLimit),
+			}}})
+			return
+		}
+		limit = n
+	}
+
+	page, err := h.svc.Changes(c.Request.Context(), c.Query("since"), limit)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, changesResponse{
+		Changes:   toChangeResponses(page.Changes),
+		NextToken: page.Token,
+		HasMore:   page.More,
+	})
+}
+
 func toResponse(td domain.Todo) todoResponse {
-	return todoResponse{ID: td.ID, Title: td.Title, Completed: td.Completed}
+	return todoResponse{
+		ID:           td.ID,
+		Title:        td.Title,
+		Completed:    td.Completed,
+		Version:      td.Version,
+		ParentID:     td.ParentID,
+		AutoComplete: td.AutoComplete,
+	}
+}
+
+// withProgress sets the progress of resp from its subtasks.
+func withProgress(resp todoResponse, subtasks []domain.Todo) todoResponse {
+	if p, ok := domain.Progress(subtasks); ok {
+		resp.Progress = &p
+	}
+	return resp
+}
+
+func toChangeResponses(changes []domain.Change) []changeResponse {
+	out := make([]changeResponse, 0, len(changes))
+	for _, ch := range changes {
+		item := changeResponse{ID: ch.Todo.ID, Deleted: ch.Deleted}
+		if !ch.Deleted {
+			td := toResponse(ch.Todo)
+			item.Todo = &td
+		}
+		out = append(out, item)
+	}
+	return out
 }
 
+// writeError maps service errors to problem responses.
 func writeError(c *gin.Context, err error) {
+	var (
+		verr  *domain.ValidationError
+		quota *domain.QuotaError
+	)
 	switch {
-	case errors.Is(err, domain.ErrInvalidTitle):
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid title"})
+	case errors.As(err, &verr):
+		writeValidationProblem(c, verr)
+	case errors.As(err, &quota):
+		writeProblem(c, problemQuotaExceeded, quota.Error())
+	// The bare sentinels, returned by Validate and by callers predating
+	// ValidationError and QuotaError.
+	case errors.Is(err, domain.ErrInvalidTitle), errors.Is(err, domain.ErrInvalidParent):
+		writeProblem(c, problemValidation, err.Error())
+	case errors.Is(err, domain.ErrQuotaExceeded):
+		writeProblem(c, problemQuotaExceeded, err.Error())
+	case errors.Is(err, ports.ErrUnknownTenant):
+		writeProblem(c, problemUnknownTenant, "the tenant does not exist or has been deprovisioned")
 	case errors.Is(err, ports.ErrNotFound):
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		writeProblem(c, problemNotFound, "")
 	case errors.Is(err, ports.ErrConflict):
-		c.JSON(http.StatusConflict, gin.H{"error": "conflict"})
+		writeProblem(c, problemConflict, "")
+	case errors.Is(err, feeds.ErrAnonymous):
+		writeProblem(c, problemUnauthorized, "a bearer token is required")
+	case errors.Is(err, ports.ErrResyncRequired):
+		writeProblem(c, problemResyncRequired, "the sync token is older than the retained change history; sync again without since")
 	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).ErrorContext(ctx, "request_failed", slog.String("error", err.Error()))
+		writeProblem(c, problemInternal, "")
 	}
 }
@@ -44,6 +44,7 @@ func TestTodos_CRUD(t *testing.T) {
 	if rec.Code != http.StatusCreated {
 		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
 	}
+	assertMatchesSpec(t, http.MethodPost, "/todos", rec)
 
 	// list -> should have 1
 	req = httptest.NewRequest(http.MethodGet, "/todos", nil)
@@ -52,6 +53,7 @@ func TestTodos_CRUD(t *testing.T) {
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
 	}
+	assertMatchesSpec(t, http.MethodGet, "/todos", rec)
 	var list []map[string]any
 	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
 		t.Fatalf("unmarshal list: %v", err)
@@ -73,6 +75,7 @@ func TestTodos_CRUD(t *testing.T) {
 	if rec.Code != http.StatusNotFound {
 		t.Fatalf("expected status %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
 	}
+	assertMatchesSpec(t, http.MethodPut, "/todos/{id}", rec)
 
 	// update existing -> 200
 	req = httptest.NewRequest(http.MethodPut, "/todos/"+id, bytes.NewReader(updateBody))
@@ -82,6 +85,28 @@ func TestTodos_CRUD(t *testing.T) {
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
 	}
+	assertMatchesSpec(t, http.MethodPut, "/todos/{id}", rec)
+
+	// update with If-Match -> 409 unless it names the current version
+	for _, tc := range []struct {
+		ifMatch string
+		status  int
+	}{
+		{`"1"`, http.StatusConflict},
+		{"soon", http.StatusBadRequest},
+		{`W/"2"`, http.StatusOK},
+		{"*", http.StatusOK},
+	} {
+		req = httptest.NewRequest(http.MethodPut, "/todos/"+id, bytes.NewReader(updateBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", tc.ifMatch)
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("If-Match %s: expected status %d, got %d: %s", tc.ifMatch, tc.status, rec.Code, rec.Body.String())
+		}
+		assertMatchesSpec(t, http.MethodPut, "/todos/{id}", rec)
+	}
 
 	// delete -> 204
 	req = httptest.NewRequest(http.MethodDelete, "/todos/"+id, nil)
@@ -90,4 +115,15 @@ func TestTodos_CRUD(t *testing.T) {
 	if rec.Code != http.StatusNoContent {
 		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
 	}
+	assertMatchesSpec(t, http.MethodDelete, "/todos/{id}", rec)
+
+	// invalid create -> 400 problem
+	req = httptest.NewRequest(http.MethodPost, "/todos", bytes.NewReader([]byte(`{"title":""}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, http.MethodPost, "/todos", rec)
 }
@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tracing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_PropagatesTraceparentDownToBolt(t *testing.T) {
+	t.Parallel()
+
+	// Other tests share the global provider; only spans of the trace started
+	// below are looked at.
+	exp := tracetest.NewInMemoryExporter()
+	tracing.Install(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp)))
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	var logs bytes.Buffer
+	srv := NewRouter(RouterOptions{
+		TodoService: svc,
+		Logger:      slog.New(tracing.LogHandler(slog.NewJSONHandler(&logs, nil))),
+	})
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00f067aa0ba902b7"
+	)
+	req := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"title":"traced"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parent+"-01")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, s := range exp.GetSpans() {
+		if s.SpanContext.TraceID().String() == traceID {
+			spans[s.Name] = s
+		}
+	}
+	chain := []string{"POST /v1/todos", "todos.Service.Create", "bolt.Update todos"}
+	parentID := parent
+	for _, name := range chain {
+		s, ok := spans[name]
+		if !ok {
+			t.Fatalf("expected span %q in trace, got %v", name, spanNames(spans))
+		}
+		if got := s.Parent.SpanID().String(); got != parentID {
+			t.Fatalf("expected span %q to be a child of %s, got %s", name, parentID, got)
+		}
+		parentID = s.SpanContext.SpanID().String()
+	}
+	if kind := spans["POST /v1/todos"].SpanKind; kind != trace.SpanKindServer {
+		t.Fatalf("expected server span, got %v", kind)
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans["bolt.Update todos"].Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["db.collection.name"] != "todos" || attrs["db.operation.name"] != "create" {
+		t.Fatalf("expected bolt attributes, got %v", attrs)
+	}
+	if _, ok := attrs["bolt.tx.duration_ms"]; !ok {
+		t.Fatalf("expected tx duration attribute, got %v", attrs)
+	}
+
+	var entry struct {
+		TraceID string `json:"trace_id"`
+		SpanID  string `json:"span_id"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("decode log: %v: %s", err, logs.String())
+	}
+	if entry.TraceID != traceID || entry.SpanID != spans["POST /v1/todos"].SpanContext.SpanID().String() {
+		t.Fatalf("expected log of span %s/%s, got %+v", traceID, spans["POST /v1/todos"].SpanContext.SpanID(), entry)
+	}
+}
+
+func spanNames(spans map[string]tracetest.SpanStub) []string {
+	var names []string
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,410 @@
+package httpapi
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiPrefix is where the web UI is served.
+const uiPrefix = "/ui"
+
+// uiContentSecurityPolicy only lets pages load the embedded assets: the
+// templates use no inline scripts or styles.
+const uiContentSecurityPolicy = "default-src 'self'; frame-ancestors 'none'; form-action 'self'; base-uri 'none'"
+
+// webFS holds the templates and static assets of the web UI.
+//
+//go:embed web
+var webFS embed.FS
+
+var uiTemplates = template.Must(template.ParseFS(webFS, "web/templates/*.html"))
+
+// uiHandler serves a server-rendered interface to the todos for browsers.
+// Pages are plain links and forms, answered with a redirect after each
+// write; web/static/ui.js upgrades them to partial updates, for which the
+// handlers answer with the #todos fragment instead.
+type uiHandler struct {
+	svc      *todos.Service
+	sessions uiSessions
+}
+
+// registerUI mounts the UI under uiPrefix. The login routes skip the todo
+// middleware, so a tenant can come from the token signed in with.
+func registerUI(r *gin.Engine, svc *todos.Service, verifier *auth.Verifier, key []byte, secure bool, feature gin.HandlerFunc, middleware []gin.HandlerFunc) {
+	h := uiHandler{svc: svc, sessions: newUISessions(key, verifier, secure)}
+	static, _ := fs.Sub(webFS, "web/static")
+
+	ui := r.Group(uiPrefix, feature, uiHeadersMiddleware())
+	ui.StaticFS("/static", http.FS(static))
+
+	withSession := ui.Group("", h.sessions.middleware())
+	if verifier != nil {
+		withSession.GET("/login", h.loginPage)
+		withSession.POST("/login", h.login)
+		withSession.POST("/logout", h.logout)
+	}
+	pages := withSession.Group("")
+	if verifier != nil {
+		pages.Use(uiSignInMiddleware())
+	}
+	pages.Use(middleware...)
+	pages.GET("", h.list)
+	pages.POST("/todos", h.create)
+	pages.POST("/todos/:id", h.update)
+	pages.POST("/todos/:id/toggle", h.toggle)
+	pages.POST("/todos/:id/delete", h.delete)
+}
+
+// uiSignInMiddleware sends anonymous sessions to the login form, as the todo
+// routes need a token once authentication is enabled.
+func uiSignInMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := auth.FromContext(c.Request.Context()); !ok {
+			c.Redirect(http.StatusSeeOther, uiPrefix+"/login")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// uiHeadersMiddleware keeps UI pages out of frames and shared caches.
+func uiHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", uiContentSecurityPolicy)
+		c.Header("X-Frame-Options", "DENY")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Referrer-Policy", "same-origin")
+		if !strings.HasPrefix(c.Request.URL.Path, uiPrefix+"/static/") {
+			c.Header("Cache-Control", "no-store")
+		}
+		c.Next()
+	}
+}
+
+// uiView is the filter of the list, carried in the query string of links
+// and the hidden fields of forms.
+type uiView struct {
+	// Filter is "all", "open" or "done".
+	Filter string
+	Query  string
+	// Edit is the ID of the todo shown as a form.
+	Edit string
+}
+
+func parseUIView(get func(string) string) uiView {
+	v := uiView{Filter: get("filter"), Query: strings.TrimSpace(get("q")), Edit: get("edit")}
+	if v.Filter != "open" && v.Filter != "done" {
+		v.Filter = "all"
+	}
+	return v
+}
+
+// URL is the list with v's filter.
+func (v uiView) URL() string {
+	return v.with(v.Filter, "")
+}
+
+// EditURL is the list with the todo id shown as a form.
+func (v uiView) EditURL(id string) string {
+	return v.with(v.Filter, id)
+}
+
+// Filtered reports whether some todos may be hidden.
+func (v uiView) Filtered() bool {
+	return v.Filter != "all" || v.Query != ""
+}
+
+func (v uiView) with(filter, edit string) string {
+	q := url.Values{}
+	if filter != "all" {
+		q.Set("filter", filter)
+	}
+	if v.Query != "" {
+		q.Set("q", v.Query)
+	}
+	if edit != "" {
+		q.Set("edit", edit)
+	}
+	if len(q) == 0 {
+		return uiPrefix
+	}
+	return uiPrefix + "?" + q.Encode()
+}
+
+func (v uiView) matches(td domain.Todo) bool {
+	switch {
+	case v.Filter == "open" && td.Completed, v.Filter == "done" && !td.Completed:
+		return false
+	}
+	return v.Query == "" || strings.Contains(strings.ToLower(td.Title), strings.ToLower(v.Query))
+}
+
+type uiFilter struct {
+	Label  string
+	URL    string
+	Count  int
+	Active bool
+}
+
+// uiPage is the data of the templates.
+type uiPage struct {
+	Title  string
+	CSRF   string
+	User   string
+	Tenant string
+	// Login is set when signing in is possible.
+	Login bool
+
+	View    uiView
+	Todos   []domain.Todo
+	Filters []uiFilter
+	// NewTitle and EditTitle refill the add and edit forms after an error.
+	NewTitle  string
+	EditTitle string
+	Error     string
+
+	MaxTitleLen int
+}
+
+func (h uiHandler) page(c *gin.Context, view uiView) uiPage {
+	sess := currentUISession(c)
+	p := uiPage{
+		Title:       "Todos",
+		CSRF:        h.sessions.csrfToken(sess),
+		Login:       h.sessions.verifier != nil,
+		View:        view,
+		MaxTitleLen: domain.MaxTitleLen,
+	}
+	if claims, ok := auth.FromContext(c.Request.Context()); ok {
+		p.User = claims.Subject
+	}
+	if id := tenancy.FromContext(c.Request.Context()); id != tenancy.DefaultID {
+		p.Tenant = id
+	}
+	return p
+}
+
+// render writes the whole page, or the #todos fragment to ui.js, with the
+// todos matching the view.
+func (h uiHandler) render(c *gin.Context, status int, p uiPage) {
+	list, err := h.svc.List(c.Request.Context())
+	if err != nil && p.Error == "" {
+		status, p.Error = h.describe(c, err)
+	}
+	counts := map[string]int{}
+	for _, td := range list {
+		byQuery := uiView{Filter: "all", Query: p.View.Query}
+		if !byQuery.matches(td) {
+			continue
+		}
+		counts["all"]++
+		if td.Completed {
+			counts["done"]++
+		} else {
+			counts["open"]++
+		}
+		if p.View.matches(td) {
+			p.Todos = append(p.Todos, td)
+		}
+		if td.ID == p.View.Edit && p.EditTitle == "" {
+			p.EditTitle = td.Title
+		}
+	}
+	for _, f := range []struct{ name, label string }{{"all", "All"}, {"open", "Open"}, {"done", "Done"}} {
+		p.Filters = append(p.Filters, uiFilter{
+			Label:  f.label,
+			URL:    p.View.with(f.name, ""),
+			Count:  counts[f.name],
+			Active: p.View.Filter == f.name,
+		})
+	}
+
+	name := "page"
+	if c.GetHeader("HX-Request") == "true" {
+		name = "todos"
+	}
+	h.execute(c, status, name, p)
+}
+
+func (h uiHandler) execute(c *gin.Context, status int, name string, data any) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := uiTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).ErrorContext(ctx, "ui_render_failed", slog.String("error", err.Error()))
+	}
+}
+
+// done answers a successful write: ui.js gets the updated fragment, a
+// browser is redirected to the list so reloading does not resubmit.
+func (h uiHandler) done(c *gin.Context, view uiView) {
+	if c.GetHeader("HX-Request") == "true" {
+		h.render(c, http.StatusOK, h.page(c, view))
+		return
+	}
+	c.Redirect(http.StatusSeeOther, view.URL())
+}
+
+// fail renders the list with the error of a write.
+func (h uiHandler) fail(c *gin.Context, p uiPage, err error) {
+	status, msg := h.describe(c, err)
+	p.Error = msg
+	h.render(c, status, p)
+}
+
+// describe turns a service error into a status and a message for people.
+func (h uiHandler) describe(c *gin.Context, err error) (int, string) {
+	var (
+		verr  *domain.ValidationError
+		quota *domain.QuotaError
+	)
+	switch {
+	case errors.As(err, &verr):
+		msg := verr.Error()
+		if msg != "" {
+			msg = strings.ToUpper(msg[:1]) + msg[1:] + "."
+		}
+		return http.StatusUnprocessableEntity, msg
+	case errors.As(err, &quota):
+		return http.StatusForbidden, "You have reached your limit of " + strconv.Itoa(quota.Limit) + " " + quota.Resource + "."
+	case errors.Is(err, ports.ErrUnknownTenant):
+		return http.StatusNotFound, "This workspace does not exist or has been deprovisioned."
+	case errors.Is(err, ports.ErrNotFound):
+		return http.StatusNotFound, "That todo was deleted meanwhile."
+	case errors.Is(err, ports.ErrConflict):
+		return http.StatusConflict, "That todo was changed meanwhile; here is its current state."
+	default:
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).ErrorContext(ctx, "request_failed", slog.String("error", err.Error()))
+		return http.StatusInternalServerError, "Something went wrong. Please try again."
+	}
+}
+
+func (h uiHandler) list(c *gin.Context) {
+	h.render(c, http.StatusOK, h.page(c, parseUIView(c.Query)))
+}
+
+func (h uiHandler) create(c *gin.Context) {
+	view := parseUIView(c.PostForm)
+	title := c.PostForm("title")
+	if _, err := h.svc.Create(c.Request.Context(), title); err != nil {
+		p := h.page(c, view)
+		p.NewTitle = title
+		h.fail(c, p, err)
+		return
+	}
+	h.done(c, view)
+}
+
+// update saves the title of the edit form, unless the todo changed since
+// the form was rendered at version.
+func (h uiHandler) update(c *gin.Context) {
+	view := parseUIView(c.PostForm)
+	id, title := c.Param("id"), c.PostForm("title")
+	cur, err := h.current(c)
+	if err == nil {
+		_, _, err = h.svc.Put(c.Request.Context(),
+			domain.Todo{ID: id, Title: title, Completed: cur.Completed},
+			todos.Precondition{Version: cur.Version})
+	}
+	if err != nil {
+		p := h.page(c, view)
+		var verr *domain.ValidationError
+		if errors.As(err, &verr) {
+			p.View.Edit, p.EditTitle = id, title
+		}
+		h.fail(c, p, err)
+		return
+	}
+	h.done(c, view)
+}
+
+func (h uiHandler) toggle(c *gin.Context) {
+	view := parseUIView(c.PostForm)
+	cur, err := h.current(c)
+	if err == nil {
+		_, _, err = h.svc.Put(c.Request.Context(),
+			domain.Todo{ID: cur.ID, Title: cur.Title, Completed: !cur.Completed},
+			todos.Precondition{Version: cur.Version})
+	}
+	if err != nil {
+		h.fail(c, h.page(c, view), err)
+		return
+	}
+	h.done(c, view)
+}
+
+func (h uiHandler) delete(c *gin.Context) {
+	view := parseUIView(c.PostForm)
+	cur, err := h.current(c)
+	if err == nil {
+		err = h.svc.DeleteIf(c.Request.Context(), cur.ID, cur.Version)
+	}
+	if err != nil {
+		h.fail(c, h.page(c, view), err)
+		return
+	}
+	h.done(c, view)
+}
+
+// current returns the todo of the request at the version its form was
+// rendered with, failing with ports.ErrConflict if it changed since.
+func (h uiHandler) current(c *gin.Context) (domain.Todo, error) {
+	td, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	if v := c.PostForm("version"); v != "" && v != strconv.FormatUint(td.Version, 10) {
+		return domain.Todo{}, ports.ErrConflict
+	}
+	return td, nil
+}
+
+func (h uiHandler) loginPage(c *gin.Context) {
+	p := h.page(c, uiView{})
+	p.Title = "Sign in · Todos"
+	h.execute(c, http.StatusOK, "login", p)
+}
+
+// login verifies the pasted token and keeps it in a new session, so a
+// session ID planted before signing in is worthless.
+func (h uiHandler) login(c *gin.Context) {
+	token := strings.TrimSpace(c.PostForm("token"))
+	claims, err := h.sessions.verifier.Verify(token)
+	if err != nil || claims.Tenant == "" {
+		p := h.page(c, uiView{})
+		p.Title = "Sign in · Todos"
+		p.Error = "That token is invalid or expired."
+		if err == nil {
+			p.Error = "That token is not bound to a tenant."
+		}
+		h.execute(c, http.StatusUnauthorized, "login", p)
+		return
+	}
+	sess := newUISession()
+	sess.Token = token
+	h.sessions.save(c, sess)
+	c.Redirect(http.StatusSeeOther, uiPrefix)
+}
+
+func (h uiHandler) logout(c *gin.Context) {
+	h.sessions.save(c, newUISession())
+	c.Redirect(http.StatusSeeOther, uiPrefix)
+}
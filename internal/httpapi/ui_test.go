@@ -0,0 +1,323 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+// uiBrowser keeps the cookies of a browser and does not follow redirects.
+type uiBrowser struct {
+	t    *testing.T
+	base string
+	http *http.Client
+	csrf string
+}
+
+func newUIServer(t *testing.T, opts RouterOptions) (*uiBrowser, *todos.Service) {
+	t.Helper()
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	opts.TodoService = svc
+	opts.UISessionKey = []byte("ui-session-key")
+	srv := httptest.NewServer(NewRouter(opts))
+	t.Cleanup(srv.Close)
+	jar, _ := cookiejar.New(nil)
+	return &uiBrowser{t: t, base: srv.URL, http: &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}, svc
+}
+
+var csrfMeta = regexp.MustCompile(`<meta name="csrf-token" content="([^"]+)">`)
+
+// get loads a page, remembering its CSRF token.
+func (b *uiBrowser) get(path string) (int, string) {
+	b.t.Helper()
+	resp, err := b.http.Get(b.base + path)
+	if err != nil {
+		b.t.Fatalf("GET %s: %v", path, err)
+	}
+	body := readBody(b.t, resp)
+	if m := csrfMeta.FindStringSubmatch(body); m != nil {
+		b.csrf = m[1]
+	}
+	return resp.StatusCode, body
+}
+
+// post submits a form with the CSRF token, as ui.js when partial is set.
+func (b *uiBrowser) post(path string, form url.Values, partial bool) *http.Response {
+	b.t.Helper()
+	if form == nil {
+		form = url.Values{}
+	}
+	if !form.Has("csrf") {
+		form.Set("csrf", b.csrf)
+	}
+	req, _ := http.NewRequest(http.MethodPost, b.base+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if partial {
+		req.Header.Set("HX-Request", "true")
+	}
+	resp, err := b.http.Do(req)
+	if err != nil {
+		b.t.Fatalf("POST %s: %v", path, err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return string(b)
+}
+
+func TestUI_ManagesTodos(t *testing.T) {
+	t.Parallel()
+
+	b, svc := newUIServer(t, RouterOptions{})
+	ctx := context.Background()
+
+	resp, err := b.http.Get(b.base + "/ui")
+	if err != nil {
+		t.Fatalf("GET /ui: %v", err)
+	}
+	if resp.Header.Get("Content-Security-Policy") == "" || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected the UI security headers, got %v", resp.Header)
+	}
+	if body := readBody(t, resp); !strings.Contains(body, "Nothing to do.") {
+		t.Fatalf("expected an empty list, got:\n%s", body)
+	}
+	if code, _ := b.get("/ui"); code != http.StatusOK || b.csrf == "" {
+		t.Fatalf("expected a page with a CSRF token, got %d", code)
+	}
+
+	// Without JavaScript, a write redirects back to the list.
+	resp = b.post("/ui/todos", url.Values{"title": {"<b>buy milk</b>"}, "filter": {"open"}}, false)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/ui?filter=open" {
+		t.Fatalf("expected a redirect to the open todos, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	_, page := b.get("/ui")
+	if !strings.Contains(page, "&lt;b&gt;buy milk&lt;/b&gt;") || strings.Contains(page, "<b>buy") {
+		t.Fatalf("expected the escaped title on the page, got:\n%s", page)
+	}
+	list, _ := svc.List(ctx)
+	if len(list) != 1 {
+		t.Fatalf("expected 1 todo, got %+v", list)
+	}
+	td := list[0]
+	version := strconv.FormatUint(td.Version, 10)
+
+	// ui.js gets the fragment instead.
+	resp = b.post("/ui/todos/"+td.ID+"/toggle", url.Values{"version": {version}}, true)
+	frag := readBody(t, resp)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(frag, `<section id="todos">`) || !strings.Contains(frag, `<li class="done">`) {
+		t.Fatalf("expected the fragment with the todo done, got %d:\n%s", resp.StatusCode, frag)
+	}
+
+	// The form was rendered before the toggle.
+	resp = b.post("/ui/todos/"+td.ID, url.Values{"version": {version}, "title": {"buy oat milk"}}, true)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusConflict || !strings.Contains(body, "changed meanwhile") {
+		t.Fatalf("expected a conflict, got %d:\n%s", resp.StatusCode, body)
+	}
+	resp = b.post("/ui/todos/"+td.ID, url.Values{"title": {"  "}}, true)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, "Title must not be empty.") || !strings.Contains(body, `class="editing"`) {
+		t.Fatalf("expected the edit form with the error, got %d:\n%s", resp.StatusCode, body)
+	}
+	resp = b.post("/ui/todos/"+td.ID, url.Values{"title": {"buy oat milk"}}, true)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusOK || !strings.Contains(body, "buy oat milk") {
+		t.Fatalf("expected the new title, got %d:\n%s", resp.StatusCode, body)
+	}
+
+	if _, err := svc.Create(ctx, "walk the dog"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	_, page = b.get("/ui?filter=done")
+	if !strings.Contains(page, "buy oat milk") || strings.Contains(page, "walk the dog") {
+		t.Fatalf("expected only the done todo, got:\n%s", page)
+	}
+	_, page = b.get("/ui?q=DOG")
+	if strings.Contains(page, "buy oat milk") || !strings.Contains(page, "walk the dog") {
+		t.Fatalf("expected only the matching todo, got:\n%s", page)
+	}
+	if _, page = b.get("/ui?edit=" + td.ID); !strings.Contains(page, `value="buy oat milk" aria-label="Title"`) {
+		t.Fatalf("expected the edit form, got:\n%s", page)
+	}
+
+	resp = b.post("/ui/todos/"+td.ID+"/delete", nil, false)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", resp.StatusCode)
+	}
+	resp = b.post("/ui/todos/"+td.ID+"/toggle", nil, true)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusNotFound || !strings.Contains(body, "deleted meanwhile") {
+		t.Fatalf("expected the todo to be gone, got %d:\n%s", resp.StatusCode, body)
+	}
+	if list, _ := svc.List(ctx); len(list) != 1 {
+		t.Fatalf("expected 1 todo left, got %+v", list)
+	}
+}
+
+func TestUI_RequiresCSRFToken(t *testing.T) {
+	t.Parallel()
+
+	b, svc := newUIServer(t, RouterOptions{})
+	b.get("/ui")
+	for _, token := range []string{"", "forged"} {
+		resp := b.post("/ui/todos", url.Values{"title": {"x"}, "csrf": {token}}, false)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("csrf %q: expected 403, got %d", token, resp.StatusCode)
+		}
+	}
+
+	// Another browser's token is worthless.
+	other, _ := newUIServer(t, RouterOptions{})
+	other.get("/ui")
+	resp := b.post("/ui/todos", url.Values{"title": {"x"}, "csrf": {other.csrf}}, false)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+
+	// A tampered cookie starts a new session.
+	u, _ := url.Parse(b.base + "/ui")
+	cookies := b.http.Jar.Cookies(u)
+	if cookies[0].Value[0] == 'A' {
+		cookies[0].Value = "B" + cookies[0].Value[1:]
+	} else {
+		cookies[0].Value = "A" + cookies[0].Value[1:]
+	}
+	cookies[0].Path = "/ui"
+	b.http.Jar.SetCookies(u, cookies)
+	resp = b.post("/ui/todos", url.Values{"title": {"x"}}, false)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+	if list, _ := svc.List(context.Background()); len(list) != 0 {
+		t.Fatalf("expected no todo, got %+v", list)
+	}
+}
+
+func TestUI_SignsInWithToken(t *testing.T) {
+	t.Parallel()
+
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+	b, svc := newUIServer(t, RouterOptions{TokenVerifier: verifier})
+	if code, _ := b.get("/ui"); code != http.StatusSeeOther {
+		t.Fatalf("expected anonymous sessions sent to the login form, got %d", code)
+	}
+	if code, page := b.get("/ui/login"); code != http.StatusOK || !strings.Contains(page, `name="token"`) {
+		t.Fatalf("expected the login form, got %d", code)
+	}
+	resp := b.post("/ui/login", url.Values{"token": {"forged"}}, false)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "invalid or expired") {
+		t.Fatalf("expected the form with an error, got %d", resp.StatusCode)
+	}
+
+	unbound, _ := verifier.Sign(auth.Claims{Subject: "alice"})
+	resp = b.post("/ui/login", url.Values{"token": {unbound}}, false)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusUnauthorized || !strings.Contains(body, "not bound to a tenant") {
+		t.Fatalf("expected a token without a tenant to be refused, got %d", resp.StatusCode)
+	}
+
+	token, _ := verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenancy.DefaultID})
+	resp = b.post("/ui/login", url.Values{"token": {token}}, false)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", resp.StatusCode)
+	}
+	// The cookie is encrypted, and Secure even though this server has no
+	// TLS, as it may be behind a proxy that does.
+	for _, c := range resp.Cookies() {
+		raw, _ := base64.RawURLEncoding.DecodeString(c.Value)
+		if c.Name == uiSessionCookie && (!c.Secure || bytes.Contains(raw, []byte(token)) || strings.Contains(c.Value, token)) {
+			t.Fatalf("expected a Secure cookie not revealing the token, got %+v", c)
+		}
+	}
+	// Signing in starts a new session.
+	stale := b.csrf
+	if resp := b.post("/ui/todos", url.Values{"title": {"x"}, "csrf": {stale}}, false); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the old CSRF token to be rejected, got %d: %s", resp.StatusCode, readBody(t, resp))
+	}
+	_, page := b.get("/ui")
+	if !strings.Contains(page, "alice") || !strings.Contains(page, "Sign out") || b.csrf == stale {
+		t.Fatalf("expected alice signed in, got:\n%s", page)
+	}
+	if resp := b.post("/ui/todos", url.Values{"title": {"alice's todo"}}, false); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", resp.StatusCode)
+	}
+	list, _ := svc.List(context.Background())
+	if len(list) != 1 || list[0].Owner != "alice" {
+		t.Fatalf("expected a todo owned by alice, got %+v", list)
+	}
+
+	if resp := b.post("/ui/logout", nil, false); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected a redirect, got %d", resp.StatusCode)
+	}
+	if code, _ := b.get("/ui"); code != http.StatusSeeOther {
+		t.Fatalf("expected the session signed out, got %d", code)
+	}
+}
+
+func TestUI_InsecureCookies(t *testing.T) {
+	t.Parallel()
+
+	for _, insecure := range []bool{false, true} {
+		b, _ := newUIServer(t, RouterOptions{UIInsecureCookies: insecure})
+		resp, err := b.http.Get(b.base + "/ui")
+		if err != nil {
+			t.Fatalf("GET /ui: %v", err)
+		}
+		_ = resp.Body.Close()
+		cookies := resp.Cookies()
+		if len(cookies) != 1 || cookies[0].Secure == insecure {
+			t.Fatalf("insecure %v: unexpected session cookie %+v", insecure, cookies)
+		}
+	}
+}
+
+func TestUI_Disabled(t *testing.T) {
+	t.Parallel()
+
+	b, _ := newUIServer(t, RouterOptions{Features: NewFeatures([]string{FeatureUI})})
+	for _, path := range []string{"/ui", "/ui/static/ui.js"} {
+		if code, _ := b.get(path); code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, code)
+		}
+	}
+	if code, _ := b.get("/ui/login"); code != http.StatusNotFound {
+		t.Fatalf("expected no login without a token verifier, got %d", code)
+	}
+}
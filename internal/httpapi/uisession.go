@@ -0,0 +1,168 @@
+package httpapi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// uiSessionCookie holds the encrypted uiSession of a browser.
+	uiSessionCookie = "todo_ui"
+	// csrfHeader carries the CSRF token of requests sent by ui.js; forms
+	// send it in the csrf field.
+	csrfHeader = "X-CSRF-Token"
+)
+
+// uiSession is the state the web UI keeps in a cookie, encrypted so it can
+// neither be read nor forged. ID keys the CSRF token; Token is the bearer
+// token the user signed in with, verified again on every request so it still
+// expires.
+type uiSession struct {
+	ID    string `json:"id"`
+	Token string `json:"token,omitempty"`
+}
+
+// uiSessions seals and opens session cookies and signs CSRF tokens.
+type uiSessions struct {
+	key      []byte
+	aead     cipher.AEAD
+	verifier *auth.Verifier
+	// secure marks the cookie Secure. It is set unless TLS is off all the
+	// way to the browser, as behind a proxy the request shows no TLS.
+	secure bool
+}
+
+// newUISessions derives the cookie encryption key from key, which also
+// signs the CSRF tokens.
+func newUISessions(key []byte, verifier *auth.Verifier, secure bool) uiSessions {
+	s := uiSessions{key: key, verifier: verifier, secure: secure}
+	block, _ := aes.NewCipher(s.mac("cookie encryption", "")) // 32 bytes: AES-256
+	s.aead, _ = cipher.NewGCM(block)
+	return s
+}
+
+func (s uiSessions) mac(purpose, data string) []byte {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(purpose))
+	m.Write([]byte{0})
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// encode seals sess with a random nonce, which prefixes the result.
+func (s uiSessions) encode(sess uiSession) string {
+	b, _ := json.Marshal(sess)
+	nonce := make([]byte, s.aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	return b64url.EncodeToString(s.aead.Seal(nonce, nonce, b, []byte(uiSessionCookie)))
+}
+
+func (s uiSessions) decode(v string) (uiSession, bool) {
+	sealed, err := b64url.DecodeString(v)
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return uiSession{}, false
+	}
+	n := s.aead.NonceSize()
+	b, err := s.aead.Open(nil, sealed[:n], sealed[n:], []byte(uiSessionCookie))
+	if err != nil {
+		return uiSession{}, false
+	}
+	var sess uiSession
+	if err := json.Unmarshal(b, &sess); err != nil || sess.ID == "" {
+		return uiSession{}, false
+	}
+	return sess, true
+}
+
+// csrfToken is the token forms of the session must send back.
+func (s uiSessions) csrfToken(sess uiSession) string {
+	return b64url.EncodeToString(s.mac("csrf", sess.ID))
+}
+
+// newUISession starts an anonymous session.
+func newUISession() uiSession {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return uiSession{ID: b64url.EncodeToString(b)}
+}
+
+var b64url = base64.RawURLEncoding
+
+// save sets the session cookie. It lasts for the browser session and is
+// only sent with same-site navigations, which with the CSRF token keeps
+// other sites from acting on it.
+func (s uiSessions) save(c *gin.Context, sess uiSession) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     uiSessionCookie,
+		Value:    s.encode(sess),
+		Path:     uiPrefix,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Set("ui_session", sess)
+}
+
+// middleware loads the session of the request, starting one if it has
+// none or it is invalid, and attaches the claims of its token to the
+// request context like authMiddleware. A session whose token expired
+// continues anonymously. Unsafe methods must carry the CSRF token.
+func (s uiSessions) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var (
+			sess uiSession
+			ok   bool
+		)
+		if cookie, err := c.Cookie(uiSessionCookie); err == nil {
+			sess, ok = s.decode(cookie)
+		}
+		if ok {
+			c.Set("ui_session", sess)
+		} else {
+			sess = newUISession()
+			s.save(c, sess)
+		}
+
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			token := c.GetHeader(csrfHeader)
+			if token == "" {
+				token = c.PostForm("csrf")
+			}
+			if !hmac.Equal([]byte(token), []byte(s.csrfToken(sess))) {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+
+		if sess.Token != "" && s.verifier != nil {
+			claims, err := s.verifier.Verify(sess.Token)
+			if err != nil {
+				sess = newUISession()
+				s.save(c, sess)
+			} else {
+				c.Set("user", claims.Subject)
+				ctx := logging.With(c.Request.Context(), slog.String("user", claims.Subject))
+				c.Request = c.Request.WithContext(auth.WithClaims(ctx, claims))
+			}
+		}
+		c.Next()
+	}
+}
+
+func currentUISession(c *gin.Context) uiSession {
+	v, _ := c.Get("ui_session")
+	sess, _ := v.(uiSession)
+	return sess
+}
@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// versionedAPI is one generation of the public todo API. A version owns its
+// request/response DTOs and handlers but shares the application services with
+// every other version, so /v1 and a future /v2 can be served side by side.
+type versionedAPI interface {
+	register(r gin.IRoutes)
+}
+
+// apiVersion is a versionedAPI mounted under /<name>.
+type apiVersion struct {
+	name string
+	api  versionedAPI
+}
+
+// apiVersions lists the mounted versions, oldest first. To add /v2, write
+// handlers with their own DTOs (see todoHandler for v1) and append them here.
+func apiVersions(opts RouterOptions) []apiVersion {
+	v1 := handlerSet{newTodoHandlerV1(opts)}
+	if opts.WebhookService != nil {
+		v1 = append(v1, webhookHandler{svc: opts.WebhookService})
+	}
+	if opts.FeedService != nil {
+		v1 = append(v1, feedTokenHandler{svc: opts.FeedService})
+	}
+	return []apiVersion{
+		{name: "v1", api: v1},
+	}
+}
+
+func newTodoHandlerV1(opts RouterOptions) todoHandler {
+	return todoHandler{svc: opts.TodoService, events: opts.Events, heartbeat: opts.StreamHeartbeat}
+}
+
+// handlerSet groups the handlers making up one version.
+type handlerSet []versionedAPI
+
+func (hs handlerSet) register(r gin.IRoutes) {
+	for _, h := range hs {
+		h.register(r)
+	}
+}
+
+// deprecationMiddleware marks responses of routes kept only for backwards
+// compatibility (RFC 9745 Deprecation, RFC 8594 Sunset) and links to the
+// equivalent route under successor. A zero deprecated date is sent as
+// "Deprecation: true", only saying that the routes are deprecated.
+func deprecationMiddleware(successor string, deprecated, sunset time.Time) gin.HandlerFunc {
+	deprecation := "true"
+	if !deprecated.IsZero() {
+		deprecation = fmt.Sprintf("@%d", deprecated.Unix())
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("Deprecation", deprecation)
+		if !sunset.IsZero() {
+			h.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		h.Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, strings.TrimSuffix(c.Request.URL.Path, "/")))
+		c.Next()
+	}
+}
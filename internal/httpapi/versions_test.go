@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestVersions_V1AndDeprecatedAliases(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	deprecated := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+	srv := NewRouter(RouterOptions{TodoService: svc, LegacyDeprecatedAt: deprecated, LegacySunset: sunset})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/todos", strings.NewReader(`{"title":"buy milk"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("expected /v1 not to be deprecated")
+	}
+	assertMatchesSpec(t, http.MethodPost, "/v1/todos", rec)
+
+	// the alias serves the same data, flagged as deprecated
+	req = httptest.NewRequest(http.MethodGet, "/todos", nil)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "buy milk") {
+		t.Fatalf("expected alias to list v1 data, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Deprecation"); got != fmt.Sprintf("@%d", deprecated.Unix()) {
+		t.Fatalf("expected Deprecation header, got %q", got)
+	}
+	if got := rec.Header().Get("Sunset"); got != "Mon, 19 Apr 2027 00:00:00 GMT" {
+		t.Fatalf("unexpected Sunset header %q", got)
+	}
+	if got := rec.Header().Get("Link"); got != `</v1/todos>; rel="successor-version"` {
+		t.Fatalf("unexpected Link header %q", got)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/todos", rec)
+}
@@ -0,0 +1,101 @@
+// Progressive enhancement of the todo UI. Links and forms marked data-swap
+// are sent with fetch and the HX-Request header, to which the server answers
+// with the #todos section alone; it replaces the current one instead of
+// reloading the page. Without JavaScript they work as plain links and forms.
+(function () {
+  "use strict";
+
+  var csrf = document.querySelector('meta[name="csrf-token"]').content;
+  document.documentElement.classList.add("scripted");
+
+  function swap(html) {
+    var tpl = document.createElement("template");
+    tpl.innerHTML = html.trim();
+    var next = tpl.content.querySelector("#todos");
+    var current = document.getElementById("todos");
+    if (!next || !current) {
+      return false;
+    }
+    // Keep typing in the search box while results update.
+    var active = document.activeElement;
+    var keepSearch = active && active.type === "search" && current.contains(active);
+    current.replaceWith(next);
+    var focus = keepSearch ? next.querySelector('input[type="search"]') : next.querySelector("[autofocus]");
+    if (focus) {
+      focus.focus();
+      if (keepSearch) {
+        focus.setSelectionRange(focus.value.length, focus.value.length);
+      }
+    }
+    return true;
+  }
+
+  function send(method, url, body, fallback) {
+    document.body.classList.add("busy");
+    return fetch(url, {
+      method: method,
+      body: body,
+      credentials: "same-origin",
+      headers: { "HX-Request": "true", "X-CSRF-Token": csrf },
+    })
+      .then(function (res) {
+        var html = (res.headers.get("Content-Type") || "").indexOf("text/html") === 0;
+        return html ? res.text().then(function (text) {
+          if (!swap(text)) {
+            fallback();
+          }
+        }) : fallback();
+      })
+      .catch(fallback)
+      .finally(function () {
+        document.body.classList.remove("busy");
+      });
+  }
+
+  function query(form) {
+    return new URLSearchParams(new FormData(form)).toString();
+  }
+
+  document.addEventListener("click", function (e) {
+    var link = e.target.closest("a[data-swap]");
+    if (!link || e.metaKey || e.ctrlKey || e.shiftKey || e.button !== 0) {
+      return;
+    }
+    e.preventDefault();
+    send("GET", link.href, null, function () { window.location = link.href; }).then(function () {
+      history.replaceState(null, "", link.href);
+    });
+  });
+
+  document.addEventListener("submit", function (e) {
+    var form = e.target.closest("form[data-swap]");
+    if (!form) {
+      return;
+    }
+    e.preventDefault();
+    if (form.dataset.confirm && !window.confirm(form.dataset.confirm)) {
+      return;
+    }
+    if (form.method.toLowerCase() === "get") {
+      var url = form.action + "?" + query(form);
+      send("GET", url, null, function () { window.location = url; }).then(function () {
+        history.replaceState(null, "", url);
+      });
+      return;
+    }
+    // A refused POST, such as one with an expired CSRF token, reloads the
+    // page rather than submitting twice.
+    send("POST", form.action, new URLSearchParams(new FormData(form)), function () { window.location.reload(); });
+  });
+
+  // Forms marked data-live submit as their fields change.
+  var timer;
+  document.addEventListener("input", function (e) {
+    var form = e.target.form;
+    if (!form || !form.hasAttribute("data-live")) {
+      return;
+    }
+    clearTimeout(timer);
+    timer = setTimeout(function () { form.requestSubmit(); }, 250);
+  });
+})();
@@ -0,0 +1,176 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"challenge-backend-arancia/internal/application/webhooks"
+	"challenge-backend-arancia/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookHandler struct {
+	svc *webhooks.Service
+}
+
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	// Secret is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	// Secret is optional; a random one is generated when omitted.
+	Secret string `json:"secret"`
+}
+
+type deliveryResponse struct {
+	ID            string                    `json:"id"`
+	WebhookID     string                    `json:"webhook_id"`
+	EventType     string                    `json:"event_type"`
+	Status        string                    `json:"status"`
+	Attempts      []deliveryAttemptResponse `json:"attempts"`
+	NextAttemptAt *time.Time                `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+}
+
+type deliveryAttemptResponse struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// register adds the webhook routes. They always need a signed-in caller, even
+// where todos are open to anonymous ones: webhooks send tenant data out.
+func (h webhookHandler) register(r gin.IRoutes) {
+	authn := requireAuthMiddleware()
+	r.GET("/webhooks", authn, h.list)
+	r.POST("/webhooks", authn, h.create)
+	r.GET("/webhooks/:id", authn, h.get)
+	r.DELETE("/webhooks/:id", authn, h.delete)
+	r.GET("/webhooks/:id/deliveries", authn, h.deliveries)
+	r.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", authn, h.redeliver)
+}
+
+func (h webhookHandler) list(c *gin.Context) {
+	out, err := h.svc.List(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := make([]webhookResponse, 0, len(out))
+	for _, w := range out {
+		resp = append(resp, toWebhookResponse(w))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h webhookHandler) create(c *gin.Context) {
+	var req createWebhookRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	types := make([]domain.EventType, 0, len(req.Events))
+	for _, e := range req.Events {
+		types = append(types, domain.EventType(e))
+	}
+	w, err := h.svc.Create(c.Request.Context(), req.URL, types, req.Secret)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := toWebhookResponse(w)
+	resp.Secret = w.Secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h webhookHandler) get(c *gin.Context) {
+	w, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toWebhookResponse(w))
+}
+
+func (h webhookHandler) delete(c *gin.Context) {
+	if err := h.svc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h webhookHandler) deliveries(c *gin.Context) {
+	status := domain.DeliveryStatus(c.Query("status"))
+	switch status {
+	case "", domain.DeliveryPending, domain.DeliverySucceeded, domain.DeliveryDead:
+	default:
+		writeValidationProblem(c, &domain.ValidationError{Violations: []domain.FieldViolation{{
+			Field:  "status",
+			Rule:   domain.RuleFormat,
+			Detail: "status must be one of pending, succeeded, dead",
+		}}})
+		return
+	}
+
+	out, err := h.svc.Deliveries(c.Request.Context(), c.Param("id"), status)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	resp := make([]deliveryResponse, 0, len(out))
+	for _, d := range out {
+		resp = append(resp, toDeliveryResponse(d))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h webhookHandler) redeliver(c *gin.Context) {
+	d, err := h.svc.Redeliver(c.Request.Context(), c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, toDeliveryResponse(d))
+}
+
+func toWebhookResponse(w domain.Webhook) webhookResponse {
+	events := make([]string, 0, len(w.Events))
+	for _, e := range w.Events {
+		events = append(events, string(e))
+	}
+	return webhookResponse{ID: w.ID, URL: w.URL, Events: events, CreatedAt: w.CreatedAt}
+}
+
+func toDeliveryResponse(d domain.Delivery) deliveryResponse {
+	resp := deliveryResponse{
+		ID:        d.ID,
+		WebhookID: d.WebhookID,
+		EventType: string(d.EventType),
+		Status:    string(d.Status),
+		Attempts:  make([]deliveryAttemptResponse, 0, len(d.Attempts)),
+		CreatedAt: d.CreatedAt,
+	}
+	if d.Status == domain.DeliveryPending {
+		next := d.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+	for _, a := range d.Attempts {
+		resp.Attempts = append(resp.Attempts, deliveryAttemptResponse{
+			At:         a.At,
+			StatusCode: a.StatusCode,
+			Error:      a.Error,
+			DurationMS: a.Duration.Milliseconds(),
+		})
+	}
+	return resp
+}
@@ -0,0 +1,148 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/application/webhooks"
+	"challenge-backend-arancia/internal/storage/boltdb"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newWebhookService(t *testing.T, db *bolt.DB) *webhooks.Service {
+	t.Helper()
+
+	hooks, err := boltdb.NewWebhookRepository(db)
+	if err != nil {
+		t.Fatalf("new webhook repo: %v", err)
+	}
+	deliveries, err := boltdb.NewDeliveryRepository(db)
+	if err != nil {
+		t.Fatalf("new delivery repo: %v", err)
+	}
+	svc, err := webhooks.NewService(hooks, deliveries, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new webhook service: %v", err)
+	}
+	return svc
+}
+
+func TestWebhooks_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	srv := fullRouter(t)
+	bearer := fullRouterBearer(t)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", bearer)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/v1/webhooks", `{"url":"ftp://example.test","events":["todo.created"]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for bad url, got %d", http.StatusBadRequest, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodPost, "/v1/webhooks", rec)
+
+	rec = do(http.MethodPost, "/v1/webhooks", `{"url":"https://example.test/hook","events":["todo.created","todo.completed"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, http.MethodPost, "/v1/webhooks", rec)
+	var created struct {
+		ID     string `json:"id"`
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if created.Secret == "" {
+		t.Fatalf("expected secret in create response")
+	}
+
+	rec = do(http.MethodGet, "/v1/webhooks/"+created.ID, "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("expected webhook without secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+	assertMatchesSpec(t, http.MethodGet, "/v1/webhooks/{id}", rec)
+
+	rec = do(http.MethodGet, "/v1/webhooks", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/v1/webhooks", rec)
+
+	rec = do(http.MethodGet, "/v1/webhooks/"+created.ID+"/deliveries?status=bogus", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for bad status filter, got %d", http.StatusBadRequest, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/v1/webhooks/{id}/deliveries", rec)
+
+	rec = do(http.MethodGet, "/v1/webhooks/"+created.ID+"/deliveries", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodGet, "/v1/webhooks/{id}/deliveries", rec)
+
+	rec = do(http.MethodPost, "/v1/webhooks/"+created.ID+"/deliveries/nope/redeliver", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodPost, "/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver", rec)
+
+	rec = do(http.MethodDelete, "/v1/webhooks/"+created.ID, "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	rec = do(http.MethodDelete, "/v1/webhooks/"+created.ID, "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodDelete, "/v1/webhooks/{id}", rec)
+}
+
+func TestWebhooks_RequireAuthentication(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	// No token verifier: todos are open to anonymous callers, webhooks are not.
+	srv := NewRouter(RouterOptions{TodoService: svc, WebhookService: newWebhookService(t, db)})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/webhooks", strings.NewReader(`{"url":"https://example.test/hook","events":["todo.created"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	assertMatchesSpec(t, http.MethodPost, "/v1/webhooks", rec)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/todos", nil)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected anonymous todos to be served, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+// Package logging carries a request-scoped *slog.Logger through
+// context.Context, so code below the transport layers can log with the
+// request ID, user and route of the call it serves.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+type ctxKey struct{}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger attached to ctx, or slog.Default() if there
+// is none. Log with the *Context methods and ctx so handlers can read the
+// trace of the call (see tracing.LogHandler).
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger adds args to every record.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// Sampler keeps a share of a high-volume stream of log lines, such as one
+// per successful request. A nil Sampler keeps every line.
+type Sampler struct {
+	mu sync.RWMutex
+	// Rate is the share kept, from 0 to 1. Change it with SetRate once the
+	// sampler is in use.
+	Rate float64
+}
+
+// SetRate changes the share of lines kept.
+func (s *Sampler) SetRate(rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Rate = rate
+}
+
+// Keep reports whether the next line should be written.
+func (s *Sampler) Keep() bool {
+	if s == nil {
+		return true
+	}
+	s.mu.RLock()
+	rate := s.Rate
+	s.mu.RUnlock()
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return rand.Float64() < rate
+	}
+}
+
+// Redacted replaces the values of sensitive headers and parameters.
+const Redacted = "[REDACTED]"
+
+// sensitiveHeaders carry credentials, in canonical form.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Admin-Token":       true,
+}
+
+// Headers logs an HTTP header as a group, with the values of credential
+// headers replaced by Redacted.
+type Headers http.Header
+
+func (h Headers) LogValue() slog.Value {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, 0, len(h))
+	for _, name := range names {
+		values := h[name]
+		v := any(values)
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			v = Redacted
+		} else if len(values) == 1 {
+			v = values[0]
+		}
+		attrs = append(attrs, slog.Any(name, v))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFromContext_DefaultsAndScopes(t *testing.T) {
+	t.Parallel()
+
+	if FromContext(context.Background()) != slog.Default() {
+		t.Fatalf("expected the default logger without a scoped one")
+	}
+
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)))
+	ctx = With(ctx, "user", "alice")
+	FromContext(ctx).InfoContext(ctx, "hello")
+	if !strings.Contains(buf.String(), "user=alice") {
+		t.Fatalf("expected scoped attribute, got %s", buf.String())
+	}
+}
+
+func TestSampler_Keep(t *testing.T) {
+	t.Parallel()
+
+	var none *Sampler
+	if !none.Keep() || !(&Sampler{Rate: 1}).Keep() {
+		t.Fatalf("expected nil and rate 1 samplers to keep every line")
+	}
+	s := &Sampler{Rate: 0}
+	if s.Keep() {
+		t.Fatalf("expected rate 0 sampler to drop every line")
+	}
+	s.SetRate(1)
+	if !s.Keep() {
+		t.Fatalf("expected the sampler to keep every line after SetRate(1)")
+	}
+}
+
+func TestHeaders_RedactsCredentials(t *testing.T) {
+	t.Parallel()
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	h.Set("X-API-Key", "secret")
+	h.Add("Accept", "text/html")
+	h.Add("Accept", "application/json")
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("req", slog.Any("headers", Headers(h)))
+	out := buf.String()
+	if strings.Contains(out, "secret") {
+		t.Fatalf("expected credentials to be redacted, got %s", out)
+	}
+	for _, want := range []string{"headers.Authorization=" + Redacted, "headers.X-Api-Key=" + Redacted, "headers.Accept=\"[text/html application/json]\""} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %s", want, out)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltCollector reports db.Stats() and the size of the database file at
+// scrape time.
+type boltCollector struct {
+	db *bolt.DB
+
+	readTx, openReadTx                   *prometheus.Desc
+	freePages, pendingPages              *prometheus.Desc
+	freeAllocBytes, freelistInuseBytes   *prometheus.Desc
+	pageAllocBytes, writes, writeSeconds *prometheus.Desc
+	fileSizeBytes                        *prometheus.Desc
+}
+
+func newBoltCollector(db *bolt.DB) *boltCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "bolt", name), help, nil, nil)
+	}
+	return &boltCollector{
+		db:                 db,
+		readTx:             desc("read_tx_total", "Read transactions started."),
+		openReadTx:         desc("open_read_tx", "Read transactions currently open."),
+		freePages:          desc("free_pages", "Pages on the freelist."),
+		pendingPages:       desc("pending_pages", "Pages freed by transactions still open."),
+		freeAllocBytes:     desc("free_alloc_bytes", "Bytes allocated in free pages."),
+		freelistInuseBytes: desc("freelist_inuse_bytes", "Bytes used by the freelist."),
+		pageAllocBytes:     desc("page_alloc_bytes_total", "Bytes allocated by committed transactions."),
+		writes:             desc("writes_total", "Page writes to disk."),
+		writeSeconds:       desc("write_seconds_total", "Time spent writing pages to disk."),
+		fileSizeBytes:      desc("file_size_bytes", "Size of the database file."),
+	}
+}
+
+func (c *boltCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.readTx, c.openReadTx, c.freePages, c.pendingPages, c.freeAllocBytes,
+		c.freelistInuseBytes, c.pageAllocBytes, c.writes, c.writeSeconds, c.fileSizeBytes,
+	} {
+		ch <- d
+	}
+}
+
+func (c *boltCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.db.Stats()
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter(c.readTx, float64(s.TxN))
+	gauge(c.openReadTx, float64(s.OpenTxN))
+	gauge(c.freePages, float64(s.FreePageN))
+	gauge(c.pendingPages, float64(s.PendingPageN))
+	gauge(c.freeAllocBytes, float64(s.FreeAlloc))
+	gauge(c.freelistInuseBytes, float64(s.FreelistInuse))
+	counter(c.pageAllocBytes, float64(s.TxStats.GetPageAlloc()))
+	counter(c.writes, float64(s.TxStats.GetWrite()))
+	counter(c.writeSeconds, s.TxStats.GetWriteTime().Seconds())
+	if fi, err := os.Stat(c.db.Path()); err == nil {
+		gauge(c.fileSizeBytes, float64(fi.Size()))
+	}
+}
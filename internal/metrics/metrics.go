@@ -0,0 +1,96 @@
+// Package metrics collects Prometheus metrics of the HTTP API, todo writes
+// and the bolt database, along with the Go runtime and process metrics. They
+// are served by Handler, which belongs on a listener that is not publicly
+// reachable.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	bolt "go.etcd.io/bbolt"
+)
+
+const namespace = "todo"
+
+// unmatchedRoute labels requests that matched no route, so that scanners
+// probing random paths cannot blow up the label cardinality.
+const unmatchedRoute = "unmatched"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	todoWrites      *prometheus.CounterVec
+	storageDuration *prometheus.HistogramVec
+}
+
+func New(db *bolt.DB) (*Metrics, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests handled, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time to handle HTTP requests, by method, route and status. Change streams count until they end.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		todoWrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "writes_total",
+			Help:      "Committed todo writes, by event type (todo.created, todo.completed, ...).",
+		}, []string{"type"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Time spent in todo repository operations, by operation and outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"op", "outcome"}),
+	}
+	for _, c := range []prometheus.Collector{
+		m.requests,
+		m.requestDuration,
+		m.todoWrites,
+		m.storageDuration,
+		newBoltCollector(db),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	} {
+		if err := m.registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest records a handled HTTP request. route is the pattern the
+// request matched, empty if none did.
+func (m *Metrics) ObserveRequest(method, route string, status int, d time.Duration) {
+	if route == "" {
+		route = unmatchedRoute
+	}
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, code).Inc()
+	m.requestDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/storage/boltdb"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("new metrics: %v", err)
+	}
+
+	ctx := context.Background()
+	instrumented := m.InstrumentRepository(repo)
+	created := domain.Event{ID: "e1", Type: domain.EventTodoCreated}
+	if err := instrumented.Create(ctx, domain.Todo{ID: "1", Title: "x"}, created); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// A failed write is timed but not counted.
+	if err := instrumented.Create(ctx, domain.Todo{ID: "1", Title: "x"}, created); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	// Writes cascading to subtasks count once per todo written.
+	if err := instrumented.Create(ctx, domain.Todo{ID: "2", Title: "y", ParentID: "1"}, domain.Event{ID: "e2", Type: domain.EventTodoCreated}); err != nil {
+		t.Fatalf("create subtask: %v", err)
+	}
+	if err := instrumented.Delete(ctx, "1", 0, domain.Event{ID: "e3", Type: domain.EventTodoDeleted}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := instrumented.Get(ctx, "missing"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	m.ObserveRequest(http.MethodGet, "/v1/todos", http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "", http.StatusNotFound, time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`todo_writes_total{type="todo.created"} 2` + "\n",
+		`todo_writes_total{type="todo.deleted"} 2` + "\n",
+		`todo_storage_operation_duration_seconds_count{op="create",outcome="conflict"} 1`,
+		`todo_storage_operation_duration_seconds_count{op="get",outcome="not_found"} 1`,
+		`todo_http_requests_total{method="GET",route="/v1/todos",status="200"} 1`,
+		`todo_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`todo_http_request_duration_seconds_bucket{method="GET",route="/v1/todos",status="200",le="0.025"} 1`,
+		"todo_bolt_file_size_bytes ",
+		"todo_bolt_read_tx_total ",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in:\n%s", want, body)
+		}
+	}
+}
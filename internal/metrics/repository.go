@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+// instrumentedRepository times the operations of a TodoRepository and counts
+// the writes it commits, cascaded ones included, by the type of the events
+// recorded with them (see ports.WriteObserver).
+type instrumentedRepository struct {
+	next ports.TodoRepository
+	m    *Metrics
+}
+
+// InstrumentRepository wraps repo so that its operations and writes are
+// measured.
+func (m *Metrics) InstrumentRepository(repo ports.TodoRepository) ports.TodoRepository {
+	return instrumentedRepository{next: repo, m: m}
+}
+
+func (r instrumentedRepository) List(ctx context.Context) ([]domain.Todo, error) {
+	start := time.Now()
+	out, err := r.next.List(ctx)
+	r.observe("list", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) ListAfter(ctx context.Context, afterID string, limit int, match func(domain.Todo) bool) ([]domain.Todo, error) {
+	start := time.Now()
+	out, err := r.next.ListAfter(ctx, afterID, limit, match)
+	r.observe("list_after", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) Count(ctx context.Context, match func(domain.Todo) bool) (int, error) {
+	start := time.Now()
+	out, err := r.next.Count(ctx, match)
+	r.observe("count", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) Get(ctx context.Context, id string) (domain.Todo, error) {
+	start := time.Now()
+	out, err := r.next.Get(ctx, id)
+	r.observe("get", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) GetVersion(ctx context.Context, id string, version uint64) (domain.Todo, error) {
+	start := time.Now()
+	out, err := r.next.GetVersion(ctx, id, version)
+	r.observe("get_version", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) Create(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
+	start := time.Now()
+	err := r.next.Create(r.counted(ctx), todo, events...)
+	r.observe("create", start, err)
+	return err
+}
+
+func (r instrumentedRepository) CreateWithin(ctx context.Context, todo domain.Todo, maxOwned int, events ...domain.Event) error {
+	start := time.Now()
+	err := r.next.CreateWithin(r.counted(ctx), todo, maxOwned, events...)
+	r.observe("create", start, err)
+	return err
+}
+
+func (r instrumentedRepository) Update(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
+	start := time.Now()
+	err := r.next.Update(r.counted(ctx), todo, events...)
+	r.observe("update", start, err)
+	return err
+}
+
+func (r instrumentedRepository) Delete(ctx context.Context, id string, version uint64, events ...domain.Event) error {
+	start := time.Now()
+	err := r.next.Delete(r.counted(ctx), id, version, events...)
+	r.observe("delete", start, err)
+	return err
+}
+
+func (r instrumentedRepository) Children(ctx context.Context, parentID string) ([]domain.Todo, error) {
+	start := time.Now()
+	out, err := r.next.Children(ctx, parentID)
+	r.observe("children", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) ChildrenOf(ctx context.Context, parentIDs []string) (map[string][]domain.Todo, error) {
+	start := time.Now()
+	out, err := r.next.ChildrenOf(ctx, parentIDs)
+	r.observe("children_of", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) ReorderChildren(ctx context.Context, parentID string, ids []string) error {
+	start := time.Now()
+	err := r.next.ReorderChildren(r.counted(ctx), parentID, ids)
+	r.observe("reorder_children", start, err)
+	return err
+}
+
+func (r instrumentedRepository) Changes(ctx context.Context, since uint64, limit int) (domain.ChangeSet, error) {
+	start := time.Now()
+	out, err := r.next.Changes(ctx, since, limit)
+	r.observe("changes", start, err)
+	return out, err
+}
+
+func (r instrumentedRepository) observe(op string, start time.Time, err error) {
+	outcome := "ok"
+	switch {
+	case errors.Is(err, ports.ErrNotFound):
+		outcome = "not_found"
+	case errors.Is(err, ports.ErrConflict):
+		outcome = "conflict"
+	case err != nil:
+		outcome = "error"
+	}
+	r.m.storageDuration.WithLabelValues(op, outcome).Observe(time.Since(start).Seconds())
+}
+
+// counted returns ctx with an observer counting the writes committed with
+// it.
+func (r instrumentedRepository) counted(ctx context.Context) context.Context {
+	return ports.WithWriteObserver(ctx, func(typ domain.EventType) {
+		r.m.todoWrites.WithLabelValues(string(typ)).Inc()
+	})
+}
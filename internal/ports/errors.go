@@ -3,6 +3,10 @@ package ports
 import "errors"
 
 var (
-	ErrNotFound = errors.New("not found")
-	ErrConflict = errors.New("conflict")
+	ErrNotFound      = errors.New("not found")
+	ErrConflict      = errors.New("conflict")
+	ErrUnknownTenant = errors.New("unknown tenant")
+	// ErrResyncRequired is returned for a sync point older than the retained
+	// change history; the client has to start over with a full sync.
+	ErrResyncRequired = errors.New("resync required")
 )
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// EventPublisher delivers domain events to interested parties.
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.Event) error
+}
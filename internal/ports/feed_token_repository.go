@@ -0,0 +1,17 @@
+package ports
+
+import "context"
+
+// FeedTokenRepository stores the tokens that authenticate feed subscriptions,
+// at most one per user of a tenant. Only hashes of the tokens are stored.
+type FeedTokenRepository interface {
+	// Put makes hash the token of subject in the tenant of ctx, revoking the
+	// token it replaces.
+	Put(ctx context.Context, subject string, hash []byte) error
+	// Delete revokes the token of subject in the tenant of ctx, failing with
+	// ErrNotFound if there is none.
+	Delete(ctx context.Context, subject string) error
+	// Lookup returns the tenant and subject hash was issued to, whatever the
+	// tenant of ctx, or ErrNotFound.
+	Lookup(ctx context.Context, hash []byte) (tenant, subject string, err error)
+}
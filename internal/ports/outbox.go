@@ -0,0 +1,40 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// OutboxEntry is an event recorded by a write that has not been relayed yet.
+// Seq orders entries by commit.
+type OutboxEntry struct {
+	Seq   uint64
+	Event domain.Event
+}
+
+// OutboxDeadLetter is an entry a sink gave up on after repeated failures.
+type OutboxDeadLetter struct {
+	Sink   string
+	Entry  OutboxEntry
+	Reason string
+	At     time.Time
+}
+
+// Outbox gives the relay access to events recorded by TodoRepository writes.
+// Every sink the relay publishes to has its own position in the outbox, so a
+// failing sink neither holds back the others nor makes them see an event
+// twice.
+type Outbox interface {
+	// Pending returns up to limit entries sink has not acknowledged, in
+	// commit order.
+	Pending(ctx context.Context, sink string, limit int) ([]OutboxEntry, error)
+	// Ack records that sink has handled every entry up to seq.
+	Ack(ctx context.Context, sink string, seq uint64) error
+	// DeadLetter sets entry aside for sink, giving the reason, and
+	// acknowledges it.
+	DeadLetter(ctx context.Context, sink string, entry OutboxEntry, reason string) error
+	// Prune removes the entries every one of sinks has acknowledged.
+	Prune(ctx context.Context, sinks ...string) error
+}
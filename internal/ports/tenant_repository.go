@@ -0,0 +1,15 @@
+package ports
+
+import (
+	"context"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// TenantRepository provisions and removes the isolated storage backing each tenant.
+type TenantRepository interface {
+	List(ctx context.Context) ([]domain.Tenant, error)
+	Get(ctx context.Context, id string) (domain.Tenant, error)
+	Create(ctx context.Context, tenant domain.Tenant) error
+	Delete(ctx context.Context, id string) error
+}
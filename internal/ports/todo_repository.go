@@ -6,11 +6,80 @@ import (
 	"challenge-backend-arancia/internal/domain"
 )
 
+// WriteObserver is called once per event recorded with a todo write, after
+// the write is committed. Cascaded subtask deletes and parent roll-ups count,
+// as they record events of their own.
+type WriteObserver func(domain.EventType)
+
+type writeObserverKey struct{}
+
+// WithWriteObserver returns a context whose TodoRepository writes report to
+// obs.
+func WithWriteObserver(ctx context.Context, obs WriteObserver) context.Context {
+	return context.WithValue(ctx, writeObserverKey{}, obs)
+}
+
+// WriteObserverFrom returns the observer attached to ctx, nil if none.
+func WriteObserverFrom(ctx context.Context) WriteObserver {
+	obs, _ := ctx.Value(writeObserverKey{}).(WriteObserver)
+	return obs
+}
+
 // TodoRepository defines persistence operations for Todo entities.
+//
+// Writes assign the next Todo.Version and append the given events to the
+// outbox in the same transaction, each with Todo.Version set to the version
+// written. Either both the change and its events are stored or neither is.
+//
+// Update and Delete are conditional when given a non-zero version (for Update,
+// todo.Version): they fail with ErrConflict unless it is the stored version.
+//
+// Writes also keep the subtasks of every todo: Create adds a todo with a
+// Todo.ParentID as the last subtask of its parent, and Update moves a todo
+// whose ParentID changed to the end of its new parent's, both checked with
+// domain.ValidateParent and failing with ErrNotFound for a missing parent.
+// Delete deletes the subtasks of a todo with it. A write changing the
+// subtasks of an AutoComplete todo rolls it up (domain.Todo.RollUp) in the
+// same transaction, recording events derived from the given ones. Every
+// event recorded is reported to the WriteObserver of the context, if any.
 type TodoRepository interface {
 	List(ctx context.Context) ([]domain.Todo, error)
+	// ListAfter returns up to limit todos with an ID greater than afterID, in
+	// ID order, skipping those match rejects; a nil match keeps all. It reads
+	// no further than the page needs.
+	ListAfter(ctx context.Context, afterID string, limit int, match func(domain.Todo) bool) ([]domain.Todo, error)
+	// Count returns how many todos match accepts, or how many there are for
+	// a nil match.
+	Count(ctx context.Context, match func(domain.Todo) bool) (int, error)
 	Get(ctx context.Context, id string) (domain.Todo, error)
-	Create(ctx context.Context, todo domain.Todo) error
-	Update(ctx context.Context, todo domain.Todo) error
-	Delete(ctx context.Context, id string) error
+	// GetVersion returns a todo as it was at version, or ErrNotFound if that
+	// version is no longer retained.
+	GetVersion(ctx context.Context, id string, version uint64) (domain.Todo, error)
+	Create(ctx context.Context, todo domain.Todo, events ...domain.Event) error
+	// CreateWithin is Create, failing with a *domain.QuotaError instead when
+	// todo.Owner already owns maxOwned todos of the tenant, subtasks
+	// included; zero is unlimited. The count is checked in the transaction
+	// of the write, so concurrent creates cannot exceed it.
+	CreateWithin(ctx context.Context, todo domain.Todo, maxOwned int, events ...domain.Event) error
+	Update(ctx context.Context, todo domain.Todo, events ...domain.Event) error
+	Delete(ctx context.Context, id string, version uint64, events ...domain.Event) error
+
+	// Children returns the subtasks of the todo parentID in their order, or
+	// ErrNotFound if it does not exist.
+	Children(ctx context.Context, parentID string) ([]domain.Todo, error)
+	// ChildrenOf returns the subtasks of each of parentIDs in their order,
+	// read in a single transaction. Parents that do not exist are left out.
+	ChildrenOf(ctx context.Context, parentIDs []string) (map[string][]domain.Todo, error)
+	// ReorderChildren sets the order of the subtasks of parentID. ids must
+	// list each of them once; otherwise it fails with ErrConflict, as the
+	// subtasks changed since the caller listed them.
+	ReorderChildren(ctx context.Context, parentID string, ids []string) error
+
+	// Changes returns up to limit todos written after the sync point since,
+	// ordered by their latest write, with tombstones for deleted ones. since 0
+	// returns the first limit live todos in ID order, More telling whether
+	// ListAfter has further ones, and the current sync point. It fails with
+	// ErrResyncRequired when since predates the retained history or was never
+	// issued.
+	Changes(ctx context.Context, since uint64, limit int) (domain.ChangeSet, error)
 }
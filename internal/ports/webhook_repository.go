@@ -0,0 +1,32 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+)
+
+// WebhookRepository stores the webhook subscriptions of the tenant in ctx.
+// Deleting a webhook also deletes its deliveries.
+type WebhookRepository interface {
+	List(ctx context.Context) ([]domain.Webhook, error)
+	Get(ctx context.Context, id string) (domain.Webhook, error)
+	Create(ctx context.Context, webhook domain.Webhook) error
+	Delete(ctx context.Context, id string) error
+}
+
+// DeliveryRepository is the durable webhook delivery queue. Pending deliveries
+// are scheduled by NextAttemptAt and survive restarts.
+type DeliveryRepository interface {
+	// Create stores and schedules deliveries of the tenant in ctx.
+	Create(ctx context.Context, deliveries ...domain.Delivery) error
+	Get(ctx context.Context, webhookID, id string) (domain.Delivery, error)
+	List(ctx context.Context, webhookID string) ([]domain.Delivery, error)
+	// Update persists the delivery and reschedules it: pending deliveries are
+	// queued for NextAttemptAt, others leave the queue.
+	Update(ctx context.Context, delivery domain.Delivery) error
+	// Due returns up to limit pending deliveries of any tenant whose
+	// NextAttemptAt is not after now, oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]domain.Delivery, error)
+}
@@ -0,0 +1,113 @@
+// Package ratelimit implements an in-memory token bucket limiter keyed by an
+// arbitrary client identifier.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped to bound memory usage.
+const sweepInterval = time.Minute
+
+// Decision is the outcome of a single Allow call.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before the next request can succeed. It is
+	// zero when the request was allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter hands out rate tokens per second with bursts of up to burst requests.
+// A zero rate disables it: every request is allowed, with a zero Limit.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New(rate float64, burst int) *Limiter {
+	l := &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit changes the rate and burst of every bucket. Clients keep the
+// tokens they have, up to the new burst.
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(burst)
+}
+
+// Allow consumes a token from the bucket of key if one is available.
+func (l *Limiter) Allow(key string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return Decision{Allowed: true}
+	}
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	d := Decision{Limit: int(l.burst)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.until(1 - b.tokens)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = l.until(l.burst - b.tokens)
+	return d
+}
+
+func (l *Limiter) until(tokens float64) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely; they are equivalent to a
+// fresh bucket. Callers must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_BurstThenRefill(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if d := l.Allow("a"); !d.Allowed {
+			t.Fatalf("request %d: expected allowed, got %+v", i, d)
+		}
+	}
+	d := l.Allow("a")
+	if d.Allowed {
+		t.Fatalf("expected third request to be limited")
+	}
+	if d.RetryAfter != time.Second || d.Remaining != 0 || d.Limit != 2 {
+		t.Fatalf("unexpected decision: %+v", d)
+	}
+
+	if d := l.Allow("b"); !d.Allowed {
+		t.Fatalf("expected other key to have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if d := l.Allow("a"); !d.Allowed {
+		t.Fatalf("expected a token after refilling, got %+v", d)
+	}
+}
+
+func TestLimiter_SetLimit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(0, 0)
+	l := New(0, 1)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if d := l.Allow("a"); !d.Allowed || d.Limit != 0 {
+			t.Fatalf("request %d: expected a disabled limiter to allow, got %+v", i, d)
+		}
+	}
+
+	l.SetLimit(1, 1)
+	if d := l.Allow("a"); !d.Allowed || d.Limit != 1 {
+		t.Fatalf("expected the first request under the new limit to pass, got %+v", d)
+	}
+	if d := l.Allow("a"); d.Allowed {
+		t.Fatalf("expected the second request to be limited")
+	}
+
+	l.SetLimit(1, 3)
+	now = now.Add(3 * time.Second)
+	if d := l.Allow("a"); !d.Allowed || d.Remaining != 2 {
+		t.Fatalf("expected the bucket to refill up to the new burst, got %+v", d)
+	}
+}
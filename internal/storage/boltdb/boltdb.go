@@ -1,6 +1,7 @@
 package boltdb
 
 import (
+	"context"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -9,3 +10,12 @@ import (
 func Open(path string) (*bolt.DB, error) {
 	return bolt.Open(path, 0o600, &bolt.Options{Timeout: 1 * time.Second})
 }
+
+// Ping checks that db is open and can start a read transaction. Unlike
+// listing todos it takes constant time, so it suits health checks.
+func Ping(ctx context.Context, db *bolt.DB) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.View(func(*bolt.Tx) error { return nil })
+}
@@ -0,0 +1,216 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// changesBucket is the change log of a tenant, keyed by the 8-byte big-endian
+// sequence of a write (the bucket sequence). Only the latest write of every
+// todo is kept; changeIndexBucket maps todo IDs to it. changesCompactedKey,
+// stored in the tenant bucket, holds the highest sequence removed by
+// CompactChanges.
+var (
+	changesBucket       = []byte("changes")
+	changeIndexBucket   = []byte("change_index")
+	changesCompactedKey = []byte("changes_compacted_through")
+)
+
+// changeRecord is an entry of the change log. UID and Owner are kept for
+// the tombstones of deleted todos.
+type changeRecord struct {
+	ID      string    `json:"id"`
+	UID     string    `json:"uid,omitempty"`
+	Owner   string    `json:"owner,omitempty"`
+	Deleted bool      `json:"deleted,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// appendChange logs a write of todo in the tenant bucket tb, replacing the
+// entry of its previous write.
+func appendChange(tb *bolt.Bucket, todo domain.Todo, deleted bool) error {
+	id := todo.ID
+	log, idx := tb.Bucket(changesBucket), tb.Bucket(changeIndexBucket)
+	if log == nil || idx == nil {
+		return fmt.Errorf("bucket %q not found", string(changesBucket))
+	}
+	if prev := idx.Get([]byte(id)); prev != nil {
+		if err := log.Delete(prev); err != nil {
+			return err
+		}
+	}
+	seq, err := log.NextSequence()
+	if err != nil {
+		return err
+	}
+	rec := changeRecord{ID: id, Deleted: deleted, At: time.Now().UTC()}
+	if deleted {
+		rec.UID, rec.Owner = todo.UID, todo.Owner
+	}
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := log.Put(seqKey(seq), payload); err != nil {
+		return err
+	}
+	return idx.Put([]byte(id), seqKey(seq))
+}
+
+func (r *TodoRepository) Changes(ctx context.Context, since uint64, limit int) (domain.ChangeSet, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.ChangeSet{}, err
+	}
+
+	var out domain.ChangeSet
+	err := view(ctx, r.db, changesBucket, "changes", func(ctx context.Context, tx *bolt.Tx) error {
+		tb, err := tenantBucket(tx, tenancy.FromContext(ctx))
+		if err != nil {
+			return err
+		}
+		todos := tb.Bucket(todosBucket)
+		log := tb.Bucket(changesBucket)
+		if todos == nil || log == nil {
+			return fmt.Errorf("bucket %q not found", string(changesBucket))
+		}
+		head := log.Sequence()
+
+		if since == 0 {
+			// The first page of the todos in ID order; ListAfter continues
+			// from its last ID.
+			out.Seq = head
+			c := todos.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				if len(out.Changes) == limit {
+					out.More = true
+					break
+				}
+				var td domain.Todo
+				if err := json.Unmarshal(v, &td); err != nil {
+					return err
+				}
+				out.Changes = append(out.Changes, domain.Change{Todo: td})
+			}
+			setKeyCount(ctx, len(out.Changes))
+			return nil
+		}
+		if since > head || since < compactedThrough(tb) {
+			return ports.ErrResyncRequired
+		}
+
+		c := log.Cursor()
+		for k, v := c.Seek(seqKey(since + 1)); k != nil; k, v = c.Next() {
+			if len(out.Changes) == limit {
+				out.More = true
+				break
+			}
+			out.Seq = binary.BigEndian.Uint64(k)
+			var rec changeRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			ch := domain.Change{Todo: domain.Todo{ID: rec.ID, UID: rec.UID, Owner: rec.Owner}, Deleted: rec.Deleted}
+			if !rec.Deleted {
+				raw := todos.Get([]byte(rec.ID))
+				if raw == nil {
+					return fmt.Errorf("change log entry %d: todo %q missing", out.Seq, rec.ID)
+				}
+				if err := json.Unmarshal(raw, &ch.Todo); err != nil {
+					return err
+				}
+			}
+			out.Changes = append(out.Changes, ch)
+		}
+		if !out.More {
+			out.Seq = head
+		}
+		setKeyCount(ctx, len(out.Changes))
+		return nil
+	})
+	if err != nil {
+		return domain.ChangeSet{}, err
+	}
+	return out, nil
+}
+
+// CompactChanges drops change log entries of every tenant written before
+// before. Sync points older than the newest dropped entry then fail with
+// ports.ErrResyncRequired. It returns the number of entries dropped.
+func (r *TodoRepository) CompactChanges(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	dropped := 0
+	err := update(ctx, r.db, changesBucket, "compact", func(ctx context.Context, tx *bolt.Tx) error {
+		var ids []string
+		if err := tx.Bucket(tenantsBucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, string(k))
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			tb := tx.Bucket(tenantBucketName(id))
+			if tb == nil {
+				continue
+			}
+			log, idx := tb.Bucket(changesBucket), tb.Bucket(changeIndexBucket)
+			if log == nil || idx == nil {
+				continue
+			}
+			var (
+				old     [][]byte
+				todoIDs []string
+			)
+			c := log.Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				var rec changeRecord
+				if err := json.Unmarshal(v, &rec); err != nil {
+					return err
+				}
+				if !rec.At.Before(before) {
+					break
+				}
+				old = append(old, bytes.Clone(k))
+				todoIDs = append(todoIDs, rec.ID)
+			}
+			if len(old) == 0 {
+				continue
+			}
+			for i, k := range old {
+				if err := log.Delete(k); err != nil {
+					return err
+				}
+				if err := idx.Delete([]byte(todoIDs[i])); err != nil {
+					return err
+				}
+			}
+			if err := tb.Put(changesCompactedKey, old[len(old)-1]); err != nil {
+				return err
+			}
+			dropped += len(old)
+		}
+		return nil
+	})
+	return dropped, err
+}
+
+func compactedThrough(tb *bolt.Bucket) uint64 {
+	v := tb.Get(changesCompactedKey)
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
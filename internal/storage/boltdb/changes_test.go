@@ -0,0 +1,106 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+func TestTodoRepository_ChangesSinceSyncPoint(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := repo.Create(ctx, domain.Todo{ID: id, Title: id}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	full, err := repo.Changes(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("full sync: %v", err)
+	}
+	if len(full.Changes) != 3 || full.Seq != 3 || full.More {
+		t.Fatalf("expected 3 todos at seq 3, got %+v", full)
+	}
+
+	head, err := repo.Changes(ctx, 0, 2)
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if len(head.Changes) != 2 || head.Changes[1].Todo.ID != "b" || head.Seq != 3 || !head.More {
+		t.Fatalf("expected a and b at seq 3 with more to come, got %+v", head)
+	}
+
+	if err := repo.Update(ctx, domain.Todo{ID: "a", Title: "a1"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := repo.Update(ctx, domain.Todo{ID: "a", Title: "a2"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := repo.Delete(ctx, "b", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	delta, err := repo.Changes(ctx, full.Seq, 10)
+	if err != nil {
+		t.Fatalf("delta: %v", err)
+	}
+	if len(delta.Changes) != 2 || delta.Seq != 6 || delta.More {
+		t.Fatalf("expected a and tombstone b at seq 6, got %+v", delta)
+	}
+	if a := delta.Changes[0]; a.Deleted || a.Todo.ID != "a" || a.Todo.Title != "a2" {
+		t.Fatalf("expected a in its current state, got %+v", a)
+	}
+	if b := delta.Changes[1]; !b.Deleted || b.Todo.ID != "b" {
+		t.Fatalf("expected tombstone for b, got %+v", b)
+	}
+
+	page, err := repo.Changes(ctx, full.Seq, 1)
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	if len(page.Changes) != 1 || !page.More {
+		t.Fatalf("expected a first page with more to come, got %+v", page)
+	}
+	rest, err := repo.Changes(ctx, page.Seq, 1)
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	if len(rest.Changes) != 1 || rest.Changes[0].Todo.ID != "b" || rest.More {
+		t.Fatalf("expected b on the last page, got %+v", rest)
+	}
+
+	if _, err := repo.Changes(ctx, 99, 10); !errors.Is(err, ports.ErrResyncRequired) {
+		t.Fatalf("expected ErrResyncRequired for an unknown sync point, got %v", err)
+	}
+
+	n, err := repo.CompactChanges(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected one entry per todo to be dropped, got %d", n)
+	}
+	if _, err := repo.Changes(ctx, full.Seq, 10); !errors.Is(err, ports.ErrResyncRequired) {
+		t.Fatalf("expected ErrResyncRequired after compaction, got %v", err)
+	}
+	caughtUp, err := repo.Changes(ctx, delta.Seq, 10)
+	if err != nil || len(caughtUp.Changes) != 0 || caughtUp.Seq != delta.Seq {
+		t.Fatalf("expected an up to date client to stay in sync, got %+v, %v", caughtUp, err)
+	}
+}
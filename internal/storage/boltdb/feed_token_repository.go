@@ -0,0 +1,140 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// feedTokensBucket maps token hashes to their owner across all tenants,
+	// so a feed request can be resolved before its tenant is known.
+	feedTokensBucket = []byte("feed_tokens")
+	// feedTokenUsersBucket maps the users of a tenant to their token hash.
+	feedTokenUsersBucket = []byte("feed_token_users")
+)
+
+type feedTokenOwner struct {
+	Tenant  string `json:"tenant"`
+	Subject string `json:"subject"`
+}
+
+// feedTokenUserKey keys users by subject; anonymous users have an empty one,
+// which bolt does not accept as a key.
+func feedTokenUserKey(subject string) []byte {
+	return []byte("user:" + subject)
+}
+
+type FeedTokenRepository struct {
+	db *bolt.DB
+}
+
+func NewFeedTokenRepository(db *bolt.DB) (*FeedTokenRepository, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(feedTokensBucket); err != nil {
+			return err
+		}
+		return ensureTenants(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FeedTokenRepository{db: db}, nil
+}
+
+func (r *FeedTokenRepository) Put(ctx context.Context, subject string, hash []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(hash) == 0 {
+		return errors.New("missing hash")
+	}
+
+	return update(ctx, r.db, feedTokensBucket, "put", func(ctx context.Context, tx *bolt.Tx) error {
+		users, err := tenantChild(ctx, tx, feedTokenUsersBucket)
+		if err != nil {
+			return err
+		}
+		tokens := tx.Bucket(feedTokensBucket)
+		k := feedTokenUserKey(subject)
+		if old := users.Get(k); old != nil {
+			if err := tokens.Delete(old); err != nil {
+				return err
+			}
+		}
+		owner, err := json.Marshal(feedTokenOwner{Tenant: tenancy.FromContext(ctx), Subject: subject})
+		if err != nil {
+			return err
+		}
+		if err := tokens.Put(hash, owner); err != nil {
+			return err
+		}
+		return users.Put(k, hash)
+	})
+}
+
+func (r *FeedTokenRepository) Delete(ctx context.Context, subject string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return update(ctx, r.db, feedTokensBucket, "delete", func(ctx context.Context, tx *bolt.Tx) error {
+		users, err := tenantChild(ctx, tx, feedTokenUsersBucket)
+		if err != nil {
+			return err
+		}
+		k := feedTokenUserKey(subject)
+		old := users.Get(k)
+		if old == nil {
+			return ports.ErrNotFound
+		}
+		if err := tx.Bucket(feedTokensBucket).Delete(old); err != nil {
+			return err
+		}
+		return users.Delete(k)
+	})
+}
+
+// Lookup only accepts hashes still registered with their user, which also
+// rejects tokens of deleted tenants.
+func (r *FeedTokenRepository) Lookup(ctx context.Context, hash []byte) (string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if len(hash) == 0 {
+		return "", "", ports.ErrNotFound
+	}
+
+	var owner feedTokenOwner
+	err := view(ctx, r.db, feedTokensBucket, "lookup", func(ctx context.Context, tx *bolt.Tx) error {
+		v := tx.Bucket(feedTokensBucket).Get(hash)
+		if v == nil {
+			return ports.ErrNotFound
+		}
+		if err := json.Unmarshal(v, &owner); err != nil {
+			return err
+		}
+		tb := tx.Bucket(tenantBucketName(owner.Tenant))
+		if tb == nil {
+			return ports.ErrNotFound
+		}
+		users := tb.Bucket(feedTokenUsersBucket)
+		if users == nil || !bytes.Equal(users.Get(feedTokenUserKey(owner.Subject)), hash) {
+			return ports.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return owner.Tenant, owner.Subject, nil
+}
@@ -0,0 +1,83 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+func TestFeedTokenRepository(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	tokens, err := NewFeedTokenRepository(db)
+	if err != nil {
+		t.Fatalf("new feed token repo: %v", err)
+	}
+	tenants, err := NewTenantRepository(db)
+	if err != nil {
+		t.Fatalf("new tenant repo: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := tenants.Create(ctx, domain.Tenant{ID: "acme"}); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	acme := tenancy.WithID(ctx, "acme")
+
+	if err := tokens.Put(acme, "alice", []byte("h1")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := tokens.Put(ctx, "", []byte("anon")); err != nil {
+		t.Fatalf("put anonymous: %v", err)
+	}
+	tenant, subject, err := tokens.Lookup(ctx, []byte("h1"))
+	if err != nil || tenant != "acme" || subject != "alice" {
+		t.Fatalf("expected acme/alice, got %q/%q, %v", tenant, subject, err)
+	}
+	if tenant, subject, err := tokens.Lookup(ctx, []byte("anon")); err != nil || tenant != tenancy.DefaultID || subject != "" {
+		t.Fatalf("expected anonymous user of the default tenant, got %q/%q, %v", tenant, subject, err)
+	}
+
+	// Issuing a new token revokes the previous one.
+	if err := tokens.Put(acme, "alice", []byte("h2")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if _, _, err := tokens.Lookup(ctx, []byte("h1")); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected replaced token to be revoked, got %v", err)
+	}
+
+	if err := tokens.Delete(acme, "alice"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, _, err := tokens.Lookup(ctx, []byte("h2")); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected deleted token to be revoked, got %v", err)
+	}
+	if err := tokens.Delete(acme, "alice"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	// Tokens die with their tenant, even if it is recreated.
+	if err := tokens.Put(acme, "bob", []byte("h3")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := tenants.Delete(ctx, "acme"); err != nil {
+		t.Fatalf("delete tenant: %v", err)
+	}
+	if err := tenants.Create(ctx, domain.Tenant{ID: "acme"}); err != nil {
+		t.Fatalf("recreate tenant: %v", err)
+	}
+	if _, _, err := tokens.Lookup(ctx, []byte("h3")); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected token of deleted tenant to be revoked, got %v", err)
+	}
+}
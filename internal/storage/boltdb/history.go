@@ -0,0 +1,82 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// historyBucket keeps the last maxHistory versions of every live todo, keyed
+// by historyKey, so sync can merge against the version a client started from.
+var historyBucket = []byte("history")
+
+const maxHistory = 100
+
+// historyKey is "<todo ID>/<8-byte big-endian version>"; todo IDs never
+// contain "/", so the versions of a todo share the prefix "<todo ID>/".
+func historyKey(id string, version uint64) []byte {
+	return append([]byte(id+"/"), seqKey(version)...)
+}
+
+// appendHistory records todo as written; a deletion drops its history.
+func appendHistory(tb *bolt.Bucket, todo domain.Todo, deleted bool) error {
+	b := tb.Bucket(historyBucket)
+	if b == nil {
+		return fmt.Errorf("bucket %q not found", string(historyBucket))
+	}
+	if deleted {
+		prefix := []byte(todo.ID + "/")
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	payload, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(historyKey(todo.ID, todo.Version), payload); err != nil {
+		return err
+	}
+	if todo.Version > maxHistory {
+		return b.Delete(historyKey(todo.ID, todo.Version-maxHistory))
+	}
+	return nil
+}
+
+func (r *TodoRepository) GetVersion(ctx context.Context, id string, version uint64) (domain.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Todo{}, err
+	}
+
+	var out domain.Todo
+	err := view(ctx, r.db, historyBucket, "get_version", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, historyBucket)
+		if err != nil {
+			return err
+		}
+		v := b.Get(historyKey(id, version))
+		if v == nil {
+			return ports.ErrNotFound
+		}
+		return json.Unmarshal(v, &out)
+	})
+	if err != nil {
+		return domain.Todo{}, err
+	}
+	return out, nil
+}
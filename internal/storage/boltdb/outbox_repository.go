@@ -0,0 +1,250 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	outboxBucket = []byte("outbox")
+	// outboxCursorsBucket holds the position of each sink in outboxBucket,
+	// outboxDeadBucket the entries sinks gave up on.
+	outboxCursorsBucket = []byte("outbox_cursors")
+	outboxDeadBucket    = []byte("outbox_dead")
+)
+
+// seqKey encodes a bucket sequence so keys sort in sequence order.
+func seqKey(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
+
+func ensureOutbox(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(outboxBucket)
+	return err
+}
+
+// appendOutbox records events as part of the write in tx, stamped with the
+// tenant and the todo as that write left it.
+func appendOutbox(tx *bolt.Tx, tenant string, todo domain.Todo, events []domain.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	b := tx.Bucket(outboxBucket)
+	if b == nil {
+		return fmt.Errorf("bucket %q not found", string(outboxBucket))
+	}
+	for _, ev := range events {
+		ev.Tenant = tenant
+		ev.Todo = todo
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(seqKey(seq), payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OutboxRepository reads the events TodoRepository records across all tenants.
+type OutboxRepository struct {
+	db *bolt.DB
+}
+
+func NewOutboxRepository(db *bolt.DB) (*OutboxRepository, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := ensureOutbox(tx); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{outboxCursorsBucket, outboxDeadBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxRepository{db: db}, nil
+}
+
+func (r *OutboxRepository) Pending(ctx context.Context, sink string, limit int) ([]ports.OutboxEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []ports.OutboxEntry
+	err := view(ctx, r.db, outboxBucket, "pending", func(ctx context.Context, tx *bolt.Tx) error {
+		b, cursors, _, err := outboxBuckets(tx)
+		if err != nil {
+			return err
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(seqKey(sinkCursor(cursors, sink) + 1)); k != nil && len(out) < limit; k, v = c.Next() {
+			var ev domain.Event
+			if err := json.Unmarshal(v, &ev); err != nil {
+				return err
+			}
+			out = append(out, ports.OutboxEntry{Seq: binary.BigEndian.Uint64(k), Event: ev})
+		}
+		setKeyCount(ctx, len(out))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) Ack(ctx context.Context, sink string, seq uint64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return update(ctx, r.db, outboxBucket, "ack", func(ctx context.Context, tx *bolt.Tx) error {
+		_, cursors, _, err := outboxBuckets(tx)
+		if err != nil {
+			return err
+		}
+		return advanceSink(cursors, sink, seq)
+	})
+}
+
+func (r *OutboxRepository) DeadLetter(ctx context.Context, sink string, entry ports.OutboxEntry, reason string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(ports.OutboxDeadLetter{Sink: sink, Entry: entry, Reason: reason, At: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	return update(ctx, r.db, outboxBucket, "dead_letter", func(ctx context.Context, tx *bolt.Tx) error {
+		_, cursors, dead, err := outboxBuckets(tx)
+		if err != nil {
+			return err
+		}
+		if err := dead.Put(deadLetterKey(sink, entry.Seq), payload); err != nil {
+			return err
+		}
+		return advanceSink(cursors, sink, entry.Seq)
+	})
+}
+
+// DeadLetters returns the entries sink gave up on, oldest first.
+func (r *OutboxRepository) DeadLetters(ctx context.Context, sink string) ([]ports.OutboxDeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []ports.OutboxDeadLetter
+	err := view(ctx, r.db, outboxDeadBucket, "dead_letters", func(ctx context.Context, tx *bolt.Tx) error {
+		_, _, dead, err := outboxBuckets(tx)
+		if err != nil {
+			return err
+		}
+		prefix := []byte(sink + "/")
+		c := dead.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var dl ports.OutboxDeadLetter
+			if err := json.Unmarshal(v, &dl); err != nil {
+				return err
+			}
+			out = append(out, dl)
+		}
+		setKeyCount(ctx, len(out))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *OutboxRepository) Prune(ctx context.Context, sinks ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	return update(ctx, r.db, outboxBucket, "prune", func(ctx context.Context, tx *bolt.Tx) error {
+		b, cursors, _, err := outboxBuckets(tx)
+		if err != nil {
+			return err
+		}
+		upTo := sinkCursor(cursors, sinks[0])
+		for _, sink := range sinks[1:] {
+			upTo = min(upTo, sinkCursor(cursors, sink))
+		}
+		// Deleting while iterating makes the cursor skip keys.
+		var done [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= upTo; k, _ = c.Next() {
+			done = append(done, append([]byte(nil), k...))
+		}
+		for _, k := range done {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		setKeyCount(ctx, len(done))
+		return nil
+	})
+}
+
+func outboxBuckets(tx *bolt.Tx) (entries, cursors, dead *bolt.Bucket, err error) {
+	for _, nb := range []struct {
+		name []byte
+		b    **bolt.Bucket
+	}{{outboxBucket, &entries}, {outboxCursorsBucket, &cursors}, {outboxDeadBucket, &dead}} {
+		if *nb.b = tx.Bucket(nb.name); *nb.b == nil {
+			return nil, nil, nil, fmt.Errorf("bucket %q not found", string(nb.name))
+		}
+	}
+	return entries, cursors, dead, nil
+}
+
+// sinkCursor returns the Seq of the last entry sink acknowledged, zero if none.
+func sinkCursor(cursors *bolt.Bucket, sink string) uint64 {
+	v := cursors.Get([]byte(sink))
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// advanceSink moves the cursor of sink to seq. It never moves backwards, so
+// a late or repeated ack cannot make a sink see entries again.
+func advanceSink(cursors *bolt.Bucket, sink string, seq uint64) error {
+	if seq <= sinkCursor(cursors, sink) {
+		return nil
+	}
+	return cursors.Put([]byte(sink), seqKey(seq))
+}
+
+func deadLetterKey(sink string, seq uint64) []byte {
+	return append([]byte(sink+"/"), seqKey(seq)...)
+}
@@ -0,0 +1,148 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+func TestOutboxRepository_RecordsEventsWithWrites(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	todos, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new todo repo: %v", err)
+	}
+	outbox, err := NewOutboxRepository(db)
+	if err != nil {
+		t.Fatalf("new outbox repo: %v", err)
+	}
+
+	ctx := tenancy.WithID(context.Background(), tenancy.DefaultID)
+	created := domain.Event{ID: "e1", Type: domain.EventTodoCreated}
+	if err := todos.Create(ctx, domain.Todo{ID: "1", Title: "a"}, created); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// A failed write must not leave its event behind.
+	if err := todos.Create(ctx, domain.Todo{ID: "1", Title: "a"}, domain.Event{ID: "dup", Type: domain.EventTodoCreated}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if err := todos.Update(ctx, domain.Todo{ID: "1", Title: "b", Completed: true}, domain.Event{ID: "e2", Type: domain.EventTodoCompleted}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := todos.Delete(ctx, "1", 0, domain.Event{ID: "e3", Type: domain.EventTodoDeleted}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	pending, err := outbox.Pending(ctx, "test", 10)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(pending) != 3 {
+		t.Fatalf("expected 3 events, got %+v", pending)
+	}
+	for i, e := range pending {
+		ev := e.Event
+		if ev.ID != []string{"e1", "e2", "e3"}[i] || ev.Tenant != tenancy.DefaultID || ev.Todo.ID != "1" {
+			t.Fatalf("entry %d: unexpected %+v", i, e)
+		}
+		if ev.Todo.Version != uint64(i+1) {
+			t.Fatalf("entry %d: expected version %d, got %d", i, i+1, ev.Todo.Version)
+		}
+	}
+	if pending[2].Event.Todo.Title != "b" {
+		t.Fatalf("expected delete event to carry the last state, got %+v", pending[2].Event.Todo)
+	}
+
+	if err := outbox.Ack(ctx, "test", pending[1].Seq); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	pending, err = outbox.Pending(ctx, "test", 10)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Event.ID != "e3" {
+		t.Fatalf("expected only e3 left, got %+v", pending)
+	}
+}
+
+func TestOutboxRepository_TracksSinksSeparately(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	todos, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new todo repo: %v", err)
+	}
+	outbox, err := NewOutboxRepository(db)
+	if err != nil {
+		t.Fatalf("new outbox repo: %v", err)
+	}
+
+	ctx := tenancy.WithID(context.Background(), tenancy.DefaultID)
+	for i, id := range []string{"e1", "e2"} {
+		todo := domain.Todo{ID: fmt.Sprint(i), Title: "a"}
+		if err := todos.Create(ctx, todo, domain.Event{ID: id, Type: domain.EventTodoCreated}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	pending, err := outbox.Pending(ctx, "streams", 10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("expected 2 pending, got %+v, %v", pending, err)
+	}
+
+	if err := outbox.Ack(ctx, "streams", pending[1].Seq); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if err := outbox.DeadLetter(ctx, "nats", pending[0], "too large"); err != nil {
+		t.Fatalf("dead letter: %v", err)
+	}
+	// A stale ack does not move a sink back.
+	if err := outbox.Ack(ctx, "streams", pending[0].Seq); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if rest, _ := outbox.Pending(ctx, "streams", 10); len(rest) != 0 {
+		t.Fatalf("expected nothing pending for streams, got %+v", rest)
+	}
+	if rest, _ := outbox.Pending(ctx, "nats", 10); len(rest) != 1 || rest[0].Event.ID != "e2" {
+		t.Fatalf("expected e2 pending for nats, got %+v", rest)
+	}
+
+	if err := outbox.Prune(ctx, "streams", "nats"); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if rest, _ := outbox.Pending(ctx, "nats", 10); len(rest) != 1 {
+		t.Fatalf("expected prune to keep entries nats has not handled, got %+v", rest)
+	}
+	if rest, _ := outbox.Pending(ctx, "new", 10); len(rest) != 1 || rest[0].Event.ID != "e2" {
+		t.Fatalf("expected prune to remove e1, got %+v", rest)
+	}
+
+	dead, err := outbox.DeadLetters(ctx, "nats")
+	if err != nil {
+		t.Fatalf("dead letters: %v", err)
+	}
+	if len(dead) != 1 || dead[0].Entry.Event.ID != "e1" || dead[0].Reason != "too large" || dead[0].At.IsZero() {
+		t.Fatalf("unexpected dead letters %+v", dead)
+	}
+	if dead, _ := outbox.DeadLetters(ctx, "streams"); len(dead) != 0 {
+		t.Fatalf("expected no dead letters for streams, got %+v", dead)
+	}
+}
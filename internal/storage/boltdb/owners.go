@@ -0,0 +1,83 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"challenge-backend-arancia/internal/domain"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ownersBucket counts the todos of each owner, for quotas: ownerKey(owner) =>
+// 8-byte big-endian count. recordWrite keeps it, so every create and delete,
+// cascaded ones included, is counted in the transaction of the write.
+var ownersBucket = []byte("owners")
+
+// ownerKey is the key of owner in ownersBucket. Anonymous todos, with no
+// owner, are all counted under "owner/".
+func ownerKey(owner string) []byte {
+	return []byte("owner/" + owner)
+}
+
+func owned(b *bolt.Bucket, owner string) uint64 {
+	v := b.Get(ownerKey(owner))
+	if v == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+// ownedTotal returns the number of todos of all owners together.
+func ownedTotal(b *bolt.Bucket) uint64 {
+	var n uint64
+	_ = b.ForEach(func(_, v []byte) error {
+		n += binary.BigEndian.Uint64(v)
+		return nil
+	})
+	return n
+}
+
+func countOwned(b *bolt.Bucket, owner string, delta int) error {
+	n := int64(owned(b, owner)) + int64(delta)
+	if n <= 0 {
+		return b.Delete(ownerKey(owner))
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(n))
+	return b.Put(ownerKey(owner), v)
+}
+
+// checkOwned fails with a *domain.QuotaError when owner already owns
+// maxOwned todos; zero is unlimited.
+func checkOwned(b *bolt.Bucket, owner string, maxOwned int) error {
+	if maxOwned <= 0 || owned(b, owner) < uint64(maxOwned) {
+		return nil
+	}
+	resource := "todos"
+	if owner == "" {
+		resource = "anonymous todos"
+	}
+	return &domain.QuotaError{Resource: resource, Limit: maxOwned}
+}
+
+// recountOwners rebuilds the owner counts of the tenant bucket tb from its
+// todos, for tenants that predate them.
+func recountOwners(tb *bolt.Bucket) error {
+	if tb.Bucket(ownersBucket) != nil {
+		if err := tb.DeleteBucket(ownersBucket); err != nil {
+			return err
+		}
+	}
+	counts, err := tb.CreateBucket(ownersBucket)
+	if err != nil {
+		return err
+	}
+	return tb.Bucket(todosBucket).ForEach(func(_, v []byte) error {
+		var td domain.Todo
+		if err := json.Unmarshal(v, &td); err != nil {
+			return err
+		}
+		return countOwned(counts, td.Owner, 1)
+	})
+}
@@ -0,0 +1,358 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// childrenBucket indexes subtasks: the ID of a todo with subtasks => JSON
+// array of their IDs, in their order. Todos without subtasks have no entry.
+var childrenBucket = []byte("children")
+
+func (r *TodoRepository) Children(ctx context.Context, parentID string) ([]domain.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []domain.Todo
+	err := view(ctx, r.db, childrenBucket, "children", func(ctx context.Context, tx *bolt.Tx) error {
+		b, idx, err := subtaskBuckets(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if _, err := getTodo(b, []byte(parentID)); err != nil {
+			return err
+		}
+		out, err = loadChildren(b, idx, parentID)
+		setKeyCount(ctx, len(out))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *TodoRepository) ChildrenOf(ctx context.Context, parentIDs []string) (map[string][]domain.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]domain.Todo, len(parentIDs))
+	err := view(ctx, r.db, childrenBucket, "children_of", func(ctx context.Context, tx *bolt.Tx) error {
+		b, idx, err := subtaskBuckets(ctx, tx)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, id := range parentIDs {
+			if b.Get([]byte(id)) == nil {
+				continue
+			}
+			children, err := loadChildren(b, idx, id)
+			if err != nil {
+				return err
+			}
+			out[id] = children
+			n += len(children)
+		}
+		setKeyCount(ctx, n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *TodoRepository) ReorderChildren(ctx context.Context, parentID string, ids []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return update(ctx, r.db, childrenBucket, "reorder", func(ctx context.Context, tx *bolt.Tx) error {
+		b, idx, err := subtaskBuckets(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if _, err := getTodo(b, []byte(parentID)); err != nil {
+			return err
+		}
+		cur, err := childIDs(idx, parentID)
+		if err != nil {
+			return err
+		}
+		if !samePermutation(cur, ids) {
+			return ports.ErrConflict
+		}
+		return putChildIDs(idx, parentID, ids)
+	})
+}
+
+func subtaskBuckets(ctx context.Context, tx *bolt.Tx) (todos, idx *bolt.Bucket, err error) {
+	if todos, err = tenantChild(ctx, tx, todosBucket); err != nil {
+		return nil, nil, err
+	}
+	if idx, err = tenantChild(ctx, tx, childrenBucket); err != nil {
+		return nil, nil, err
+	}
+	return todos, idx, nil
+}
+
+func childIDs(idx *bolt.Bucket, parentID string) ([]string, error) {
+	v := idx.Get([]byte(parentID))
+	if v == nil {
+		return nil, nil
+	}
+	var ids []string
+	if err := json.Unmarshal(v, &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func putChildIDs(idx *bolt.Bucket, parentID string, ids []string) error {
+	if len(ids) == 0 {
+		return idx.Delete([]byte(parentID))
+	}
+	payload, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	return idx.Put([]byte(parentID), payload)
+}
+
+func loadChildren(b, idx *bolt.Bucket, parentID string) ([]domain.Todo, error) {
+	ids, err := childIDs(idx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.Todo, 0, len(ids))
+	for _, id := range ids {
+		td, err := getTodo(b, []byte(id))
+		if err != nil {
+			return nil, fmt.Errorf("subtask %q of %q: %w", id, parentID, err)
+		}
+		out = append(out, td)
+	}
+	return out, nil
+}
+
+// attach adds todo as the last subtask of its parent, if it has one, after
+// checking the move with domain.ValidateParent.
+func attach(b, idx *bolt.Bucket, todo domain.Todo) error {
+	if todo.ParentID == "" {
+		return nil
+	}
+	ancestry, err := ancestry(b, todo.ParentID)
+	if errors.Is(err, ports.ErrNotFound) {
+		return fmt.Errorf("parent %q: %w", todo.ParentID, ports.ErrNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	height, err := subtreeHeight(idx, todo.ID, 1)
+	if err != nil {
+		return err
+	}
+	if err := domain.ValidateParent(todo.ID, ancestry, height); err != nil {
+		return err
+	}
+	ids, err := childIDs(idx, todo.ParentID)
+	if err != nil {
+		return err
+	}
+	return putChildIDs(idx, todo.ParentID, append(ids, todo.ID))
+}
+
+// detach removes todo from the subtasks of its parent, if it has one.
+func detach(idx *bolt.Bucket, todo domain.Todo) error {
+	if todo.ParentID == "" {
+		return nil
+	}
+	ids, err := childIDs(idx, todo.ParentID)
+	if err != nil {
+		return err
+	}
+	kept := ids[:0]
+	for _, id := range ids {
+		if id != todo.ID {
+			kept = append(kept, id)
+		}
+	}
+	return putChildIDs(idx, todo.ParentID, kept)
+}
+
+// ancestry returns id followed by the IDs of its ancestors, up to a top-level
+// todo.
+func ancestry(b *bolt.Bucket, id string) ([]string, error) {
+	var out []string
+	for id != "" {
+		if len(out) == domain.MaxDepth {
+			return nil, fmt.Errorf("todo %q: nested deeper than %d levels", out[0], domain.MaxDepth)
+		}
+		td, err := getTodo(b, []byte(id))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+		id = td.ParentID
+	}
+	return out, nil
+}
+
+// subtreeHeight returns the number of levels of the subtree rooted at id,
+// which is at the given level of the tree walked.
+func subtreeHeight(idx *bolt.Bucket, id string, level int) (int, error) {
+	if level > domain.MaxDepth {
+		return 0, fmt.Errorf("todo %q: nested deeper than %d levels", id, domain.MaxDepth)
+	}
+	ids, err := childIDs(idx, id)
+	if err != nil {
+		return 0, err
+	}
+	height := 1
+	for _, child := range ids {
+		h, err := subtreeHeight(idx, child, level+1)
+		if err != nil {
+			return 0, err
+		}
+		height = max(height, h+1)
+	}
+	return height, nil
+}
+
+// descendants returns the IDs of the subtasks of id and of theirs, each
+// after its own subtasks.
+func descendants(idx *bolt.Bucket, id string) ([]string, error) {
+	ids, err := childIDs(idx, id)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, child := range ids {
+		below, err := descendants(idx, child)
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(out, below...), child)
+	}
+	return out, nil
+}
+
+// deleteSubtasks deletes the subtasks of id, recursively, as part of the
+// deletion recorded with events.
+func deleteSubtasks(ctx context.Context, tx *bolt.Tx, b, idx *bolt.Bucket, id string, events []domain.Event) error {
+	ids, err := descendants(idx, id)
+	if err != nil {
+		return err
+	}
+	for _, child := range ids {
+		k := []byte(child)
+		td, err := getTodo(b, k)
+		if err != nil {
+			return fmt.Errorf("subtask %q: %w", child, err)
+		}
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+		if err := idx.Delete(k); err != nil {
+			return err
+		}
+		td.Version++
+		if err := recordWrite(ctx, tx, td, true, cascadeEvents(events, domain.EventTodoDeleted, td)); err != nil {
+			return err
+		}
+	}
+	return idx.Delete([]byte(id))
+}
+
+// rollUp applies domain.Todo.RollUp to the todo id after its subtasks
+// changed, and to its ancestors while that changes their subtasks in turn.
+// They are stamped with the time of the write recorded with events.
+func rollUp(ctx context.Context, tx *bolt.Tx, b, idx *bolt.Bucket, id string, events []domain.Event) error {
+	now := writeTime(events)
+	for id != "" {
+		td, err := getTodo(b, []byte(id))
+		if err != nil {
+			return err
+		}
+		subtasks, err := loadChildren(b, idx, id)
+		if err != nil {
+			return err
+		}
+		next, changed := td.RollUp(subtasks)
+		if !changed {
+			return nil
+		}
+		next.Version++
+		next.UpdatedAt = now
+		event := domain.EventTodoUpdated
+		next.CompletedAt = time.Time{}
+		if next.Completed {
+			event = domain.EventTodoCompleted
+			next.CompletedAt = now
+		}
+		payload, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(id), payload); err != nil {
+			return err
+		}
+		if err := recordWrite(ctx, tx, next, false, cascadeEvents(events, event, next)); err != nil {
+			return err
+		}
+		id = next.ParentID
+	}
+	return nil
+}
+
+// writeTime is when the write recorded with events happened, by the clock
+// of the service that made it, which stamps their OccurredAt.
+func writeTime(events []domain.Event) time.Time {
+	for _, ev := range events {
+		if !ev.OccurredAt.IsZero() {
+			return ev.OccurredAt.UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// cascadeEvents returns the events of a write the repository makes to todo
+// as a consequence of one recorded with events: one of type typ for each,
+// with an ID derived from it and the version written.
+func cascadeEvents(events []domain.Event, typ domain.EventType, todo domain.Todo) []domain.Event {
+	out := make([]domain.Event, 0, len(events))
+	for _, ev := range events {
+		id := fmt.Sprintf("%s/%s/%d", ev.ID, todo.ID, todo.Version)
+		out = append(out, domain.Event{ID: id, Type: typ, OccurredAt: ev.OccurredAt})
+	}
+	return out
+}
+
+// samePermutation reports whether a and b hold the same IDs, each once.
+func samePermutation(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return true
+}
@@ -0,0 +1,346 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+func newSubtaskRepo(t *testing.T) *TodoRepository {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	return repo
+}
+
+func childIDsOf(t *testing.T, repo *TodoRepository, parentID string) []string {
+	t.Helper()
+
+	children, err := repo.Children(context.Background(), parentID)
+	if err != nil {
+		t.Fatalf("children of %s: %v", parentID, err)
+	}
+	ids := make([]string, 0, len(children))
+	for _, td := range children {
+		ids = append(ids, td.ID)
+	}
+	return ids
+}
+
+func TestTodoRepository_SubtasksKeepTheirOrder(t *testing.T) {
+	t.Parallel()
+
+	repo := newSubtaskRepo(t)
+	ctx := context.Background()
+	for _, td := range []domain.Todo{
+		{ID: "p", Title: "move house"},
+		{ID: "a", Title: "pack", ParentID: "p"},
+		{ID: "b", Title: "book van", ParentID: "p"},
+		{ID: "c", Title: "clean", ParentID: "p"},
+	} {
+		if err := repo.Create(ctx, td); err != nil {
+			t.Fatalf("create %s: %v", td.ID, err)
+		}
+	}
+	if err := repo.Create(ctx, domain.Todo{ID: "x", Title: "orphan", ParentID: "missing"}); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing parent, got %v", err)
+	}
+	if got := childIDsOf(t, repo, "p"); len(got) != 3 || got[0] != "a" || got[2] != "c" {
+		t.Fatalf("expected [a b c], got %v", got)
+	}
+
+	if err := repo.ReorderChildren(ctx, "p", []string{"c", "a", "b"}); err != nil {
+		t.Fatalf("reorder: %v", err)
+	}
+	if got := childIDsOf(t, repo, "p"); got[0] != "c" || got[1] != "a" || got[2] != "b" {
+		t.Fatalf("expected [c a b], got %v", got)
+	}
+	for _, ids := range [][]string{{"c", "a"}, {"c", "a", "a"}, {"c", "a", "x"}} {
+		if err := repo.ReorderChildren(ctx, "p", ids); !errors.Is(err, ports.ErrConflict) {
+			t.Fatalf("reorder %v: expected ErrConflict, got %v", ids, err)
+		}
+	}
+
+	// Moving a subtask to the top level takes it out of the order.
+	a, _ := repo.Get(ctx, "a")
+	a.ParentID = ""
+	if err := repo.Update(ctx, a); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if got := childIDsOf(t, repo, "p"); len(got) != 2 || got[0] != "c" || got[1] != "b" {
+		t.Fatalf("expected [c b], got %v", got)
+	}
+}
+
+func TestTodoRepository_ChildrenOfReadsManyParents(t *testing.T) {
+	t.Parallel()
+
+	repo := newSubtaskRepo(t)
+	ctx := context.Background()
+	for _, td := range []domain.Todo{
+		{ID: "p", Title: "move house"},
+		{ID: "q", Title: "paint"},
+		{ID: "a", Title: "pack", ParentID: "p"},
+		{ID: "b", Title: "book van", ParentID: "p"},
+	} {
+		if err := repo.Create(ctx, td); err != nil {
+			t.Fatalf("create %s: %v", td.ID, err)
+		}
+	}
+
+	got, err := repo.ChildrenOf(ctx, []string{"p", "q", "missing"})
+	if err != nil {
+		t.Fatalf("children of: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected entries for p and q only, got %v", got)
+	}
+	if p := got["p"]; len(p) != 2 || p[0].ID != "a" || p[1].ID != "b" {
+		t.Fatalf("expected p to have subtasks a, b, got %v", got["p"])
+	}
+	if len(got["q"]) != 0 {
+		t.Fatalf("expected q to have no subtasks, got %v", got["q"])
+	}
+}
+
+func TestTodoRepository_RejectsCyclesAndDeepTrees(t *testing.T) {
+	t.Parallel()
+
+	repo := newSubtaskRepo(t)
+	ctx := context.Background()
+	parent := ""
+	for _, id := range []string{"1", "2", "3", "4"} {
+		if err := repo.Create(ctx, domain.Todo{ID: id, Title: "level " + id, ParentID: parent}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+		parent = id
+	}
+	if err := repo.Create(ctx, domain.Todo{ID: "5", Title: "level 5", ParentID: "4"}); !errors.Is(err, domain.ErrInvalidParent) {
+		t.Fatalf("expected ErrInvalidParent, got %v", err)
+	}
+
+	root, _ := repo.Get(ctx, "1")
+	root.ParentID = "3"
+	if err := repo.Update(ctx, root); !errors.Is(err, domain.ErrInvalidParent) {
+		t.Fatalf("expected ErrInvalidParent for a cycle, got %v", err)
+	}
+
+	// A subtree of two levels fits under a todo at the second level only.
+	if err := repo.Create(ctx, domain.Todo{ID: "x", Title: "x"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	three, _ := repo.Get(ctx, "3")
+	three.ParentID = "x"
+	if err := repo.Update(ctx, three); err != nil {
+		t.Fatalf("move under x: %v", err)
+	}
+	three, _ = repo.Get(ctx, "3")
+	three.ParentID = "2"
+	if err := repo.Update(ctx, three); err != nil {
+		t.Fatalf("move back: %v", err)
+	}
+	x, _ := repo.Get(ctx, "x")
+	x.ParentID = "3"
+	if err := repo.Update(ctx, x); err != nil {
+		t.Fatalf("move x under 3: %v", err)
+	}
+	three, _ = repo.Get(ctx, "3")
+	three.ParentID = "x"
+	if err := repo.Update(ctx, three); !errors.Is(err, domain.ErrInvalidParent) {
+		t.Fatalf("expected ErrInvalidParent for a move under its own subtask, got %v", err)
+	}
+}
+
+func TestTodoRepository_AutoCompletesParents(t *testing.T) {
+	t.Parallel()
+
+	repo := newSubtaskRepo(t)
+	ctx := context.Background()
+	for _, td := range []domain.Todo{
+		{ID: "g", Title: "release", AutoComplete: true},
+		{ID: "p", Title: "docs", ParentID: "g", AutoComplete: true},
+		{ID: "a", Title: "guide", ParentID: "p"},
+		{ID: "b", Title: "changelog", ParentID: "p"},
+		{ID: "manual", Title: "announce"},
+		{ID: "m", Title: "draft", ParentID: "manual"},
+	} {
+		if err := repo.Create(ctx, td); err != nil {
+			t.Fatalf("create %s: %v", td.ID, err)
+		}
+	}
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	complete := func(id string, done bool) {
+		t.Helper()
+		td, _ := repo.Get(ctx, id)
+		td.Completed = done
+		if err := repo.Update(ctx, td, domain.Event{ID: "e-" + id, Type: domain.EventTodoUpdated, OccurredAt: at}); err != nil {
+			t.Fatalf("update %s: %v", id, err)
+		}
+	}
+	completed := func(id string) bool {
+		t.Helper()
+		td, err := repo.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		return td.Completed
+	}
+
+	complete("a", true)
+	if completed("p") {
+		t.Fatalf("expected p open while b is")
+	}
+	complete("b", true)
+	if !completed("p") || !completed("g") {
+		t.Fatalf("expected p and g completed with all their subtasks")
+	}
+	// Rolled-up parents are stamped by the clock of the write's events.
+	if p, _ := repo.Get(ctx, "p"); !p.CompletedAt.Equal(at) || !p.UpdatedAt.Equal(at) || p.Version != 2 {
+		t.Fatalf("expected p completed at %v, got %+v", at, p)
+	}
+	complete("m", true)
+	if completed("manual") {
+		t.Fatalf("expected todos without AutoComplete to stay open")
+	}
+
+	// Reopening or adding an open subtask reopens the parents, and deleting
+	// the last open one completes them again.
+	complete("a", false)
+	if completed("p") || completed("g") {
+		t.Fatalf("expected p and g reopened")
+	}
+	complete("a", true)
+	if err := repo.Create(ctx, domain.Todo{ID: "c", Title: "faq", ParentID: "p"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if completed("p") {
+		t.Fatalf("expected p reopened by an open subtask")
+	}
+	if err := repo.Delete(ctx, "c", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if !completed("p") || !completed("g") {
+		t.Fatalf("expected p and g completed again")
+	}
+}
+
+func TestTodoRepository_DeleteCascadesToSubtasks(t *testing.T) {
+	t.Parallel()
+
+	repo := newSubtaskRepo(t)
+	ctx := context.Background()
+	for _, td := range []domain.Todo{
+		{ID: "p", Title: "party"},
+		{ID: "a", Title: "invite", ParentID: "p"},
+		{ID: "a1", Title: "list guests", ParentID: "a"},
+		{ID: "b", Title: "cake", ParentID: "p"},
+		{ID: "other", Title: "unrelated"},
+	} {
+		if err := repo.Create(ctx, td); err != nil {
+			t.Fatalf("create %s: %v", td.ID, err)
+		}
+	}
+	since, err := repo.Changes(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+
+	if err := repo.Delete(ctx, "a", 0, domain.Event{ID: "del", Type: domain.EventTodoDeleted}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got := childIDsOf(t, repo, "p"); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("expected [b], got %v", got)
+	}
+	if err := repo.Delete(ctx, "p", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	list, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != "other" {
+		t.Fatalf("expected only the unrelated todo left, got %+v", list)
+	}
+
+	changes, err := repo.Changes(ctx, since.Seq, 10)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	var tombstones []string
+	for _, ch := range changes.Changes {
+		if ch.Deleted {
+			tombstones = append(tombstones, ch.Todo.ID)
+		}
+	}
+	if len(tombstones) != 4 || tombstones[0] != "a1" || tombstones[1] != "a" {
+		t.Fatalf("expected tombstones for a1, a, b and p, got %v", tombstones)
+	}
+}
+
+func TestTodoRepository_CreateWithinCountsOwnedTodos(t *testing.T) {
+	t.Parallel()
+
+	repo := newSubtaskRepo(t)
+	ctx := context.Background()
+	create := func(id, owner, parent string) error {
+		return repo.CreateWithin(ctx, domain.Todo{ID: id, Title: id, Owner: owner, ParentID: parent}, 3)
+	}
+	for _, id := range []string{"p", "a", "b"} {
+		parent := "p"
+		if id == "p" {
+			parent = ""
+		}
+		if err := create(id, "alice", parent); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := create("c", "alice", ""); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := create("x", "", ""); err != nil {
+		t.Fatalf("expected a separate count for anonymous todos, got %v", err)
+	}
+
+	// Deleting the parent frees the slots of its subtasks too.
+	if err := repo.Delete(ctx, "p", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = create(fmt.Sprintf("n%d", i), "alice", "")
+		}(i)
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, domain.ErrQuotaExceeded):
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if created != 3 {
+		t.Fatalf("expected concurrent creates to stop at the quota, got %d created", created)
+	}
+}
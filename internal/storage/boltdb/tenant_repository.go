@@ -0,0 +1,256 @@
+package boltdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Layout:
+//
+//	tenants                  -> tenant ID => JSON tenant metadata
+//	tenant/<id>              -> one top-level bucket per tenant
+//	tenant/<id>/todos        -> todo ID => JSON todo
+//	tenant/<id>/history      -> "<todo ID>/<version>" => JSON todo (see history.go)
+//	tenant/<id>/changes      -> write sequence => JSON change record (see changes.go)
+//	tenant/<id>/change_index -> todo ID => sequence of its entry in changes
+//	tenant/<id>/children     -> todo ID => JSON IDs of its subtasks (see subtasks.go)
+//	tenant/<id>/owners       -> "owner/<owner>" => number of todos owned (see owners.go)
+//	tenant/<id>/webhooks     -> webhook ID => JSON webhook
+//	tenant/<id>/deliveries   -> "<webhook ID>/<delivery ID>" => JSON delivery
+//	webhook_queue            -> schedule key (see queueKey) => nothing
+//	outbox                   -> 8-byte big-endian sequence => JSON domain event
+//	outbox_cursors           -> sink name => sequence of the last entry it handled
+//	outbox_dead              -> "<sink>/" + sequence => JSON dead letter
+var (
+	tenantsBucket      = []byte("tenants")
+	tenantBucketPrefix = "tenant/"
+)
+
+func tenantBucketName(id string) []byte {
+	return []byte(tenantBucketPrefix + id)
+}
+
+// tenantBucket returns the top-level bucket of a provisioned tenant.
+func tenantBucket(tx *bolt.Tx, id string) (*bolt.Bucket, error) {
+	b := tx.Bucket(tenantBucketName(id))
+	if b == nil {
+		return nil, fmt.Errorf("tenant %q: %w", id, ports.ErrUnknownTenant)
+	}
+	return b, nil
+}
+
+// tenantChild returns a nested bucket of the tenant attached to ctx.
+func tenantChild(ctx context.Context, tx *bolt.Tx, name []byte) (*bolt.Bucket, error) {
+	tb, err := tenantBucket(tx, tenancy.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	b := tb.Bucket(name)
+	if b == nil {
+		return nil, fmt.Errorf("bucket %q not found", string(name))
+	}
+	return b, nil
+}
+
+// provisionTenant creates the registry entry and every bucket a tenant needs.
+// Existing tenants are left untouched.
+func provisionTenant(tx *bolt.Tx, t domain.Tenant) error {
+	reg, err := tx.CreateBucketIfNotExists(tenantsBucket)
+	if err != nil {
+		return err
+	}
+	if reg.Get([]byte(t.ID)) == nil {
+		payload, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if err := reg.Put([]byte(t.ID), payload); err != nil {
+			return err
+		}
+	}
+	tb, err := tx.CreateBucketIfNotExists(tenantBucketName(t.ID))
+	if err != nil {
+		return err
+	}
+	backfill := tb.Bucket(ownersBucket) == nil
+	for _, name := range tenantBuckets {
+		if _, err := tb.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	if backfill {
+		return recountOwners(tb)
+	}
+	return nil
+}
+
+// tenantBuckets lists the nested buckets created for every tenant. Buckets
+// added here are backfilled into existing tenants on startup (ensureTenants).
+var tenantBuckets = [][]byte{todosBucket, historyBucket, changesBucket, changeIndexBucket, childrenBucket, ownersBucket, webhooksBucket, deliveriesBucket, feedTokenUsersBucket}
+
+type TenantRepository struct {
+	db *bolt.DB
+}
+
+func NewTenantRepository(db *bolt.DB) (*TenantRepository, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tenantsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TenantRepository{db: db}, nil
+}
+
+func (r *TenantRepository) List(ctx context.Context) ([]domain.Tenant, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []domain.Tenant
+	err := view(ctx, r.db, tenantsBucket, "list", func(ctx context.Context, tx *bolt.Tx) error {
+		b := tx.Bucket(tenantsBucket)
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", string(tenantsBucket))
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var t domain.Tenant
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			out = append(out, t)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *TenantRepository) Get(ctx context.Context, id string) (domain.Tenant, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Tenant{}, err
+	}
+
+	var out domain.Tenant
+	err := view(ctx, r.db, tenantsBucket, "get", func(ctx context.Context, tx *bolt.Tx) error {
+		b := tx.Bucket(tenantsBucket)
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", string(tenantsBucket))
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ports.ErrNotFound
+		}
+		return json.Unmarshal(v, &out)
+	})
+	if err != nil {
+		return domain.Tenant{}, err
+	}
+	return out, nil
+}
+
+func (r *TenantRepository) Create(ctx context.Context, tenant domain.Tenant) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := tenant.Validate(); err != nil {
+		return err
+	}
+
+	return update(ctx, r.db, tenantsBucket, "create", func(ctx context.Context, tx *bolt.Tx) error {
+		b := tx.Bucket(tenantsBucket)
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", string(tenantsBucket))
+		}
+		if existing := b.Get([]byte(tenant.ID)); existing != nil {
+			return ports.ErrConflict
+		}
+		return provisionTenant(tx, tenant)
+	})
+}
+
+// Delete removes the tenant and every piece of data stored under it.
+func (r *TenantRepository) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("missing id")
+	}
+
+	return update(ctx, r.db, tenantsBucket, "delete", func(ctx context.Context, tx *bolt.Tx) error {
+		b := tx.Bucket(tenantsBucket)
+		if b == nil {
+			return fmt.Errorf("bucket %q not found", string(tenantsBucket))
+		}
+		k := []byte(id)
+		if existing := b.Get(k); existing == nil {
+			return ports.ErrNotFound
+		}
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+		err := tx.DeleteBucket(tenantBucketName(id))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// ensureTenants provisions tenancy.DefaultID, backfills nested buckets added
+// since each tenant was created, and moves todos written by versions that
+// predate multi-tenancy (a single global "todos" bucket) into the default
+// tenant.
+func ensureTenants(tx *bolt.Tx) error {
+	if err := provisionTenant(tx, domain.Tenant{ID: tenancy.DefaultID, CreatedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+	var existing []domain.Tenant
+	if err := tx.Bucket(tenantsBucket).ForEach(func(_, v []byte) error {
+		var t domain.Tenant
+		if err := json.Unmarshal(v, &t); err != nil {
+			return err
+		}
+		existing = append(existing, t)
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, t := range existing {
+		if err := provisionTenant(tx, t); err != nil {
+			return err
+		}
+	}
+
+	legacy := tx.Bucket(todosBucket)
+	if legacy == nil {
+		return nil
+	}
+	tb := tx.Bucket(tenantBucketName(tenancy.DefaultID))
+	dst := tb.Bucket(todosBucket)
+	if err := legacy.ForEach(func(k, v []byte) error {
+		return dst.Put(k, v)
+	}); err != nil {
+		return err
+	}
+	if err := recountOwners(tb); err != nil {
+		return err
+	}
+	return tx.DeleteBucket(todosBucket)
+}
@@ -0,0 +1,105 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestTenantRepository_IsolatesTodos(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	todos, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new todo repo: %v", err)
+	}
+	tenants, err := NewTenantRepository(db)
+	if err != nil {
+		t.Fatalf("new tenant repo: %v", err)
+	}
+
+	ctx := context.Background()
+	acme := tenancy.WithID(ctx, "acme")
+
+	if err := todos.Create(acme, domain.Todo{ID: "1", Title: "x"}); !errors.Is(err, ports.ErrUnknownTenant) {
+		t.Fatalf("expected ErrUnknownTenant before provisioning, got %v", err)
+	}
+
+	if err := tenants.Create(ctx, domain.Tenant{ID: "acme"}); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	if err := tenants.Create(ctx, domain.Tenant{ID: "acme"}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	if err := todos.Create(acme, domain.Todo{ID: "1", Title: "acme todo"}); err != nil {
+		t.Fatalf("create acme todo: %v", err)
+	}
+	if _, err := todos.Get(ctx, "1"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected default tenant not to see acme todo, got %v", err)
+	}
+
+	list, err := tenants.List(ctx)
+	if err != nil {
+		t.Fatalf("list tenants: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected default and acme tenants, got %+v", list)
+	}
+
+	if err := tenants.Delete(ctx, "acme"); err != nil {
+		t.Fatalf("delete tenant: %v", err)
+	}
+	if _, err := todos.List(acme); !errors.Is(err, ports.ErrUnknownTenant) {
+		t.Fatalf("expected ErrUnknownTenant after deprovisioning, got %v", err)
+	}
+	if err := tenants.Delete(ctx, "acme"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestTodoRepository_MigratesLegacyBucket(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(todosBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("old"), []byte(`{"ID":"old","Title":"from v0","Completed":false}`))
+	})
+	if err != nil {
+		t.Fatalf("seed legacy bucket: %v", err)
+	}
+
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	got, err := repo.Get(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("get migrated todo: %v", err)
+	}
+	if got.Title != "from v0" {
+		t.Fatalf("unexpected todo: %+v", got)
+	}
+}
@@ -4,16 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 
 	"challenge-backend-arancia/internal/domain"
 	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 var todosBucket = []byte("todos")
 
+// TodoRepository stores todos in the bucket of the tenant attached to the
+// request context (see tenancy.FromContext).
 type TodoRepository struct {
 	db *bolt.DB
 }
@@ -33,9 +35,11 @@ func (r *TodoRepository) ensureBuckets(ctx context.Context) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
-	return r.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(todosBucket)
-		return err
+	return update(ctx, r.db, todosBucket, "ensure", func(ctx context.Context, tx *bolt.Tx) error {
+		if err := ensureOutbox(tx); err != nil {
+			return err
+		}
+		return ensureTenants(tx)
 	})
 }
 
@@ -45,10 +49,10 @@ func (r *TodoRepository) List(ctx context.Context) ([]domain.Todo, error) {
 	}
 
 	var out []domain.Todo
-	err := r.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(todosBucket)
-		if b == nil {
-			return fmt.Errorf("bucket %q not found", string(todosBucket))
+	err := view(ctx, r.db, todosBucket, "list", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, todosBucket)
+		if err != nil {
+			return err
 		}
 
 		c := b.Cursor()
@@ -62,6 +66,46 @@ func (r *TodoRepository) List(ctx context.Context) ([]domain.Todo, error) {
 			}
 			out = append(out, td)
 		}
+		setKeyCount(ctx, len(out))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *TodoRepository) ListAfter(ctx context.Context, afterID string, limit int, match func(domain.Todo) bool) ([]domain.Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := []domain.Todo{}
+	err := view(ctx, r.db, todosBucket, "list_after", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, todosBucket)
+		if err != nil {
+			return err
+		}
+
+		// Keys are IDs, so the cursor walks todos in ID order.
+		c := b.Cursor()
+		k, v := c.Seek([]byte(afterID))
+		if k != nil && string(k) == afterID {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(out) < limit; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var td domain.Todo
+			if err := json.Unmarshal(v, &td); err != nil {
+				return err
+			}
+			if match == nil || match(td) {
+				out = append(out, td)
+			}
+		}
+		setKeyCount(ctx, len(out))
 		return nil
 	})
 	if err != nil {
@@ -70,16 +114,56 @@ func (r *TodoRepository) List(ctx context.Context) ([]domain.Todo, error) {
 	return out, nil
 }
 
+func (r *TodoRepository) Count(ctx context.Context, match func(domain.Todo) bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err := view(ctx, r.db, todosBucket, "count", func(ctx context.Context, tx *bolt.Tx) error {
+		if match == nil {
+			// The owner counts add up to all todos without reading them.
+			owners, err := tenantChild(ctx, tx, ownersBucket)
+			if err != nil {
+				return err
+			}
+			n = int(ownedTotal(owners))
+			return nil
+		}
+		b, err := tenantChild(ctx, tx, todosBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(_, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var td domain.Todo
+			if err := json.Unmarshal(v, &td); err != nil {
+				return err
+			}
+			if match(td) {
+				n++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
 func (r *TodoRepository) Get(ctx context.Context, id string) (domain.Todo, error) {
 	if err := ctx.Err(); err != nil {
 		return domain.Todo{}, err
 	}
 
 	var out domain.Todo
-	err := r.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(todosBucket)
-		if b == nil {
-			return fmt.Errorf("bucket %q not found", string(todosBucket))
+	err := view(ctx, r.db, todosBucket, "get", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, todosBucket)
+		if err != nil {
+			return err
 		}
 		v := b.Get([]byte(id))
 		if v == nil {
@@ -93,65 +177,117 @@ func (r *TodoRepository) Get(ctx context.Context, id string) (domain.Todo, error
 	return out, nil
 }
 
-func (r *TodoRepository) Create(ctx context.Context, todo domain.Todo) error {
+func (r *TodoRepository) Create(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
+	return r.CreateWithin(ctx, todo, 0, events...)
+}
+
+func (r *TodoRepository) CreateWithin(ctx context.Context, todo domain.Todo, maxOwned int, events ...domain.Event) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 	if todo.ID == "" {
 		return errors.New("missing id")
 	}
-	if err := todo.Validate(); err != nil {
+	if err := todo.Check(); err != nil {
 		return err
 	}
 
+	todo.Version = 1
 	payload, err := json.Marshal(todo)
 	if err != nil {
 		return err
 	}
 
-	return r.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(todosBucket)
-		if b == nil {
-			return fmt.Errorf("bucket %q not found", string(todosBucket))
+	return update(ctx, r.db, todosBucket, "create", func(ctx context.Context, tx *bolt.Tx) error {
+		b, idx, err := subtaskBuckets(ctx, tx)
+		if err != nil {
+			return err
 		}
 		k := []byte(todo.ID)
 		if existing := b.Get(k); existing != nil {
 			return ports.ErrConflict
 		}
-		return b.Put(k, payload)
+		owners, err := tenantChild(ctx, tx, ownersBucket)
+		if err != nil {
+			return err
+		}
+		if err := checkOwned(owners, todo.Owner, maxOwned); err != nil {
+			return err
+		}
+		if err := attach(b, idx, todo); err != nil {
+			return err
+		}
+		if err := b.Put(k, payload); err != nil {
+			return err
+		}
+		if err := recordWrite(ctx, tx, todo, false, events); err != nil {
+			return err
+		}
+		return rollUp(ctx, tx, b, idx, todo.ParentID, events)
 	})
 }
 
-func (r *TodoRepository) Update(ctx context.Context, todo domain.Todo) error {
+func (r *TodoRepository) Update(ctx context.Context, todo domain.Todo, events ...domain.Event) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
 	if todo.ID == "" {
 		return errors.New("missing id")
 	}
-	if err := todo.Validate(); err != nil {
+	if err := todo.Check(); err != nil {
 		return err
 	}
 
-	payload, err := json.Marshal(todo)
-	if err != nil {
-		return err
-	}
-
-	return r.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(todosBucket)
-		if b == nil {
-			return fmt.Errorf("bucket %q not found", string(todosBucket))
+	return update(ctx, r.db, todosBucket, "update", func(ctx context.Context, tx *bolt.Tx) error {
+		b, idx, err := subtaskBuckets(ctx, tx)
+		if err != nil {
+			return err
 		}
 		k := []byte(todo.ID)
-		if existing := b.Get(k); existing == nil {
-			return ports.ErrNotFound
+		prev, err := getTodo(b, k)
+		if err != nil {
+			return err
 		}
-		return b.Put(k, payload)
+		if todo.Version != 0 && todo.Version != prev.Version {
+			return ports.ErrConflict
+		}
+		moved := todo.ParentID != prev.ParentID
+		if moved {
+			if err := detach(idx, prev); err != nil {
+				return err
+			}
+			if err := attach(b, idx, todo); err != nil {
+				return err
+			}
+		}
+		todo.Version = prev.Version + 1
+		payload, err := json.Marshal(todo)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(k, payload); err != nil {
+			return err
+		}
+		if err := recordWrite(ctx, tx, todo, false, events); err != nil {
+			return err
+		}
+		switch {
+		case moved:
+			if err := rollUp(ctx, tx, b, idx, prev.ParentID, events); err != nil {
+				return err
+			}
+			return rollUp(ctx, tx, b, idx, todo.ParentID, events)
+		case todo.Completed != prev.Completed:
+			return rollUp(ctx, tx, b, idx, todo.ParentID, events)
+		}
+		return nil
 	})
 }
 
-func (r *TodoRepository) Delete(ctx context.Context, id string) error {
+// Delete removes a todo and its subtasks, subtasks first. Its events carry
+// the last stored state with the version after the last write, so they sort
+// after every earlier event of the todo.
+func (r *TodoRepository) Delete(ctx context.Context, id string, version uint64, events ...domain.Event) error {
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -159,16 +295,76 @@ func (r *TodoRepository) Delete(ctx context.Context, id string) error {
 		return errors.New("missing id")
 	}
 
-	return r.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(todosBucket)
-		if b == nil {
-			return fmt.Errorf("bucket %q not found", string(todosBucket))
+	return update(ctx, r.db, todosBucket, "delete", func(ctx context.Context, tx *bolt.Tx) error {
+		b, idx, err := subtaskBuckets(ctx, tx)
+		if err != nil {
+			return err
 		}
 		k := []byte(id)
-		if existing := b.Get(k); existing == nil {
-			return ports.ErrNotFound
+		prev, err := getTodo(b, k)
+		if err != nil {
+			return err
 		}
-		return b.Delete(k)
+		if version != 0 && version != prev.Version {
+			return ports.ErrConflict
+		}
+		if err := deleteSubtasks(ctx, tx, b, idx, id, events); err != nil {
+			return err
+		}
+		if err := detach(idx, prev); err != nil {
+			return err
+		}
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+		prev.Version++
+		if err := recordWrite(ctx, tx, prev, true, events); err != nil {
+			return err
+		}
+		return rollUp(ctx, tx, b, idx, prev.ParentID, events)
 	})
 }
 
+// recordWrite logs a write of todo to its history, the change feed and its
+// events to the outbox, within the transaction of the write.
+func recordWrite(ctx context.Context, tx *bolt.Tx, todo domain.Todo, deleted bool, events []domain.Event) error {
+	tenant := tenancy.FromContext(ctx)
+	tb, err := tenantBucket(tx, tenant)
+	if err != nil {
+		return err
+	}
+	if err := appendHistory(tb, todo, deleted); err != nil {
+		return err
+	}
+	switch {
+	case deleted:
+		err = countOwned(tb.Bucket(ownersBucket), todo.Owner, -1)
+	case todo.Version == 1:
+		err = countOwned(tb.Bucket(ownersBucket), todo.Owner, 1)
+	}
+	if err != nil {
+		return err
+	}
+	if err := appendChange(tb, todo, deleted); err != nil {
+		return err
+	}
+	if obs := ports.WriteObserverFrom(ctx); obs != nil {
+		for _, ev := range events {
+			typ := ev.Type
+			tx.OnCommit(func() { obs(typ) })
+		}
+	}
+	return appendOutbox(tx, tenant, todo, events)
+}
+
+func getTodo(b *bolt.Bucket, k []byte) (domain.Todo, error) {
+	v := b.Get(k)
+	if v == nil {
+		return domain.Todo{}, ports.ErrNotFound
+	}
+	var td domain.Todo
+	if err := json.Unmarshal(v, &td); err != nil {
+		return domain.Todo{}, err
+	}
+	return td, nil
+}
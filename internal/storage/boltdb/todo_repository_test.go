@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"challenge-backend-arancia/internal/domain"
@@ -74,11 +75,122 @@ func TestTodoRepository_CRUD(t *testing.T) {
 	}
 
 	// delete
-	if err := repo.Delete(ctx, "1"); err != nil {
+	if err := repo.Delete(ctx, "1", 0); err != nil {
 		t.Fatalf("delete: %v", err)
 	}
-	if err := repo.Delete(ctx, "1"); !errors.Is(err, ports.ErrNotFound) {
+	if err := repo.Delete(ctx, "1", 0); !errors.Is(err, ports.ErrNotFound) {
 		t.Fatalf("expected ErrNotFound, got %v", err)
 	}
 }
 
+func TestTodoRepository_ConditionalWritesAndHistory(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, domain.Todo{ID: "1", Title: "buy milk"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := repo.Update(ctx, domain.Todo{ID: "1", Title: "buy oat milk", Version: 1}); err != nil {
+		t.Fatalf("update at version 1: %v", err)
+	}
+	if err := repo.Update(ctx, domain.Todo{ID: "1", Title: "stale", Version: 1}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a stale update, got %v", err)
+	}
+	if err := repo.Delete(ctx, "1", 1); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict for a stale delete, got %v", err)
+	}
+
+	v1, err := repo.GetVersion(ctx, "1", 1)
+	if err != nil {
+		t.Fatalf("get version 1: %v", err)
+	}
+	if v1.Title != "buy milk" || v1.Version != 1 {
+		t.Fatalf("unexpected version 1: %+v", v1)
+	}
+	if _, err := repo.GetVersion(ctx, "1", 3); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unwritten version, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, "1", 2); err != nil {
+		t.Fatalf("delete at version 2: %v", err)
+	}
+	if _, err := repo.GetVersion(ctx, "1", 2); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected deletion to drop history, got %v", err)
+	}
+}
+
+func TestTodoRepository_ListAfterAndCount(t *testing.T) {
+	t.Parallel()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, td := range []domain.Todo{
+		{ID: "a", Title: "buy milk", Owner: "alice"},
+		{ID: "b", Title: "walk dog"},
+		{ID: "c", Title: "buy bread", Owner: "bob", Completed: true},
+		{ID: "d", Title: "buy eggs"},
+	} {
+		if err := repo.Create(ctx, td); err != nil {
+			t.Fatalf("create %s: %v", td.ID, err)
+		}
+	}
+	buying := func(td domain.Todo) bool { return strings.HasPrefix(td.Title, "buy") }
+	ids := func(list []domain.Todo) string {
+		var out []string
+		for _, td := range list {
+			out = append(out, td.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	for _, tc := range []struct {
+		after string
+		limit int
+		match func(domain.Todo) bool
+		want  string
+	}{
+		{"", 2, nil, "a,b"},
+		{"b", 2, nil, "c,d"},
+		{"bb", 10, nil, "c,d"},
+		{"", 2, buying, "a,c"},
+		{"c", 2, buying, "d"},
+		{"d", 2, nil, ""},
+	} {
+		list, err := repo.ListAfter(ctx, tc.after, tc.limit, tc.match)
+		if err != nil {
+			t.Fatalf("list after %q: %v", tc.after, err)
+		}
+		if got := ids(list); got != tc.want {
+			t.Fatalf("list after %q: expected %q, got %q", tc.after, tc.want, got)
+		}
+	}
+
+	// Without a filter the count comes from the owner counts, which include
+	// anonymous todos.
+	if n, err := repo.Count(ctx, nil); err != nil || n != 4 {
+		t.Fatalf("expected 4 todos, got %d (%v)", n, err)
+	}
+	if n, err := repo.Count(ctx, buying); err != nil || n != 3 {
+		t.Fatalf("expected 3 matching todos, got %d (%v)", n, err)
+	}
+}
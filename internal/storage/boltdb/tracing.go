@@ -0,0 +1,103 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"challenge-backend-arancia/internal/logging"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tracing"
+
+	bolt "go.etcd.io/bbolt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("storage/boltdb")
+
+// txFunc is the body of a traced transaction. Its ctx carries the span of
+// the transaction, for setKeyCount.
+type txFunc func(ctx context.Context, tx *bolt.Tx) error
+
+// view runs fn in a read-only transaction traced as a span; op names what
+// the transaction does to bucket, such as "get" on "todos".
+func view(ctx context.Context, db *bolt.DB, bucket []byte, op string, fn txFunc) error {
+	return traceTx(ctx, db.View, false, bucket, op, fn)
+}
+
+// update is view for read-write transactions.
+func update(ctx context.Context, db *bolt.DB, bucket []byte, op string, fn txFunc) error {
+	return traceTx(ctx, db.Update, true, bucket, op, fn)
+}
+
+func traceTx(ctx context.Context, run func(func(*bolt.Tx) error) error, writable bool, bucket []byte, op string, fn txFunc) error {
+	name := "bolt.View "
+	if writable {
+		name = "bolt.Update "
+	}
+	ctx, span := tracer.Start(ctx, name+string(bucket),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "boltdb"),
+			attribute.String("db.operation.name", op),
+			attribute.String("db.collection.name", string(bucket)),
+			attribute.Bool("bolt.tx.writable", writable),
+		),
+	)
+	start := time.Now()
+	var stats bolt.TxStats
+	err := run(func(tx *bolt.Tx) error {
+		err := fn(ctx, tx)
+		stats = tx.Stats()
+		return err
+	})
+	// The duration includes waiting for the writer lock and the commit.
+	elapsed := time.Since(start)
+	span.SetAttributes(
+		attribute.Float64("bolt.tx.duration_ms", float64(elapsed.Microseconds())/1000),
+		attribute.Int64("bolt.tx.cursors", stats.GetCursorCount()),
+		attribute.Int64("bolt.tx.nodes", stats.GetNodeCount()),
+	)
+	logTx(ctx, name+string(bucket), op, elapsed, err)
+	tracing.End(span, err)
+	return err
+}
+
+// slowTx is the duration above which a transaction is logged as a warning.
+const slowTx = 100 * time.Millisecond
+
+// logTx logs, with the logger of the request in ctx, transactions that
+// failed other than with a ports error the callers expect, or were slow.
+func logTx(ctx context.Context, tx, op string, elapsed time.Duration, err error) {
+	logger := logging.FromContext(ctx)
+	switch {
+	case err != nil && !expected(err):
+		logger.ErrorContext(ctx, "bolt_tx_failed",
+			slog.String("tx", tx),
+			slog.String("op", op),
+			slog.String("error", err.Error()),
+		)
+	case elapsed > slowTx:
+		logger.WarnContext(ctx, "bolt_tx_slow",
+			slog.String("tx", tx),
+			slog.String("op", op),
+			slog.Duration("duration", elapsed),
+		)
+	}
+}
+
+func expected(err error) bool {
+	return errors.Is(err, ports.ErrNotFound) ||
+		errors.Is(err, ports.ErrConflict) ||
+		errors.Is(err, ports.ErrUnknownTenant) ||
+		errors.Is(err, ports.ErrResyncRequired) ||
+		errors.Is(err, context.Canceled)
+}
+
+// setKeyCount records on the transaction span in ctx how many keys it read
+// or wrote.
+func setKeyCount(ctx context.Context, n int) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("bolt.keys", n))
+}
@@ -0,0 +1,412 @@
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+	"challenge-backend-arancia/internal/tenancy"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	webhooksBucket     = []byte("webhooks")
+	deliveriesBucket   = []byte("deliveries")
+	webhookQueueBucket = []byte("webhook_queue")
+)
+
+// deliveryKey groups deliveries by webhook so they can be listed with a prefix
+// scan.
+func deliveryKey(webhookID, id string) []byte {
+	return []byte(webhookID + "/" + id)
+}
+
+// queueKey orders pending deliveries by due time across all tenants:
+// 8-byte big-endian unix nanoseconds followed by "<tenant>/<webhook>/<delivery>".
+func queueKey(d domain.Delivery) []byte {
+	k := make([]byte, 8, 8+len(d.Tenant)+len(d.WebhookID)+len(d.ID)+2)
+	binary.BigEndian.PutUint64(k, uint64(d.NextAttemptAt.UnixNano()))
+	return append(k, d.Tenant+"/"+d.WebhookID+"/"+d.ID...)
+}
+
+func parseQueueKey(k []byte) (due time.Time, tenant, webhookID, id string, ok bool) {
+	if len(k) < 8 {
+		return time.Time{}, "", "", "", false
+	}
+	parts := bytes.SplitN(k[8:], []byte("/"), 3)
+	if len(parts) != 3 {
+		return time.Time{}, "", "", "", false
+	}
+	due = time.Unix(0, int64(binary.BigEndian.Uint64(k[:8])))
+	return due, string(parts[0]), string(parts[1]), string(parts[2]), true
+}
+
+func ensureWebhookQueue(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(webhookQueueBucket); err != nil {
+			return err
+		}
+		return ensureTenants(tx)
+	})
+}
+
+type WebhookRepository struct {
+	db *bolt.DB
+}
+
+func NewWebhookRepository(db *bolt.DB) (*WebhookRepository, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	if err := ensureWebhookQueue(db); err != nil {
+		return nil, err
+	}
+	return &WebhookRepository{db: db}, nil
+}
+
+func (r *WebhookRepository) List(ctx context.Context) ([]domain.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []domain.Webhook
+	err := view(ctx, r.db, webhooksBucket, "list", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, webhooksBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var w domain.Webhook
+			if err := json.Unmarshal(v, &w); err != nil {
+				return err
+			}
+			out = append(out, w)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *WebhookRepository) Get(ctx context.Context, id string) (domain.Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Webhook{}, err
+	}
+
+	var out domain.Webhook
+	err := view(ctx, r.db, webhooksBucket, "get", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, webhooksBucket)
+		if err != nil {
+			return err
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ports.ErrNotFound
+		}
+		return json.Unmarshal(v, &out)
+	})
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	return out, nil
+}
+
+func (r *WebhookRepository) Create(ctx context.Context, webhook domain.Webhook) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if webhook.ID == "" {
+		return errors.New("missing id")
+	}
+	if err := webhook.Validate(); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+
+	return update(ctx, r.db, webhooksBucket, "create", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, webhooksBucket)
+		if err != nil {
+			return err
+		}
+		k := []byte(webhook.ID)
+		if existing := b.Get(k); existing != nil {
+			return ports.ErrConflict
+		}
+		return b.Put(k, payload)
+	})
+}
+
+// Delete removes the webhook together with its deliveries and their queue
+// entries.
+func (r *WebhookRepository) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("missing id")
+	}
+
+	return update(ctx, r.db, webhooksBucket, "delete", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, webhooksBucket)
+		if err != nil {
+			return err
+		}
+		k := []byte(id)
+		if existing := b.Get(k); existing == nil {
+			return ports.ErrNotFound
+		}
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+
+		db, err := tenantChild(ctx, tx, deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		queue := tx.Bucket(webhookQueueBucket)
+		prefix := deliveryKey(id, "")
+		var keys [][]byte
+		c := db.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var d domain.Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			if d.Status == domain.DeliveryPending {
+				if err := queue.Delete(queueKey(d)); err != nil {
+					return err
+				}
+			}
+			keys = append(keys, k)
+		}
+		for _, k := range keys {
+			if err := db.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type DeliveryRepository struct {
+	db *bolt.DB
+}
+
+func NewDeliveryRepository(db *bolt.DB) (*DeliveryRepository, error) {
+	if db == nil {
+		return nil, errors.New("nil db")
+	}
+	if err := ensureWebhookQueue(db); err != nil {
+		return nil, err
+	}
+	return &DeliveryRepository{db: db}, nil
+}
+
+func (r *DeliveryRepository) Create(ctx context.Context, deliveries ...domain.Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tenant := tenancy.FromContext(ctx)
+
+	return update(ctx, r.db, deliveriesBucket, "create", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		queue := tx.Bucket(webhookQueueBucket)
+		for _, d := range deliveries {
+			if d.ID == "" || d.WebhookID == "" {
+				return errors.New("missing id")
+			}
+			d.Tenant = tenant
+			k := deliveryKey(d.WebhookID, d.ID)
+			if existing := b.Get(k); existing != nil {
+				return ports.ErrConflict
+			}
+			if err := putDelivery(b, queue, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *DeliveryRepository) Get(ctx context.Context, webhookID, id string) (domain.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Delivery{}, err
+	}
+
+	var out domain.Delivery
+	err := view(ctx, r.db, deliveriesBucket, "get", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		v := b.Get(deliveryKey(webhookID, id))
+		if v == nil {
+			return ports.ErrNotFound
+		}
+		return json.Unmarshal(v, &out)
+	})
+	if err != nil {
+		return domain.Delivery{}, err
+	}
+	return out, nil
+}
+
+// List returns the deliveries of a webhook, oldest first.
+func (r *DeliveryRepository) List(ctx context.Context, webhookID string) ([]domain.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []domain.Delivery
+	err := view(ctx, r.db, deliveriesBucket, "list", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		prefix := deliveryKey(webhookID, "")
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var d domain.Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			out = append(out, d)
+		}
+		setKeyCount(ctx, len(out))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *DeliveryRepository) Update(ctx context.Context, delivery domain.Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if delivery.ID == "" || delivery.WebhookID == "" {
+		return errors.New("missing id")
+	}
+	delivery.Tenant = tenancy.FromContext(ctx)
+
+	return update(ctx, r.db, deliveriesBucket, "update", func(ctx context.Context, tx *bolt.Tx) error {
+		b, err := tenantChild(ctx, tx, deliveriesBucket)
+		if err != nil {
+			return err
+		}
+		queue := tx.Bucket(webhookQueueBucket)
+		v := b.Get(deliveryKey(delivery.WebhookID, delivery.ID))
+		if v == nil {
+			return ports.ErrNotFound
+		}
+		var prev domain.Delivery
+		if err := json.Unmarshal(v, &prev); err != nil {
+			return err
+		}
+		if prev.Status == domain.DeliveryPending {
+			if err := queue.Delete(queueKey(prev)); err != nil {
+				return err
+			}
+		}
+		return putDelivery(b, queue, delivery)
+	})
+}
+
+func (r *DeliveryRepository) Due(ctx context.Context, now time.Time, limit int) ([]domain.Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		out    []domain.Delivery
+		orphan [][]byte
+	)
+	err := view(ctx, r.db, webhookQueueBucket, "due", func(ctx context.Context, tx *bolt.Tx) error {
+		queue := tx.Bucket(webhookQueueBucket)
+		if queue == nil {
+			return fmt.Errorf("bucket %q not found", string(webhookQueueBucket))
+		}
+		c := queue.Cursor()
+		for k, _ := c.First(); k != nil && len(out) < limit; k, _ = c.Next() {
+			due, tenant, webhookID, id, ok := parseQueueKey(k)
+			if !ok {
+				orphan = append(orphan, bytes.Clone(k))
+				continue
+			}
+			if due.After(now) {
+				break
+			}
+			b, err := tenantChild(tenancy.WithID(ctx, tenant), tx, deliveriesBucket)
+			if err != nil {
+				// The tenant was deprovisioned after scheduling.
+				orphan = append(orphan, bytes.Clone(k))
+				continue
+			}
+			v := b.Get(deliveryKey(webhookID, id))
+			if v == nil {
+				orphan = append(orphan, bytes.Clone(k))
+				continue
+			}
+			var d domain.Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			out = append(out, d)
+		}
+		setKeyCount(ctx, len(out))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orphan) > 0 {
+		err = update(ctx, r.db, webhookQueueBucket, "drop_orphans", func(ctx context.Context, tx *bolt.Tx) error {
+			queue := tx.Bucket(webhookQueueBucket)
+			for _, k := range orphan {
+				if err := queue.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// putDelivery writes d and, if it is pending, its queue entry.
+func putDelivery(b, queue *bolt.Bucket, d domain.Delivery) error {
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(deliveryKey(d.WebhookID, d.ID), payload); err != nil {
+		return err
+	}
+	if d.Status != domain.DeliveryPending {
+		return nil
+	}
+	return queue.Put(queueKey(d), []byte{})
+}
@@ -0,0 +1,122 @@
+package boltdb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/domain"
+	"challenge-backend-arancia/internal/ports"
+)
+
+func newWebhookRepos(t *testing.T) (*WebhookRepository, *DeliveryRepository) {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := NewTodoRepository(db); err != nil {
+		t.Fatalf("new todo repo: %v", err)
+	}
+	hooks, err := NewWebhookRepository(db)
+	if err != nil {
+		t.Fatalf("new webhook repo: %v", err)
+	}
+	deliveries, err := NewDeliveryRepository(db)
+	if err != nil {
+		t.Fatalf("new delivery repo: %v", err)
+	}
+	return hooks, deliveries
+}
+
+func TestDeliveryRepository_DueFollowsSchedule(t *testing.T) {
+	t.Parallel()
+
+	hooks, deliveries := newWebhookRepos(t)
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := hooks.Create(ctx, domain.Webhook{ID: "w1", URL: "http://example.test", Events: []domain.EventType{domain.EventTodoCreated}, Secret: "s"}); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	err := deliveries.Create(ctx,
+		domain.Delivery{ID: "late", WebhookID: "w1", Status: domain.DeliveryPending, NextAttemptAt: now.Add(time.Minute), CreatedAt: now},
+		domain.Delivery{ID: "first", WebhookID: "w1", Status: domain.DeliveryPending, NextAttemptAt: now.Add(-time.Second), CreatedAt: now},
+		domain.Delivery{ID: "second", WebhookID: "w1", Status: domain.DeliveryPending, NextAttemptAt: now, CreatedAt: now},
+	)
+	if err != nil {
+		t.Fatalf("create deliveries: %v", err)
+	}
+
+	due, err := deliveries.Due(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	if len(due) != 2 || due[0].ID != "first" || due[1].ID != "second" {
+		t.Fatalf("expected first and second in order, got %+v", due)
+	}
+	if due[0].Tenant != "default" {
+		t.Fatalf("expected tenant to be recorded, got %q", due[0].Tenant)
+	}
+
+	// Rescheduling moves the delivery out of the due window, finishing it
+	// removes it from the queue.
+	first := due[0]
+	first.NextAttemptAt = now.Add(time.Hour)
+	if err := deliveries.Update(ctx, first); err != nil {
+		t.Fatalf("reschedule: %v", err)
+	}
+	second := due[1]
+	second.Status = domain.DeliverySucceeded
+	if err := deliveries.Update(ctx, second); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	due, err = deliveries.Due(ctx, now.Add(2*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	if len(due) != 1 || due[0].ID != "late" {
+		t.Fatalf("expected only late to be due, got %+v", due)
+	}
+}
+
+func TestWebhookRepository_DeleteCascades(t *testing.T) {
+	t.Parallel()
+
+	hooks, deliveries := newWebhookRepos(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	if err := hooks.Create(ctx, domain.Webhook{ID: "w1", URL: "http://example.test", Events: []domain.EventType{domain.EventTodoCreated}, Secret: "s"}); err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	if err := hooks.Create(ctx, domain.Webhook{ID: "w1", URL: "http://example.test", Events: []domain.EventType{domain.EventTodoCreated}, Secret: "s"}); !errors.Is(err, ports.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if err := deliveries.Create(ctx, domain.Delivery{ID: "d1", WebhookID: "w1", Status: domain.DeliveryPending, NextAttemptAt: now, CreatedAt: now}); err != nil {
+		t.Fatalf("create delivery: %v", err)
+	}
+
+	if err := hooks.Delete(ctx, "w1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := deliveries.Get(ctx, "w1", "d1"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected delivery to be deleted, got %v", err)
+	}
+	due, err := deliveries.Due(ctx, now.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	if len(due) != 0 {
+		t.Fatalf("expected empty queue, got %+v", due)
+	}
+	if err := hooks.Delete(ctx, "w1"); !errors.Is(err, ports.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Package tenancy carries the tenant a request acts on through context.Context.
+package tenancy
+
+import "context"
+
+// DefaultID is the tenant used when no tenant has been attached to a context.
+// It is always provisioned by the storage layer.
+const DefaultID = "default"
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx scoped to the given tenant.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant attached to ctx, or DefaultID if there is none.
+func FromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKey{}).(string); ok && id != "" {
+		return id
+	}
+	return DefaultID
+}
+
+// Lookup returns the tenant attached to ctx and whether there is one.
+func Lookup(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,122 @@
+// Package tlsconfig serves TLS from certificate files that may be replaced
+// while serving, such as a mounted Kubernetes Secret, optionally verifying
+// client certificates against a CA bundle (mutual TLS).
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// ClientAuth is how client certificates are handled when a client CA bundle
+// is configured.
+type ClientAuth string
+
+const (
+	// RequireClientCert rejects connections without a valid client
+	// certificate.
+	RequireClientCert ClientAuth = "require"
+	// VerifyClientCertIfGiven accepts connections without a certificate,
+	// which can authenticate otherwise, but rejects invalid ones.
+	VerifyClientCertIfGiven ClientAuth = "optional"
+)
+
+// Options names the files a Reloader reads.
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables client certificate verification when set.
+	ClientCAFile string
+	ClientAuth   ClientAuth
+}
+
+// Reloader hands out the certificate and client CAs last read from the
+// files of its Options. Connections in progress keep the ones they started
+// with.
+type Reloader struct {
+	opts    Options
+	current atomic.Pointer[tls.Config]
+}
+
+// NewReloader reads the files of opts, failing if they are missing or
+// invalid.
+func NewReloader(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("certificate and key files are required")
+	}
+	if opts.ClientAuth == "" {
+		opts.ClientAuth = RequireClientCert
+	}
+	r := &Reloader{opts: opts}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous certificate and
+// client CAs stay in use.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate: %w", err)
+	}
+	cfg := baseConfig()
+	cfg.Certificates = []tls.Certificate{cert}
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA bundle: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA bundle %s holds no PEM certificates", r.opts.ClientCAFile)
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if r.opts.ClientAuth == VerifyClientCertIfGiven {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	r.current.Store(cfg)
+	return nil
+}
+
+// Files lists the files read, to watch for changes.
+func (r *Reloader) Files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+// Leaf returns the certificate currently served.
+func (r *Reloader) Leaf() *x509.Certificate {
+	cert := r.current.Load().Certificates[0]
+	if cert.Leaf != nil {
+		return cert.Leaf
+	}
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	return leaf
+}
+
+// Config returns a server configuration that uses the files last read for
+// every new connection. It offers HTTP/2 over ALPN.
+func (r *Reloader) Config() *tls.Config {
+	cfg := baseConfig()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return r.current.Load(), nil
+	}
+	return cfg
+}
+
+func baseConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+}
@@ -0,0 +1,233 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issuer signs certificates for tests; a nil parent makes them self-signed.
+type issuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) issuer {
+	t.Helper()
+	return issue(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+}
+
+func issue(t *testing.T, parent *issuer, tmpl *x509.Certificate) issuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Minute)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return issuer{cert: cert, key: key}
+}
+
+func (i issuer) serverCert(t *testing.T, name string) issuer {
+	t.Helper()
+	return issue(t, &i, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func (i issuer) clientCert(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	c := issue(t, &i, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// write stores the certificate of i, and its key unless keyFile is empty, as
+// PEM.
+func (i issuer) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	key, err := x509.MarshalECPrivateKey(i.key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	writePEM(t, certFile, "CERTIFICATE", i.cert.Raw)
+	if keyFile != "" {
+		writePEM(t, keyFile, "EC PRIVATE KEY", key)
+	}
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestReloader_MutualTLSOverHTTP2(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	opts := Options{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	ca := newCA(t)
+	ca.write(t, opts.ClientCAFile, "")
+	ca.serverCert(t, "server-1").write(t, opts.CertFile, opts.KeyFile)
+
+	r, err := NewReloader(opts)
+	if err != nil {
+		t.Fatalf("new reloader: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = r.Config()
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := ca.clientCert(t, "billing")
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		// A new transport per request, so every request handshakes again.
+		tr := &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+			ForceAttemptHTTP2: true,
+		}
+		defer tr.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	resp, err := get(client)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2, got %s", resp.Proto)
+	}
+	if got := resp.TLS.PeerCertificates[0].Subject.CommonName; got != "server-1" {
+		t.Fatalf("expected server-1, got %s", got)
+	}
+
+	if _, err := get(); err == nil {
+		t.Fatalf("expected a client without certificate to be rejected")
+	}
+	other := newCA(t).clientCert(t, "mallory")
+	if _, err := get(other); err == nil {
+		t.Fatalf("expected a certificate of another CA to be rejected")
+	}
+
+	// Rotate the server certificate the way a Secret update does.
+	ca.serverCert(t, "server-2").write(t, opts.CertFile, opts.KeyFile)
+	if err := r.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	resp, err = get(client)
+	if err != nil {
+		t.Fatalf("get after reload: %v", err)
+	}
+	if got := resp.TLS.PeerCertificates[0].Subject.CommonName; got != "server-2" {
+		t.Fatalf("expected the rotated certificate, got %s", got)
+	}
+
+	// A broken update keeps the last good certificate.
+	if err := os.WriteFile(opts.KeyFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatalf("expected reload of a broken key to fail")
+	}
+	if r.Leaf().Subject.CommonName != "server-2" {
+		t.Fatalf("expected server-2 to stay in use, got %s", r.Leaf().Subject.CommonName)
+	}
+	if _, err := get(client); err != nil {
+		t.Fatalf("get after failed reload: %v", err)
+	}
+}
+
+func TestReloader_OptionalClientCert(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	opts := Options{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   VerifyClientCertIfGiven,
+	}
+	ca := newCA(t)
+	ca.write(t, opts.ClientCAFile, "")
+	ca.serverCert(t, "server").write(t, opts.CertFile, opts.KeyFile)
+	r, err := NewReloader(opts)
+	if err != nil {
+		t.Fatalf("new reloader: %v", err)
+	}
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.Config())
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: roots, NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatalf("expected a client without certificate to connect, got %v", err)
+	}
+	if got := conn.ConnectionState().NegotiatedProtocol; got != "h2" {
+		t.Fatalf("expected h2 to be negotiated, got %q", got)
+	}
+	_ = conn.Close()
+}
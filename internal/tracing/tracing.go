@@ -0,0 +1,138 @@
+// Package tracing sets up OpenTelemetry tracing and holds the helpers the
+// HTTP, service and storage layers share to create spans.
+//
+// Components get their tracer from the global provider (see Tracer), which
+// Setup installs; until then spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation prefixes the name of every tracer.
+const instrumentation = "challenge-backend-arancia/internal/"
+
+// defaultServiceName applies unless OTEL_SERVICE_NAME is set.
+const defaultServiceName = "todo-api"
+
+// Exporters accepted by Setup.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Propagator reads and writes W3C trace context and baggage headers.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// Tracer returns the tracer of a component, named after its package path
+// below internal/ ("httpapi", "storage/boltdb", ...).
+func Tracer(component string) trace.Tracer {
+	return otel.Tracer(instrumentation + component)
+}
+
+// Setup installs a global tracer provider exporting to exporter, sampling
+// sampleRatio of the traces that do not inherit a decision from their
+// caller. The OTLP exporter is configured by the standard OTEL_EXPORTER_OTLP_*
+// variables; stdout writes to out. The returned func flushes pending spans.
+func Setup(ctx context.Context, exporter string, sampleRatio float64, out io.Writer) (func(context.Context) error, error) {
+	var (
+		exp sdktrace.SpanExporter
+		err error
+	)
+	switch exporter {
+	case "", ExporterNone:
+		otel.SetTextMapPropagator(Propagator)
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exp, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(out))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(defaultServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tp := NewProvider(exp, sampleRatio, res)
+	Install(tp)
+	return tp.Shutdown, nil
+}
+
+// NewProvider returns a provider batching spans to exp. Tests rather build
+// a provider syncing to an in-memory exporter (tracetest.NewInMemoryExporter)
+// and Install it.
+func NewProvider(exp sdktrace.SpanExporter, sampleRatio float64, res *resource.Resource) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+}
+
+// Install makes tp the global provider and Propagator the global propagator.
+func Install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(Propagator)
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// LogHandler adds the trace_id and span_id of the span in the context of
+// each record to the records h handles, so logs can be joined with traces.
+func LogHandler(h slog.Handler) slog.Handler {
+	return logHandler{h}
+}
+
+type logHandler struct {
+	slog.Handler
+}
+
+func (h logHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return logHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h logHandler) WithGroup(name string) slog.Handler {
+	return logHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSetup_RejectsUnknownExporter(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Setup(context.Background(), "zipkin", 1, nil); err == nil {
+		t.Fatalf("expected error for unknown exporter")
+	}
+}
+
+func TestLogHandler_AddsIDsOnlyWithinSpans(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(LogHandler(slog.NewTextHandler(&buf, nil))).With("component", "test")
+
+	logger.InfoContext(context.Background(), "untraced")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Fatalf("expected no trace id outside a span, got %s", buf.String())
+	}
+
+	buf.Reset()
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "traced")
+	for _, want := range []string{"trace_id=" + sc.TraceID().String(), "span_id=" + sc.SpanID().String(), "component=test"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in %s", want, buf.String())
+		}
+	}
+}
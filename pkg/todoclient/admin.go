@@ -0,0 +1,74 @@
+package todoclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// The admin endpoints require the AdminToken authenticator.
+
+// Tenant is a provisioned tenant.
+type Tenant struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TenantExport is every todo of a tenant.
+type TenantExport struct {
+	Tenant Tenant `json:"tenant"`
+	Todos  []Todo `json:"todos"`
+}
+
+// ConfigSetting is the effective value of a setting of the server and where
+// it came from: "flag", "env", "file" or "default".
+type ConfigSetting struct {
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+func tenantPath(id string) string {
+	return "/admin/tenants/" + url.PathEscape(id)
+}
+
+// ListTenants returns the provisioned tenants.
+func (c *Client) ListTenants(ctx context.Context) ([]Tenant, error) {
+	var out []Tenant
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/tenants"}, &out)
+	return out, err
+}
+
+// CreateTenant provisions a tenant; it fails with ErrConflict if it exists.
+func (c *Client) CreateTenant(ctx context.Context, id string) (Tenant, error) {
+	var out Tenant
+	err := c.do(ctx, request{method: http.MethodPost, path: "/admin/tenants", body: map[string]string{"id": id}}, &out)
+	return out, err
+}
+
+// GetTenant returns a tenant.
+func (c *Client) GetTenant(ctx context.Context, id string) (Tenant, error) {
+	var out Tenant
+	err := c.do(ctx, request{method: http.MethodGet, path: tenantPath(id)}, &out)
+	return out, err
+}
+
+// DeleteTenant deprovisions a tenant and deletes its data.
+func (c *Client) DeleteTenant(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: tenantPath(id)}, nil)
+}
+
+// ExportTenant returns every todo of a tenant.
+func (c *Client) ExportTenant(ctx context.Context, id string) (TenantExport, error) {
+	var out TenantExport
+	err := c.do(ctx, request{method: http.MethodGet, path: tenantPath(id) + "/export"}, &out)
+	return out, err
+}
+
+// Config returns the effective configuration of the server by setting name,
+// with secrets redacted.
+func (c *Client) Config(ctx context.Context) (map[string]ConfigSetting, error) {
+	var out map[string]ConfigSetting
+	err := c.do(ctx, request{method: http.MethodGet, path: "/admin/config"}, &out)
+	return out, err
+}
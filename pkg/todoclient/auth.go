@@ -0,0 +1,51 @@
+package todoclient
+
+import "net/http"
+
+// Authenticator adds credentials to a request before it is sent, such as a
+// token fetched from an identity provider.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken authenticates with a token the API verifies (AUTH_TOKEN_SECRET).
+func BearerToken(token string) Authenticator {
+	return header("Authorization", "Bearer "+token)
+}
+
+// APIKey sends key in X-API-Key, which identifies the client for rate
+// limiting by API key.
+func APIKey(key string) Authenticator {
+	return header("X-API-Key", key)
+}
+
+// AdminToken authenticates the admin endpoints (ADMIN_TOKEN).
+func AdminToken(token string) Authenticator {
+	return header("X-Admin-Token", token)
+}
+
+// Chain applies every authenticator in turn, to send several credentials.
+func Chain(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		for _, a := range auths {
+			if err := a.Authenticate(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func header(name, value string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(name, value)
+		return nil
+	})
+}
@@ -0,0 +1,247 @@
+// Package todoclient is a Go client for the todo HTTP API (/v1).
+//
+// A Client covers the JSON endpoints, including the admin ones, and the SSE
+// change stream. Errors returned for problem responses wrap the sentinel of
+// their problem type (ErrNotFound, ErrConflict, ...), so callers can match
+// them with errors.Is, and are *Error values carrying the details:
+//
+//	c, err := todoclient.New(todoclient.Options{
+//		BaseURL: "https://todo.example.com",
+//		Auth:    todoclient.BearerToken(token),
+//	})
+//	...
+//	td, err := c.CreateTodo(ctx, "buy milk")
+//	if errors.Is(err, todoclient.ErrValidation) { ... }
+//
+// The GraphQL, CalDAV, WebSocket and feed (iCalendar, Atom) endpoints speak
+// their own protocols and are best used with dedicated clients.
+package todoclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTenantHeader is the header the API reads the tenant from by default.
+const DefaultTenantHeader = "X-Tenant-Id"
+
+// Options configures a Client.
+type Options struct {
+	// BaseURL is where the API is served, such as "https://todo.example.com".
+	BaseURL string
+	// HTTPClient sends the requests; it defaults to http.DefaultClient. Set
+	// its Transport to use client certificates (mutual TLS). A Timeout also
+	// ends change streams.
+	HTTPClient *http.Client
+	// Auth adds credentials to every request when set.
+	Auth Authenticator
+	// Tenant is sent in TenantHeader (DefaultTenantHeader) when set.
+	Tenant       string
+	TenantHeader string
+	// Retry governs retries of idempotent calls.
+	Retry RetryPolicy
+	// UserAgent is sent with every request when set.
+	UserAgent string
+}
+
+// RetryPolicy retries idempotent calls (GET, PUT and DELETE) that failed
+// with a network error, 429 or 502-504, with exponential backoff and full
+// jitter, honouring Retry-After. Non-idempotent calls (POST) are never
+// retried. The zero value uses the defaults.
+type RetryPolicy struct {
+	// MaxAttempts counts the first try; 1 disables retries. Default 3.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for each
+	// further one up to MaxDelay. Defaults 100ms and 2s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 100 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 2 * time.Second
+	}
+	return p
+}
+
+// backoff returns the wait before retry n (from 1), at least retryAfter.
+func (p RetryPolicy) backoff(n int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d > p.MaxDelay || d <= 0 {
+		d = p.MaxDelay
+	}
+	d = rand.N(d + 1)
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// Client calls the todo API. It is safe for concurrent use.
+type Client struct {
+	base *url.URL
+	opts Options
+}
+
+// New returns a Client for the API at opts.BaseURL.
+func New(opts Options) (*Client, error) {
+	if opts.BaseURL == "" {
+		return nil, errors.New("empty base URL")
+	}
+	base, err := url.Parse(strings.TrimSuffix(opts.BaseURL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", opts.BaseURL)
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.TenantHeader == "" {
+		opts.TenantHeader = DefaultTenantHeader
+	}
+	opts.Retry = opts.Retry.withDefaults()
+	return &Client{base: base, opts: opts}, nil
+}
+
+// request describes a call; body is encoded as JSON when not nil.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   any
+}
+
+// do sends req, retrying it if idempotent, and decodes a successful response
+// into out unless it is nil. Problem responses are returned as *Error.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send returns the response to req once it succeeded, leaving the body to
+// the caller.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("encode %s %s request: %w", req.method, req.path, err)
+		}
+	}
+	attempts := 1
+	switch req.method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		attempts = c.opts.Retry.MaxAttempts
+	}
+
+	for n := 1; ; n++ {
+		resp, err := c.attempt(ctx, req, body)
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		case resp.StatusCode < 400:
+			return resp, nil
+		default:
+			err = decodeError(resp)
+			_ = resp.Body.Close()
+			if !retryable(resp.StatusCode) {
+				return nil, err
+			}
+			retryAfter = err.(*Error).RetryAfter
+		}
+		if n >= attempts {
+			return nil, err
+		}
+
+		timer := time.NewTimer(c.opts.Retry.backoff(n, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	u := *c.base
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+	if c.opts.Tenant != "" {
+		httpReq.Header.Set(c.opts.TenantHeader, c.opts.Tenant)
+	}
+	if c.opts.Auth != nil {
+		if err := c.opts.Auth.Authenticate(httpReq); err != nil {
+			return nil, fmt.Errorf("authenticate: %w", err)
+		}
+	}
+	return c.opts.HTTPClient.Do(httpReq)
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header in seconds; HTTP dates are not
+// sent by the API.
+func parseRetryAfter(v string) time.Duration {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,404 @@
+package todoclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"challenge-backend-arancia/internal/application/feeds"
+	"challenge-backend-arancia/internal/application/tenants"
+	"challenge-backend-arancia/internal/application/todos"
+	"challenge-backend-arancia/internal/application/webhooks"
+	"challenge-backend-arancia/internal/auth"
+	"challenge-backend-arancia/internal/config"
+	"challenge-backend-arancia/internal/events"
+	"challenge-backend-arancia/internal/httpapi"
+	"challenge-backend-arancia/internal/storage/boltdb"
+	"challenge-backend-arancia/internal/tenancy"
+)
+
+type testServer struct {
+	*httptest.Server
+	verifier *auth.Verifier
+}
+
+// newServer serves the full API, with every request passed through wrap
+// first when it is not nil.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) testServer {
+	t.Helper()
+
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	repo, err := boltdb.NewTodoRepository(db)
+	if err != nil {
+		t.Fatalf("new repo: %v", err)
+	}
+	svc, err := todos.NewService(repo, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	hooks, err := boltdb.NewWebhookRepository(db)
+	if err != nil {
+		t.Fatalf("new webhook repo: %v", err)
+	}
+	deliveries, err := boltdb.NewDeliveryRepository(db)
+	if err != nil {
+		t.Fatalf("new delivery repo: %v", err)
+	}
+	webhookSvc, err := webhooks.NewService(hooks, deliveries, todos.UUIDGenerator{})
+	if err != nil {
+		t.Fatalf("new webhook service: %v", err)
+	}
+	feedTokens, err := boltdb.NewFeedTokenRepository(db)
+	if err != nil {
+		t.Fatalf("new feed token repo: %v", err)
+	}
+	feedSvc, err := feeds.NewService(feedTokens)
+	if err != nil {
+		t.Fatalf("new feed service: %v", err)
+	}
+	tenantRepo, err := boltdb.NewTenantRepository(db)
+	if err != nil {
+		t.Fatalf("new tenant repo: %v", err)
+	}
+	tenantSvc, err := tenants.NewService(tenantRepo, repo)
+	if err != nil {
+		t.Fatalf("new tenant service: %v", err)
+	}
+	verifier, err := auth.NewVerifier([]byte("secret"))
+	if err != nil {
+		t.Fatalf("new verifier: %v", err)
+	}
+
+	outbox, err := boltdb.NewOutboxRepository(db)
+	if err != nil {
+		t.Fatalf("new outbox: %v", err)
+	}
+	bus := events.NewBus()
+	relay, err := events.NewRelay(outbox, []events.Sink{{Name: "streams", Publisher: bus}}, events.DefaultRelayAttempts, nil)
+	if err != nil {
+		t.Fatalf("new relay: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx, 5*time.Millisecond)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	var h http.Handler = httpapi.NewRouter(httpapi.RouterOptions{
+		TodoService:     svc,
+		TenantResolvers: []httpapi.TenantResolver{httpapi.HeaderTenantResolver(DefaultTenantHeader), httpapi.ClaimTenantResolver()},
+		TokenVerifier:   verifier,
+		Events:          bus,
+		StreamHeartbeat: 50 * time.Millisecond,
+		WebhookService:  webhookSvc,
+		FeedService:     feedSvc,
+		TenantService:   tenantSvc,
+		AdminToken:      "admin",
+		Settings: func() []config.Setting {
+			return []config.Setting{{Name: "PORT", Value: "8080", Source: config.SourceDefault}}
+		},
+	})
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	t.Cleanup(bus.Close)
+	return testServer{Server: srv, verifier: verifier}
+}
+
+func newClient(t *testing.T, srv testServer, opts Options) *Client {
+	t.Helper()
+	opts.BaseURL = srv.URL
+	if opts.Auth == nil {
+		// The server needs a token bound to a tenant for the todo routes.
+		token, _ := srv.verifier.Sign(auth.Claims{Subject: "alice", Tenant: tenancy.DefaultID})
+		opts.Auth = BearerToken(token)
+	}
+	c, err := New(opts)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return c
+}
+
+func TestClient_Todos(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil), Options{})
+
+	td, err := c.CreateTodo(ctx, "buy milk")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if td.ID == "" || td.Title != "buy milk" || td.Completed {
+		t.Fatalf("unexpected todo: %+v", td)
+	}
+	td, err = c.UpdateTodo(ctx, td.ID, "buy oat milk", true)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !td.Completed || td.Title != "buy oat milk" {
+		t.Fatalf("unexpected todo: %+v", td)
+	}
+	list, err := c.ListTodos(ctx)
+	if err != nil || len(list) != 1 || list[0] != td {
+		t.Fatalf("expected [%+v], got %+v, %v", td, list, err)
+	}
+	if err := c.DeleteTodo(ctx, td.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	_, err = c.UpdateTodo(ctx, td.ID, "gone", false)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	_, err = c.CreateTodo(ctx, strings.Repeat("x", 1000))
+	var apiErr *Error
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &apiErr) {
+		t.Fatalf("expected a validation *Error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || len(apiErr.Errors) != 1 || apiErr.Errors[0].Field != "title" || apiErr.Instance == "" {
+		t.Fatalf("unexpected problem: %+v", apiErr)
+	}
+}
+
+func TestClient_IterateChanges(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil), Options{})
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := c.CreateTodo(ctx, title); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	it := c.IterateChanges(ctx, "", 2)
+	n := 0
+	for it.Next() {
+		if it.Change().Todo == nil {
+			t.Fatalf("unexpected change %+v", it.Change())
+		}
+		n++
+	}
+	if it.Err() != nil || n != 5 || it.Token() == "" {
+		t.Fatalf("expected 5 changes and a token, got %d, %q, %v", n, it.Token(), it.Err())
+	}
+
+	td, err := c.CreateTodo(ctx, "f")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	it = c.IterateChanges(ctx, it.Token(), 2)
+	var got []Change
+	for it.Next() {
+		got = append(got, it.Change())
+	}
+	if it.Err() != nil || len(got) != 1 || got[0].ID != td.ID {
+		t.Fatalf("expected only the new todo, got %+v, %v", got, it.Err())
+	}
+
+	it = c.IterateChanges(ctx, "not-a-token", 0)
+	if it.Next() || !errors.Is(it.Err(), ErrValidation) {
+		t.Fatalf("expected a malformed token to fail, got %v", it.Err())
+	}
+}
+
+func TestClient_Sync(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil), Options{})
+	res, err := c.Sync(ctx, "", []Mutation{{Op: OpCreate, ID: "11111111-1111-4111-8111-111111111111", Title: "offline"}})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(res.Results) != 1 || res.Results[0].Status != MutationApplied || res.Results[0].Todo == nil {
+		t.Fatalf("unexpected results: %+v", res.Results)
+	}
+	if len(res.Changes) != 1 || res.NextToken == "" {
+		t.Fatalf("expected the created todo in the delta, got %+v", res.ChangesPage)
+	}
+}
+
+func TestClient_RetriesIdempotentCalls(t *testing.T) {
+	t.Parallel()
+
+	var failures, calls atomic.Int32
+	failures.Store(2)
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			if failures.Add(-1) >= 0 {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{"type":"/problems/unavailable","title":"Service unavailable","status":503}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(t, srv, Options{Retry: RetryPolicy{BaseDelay: time.Millisecond}})
+	ctx := context.Background()
+
+	if _, err := c.ListTodos(ctx); err != nil {
+		t.Fatalf("expected the list to succeed on the third attempt, got %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+
+	calls.Store(0)
+	failures.Store(1)
+	if _, err := c.CreateTodo(ctx, "once"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the create to fail without retry, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single attempt, got %d", n)
+	}
+
+	calls.Store(0)
+	failures.Store(5)
+	c = newClient(t, srv, Options{Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+	if _, err := c.ListTodos(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the list to give up, got %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	srv := newServer(t, nil)
+	admin := newClient(t, srv, Options{Auth: AdminToken("admin")})
+	if _, err := admin.CreateTenant(ctx, "acme"); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	if _, err := admin.CreateTenant(ctx, "acme"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	token, _ := srv.verifier.Sign(auth.Claims{Subject: "alice", Tenant: "acme"})
+	alice := newClient(t, srv, Options{Auth: BearerToken(token)})
+	if _, err := alice.CreateTodo(ctx, "acme todo"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	exp, err := admin.ExportTenant(ctx, "acme")
+	if err != nil || len(exp.Todos) != 1 {
+		t.Fatalf("expected alice's todo in acme, got %+v, %v", exp, err)
+	}
+
+	other := newClient(t, srv, Options{Auth: BearerToken(token), Tenant: "globex"})
+	if _, err := other.ListTodos(ctx); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("expected ErrForbidden for another tenant, got %v", err)
+	}
+	forged := newClient(t, srv, Options{Auth: BearerToken("forged")})
+	if _, err := forged.ListTodos(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+	if _, err := newClient(t, srv, Options{}).ListTenants(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected admin endpoints to require the admin token, got %v", err)
+	}
+
+	cfg, err := admin.Config(ctx)
+	if err != nil || cfg["PORT"].Value != "8080" || cfg["PORT"].Source != "default" {
+		t.Fatalf("unexpected config: %+v, %v", cfg, err)
+	}
+
+	ft, err := alice.IssueFeedToken(ctx)
+	if err != nil || ft.Token == "" || !strings.HasPrefix(ft.ICSURL, "/todos.ics?token=") {
+		t.Fatalf("unexpected feed token: %+v, %v", ft, err)
+	}
+	if err := alice.RevokeFeedToken(ctx); err != nil {
+		t.Fatalf("revoke feed token: %v", err)
+	}
+
+	if err := admin.DeleteTenant(ctx, "acme"); err != nil {
+		t.Fatalf("delete tenant: %v", err)
+	}
+	if _, err := alice.ListTodos(ctx); !errors.Is(err, ErrUnknownTenant) {
+		t.Fatalf("expected ErrUnknownTenant, got %v", err)
+	}
+}
+
+func TestClient_Webhooks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	c := newClient(t, newServer(t, nil), Options{})
+	hook, err := c.CreateWebhook(ctx, "https://example.com/hook", []string{"todo.created"}, "")
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	if hook.ID == "" || hook.Secret == "" {
+		t.Fatalf("expected an ID and a generated secret, got %+v", hook)
+	}
+	got, err := c.GetWebhook(ctx, hook.ID)
+	if err != nil || got.URL != hook.URL || got.Secret != "" {
+		t.Fatalf("unexpected webhook: %+v, %v", got, err)
+	}
+	list, err := c.ListWebhooks(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one webhook, got %+v, %v", list, err)
+	}
+	if _, err := c.Deliveries(ctx, hook.ID, DeliveryDead); err != nil {
+		t.Fatalf("deliveries: %v", err)
+	}
+	if _, err := c.Deliveries(ctx, hook.ID, "lost"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected an unknown status to be rejected, got %v", err)
+	}
+	if _, err := c.Redeliver(ctx, hook.ID, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := c.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Fatalf("delete webhook: %v", err)
+	}
+	if _, err := c.GetWebhook(ctx, hook.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := newClient(t, newServer(t, nil), Options{})
+
+	stream, err := c.Events(ctx, EventsOptions{})
+	if err != nil {
+		t.Fatalf("events: %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	td, err := c.CreateTodo(ctx, "watched")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !stream.Next() {
+		t.Fatalf("expected an event, got %v", stream.Err())
+	}
+	ev := stream.Event()
+	if ev.Type != "todo.created" || ev.Todo.ID != td.ID || ev.ID == "" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
@@ -0,0 +1,141 @@
+package todoclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinels of the problem types of the API, matched by *Error with
+// errors.Is.
+var (
+	// ErrValidation is returned for invalid input; Error.Errors lists the
+	// offending fields.
+	ErrValidation     = errors.New("validation failed")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrQuotaExceeded  = errors.New("quota exceeded")
+	ErrNotFound       = errors.New("not found")
+	ErrUnknownTenant  = errors.New("unknown tenant")
+	ErrConflict       = errors.New("conflict")
+	ErrResyncRequired = errors.New("resync required")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrUnavailable    = errors.New("service unavailable")
+)
+
+// problemSentinels maps the problem types of the API, without their
+// prefix, to sentinels.
+var problemSentinels = map[string]error{
+	"validation-error": ErrValidation,
+	"tenant-required":  ErrValidation,
+	"invalid-tenant":   ErrValidation,
+	"unauthorized":     ErrUnauthorized,
+	"tenant-mismatch":  ErrForbidden,
+	"quota-exceeded":   ErrQuotaExceeded,
+	"not-found":        ErrNotFound,
+	"unknown-tenant":   ErrUnknownTenant,
+	"conflict":         ErrConflict,
+	"resync-required":  ErrResyncRequired,
+	"rate-limited":     ErrRateLimited,
+	"unavailable":      ErrUnavailable,
+}
+
+// problemSlug returns the name of a problem type of the API, whose URIs
+// are "urn:todo-api:problem:<name>", and were "/problems/<name>" in older
+// servers.
+func problemSlug(typ string) (string, bool) {
+	if slug, ok := strings.CutPrefix(typ, "urn:todo-api:problem:"); ok {
+		return slug, true
+	}
+	return strings.CutPrefix(typ, "/problems/")
+}
+
+// statusSentinels covers responses without a known problem type, such as
+// those of proxies.
+var statusSentinels = map[int]error{
+	http.StatusBadRequest:         ErrValidation,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrForbidden,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusConflict:           ErrConflict,
+	http.StatusGone:               ErrResyncRequired,
+	http.StatusTooManyRequests:    ErrRateLimited,
+	http.StatusServiceUnavailable: ErrUnavailable,
+}
+
+// Error is an unsuccessful response, decoded from its RFC 7807 problem
+// details when it has them.
+type Error struct {
+	StatusCode int
+	// Type identifies the problem, such as "urn:todo-api:problem:not-found".
+	Type   string
+	Title  string
+	Detail string
+	// Instance is the request ID, to quote in reports.
+	Instance string
+	Errors   []FieldError
+	// RetryAfter is when the call may succeed again, for 429 responses.
+	RetryAfter time.Duration
+}
+
+// FieldError is a rejected field of a request.
+type FieldError struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("todo API: %d", e.StatusCode)
+	if e.Title != "" {
+		msg += " " + e.Title
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for _, fe := range e.Errors {
+		msg += fmt.Sprintf("; %s: %s", fe.Field, fe.Detail)
+	}
+	return msg
+}
+
+// Is reports whether target is the sentinel of the problem.
+func (e *Error) Is(target error) bool {
+	if slug, ok := problemSlug(e.Type); ok {
+		if sentinel, ok := problemSentinels[slug]; ok {
+			return sentinel == target
+		}
+	}
+	return statusSentinels[e.StatusCode] == target
+}
+
+// decodeError reads the problem details of resp, if any.
+func decodeError(resp *http.Response) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Title:      http.StatusText(resp.StatusCode),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return e
+	}
+	var p struct {
+		Type     string       `json:"type"`
+		Title    string       `json:"title"`
+		Detail   string       `json:"detail"`
+		Instance string       `json:"instance"`
+		Errors   []FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&p); err != nil {
+		return e
+	}
+	e.Type, e.Detail, e.Instance, e.Errors = p.Type, p.Detail, p.Instance, p.Errors
+	if p.Title != "" {
+		e.Title = p.Title
+	}
+	return e
+}
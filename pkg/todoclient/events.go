@@ -0,0 +1,126 @@
+package todoclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Control events of the change stream, sent without an ID or todo.
+const (
+	// EventReset means events were missed, as the resumed ID is too old:
+	// refetch the todos, or sync.
+	EventReset = "reset"
+	// EventLagged means the stream is closed for reading too slowly.
+	EventLagged = "lagged"
+)
+
+// Event is a change to a todo, such as "todo.created", or a control event.
+type Event struct {
+	// ID resumes the stream after this event.
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Todo       Todo      `json:"todo"`
+}
+
+// EventsOptions selects the events of a change stream.
+type EventsOptions struct {
+	// LastEventID resumes after the event with this ID.
+	LastEventID string
+	// Mine only streams changes to the caller's own todos.
+	Mine bool
+}
+
+// Events opens the server-sent event stream of the tenant's todo changes.
+// It ends when ctx is done, the server shuts down or the stream is closed;
+// reconnect with the ID of the last event to resume.
+func (c *Client) Events(ctx context.Context, opts EventsOptions) (*EventStream, error) {
+	q := url.Values{}
+	if opts.Mine {
+		q.Set("mine", "true")
+	}
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if opts.LastEventID != "" {
+		header.Set("Last-Event-ID", opts.LastEventID)
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/v1/todos/events", query: q, header: header})
+	if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	return &EventStream{body: resp.Body, sc: sc}, nil
+}
+
+// EventStream reads the events of a change stream; see Client.Events.
+type EventStream struct {
+	body io.ReadCloser
+	sc   *bufio.Scanner
+	cur  Event
+	err  error
+}
+
+// Next waits for the next event. It returns false once the stream ended or
+// failed.
+func (s *EventStream) Next() bool {
+	var typ, data string
+	for s.err == nil && s.sc.Scan() {
+		line := s.sc.Text()
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			if line != "" {
+				// A comment, such as the heartbeat.
+				continue
+			}
+			if data == "" {
+				typ = ""
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(data), &ev); err != nil {
+				s.err = fmt.Errorf("decode event: %w", err)
+				return false
+			}
+			if ev.Type == "" {
+				ev.Type = typ
+			}
+			s.cur = ev
+			return true
+		case "event":
+			typ = value
+		case "data":
+			if data != "" {
+				data += "\n"
+			}
+			data += value
+		}
+	}
+	if s.err == nil {
+		s.err = s.sc.Err()
+	}
+	return false
+}
+
+// Event returns the current event.
+func (s *EventStream) Event() Event {
+	return s.cur
+}
+
+// Err returns the error that ended the stream, if it did not end normally.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close ends the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,241 @@
+package todoclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Todo is a todo as returned by the API.
+type Todo struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+	// Version increases with every change; sync mutations are based on it.
+	Version uint64 `json:"version"`
+	// ParentID is the todo this one is a subtask of, empty for top-level
+	// todos.
+	ParentID     string `json:"parent_id,omitempty"`
+	AutoComplete bool   `json:"auto_complete,omitempty"`
+	// Progress is the percentage of subtasks completed, nil for todos
+	// without subtasks and in change feeds.
+	Progress *int `json:"progress,omitempty"`
+}
+
+// Change is an entry of the change feed: the current state of a todo, or
+// its deletion.
+type Change struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+	// Todo is nil for deletions.
+	Todo *Todo `json:"todo,omitempty"`
+}
+
+// ChangesPage is a page of the change feed.
+type ChangesPage struct {
+	Changes []Change `json:"changes"`
+	// NextToken is the since of the next page, or of the next sync once
+	// HasMore is false.
+	NextToken string `json:"next_token"`
+	HasMore   bool   `json:"has_more"`
+}
+
+func todoPath(id string) string {
+	return "/v1/todos/" + url.PathEscape(id)
+}
+
+// ListTodos returns every todo of the tenant.
+func (c *Client) ListTodos(ctx context.Context) ([]Todo, error) {
+	var out []Todo
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/todos"}, &out)
+	return out, err
+}
+
+// CreateTodo creates an open todo.
+func (c *Client) CreateTodo(ctx context.Context, title string) (Todo, error) {
+	var out Todo
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/todos",
+		body:   map[string]string{"title": title},
+	}, &out)
+	return out, err
+}
+
+// UpdateTodo replaces the title and completion of a todo.
+func (c *Client) UpdateTodo(ctx context.Context, id, title string, completed bool) (Todo, error) {
+	var out Todo
+	err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   todoPath(id),
+		body:   map[string]any{"title": title, "completed": completed},
+	}, &out)
+	return out, err
+}
+
+// MoveTodo makes the todo id the last subtask of parentID. Moving a todo
+// under the parent it already has leaves it in place.
+func (c *Client) MoveTodo(ctx context.Context, id, parentID string) (Todo, error) {
+	var out Todo
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   todoPath(parentID) + "/children",
+		body:   map[string]string{"id": id},
+	}, &out)
+	return out, err
+}
+
+// DeleteTodo deletes a todo. A retried call may report ErrNotFound if an
+// earlier attempt deleted it.
+func (c *Client) DeleteTodo(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: todoPath(id)}, nil)
+}
+
+// Changes returns a page of the changes since the sync token since, or of
+// every todo if since is empty. A zero limit uses the server default; an
+// expired token fails with ErrResyncRequired.
+func (c *Client) Changes(ctx context.Context, since string, limit int) (ChangesPage, error) {
+	q := url.Values{}
+	if since != "" {
+		q.Set("since", since)
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out ChangesPage
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/todos/changes", query: q}, &out)
+	return out, err
+}
+
+// IterateChanges walks the change feed since the sync token since, fetching
+// pages of up to pageSize changes (zero for the server default) as needed:
+//
+//	it := c.IterateChanges(ctx, token, 0)
+//	for it.Next() {
+//		apply(it.Change())
+//	}
+//	if err := it.Err(); err != nil { ... }
+//	token = it.Token()
+func (c *Client) IterateChanges(ctx context.Context, since string, pageSize int) *ChangeIterator {
+	return &ChangeIterator{c: c, ctx: ctx, token: since, pageSize: pageSize, more: true}
+}
+
+// ChangeIterator is a cursor over the change feed; see IterateChanges.
+type ChangeIterator struct {
+	c        *Client
+	ctx      context.Context
+	pageSize int
+
+	page  []Change
+	cur   Change
+	token string
+	more  bool
+	err   error
+}
+
+// Next advances to the next change, fetching the next page if needed. It
+// returns false at the end of the feed or on error.
+func (it *ChangeIterator) Next() bool {
+	for len(it.page) == 0 {
+		if !it.more || it.err != nil {
+			return false
+		}
+		page, err := it.c.Changes(it.ctx, it.token, it.pageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.token, it.more = page.Changes, page.NextToken, page.HasMore
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Change returns the current change.
+func (it *ChangeIterator) Change() Change {
+	return it.cur
+}
+
+// Token returns the sync token of the last page fetched: once Next returned
+// false without error, the since of the next sync.
+func (it *ChangeIterator) Token() string {
+	return it.token
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ChangeIterator) Err() error {
+	return it.err
+}
+
+// MutationOp is the kind of a sync mutation.
+type MutationOp string
+
+const (
+	OpCreate MutationOp = "create"
+	OpUpdate MutationOp = "update"
+	OpDelete MutationOp = "delete"
+)
+
+// Mutation is a change made offline, applied by Sync.
+type Mutation struct {
+	Op MutationOp `json:"op"`
+	// ID is chosen by the client for creations.
+	ID string `json:"id"`
+	// BaseVersion is the version of the todo the change was made to.
+	BaseVersion uint64 `json:"base_version"`
+	Title       string `json:"title"`
+	Completed   bool   `json:"completed"`
+}
+
+// MutationStatus is the outcome of a mutation.
+type MutationStatus string
+
+const (
+	MutationApplied  MutationStatus = "applied"
+	MutationMerged   MutationStatus = "merged"
+	MutationConflict MutationStatus = "conflict"
+	MutationRejected MutationStatus = "rejected"
+)
+
+// MutationResult is the outcome of a mutation, in the order sent.
+type MutationResult struct {
+	ID     string         `json:"id"`
+	Status MutationStatus `json:"status"`
+	// Todo is the todo after the mutation, if it still exists.
+	Todo     *Todo     `json:"todo,omitempty"`
+	Conflict *Conflict `json:"conflict,omitempty"`
+	// Errors explains rejections.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// Conflict describes the fields a mutation and the server both changed.
+type Conflict struct {
+	Fields []string `json:"fields"`
+	Base   *Todo    `json:"base,omitempty"`
+	Client *Todo    `json:"client,omitempty"`
+	Server *Todo    `json:"server,omitempty"`
+}
+
+// SyncResult is the outcome of Sync and the server changes since the token
+// it was given, as a first page of the change feed.
+type SyncResult struct {
+	Results []MutationResult `json:"results"`
+	ChangesPage
+}
+
+// Sync applies mutations made offline and returns the changes since the
+// sync token since. It is not retried: resend failed batches with their
+// base versions unchanged.
+func (c *Client) Sync(ctx context.Context, since string, mutations []Mutation) (SyncResult, error) {
+	if mutations == nil {
+		mutations = []Mutation{}
+	}
+	var out SyncResult
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/sync",
+		body:   map[string]any{"since": since, "mutations": mutations},
+	}, &out)
+	return out, err
+}
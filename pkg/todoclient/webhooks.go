@@ -0,0 +1,125 @@
+package todoclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Webhook is a subscription to todo events.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	// Secret signs the deliveries. It is only returned by CreateWebhook.
+	Secret string `json:"secret,omitempty"`
+}
+
+// DeliveryStatus is the state of a webhook delivery.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+// Delivery is an event sent, or to be sent, to a webhook.
+type Delivery struct {
+	ID        string            `json:"id"`
+	WebhookID string            `json:"webhook_id"`
+	EventType string            `json:"event_type"`
+	Status    DeliveryStatus    `json:"status"`
+	Attempts  []DeliveryAttempt `json:"attempts"`
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// DeliveryAttempt is a try of a delivery.
+type DeliveryAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+func webhookPath(id string) string {
+	return "/v1/webhooks/" + url.PathEscape(id)
+}
+
+// ListWebhooks returns the webhooks of the tenant.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var out []Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: "/v1/webhooks"}, &out)
+	return out, err
+}
+
+// CreateWebhook subscribes url to the given event types, such as
+// "todo.created". An empty secret lets the server generate one, returned in
+// Webhook.Secret.
+func (c *Client) CreateWebhook(ctx context.Context, url string, events []string, secret string) (Webhook, error) {
+	var out Webhook
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/webhooks",
+		body:   map[string]any{"url": url, "events": events, "secret": secret},
+	}, &out)
+	return out, err
+}
+
+// GetWebhook returns a webhook.
+func (c *Client) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	var out Webhook
+	err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(id)}, &out)
+	return out, err
+}
+
+// DeleteWebhook unsubscribes a webhook.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: webhookPath(id)}, nil)
+}
+
+// Deliveries returns the deliveries of a webhook, only those in status
+// unless it is empty.
+func (c *Client) Deliveries(ctx context.Context, webhookID string, status DeliveryStatus) ([]Delivery, error) {
+	q := url.Values{}
+	if status != "" {
+		q.Set("status", string(status))
+	}
+	var out []Delivery
+	err := c.do(ctx, request{method: http.MethodGet, path: webhookPath(webhookID) + "/deliveries", query: q}, &out)
+	return out, err
+}
+
+// Redeliver queues a delivery to be sent again.
+func (c *Client) Redeliver(ctx context.Context, webhookID, deliveryID string) (Delivery, error) {
+	var out Delivery
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   webhookPath(webhookID) + "/deliveries/" + url.PathEscape(deliveryID) + "/redeliver",
+	}, &out)
+	return out, err
+}
+
+// FeedToken authenticates the iCalendar and Atom feeds of its user.
+type FeedToken struct {
+	Token string `json:"token"`
+	// ICSURL and AtomURL are the feed paths, relative to the base URL.
+	ICSURL  string `json:"ics_url"`
+	AtomURL string `json:"atom_url"`
+}
+
+// IssueFeedToken issues a feed token, replacing the caller's previous one.
+func (c *Client) IssueFeedToken(ctx context.Context) (FeedToken, error) {
+	var out FeedToken
+	err := c.do(ctx, request{method: http.MethodPost, path: "/v1/feeds/token"}, &out)
+	return out, err
+}
+
+// RevokeFeedToken revokes the caller's feed token.
+func (c *Client) RevokeFeedToken(ctx context.Context) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/v1/feeds/token"}, nil)
+}
@@ -15,7 +15,7 @@ curl -sS "$BASE_URL/readyz" | jq .
 
 echo
 echo "== create todo =="
-CREATE_RES="$(curl -sS -X POST "$BASE_URL/todos" -H 'Content-Type: application/json' -d '{"title":"buy milk"}')"
+CREATE_RES="$(curl -sS -X POST "$BASE_URL/v1/todos" -H 'Content-Type: application/json' -d '{"title":"buy milk"}')"
 echo "$CREATE_RES" | jq .
 
 ID="$(echo "$CREATE_RES" | jq -r '.id')"
@@ -23,17 +23,17 @@ echo "Created id=$ID"
 
 echo
 echo "== list todos =="
-curl -sS "$BASE_URL/todos" | jq .
+curl -sS "$BASE_URL/v1/todos" | jq .
 
 echo
 echo "== update todo =="
-curl -sS -X PUT "$BASE_URL/todos/$ID" -H 'Content-Type: application/json' -d '{"title":"buy milk (done)","completed":true}' | jq .
+curl -sS -X PUT "$BASE_URL/v1/todos/$ID" -H 'Content-Type: application/json' -d '{"title":"buy milk (done)","completed":true}' | jq .
 
 echo
 echo "== delete todo =="
-curl -sS -X DELETE "$BASE_URL/todos/$ID" -i | head -n 1
+curl -sS -X DELETE "$BASE_URL/v1/todos/$ID" -i | head -n 1
 
 echo
 echo "== list todos (empty) =="
-curl -sS "$BASE_URL/todos" | jq .
+curl -sS "$BASE_URL/v1/todos" | jq .
 